@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"go-authentication/config"
 	"go-authentication/db"
 	"go-authentication/handlers"
@@ -9,6 +10,7 @@ import (
 	"go-authentication/internal/routes"
 	"go-authentication/internal/services"
 	"go-authentication/internal/usecase"
+	"go-authentication/pkg"
 	"log"
 
 	"github.com/gin-gonic/gin"
@@ -28,6 +30,17 @@ func main() {
 	// Run database migrations
 	db.Migrate()
 
+	// Load the JWT signing keys
+	keySet, err := pkg.LoadKeySet(cfg.JWTKeysDir, cfg.JWTActiveKeyID, cfg.JWTSecret)
+	if err != nil {
+		log.Fatalf("Failed to load JWT signing keys: %v", err)
+	}
+	log.Printf("Signing tokens with key %q (%s)", keySet.Active().ID, keySet.Active().Method.Alg())
+	tokenService, err := pkg.NewTokenService(cfg, keySet)
+	if err != nil {
+		log.Fatalf("Invalid JWT configuration: %v", err)
+	}
+
 	// Initialize NATS service
 	natsService, err := services.NewNatsService()
 	if err != nil {
@@ -38,25 +51,136 @@ func main() {
 	// Initialize repositories
 	userRepository := repository.NewUserRepository()
 	chatRepository := repository.NewChatRepository()
+	refreshTokenRepository := repository.NewRefreshTokenRepository()
+	passwordResetRepository := repository.NewPasswordResetRepository()
+	mfaRepository := repository.NewMFARepository()
+	loginThrottleRepository := repository.NewLoginThrottleRepository()
+	roleRepository := repository.NewRoleRepository()
+	apiKeyRepository := repository.NewAPIKeyRepository()
+	sessionRepository := repository.NewSessionRepository()
+	identityRepository := repository.NewUserIdentityRepository()
+	oauthRepository := repository.NewOAuthRepository()
+	passkeyRepository := repository.NewPasskeyRepository()
+	authEventRepository := repository.NewAuthEventRepository()
+	userSearchRepository := repository.NewUserSearchRepository()
+	blockRepository := repository.NewBlockRepository()
+	conversationMuteRepository := repository.NewConversationMuteRepository()
+	receiptRepository := repository.NewReceiptRepository()
+
+	// Initialize the token revocation store
+	var revocationStore repository.RevocationStore
+	switch cfg.RevocationStore {
+	case "memory":
+		revocationStore = repository.NewMemoryRevocationStore()
+	default:
+		revocationStore = repository.NewPostgresRevocationStore()
+	}
+
+	// Initialize the presence store; without JetStream, presence is only known to this instance
+	var presenceStore repository.PresenceStore
+	if cfg.PresenceStore == "nats" {
+		live, err := natsService.KeyValue("presence_live", repository.PresenceRecordMaxAge)
+		if err != nil {
+			log.Printf("Falling back to in-memory presence: %v", err)
+		} else if seen, err := natsService.KeyValue("presence_seen", 0); err != nil {
+			log.Printf("Falling back to in-memory presence: %v", err)
+		} else {
+			presenceStore = repository.NewNATSPresenceStore(live, seen)
+		}
+	}
+	if presenceStore == nil {
+		presenceStore = repository.NewMemoryPresenceStore()
+	}
+
+	// Initialize the mailer used for verification emails
+	mailer, err := services.NewMailer(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize mailer: %v", err)
+	}
+
+	// Initialize the login lockout policy
+	lockoutPolicy, err := usecase.NewLockoutPolicy(cfg)
+	if err != nil {
+		log.Fatalf("Invalid login lockout configuration: %v", err)
+	}
+
+	// Initialize the password policy
+	passwordPolicy, err := pkg.NewPasswordPolicy(cfg)
+	if err != nil {
+		log.Fatalf("Invalid password policy configuration: %v", err)
+	}
+
+	// Initialize the password hasher
+	passwordHasher, err := pkg.NewPasswordHasher(cfg)
+	if err != nil {
+		log.Fatalf("Invalid password hashing configuration: %v", err)
+	}
+
+	// Initialize the OIDC provider used for single sign-on, if one is configured
+	var oidcProvider *pkg.OIDCProvider
+	if cfg.OIDCIssuer != "" {
+		oidcProvider, err = pkg.NewOIDCProvider(cfg)
+		if err != nil {
+			log.Fatalf("Invalid OIDC configuration: %v", err)
+		}
+	}
+
+	// Initialize the WebAuthn relying party used for passkeys
+	relyingParty, err := pkg.NewWebAuthnRelyingParty(cfg)
+	if err != nil {
+		log.Fatalf("Invalid WebAuthn configuration: %v", err)
+	}
 
 	// Initialize usecases
-	authUsecase := usecase.NewAuthorizaationcase(userRepository)
-	chatUsecase := usecase.NewChatUsecase(chatRepository, userRepository, natsService)
+	auditUsecase := usecase.NewAuditUsecase(authEventRepository)
+	verificationUsecase := usecase.NewVerificationUsecase(userRepository, tokenService, mailer, cfg.AppBaseURL, auditUsecase)
+	lockoutUsecase := usecase.NewLockoutUsecase(loginThrottleRepository, userRepository, lockoutPolicy, auditUsecase)
+	mfaUsecase := usecase.NewMFAUsecase(mfaRepository, userRepository, lockoutUsecase, passwordHasher, cfg.MFAIssuer, auditUsecase)
+	roleUsecase := usecase.NewRoleUsecase(roleRepository, userRepository, revocationStore, cfg.AdminEmails, auditUsecase)
+	sessionUsecase := usecase.NewSessionUsecase(sessionRepository, refreshTokenRepository, revocationStore, natsService, tokenService, auditUsecase)
+	apiKeyUsecase := usecase.NewAPIKeyUsecase(apiKeyRepository, userRepository, roleUsecase, auditUsecase)
+	passkeyUsecase := usecase.NewPasskeyUsecase(passkeyRepository, userRepository, relyingParty, auditUsecase)
+	authUsecase := usecase.NewAuthorizaationcase(userRepository, refreshTokenRepository, revocationStore, tokenService, verificationUsecase, mfaUsecase, lockoutUsecase, passwordPolicy, passwordHasher, roleUsecase, apiKeyUsecase, sessionUsecase, passkeyUsecase, auditUsecase)
+	passwordUsecase := usecase.NewPasswordUsecase(userRepository, passwordResetRepository, sessionUsecase, mailer, passwordPolicy, passwordHasher, lockoutUsecase, cfg.AppBaseURL, auditUsecase)
+	profileUsecase := usecase.NewProfileUsecase(userRepository, userSearchRepository, tokenService, revocationStore, mailer, passwordHasher, lockoutUsecase, cfg.AppBaseURL, auditUsecase)
+	oidcUsecase := usecase.NewOIDCUsecase(oidcProvider, identityRepository, userRepository, authUsecase)
+	oauthUsecase := usecase.NewOAuthUsecase(oauthRepository, userRepository, revocationStore, tokenService, auditUsecase)
+	blockUsecase := usecase.NewBlockUsecase(blockRepository, userRepository)
+	chatUsecase := usecase.NewChatUsecase(chatRepository, userRepository, conversationMuteRepository, receiptRepository, blockUsecase, natsService)
+	groupUsecase := usecase.NewGroupUsecase(chatRepository, userRepository, blockUsecase)
+	typingUsecase := usecase.NewTypingUsecase(chatRepository, blockUsecase, natsService)
+	presenceUsecase := usecase.NewPresenceUsecase(presenceStore, chatRepository, userRepository, blockUsecase, natsService)
+
+	// Report users whose connections stopped sending heartbeats
+	go presenceUsecase.SweepEvery(context.Background(), presenceUsecase.HeartbeatInterval())
 
 	// Initialize handlers
 	authHandler := delivery.NewAuthHandler(authUsecase)
+	passwordHandler := delivery.NewPasswordHandler(passwordUsecase)
+	profileHandler := delivery.NewProfileHandler(profileUsecase)
+	mfaHandler := delivery.NewMFAHandler(mfaUsecase)
+	passkeyHandler := delivery.NewPasskeyHandler(passkeyUsecase)
+	adminHandler := delivery.NewAdminHandler(lockoutUsecase, roleUsecase)
+	apiKeyHandler := delivery.NewAPIKeyHandler(apiKeyUsecase)
+	sessionHandler := delivery.NewSessionHandler(sessionUsecase)
+	oidcHandler := delivery.NewOIDCHandler(oidcUsecase)
+	auditHandler := delivery.NewAuditHandler(auditUsecase)
+	oauthHandler := delivery.NewOAuthHandler(oauthUsecase)
+	blockHandler := delivery.NewBlockHandler(blockUsecase)
 	chatHandler := delivery.NewChatHandler(chatUsecase)
-	wsHandler := delivery.NewWebSocketHandler(chatUsecase)
+	groupHandler := delivery.NewGroupHandler(groupUsecase)
+	presenceHandler := delivery.NewPresenceHandler(presenceUsecase)
+	wsHandler := delivery.NewWebSocketHandler(chatUsecase, typingUsecase, presenceUsecase, sessionUsecase)
 	messageHandler := handlers.NewMessageHandler(natsService, chatUsecase)
 
 	// Initialize and configure router
 	router := gin.Default()
 
 	// Apply middlewares (if needed, e.g., CORS, logging, recovery)
-	// router.Use(someMiddleware())
+	router.Use(delivery.ClientContext())
 
 	// Register routes
-	routes.SetupRoutes(router, authHandler, chatHandler, wsHandler, messageHandler)
+	routes.SetupRoutes(router, authHandler, passwordHandler, profileHandler, mfaHandler, passkeyHandler, adminHandler, apiKeyHandler, sessionHandler, oidcHandler, oauthHandler, auditHandler, blockHandler, presenceHandler, chatHandler, groupHandler, wsHandler, messageHandler)
 
 	// Start the server
 	port := cfg.Port
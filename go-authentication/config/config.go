@@ -17,6 +17,68 @@ type Config struct {
 	DBSSLMode     string
 	JWTSecret     string
 	JWTExpiration string
+	// JWTIssuer and JWTAudience scope issued tokens to this service
+	JWTIssuer   string
+	JWTAudience string
+	// JWTClockSkew is the leeway in seconds applied to exp, nbf and iat
+	JWTClockSkew           string
+	RefreshTokenExpiration string
+	// JWTKeysDir holds <kid>.pem signing keys; when empty tokens are signed with JWTSecret
+	JWTKeysDir     string
+	JWTActiveKeyID string
+	// AppBaseURL is the public URL used to build links in emails
+	AppBaseURL string
+	// MailDriver selects how emails are delivered: "smtp" or "log"
+	MailDriver   string
+	MailFrom     string
+	MailLogFile  string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	// RevocationStore selects where revoked tokens are tracked: "postgres" or "memory"
+	RevocationStore string
+	// PresenceStore selects where online presence is tracked: "nats" (JetStream key-value) or "memory"
+	PresenceStore string
+	// MFAIssuer is the name authenticator apps show next to TOTP codes
+	MFAIssuer string
+	// Login lockout thresholds; failures are counted per account and per client IP
+	LoginMaxAccountFailures   string
+	LoginMaxIPFailures        string
+	LoginLockoutSeconds       string
+	LoginMaxLockoutSeconds    string
+	LoginFailureWindowSeconds string
+	// AdminEmails is a comma separated list of accounts granted the admin role when they sign up
+	AdminEmails string
+	// Password policy; PASSWORD_MAX_LENGTH is in bytes and cannot exceed bcrypt's 72
+	PasswordMinLength     string
+	PasswordMaxLength     string
+	PasswordRequireUpper  string
+	PasswordRequireLower  string
+	PasswordRequireDigit  string
+	PasswordRequireSymbol string
+	// PasswordBreachedListFile holds SHA-1 hashes of breached passwords, one per line
+	PasswordBreachedListFile string
+	// PasswordHashAlgorithm is "argon2id" or "bcrypt"; stale hashes are upgraded on login
+	PasswordHashAlgorithm string
+	BcryptCost            string
+	Argon2MemoryKiB       string
+	Argon2Iterations      string
+	Argon2Parallelism     string
+	// OIDC login; disabled when OIDCIssuer is empty
+	OIDCProviderName string
+	OIDCIssuer       string
+	OIDCClientID     string
+	OIDCClientSecret string
+	// OIDCRedirectURL defaults to APP_BASE_URL + /oidc/callback
+	OIDCRedirectURL string
+	// OIDCScopes is a space separated list of scopes to request
+	OIDCScopes string
+	// WebAuthn relying party; the ID and origins default to the host and origin of AppBaseURL
+	WebAuthnRPID   string
+	WebAuthnRPName string
+	// WebAuthnOrigins is a comma separated list of origins passkeys may be used from
+	WebAuthnOrigins string
 }
 
 func LoadEnv() {
@@ -40,15 +102,59 @@ func LoadConfig() *Config {
 	}
 
 	return &Config{
-		Port:          os.Getenv("PORT"),
-		DBHost:        os.Getenv("DB_HOST"),
-		DBPort:        os.Getenv("DB_PORT"),
-		DBUser:        os.Getenv("DB_USER"),
-		DBPassword:    os.Getenv("DB_PASSWORD"),
-		DBName:        os.Getenv("DB_NAME"),
-		DBSSLMode:     os.Getenv("DB_SSLMODE"),
-		JWTSecret:     os.Getenv("JWT_SECRET"),
-		JWTExpiration: os.Getenv("JWT_EXPIRATION_HOURS"),
+		Port:                      os.Getenv("PORT"),
+		DBHost:                    os.Getenv("DB_HOST"),
+		DBPort:                    os.Getenv("DB_PORT"),
+		DBUser:                    os.Getenv("DB_USER"),
+		DBPassword:                os.Getenv("DB_PASSWORD"),
+		DBName:                    os.Getenv("DB_NAME"),
+		DBSSLMode:                 os.Getenv("DB_SSLMODE"),
+		JWTSecret:                 os.Getenv("JWT_SECRET"),
+		JWTExpiration:             os.Getenv("JWT_EXPIRATION_HOURS"),
+		JWTIssuer:                 Getenv("JWT_ISSUER", "go-authentication"),
+		JWTAudience:               Getenv("JWT_AUDIENCE", "go-authentication"),
+		JWTClockSkew:              os.Getenv("JWT_CLOCK_SKEW_SECONDS"),
+		RefreshTokenExpiration:    os.Getenv("REFRESH_TOKEN_EXPIRATION_HOURS"),
+		JWTKeysDir:                os.Getenv("JWT_KEYS_DIR"),
+		JWTActiveKeyID:            os.Getenv("JWT_ACTIVE_KEY_ID"),
+		AppBaseURL:                Getenv("APP_BASE_URL", "http://localhost:8081"),
+		MailDriver:                Getenv("MAIL_DRIVER", "log"),
+		MailFrom:                  os.Getenv("MAIL_FROM"),
+		MailLogFile:               os.Getenv("MAIL_LOG_FILE"),
+		SMTPHost:                  os.Getenv("SMTP_HOST"),
+		SMTPPort:                  os.Getenv("SMTP_PORT"),
+		SMTPUsername:              os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:              os.Getenv("SMTP_PASSWORD"),
+		RevocationStore:           Getenv("REVOCATION_STORE", "postgres"),
+		PresenceStore:             Getenv("PRESENCE_STORE", "nats"),
+		MFAIssuer:                 Getenv("MFA_ISSUER", "go-authentication"),
+		LoginMaxAccountFailures:   os.Getenv("LOGIN_MAX_ACCOUNT_FAILURES"),
+		LoginMaxIPFailures:        os.Getenv("LOGIN_MAX_IP_FAILURES"),
+		LoginLockoutSeconds:       os.Getenv("LOGIN_LOCKOUT_SECONDS"),
+		LoginMaxLockoutSeconds:    os.Getenv("LOGIN_MAX_LOCKOUT_SECONDS"),
+		LoginFailureWindowSeconds: os.Getenv("LOGIN_FAILURE_WINDOW_SECONDS"),
+		AdminEmails:               os.Getenv("ADMIN_EMAILS"),
+		PasswordMinLength:         os.Getenv("PASSWORD_MIN_LENGTH"),
+		PasswordMaxLength:         os.Getenv("PASSWORD_MAX_LENGTH"),
+		PasswordRequireUpper:      os.Getenv("PASSWORD_REQUIRE_UPPER"),
+		PasswordRequireLower:      os.Getenv("PASSWORD_REQUIRE_LOWER"),
+		PasswordRequireDigit:      os.Getenv("PASSWORD_REQUIRE_DIGIT"),
+		PasswordRequireSymbol:     os.Getenv("PASSWORD_REQUIRE_SYMBOL"),
+		PasswordBreachedListFile:  os.Getenv("PASSWORD_BREACHED_LIST_FILE"),
+		PasswordHashAlgorithm:     Getenv("PASSWORD_HASH_ALGORITHM", "argon2id"),
+		BcryptCost:                os.Getenv("BCRYPT_COST"),
+		Argon2MemoryKiB:           os.Getenv("ARGON2_MEMORY_KIB"),
+		Argon2Iterations:          os.Getenv("ARGON2_ITERATIONS"),
+		Argon2Parallelism:         os.Getenv("ARGON2_PARALLELISM"),
+		OIDCProviderName:          Getenv("OIDC_PROVIDER_NAME", "oidc"),
+		OIDCIssuer:                os.Getenv("OIDC_ISSUER"),
+		OIDCClientID:              os.Getenv("OIDC_CLIENT_ID"),
+		OIDCClientSecret:          os.Getenv("OIDC_CLIENT_SECRET"),
+		OIDCRedirectURL:           os.Getenv("OIDC_REDIRECT_URL"),
+		OIDCScopes:                Getenv("OIDC_SCOPES", "openid email profile"),
+		WebAuthnRPID:              os.Getenv("WEBAUTHN_RP_ID"),
+		WebAuthnRPName:            Getenv("WEBAUTHN_RP_NAME", "go-authentication"),
+		WebAuthnOrigins:           os.Getenv("WEBAUTHN_ORIGINS"),
 	}
 
 }
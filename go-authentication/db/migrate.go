@@ -9,7 +9,35 @@ import (
 func Migrate() {
 	// Drop existing tables if they exist (this will cascade drop all constraints)
 	dropTables := `
+	DROP TABLE IF EXISTS oauth_refresh_tokens CASCADE;
+	DROP TABLE IF EXISTS oauth_consents CASCADE;
+	DROP TABLE IF EXISTS oauth_authorization_codes CASCADE;
+	DROP TABLE IF EXISTS oauth_clients CASCADE;
+	DROP TABLE IF EXISTS auth_events CASCADE;
+	DROP TABLE IF EXISTS conversation_mutes CASCADE;
+	DROP TABLE IF EXISTS user_blocks CASCADE;
+	DROP TABLE IF EXISTS webauthn_challenges CASCADE;
+	DROP TABLE IF EXISTS passkeys CASCADE;
+	DROP TABLE IF EXISTS oidc_login_states CASCADE;
+	DROP TABLE IF EXISTS user_identities CASCADE;
+	DROP TABLE IF EXISTS api_keys CASCADE;
+	DROP TABLE IF EXISTS user_roles CASCADE;
+	DROP TABLE IF EXISTS role_permissions CASCADE;
+	DROP TABLE IF EXISTS permissions CASCADE;
+	DROP TABLE IF EXISTS roles CASCADE;
+	DROP TABLE IF EXISTS lockout_events CASCADE;
+	DROP TABLE IF EXISTS login_throttles CASCADE;
+	DROP TABLE IF EXISTS mfa_recovery_codes CASCADE;
+	DROP TABLE IF EXISTS user_mfa CASCADE;
+	DROP TABLE IF EXISTS password_reset_tokens CASCADE;
+	DROP TABLE IF EXISTS revoked_tokens CASCADE;
+	DROP TABLE IF EXISTS revoked_sessions CASCADE;
+	DROP TABLE IF EXISTS sessions CASCADE;
+	DROP TABLE IF EXISTS user_token_revocations CASCADE;
+	DROP TABLE IF EXISTS refresh_tokens CASCADE;
+	DROP TABLE IF EXISTS message_receipts CASCADE;
 	DROP TABLE IF EXISTS messages CASCADE;
+	DROP TABLE IF EXISTS conversation_members CASCADE;
 	DROP TABLE IF EXISTS conversations CASCADE;
 	DROP TABLE IF EXISTS users CASCADE;
 	`
@@ -18,37 +46,452 @@ func Migrate() {
 	CREATE TABLE IF NOT EXISTS users (
 		id SERIAL PRIMARY KEY,
 		name VARCHAR(100) NOT NULL,
-		email VARCHAR(100) UNIQUE NOT NULL,
+		email VARCHAR(100) NOT NULL,
 		password TEXT NOT NULL,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		email_verified_at TIMESTAMP,
+		display_name VARCHAR(100) NOT NULL DEFAULT '',
+		bio VARCHAR(500) NOT NULL DEFAULT '',
+		status_text VARCHAR(140) NOT NULL DEFAULT '',
+		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 	);
+	-- Addresses differing only in case belong to the same mailbox
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_lower ON users (lower(email));
 	`
 
+	// A direct conversation is keyed by "<lower user id>:<higher user id>", so each pair has at most one
+	conversationsTable := `
+	CREATE TABLE IF NOT EXISTS conversations (
+		id SERIAL PRIMARY KEY,
+		type VARCHAR(10) NOT NULL DEFAULT 'direct' CHECK (type IN ('direct', 'group')),
+		name VARCHAR(100) NOT NULL DEFAULT '',
+		direct_key VARCHAR(32) UNIQUE,
+		last_message TEXT DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		CONSTRAINT conversations_direct_key CHECK ((type = 'direct') = (direct_key IS NOT NULL))
+	);
+	CREATE INDEX IF NOT EXISTS idx_conversations_updated ON conversations(updated_at DESC, id DESC);
+	`
+
+	// Messages after last_read_message_id from other members are unread
+	conversationMembersTable := `
+	CREATE TABLE IF NOT EXISTS conversation_members (
+		conversation_id INTEGER NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		role VARCHAR(10) NOT NULL DEFAULT 'member' CHECK (role IN ('owner', 'admin', 'member')),
+		last_read_message_id INTEGER NOT NULL DEFAULT 0,
+		joined_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (conversation_id, user_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_conversation_members_user ON conversation_members(user_id);
+	`
+
+	// receiver_id is only set on direct messages; group messages reach every member of the conversation
 	messagesTable := `
 	CREATE TABLE IF NOT EXISTS messages (
 		id SERIAL PRIMARY KEY,
+		conversation_id INTEGER NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
 		sender_id INTEGER NOT NULL REFERENCES users(id),
-		receiver_id INTEGER NOT NULL REFERENCES users(id),
+		receiver_id INTEGER REFERENCES users(id),
 		content TEXT NOT NULL,
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 		CONSTRAINT different_users CHECK (sender_id != receiver_id)
 	);
+	CREATE INDEX IF NOT EXISTS idx_messages_conversation ON messages(conversation_id, id);
 	`
 
-	conversationsTable := `
-	CREATE TABLE IF NOT EXISTS conversations (
+	// A row per recipient once a message reached one of their devices or they read it
+	messageReceiptsTable := `
+	CREATE TABLE IF NOT EXISTS message_receipts (
+		message_id INTEGER NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		delivered_at TIMESTAMP NOT NULL,
+		read_at TIMESTAMP,
+		PRIMARY KEY (message_id, user_id)
+	);
+	`
+
+	refreshTokensTable := `
+	CREATE TABLE IF NOT EXISTS refresh_tokens (
 		id SERIAL PRIMARY KEY,
-		user1_id INTEGER NOT NULL REFERENCES users(id),
-		user2_id INTEGER NOT NULL REFERENCES users(id),
-		last_message TEXT DEFAULT '',
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		family_id VARCHAR(64) NOT NULL,
+		token_hash VARCHAR(64) UNIQUE NOT NULL,
+		expires_at TIMESTAMP NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		rotated_at TIMESTAMP,
+		revoked_at TIMESTAMP,
+		replaced_by INTEGER REFERENCES refresh_tokens(id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family ON refresh_tokens(family_id);
+	`
+
+	revokedTokensTable := `
+	CREATE TABLE IF NOT EXISTS revoked_tokens (
+		jti VARCHAR(64) PRIMARY KEY,
+		expires_at TIMESTAMP NOT NULL,
+		revoked_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expires_at ON revoked_tokens(expires_at);
+	`
+
+	// A session is one login; its ID is the family_id of the refresh tokens it issued
+	sessionsTable := `
+	CREATE TABLE IF NOT EXISTS sessions (
+		id VARCHAR(64) PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		ip VARCHAR(64) NOT NULL DEFAULT '',
+		user_agent TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		last_seen_at TIMESTAMP NOT NULL,
+		expires_at TIMESTAMP NOT NULL,
+		revoked_at TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions(user_id);
+	`
+
+	revokedSessionsTable := `
+	CREATE TABLE IF NOT EXISTS revoked_sessions (
+		session_id VARCHAR(64) PRIMARY KEY,
+		expires_at TIMESTAMP NOT NULL,
+		revoked_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_revoked_sessions_expires_at ON revoked_sessions(expires_at);
+	`
+
+	userTokenRevocationsTable := `
+	CREATE TABLE IF NOT EXISTS user_token_revocations (
+		user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+		revoked_before TIMESTAMP NOT NULL
+	);
+	`
+
+	passwordResetTokensTable := `
+	CREATE TABLE IF NOT EXISTS password_reset_tokens (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		token_hash VARCHAR(64) UNIQUE NOT NULL,
+		expires_at TIMESTAMP NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		used_at TIMESTAMP
+	);
+	`
+
+	userMFATable := `
+	CREATE TABLE IF NOT EXISTS user_mfa (
+		user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+		secret VARCHAR(64) NOT NULL,
+		enabled_at TIMESTAMP,
+		last_used_step BIGINT NOT NULL DEFAULT 0,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+
+	mfaRecoveryCodesTable := `
+	CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		code_hash VARCHAR(64) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		used_at TIMESTAMP,
+		CONSTRAINT unique_recovery_code UNIQUE (user_id, code_hash)
+	);
+	`
+
+	loginThrottlesTable := `
+	CREATE TABLE IF NOT EXISTS login_throttles (
+		key VARCHAR(150) PRIMARY KEY,
+		failures INTEGER NOT NULL DEFAULT 0,
+		last_failure_at TIMESTAMP,
+		locked_until TIMESTAMP
+	);
+	`
+
+	lockoutEventsTable := `
+	CREATE TABLE IF NOT EXISTS lockout_events (
+		id SERIAL PRIMARY KEY,
+		key VARCHAR(150) NOT NULL,
+		user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		action VARCHAR(20) NOT NULL,
+		failures INTEGER NOT NULL DEFAULT 0,
+		ip VARCHAR(64) DEFAULT '',
+		user_agent TEXT DEFAULT '',
+		locked_until TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_lockout_events_key ON lockout_events(key);
+	`
+
+	rolesTable := `
+	CREATE TABLE IF NOT EXISTS roles (
+		id SERIAL PRIMARY KEY,
+		name VARCHAR(50) UNIQUE NOT NULL,
+		description TEXT DEFAULT ''
+	);
+	`
+
+	permissionsTable := `
+	CREATE TABLE IF NOT EXISTS permissions (
+		id SERIAL PRIMARY KEY,
+		name VARCHAR(100) UNIQUE NOT NULL,
+		description TEXT DEFAULT ''
+	);
+	`
+
+	rolePermissionsTable := `
+	CREATE TABLE IF NOT EXISTS role_permissions (
+		role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+		permission_id INTEGER NOT NULL REFERENCES permissions(id) ON DELETE CASCADE,
+		PRIMARY KEY (role_id, permission_id)
+	);
+	`
+
+	userRolesTable := `
+	CREATE TABLE IF NOT EXISTS user_roles (
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+		granted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, role_id)
+	);
+	`
+
+	apiKeysTable := `
+	CREATE TABLE IF NOT EXISTS api_keys (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		name VARCHAR(100) NOT NULL,
+		prefix VARCHAR(32) UNIQUE NOT NULL,
+		key_hash VARCHAR(64) NOT NULL,
+		scopes TEXT[] NOT NULL DEFAULT '{}',
+		expires_at TIMESTAMP NOT NULL,
+		last_used_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		revoked_at TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_api_keys_user ON api_keys(user_id);
+	`
+
+	userIdentitiesTable := `
+	CREATE TABLE IF NOT EXISTS user_identities (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		provider VARCHAR(50) NOT NULL,
+		subject VARCHAR(255) NOT NULL,
+		email VARCHAR(100) NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		last_login_at TIMESTAMP,
+		UNIQUE (provider, subject)
+	);
+	CREATE INDEX IF NOT EXISTS idx_user_identities_user ON user_identities(user_id);
+	`
+
+	oidcLoginStatesTable := `
+	CREATE TABLE IF NOT EXISTS oidc_login_states (
+		state_hash VARCHAR(64) PRIMARY KEY,
+		nonce VARCHAR(64) NOT NULL,
+		code_verifier VARCHAR(128) NOT NULL,
+		expires_at TIMESTAMP NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+
+	oauthClientsTable := `
+	CREATE TABLE IF NOT EXISTS oauth_clients (
+		id SERIAL PRIMARY KEY,
+		client_id VARCHAR(64) UNIQUE NOT NULL,
+		name VARCHAR(100) NOT NULL,
+		secret_hash VARCHAR(64) NOT NULL DEFAULT '',
+		redirect_uris TEXT[] NOT NULL DEFAULT '{}',
+		grant_types TEXT[] NOT NULL DEFAULT '{}',
+		scopes TEXT[] NOT NULL DEFAULT '{}',
+		confidential BOOLEAN NOT NULL DEFAULT FALSE,
+		created_by INTEGER NOT NULL REFERENCES users(id),
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		revoked_at TIMESTAMP
+	);
+	`
+
+	oauthAuthorizationCodesTable := `
+	CREATE TABLE IF NOT EXISTS oauth_authorization_codes (
+		code_hash VARCHAR(64) PRIMARY KEY,
+		client_id VARCHAR(64) NOT NULL REFERENCES oauth_clients(client_id) ON DELETE CASCADE,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		redirect_uri TEXT NOT NULL,
+		scopes TEXT[] NOT NULL DEFAULT '{}',
+		code_challenge VARCHAR(128) NOT NULL,
+		expires_at TIMESTAMP NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+
+	oauthConsentsTable := `
+	CREATE TABLE IF NOT EXISTS oauth_consents (
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		client_id VARCHAR(64) NOT NULL REFERENCES oauth_clients(client_id) ON DELETE CASCADE,
+		scopes TEXT[] NOT NULL DEFAULT '{}',
+		granted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		CONSTRAINT conversations_different_users CHECK (user1_id != user2_id),
-		CONSTRAINT unique_conversation UNIQUE (user1_id, user2_id)
+		PRIMARY KEY (user_id, client_id)
+	);
+	`
+
+	oauthRefreshTokensTable := `
+	CREATE TABLE IF NOT EXISTS oauth_refresh_tokens (
+		id SERIAL PRIMARY KEY,
+		token_hash VARCHAR(64) UNIQUE NOT NULL,
+		client_id VARCHAR(64) NOT NULL REFERENCES oauth_clients(client_id) ON DELETE CASCADE,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		scopes TEXT[] NOT NULL DEFAULT '{}',
+		expires_at TIMESTAMP NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		rotated_at TIMESTAMP,
+		revoked_at TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_oauth_refresh_tokens_grant ON oauth_refresh_tokens(user_id, client_id);
+	`
+
+	// Moderators can moderate messages; admins hold every permission
+	seedRoles := `
+	INSERT INTO roles (name, description) VALUES
+		('user', 'Regular account'),
+		('moderator', 'Moderates conversations'),
+		('admin', 'Manages accounts and roles')
+	ON CONFLICT (name) DO NOTHING;
+
+	INSERT INTO permissions (name, description) VALUES
+		('users:unlock', 'Lift login lockouts'),
+		('roles:assign', 'Grant and remove roles'),
+		('messages:moderate', 'Moderate messages'),
+		('oauth_clients:manage', 'Register and revoke OAuth clients'),
+		('audit:read', 'Query the authentication audit log')
+	ON CONFLICT (name) DO NOTHING;
+
+	INSERT INTO role_permissions (role_id, permission_id)
+	SELECT r.id, p.id FROM roles r, permissions p
+	WHERE r.name = 'admin' OR (r.name = 'moderator' AND p.name = 'messages:moderate')
+	ON CONFLICT DO NOTHING;
+	`
+
+	passkeysTable := `
+	CREATE TABLE IF NOT EXISTS passkeys (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		credential_id VARCHAR(1400) UNIQUE NOT NULL,
+		public_key BYTEA NOT NULL,
+		algorithm INTEGER NOT NULL,
+		sign_count BIGINT NOT NULL DEFAULT 0,
+		name VARCHAR(100) NOT NULL,
+		transports TEXT[] NOT NULL DEFAULT '{}',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		last_used_at TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_passkeys_user ON passkeys(user_id);
+	`
+
+	webauthnChallengesTable := `
+	CREATE TABLE IF NOT EXISTS webauthn_challenges (
+		challenge_hash VARCHAR(64) PRIMARY KEY,
+		ceremony VARCHAR(20) NOT NULL,
+		user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+		expires_at TIMESTAMP NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+
+	authEventsTable := `
+	CREATE TABLE IF NOT EXISTS auth_events (
+		id BIGSERIAL PRIMARY KEY,
+		type VARCHAR(50) NOT NULL,
+		user_id INTEGER,
+		actor_id INTEGER,
+		ip VARCHAR(64) NOT NULL DEFAULT '',
+		user_agent TEXT NOT NULL DEFAULT '',
+		metadata JSONB NOT NULL DEFAULT '{}',
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_auth_events_user ON auth_events(user_id, id);
+	CREATE INDEX IF NOT EXISTS idx_auth_events_type ON auth_events(type, id);
+
+	-- The audit log is append-only, and keeps its entries when an account is deleted
+	CREATE OR REPLACE FUNCTION reject_auth_event_change() RETURNS trigger AS $$
+	BEGIN
+		RAISE EXCEPTION 'auth_events is append-only';
+	END;
+	$$ LANGUAGE plpgsql;
+
+	CREATE TRIGGER auth_events_no_update_delete BEFORE UPDATE OR DELETE ON auth_events
+		FOR EACH ROW EXECUTE FUNCTION reject_auth_event_change();
+	CREATE TRIGGER auth_events_no_truncate BEFORE TRUNCATE ON auth_events
+		FOR EACH STATEMENT EXECUTE FUNCTION reject_auth_event_change();
+	`
+
+	// Trigram indexes back the fuzzy matching of the user directory search
+	userSearchIndexes := `
+	CREATE EXTENSION IF NOT EXISTS pg_trgm;
+	CREATE INDEX IF NOT EXISTS idx_users_name_trgm ON users USING GIN (lower(name) gin_trgm_ops);
+	CREATE INDEX IF NOT EXISTS idx_users_email_trgm ON users USING GIN (lower(email) gin_trgm_ops);
+	`
+
+	// A blocked user can neither message the blocker nor find them in the directory search
+	userBlocksTable := `
+	CREATE TABLE IF NOT EXISTS user_blocks (
+		blocker_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		blocked_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (blocker_id, blocked_id),
+		CHECK (blocker_id <> blocked_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_user_blocks_blocked ON user_blocks(blocked_id);
+	`
+
+	// Messages of a muted conversation are stored but not pushed in real time
+	conversationMutesTable := `
+	CREATE TABLE IF NOT EXISTS conversation_mutes (
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		conversation_id INTEGER NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, conversation_id)
 	);
 	`
 
 	// Execute migrations
-	migrations := []string{dropTables, usersTable, messagesTable, conversationsTable}
+	migrations := []string{
+		dropTables,
+		usersTable,
+		conversationsTable,
+		conversationMembersTable,
+		messagesTable,
+		messageReceiptsTable,
+		refreshTokensTable,
+		revokedTokensTable,
+		sessionsTable,
+		revokedSessionsTable,
+		userTokenRevocationsTable,
+		passwordResetTokensTable,
+		userMFATable,
+		mfaRecoveryCodesTable,
+		loginThrottlesTable,
+		lockoutEventsTable,
+		rolesTable,
+		permissionsTable,
+		rolePermissionsTable,
+		userRolesTable,
+		seedRoles,
+		apiKeysTable,
+		userIdentitiesTable,
+		oidcLoginStatesTable,
+		oauthClientsTable,
+		oauthAuthorizationCodesTable,
+		oauthConsentsTable,
+		oauthRefreshTokensTable,
+		passkeysTable,
+		webauthnChallengesTable,
+		authEventsTable,
+		userSearchIndexes,
+		userBlocksTable,
+		conversationMutesTable,
+	}
 
 	for _, migration := range migrations {
 		_, err := DB.Exec(context.Background(), migration)
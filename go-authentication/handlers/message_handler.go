@@ -140,9 +140,9 @@ func (h *MessageHandler) SendMessage(c *gin.Context) {
 	})
 }
 
-func (h *MessageHandler) SetupRoutes(r *gin.Engine) {
+func (h *MessageHandler) SetupRoutes(r *gin.Engine, authUsecase *usecase.AuthUsecase) {
 	messages := r.Group("/messages")
-	messages.Use(delivery.AuthMiddleware())
+	messages.Use(delivery.AuthMiddleware(authUsecase), delivery.RequireVerifiedEmail())
 	{
 		messages.POST("/send", h.SendMessage)
 		messages.GET("/:user_id", h.GetMessages)
@@ -0,0 +1,124 @@
+package delivery
+
+import (
+	"errors"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/usecase"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminHandler handles HTTP requests for administrative account actions
+type AdminHandler struct {
+	LockoutUsecase *usecase.LockoutUsecase
+	RoleUsecase    *usecase.RoleUsecase
+}
+
+// NewAdminHandler creates a new instance of AdminHandler
+func NewAdminHandler(lockoutUsecase *usecase.LockoutUsecase, roleUsecase *usecase.RoleUsecase) *AdminHandler {
+	return &AdminHandler{
+		LockoutUsecase: lockoutUsecase,
+		RoleUsecase:    roleUsecase,
+	}
+}
+
+// UnlockUserHandler lifts a login lockout on an account
+func (h *AdminHandler) UnlockUserHandler(c *gin.Context) {
+	adminID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if err := h.LockoutUsecase.Unlock(c.Request.Context(), userID, adminID); err != nil {
+		if errors.Is(err, usecase.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error unlocking user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock account"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account unlocked"})
+}
+
+// GetUserRolesHandler lists the roles and permissions of a user
+func (h *AdminHandler) GetUserRolesHandler(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	roles, err := h.RoleUsecase.UserRoles(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("Error fetching roles of user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+		return
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
+
+// AssignRoleHandler grants a role to a user
+func (h *AdminHandler) AssignRoleHandler(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req domain.AssignRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role is required"})
+		return
+	}
+
+	roles, err := h.RoleUsecase.AssignRole(c.Request.Context(), userID, req.Role)
+	if err != nil {
+		h.handleRoleError(c, userID, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
+
+// RemoveRoleHandler takes a role away from a user
+func (h *AdminHandler) RemoveRoleHandler(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	roles, err := h.RoleUsecase.RemoveRole(c.Request.Context(), userID, c.Param("role"))
+	if err != nil {
+		h.handleRoleError(c, userID, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
+
+// handleRoleError maps role usecase errors to HTTP responses
+func (h *AdminHandler) handleRoleError(c *gin.Context, userID int, err error) {
+	switch {
+	case errors.Is(err, usecase.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, usecase.ErrUnknownRole):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Error updating roles of user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update roles"})
+	}
+}
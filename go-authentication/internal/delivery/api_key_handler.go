@@ -0,0 +1,102 @@
+package delivery
+
+import (
+	"errors"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/usecase"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHandler handles HTTP requests for managing personal API keys
+type APIKeyHandler struct {
+	APIKeyUsecase *usecase.APIKeyUsecase
+}
+
+// NewAPIKeyHandler creates a new instance of APIKeyHandler
+func NewAPIKeyHandler(apiKeyUsecase *usecase.APIKeyUsecase) *APIKeyHandler {
+	return &APIKeyHandler{APIKeyUsecase: apiKeyUsecase}
+}
+
+// CreateHandler creates an API key and returns it once
+func (h *APIKeyHandler) CreateHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req domain.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+
+	key, err := h.APIKeyUsecase.Create(c.Request.Context(), userID, &req)
+	if err != nil {
+		var scopeErr *usecase.InvalidScopeError
+		switch {
+		case errors.As(err, &scopeErr):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, usecase.ErrInvalidAPIKeyName), errors.Is(err, usecase.ErrInvalidAPIKeyExpiry):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			log.Printf("Error creating api key for user %d: %v", userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "API key created. Store it somewhere safe; it will not be shown again.",
+		"api_key": key,
+	})
+}
+
+// ListHandler lists the user's API keys without their secrets
+func (h *APIKeyHandler) ListHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	keys, err := h.APIKeyUsecase.List(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("Error listing api keys for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list API keys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+// RevokeHandler revokes one of the user's API keys
+func (h *APIKeyHandler) RevokeHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	keyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	if err := h.APIKeyUsecase.Revoke(c.Request.Context(), userID, keyID); err != nil {
+		if errors.Is(err, usecase.ErrAPIKeyNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error revoking api key %d for user %d: %v", keyID, userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
@@ -0,0 +1,71 @@
+package delivery
+
+import (
+	"errors"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/usecase"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuditHandler handles HTTP requests for reading the authentication audit log
+type AuditHandler struct {
+	AuditUsecase *usecase.AuditUsecase
+}
+
+// NewAuditHandler creates a new instance of AuditHandler
+func NewAuditHandler(auditUsecase *usecase.AuditUsecase) *AuditHandler {
+	return &AuditHandler{AuditUsecase: auditUsecase}
+}
+
+// SecurityEventsHandler lists the security events of the signed in user, newest first
+func (h *AuditHandler) SecurityEventsHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var query domain.SecurityEventsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := h.AuditUsecase.ListForUser(c.Request.Context(), userID, query.Before, query.Limit)
+	if err != nil {
+		if errors.Is(err, usecase.ErrInvalidAuthEventQuery) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error listing security events for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list security events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// QueryHandler lets admins search the audit log by user, event type, IP and time range
+func (h *AuditHandler) QueryHandler(c *gin.Context) {
+	var query domain.AuthEventQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := h.AuditUsecase.Query(c.Request.Context(), &query)
+	if err != nil {
+		if errors.Is(err, usecase.ErrInvalidAuthEventQuery) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error querying auth events: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query auth events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
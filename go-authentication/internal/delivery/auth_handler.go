@@ -1,11 +1,15 @@
 package delivery
 
 import (
-	"context"
+	"errors"
 	"fmt"
 	"go-authentication/internal/domain"
 	"go-authentication/internal/usecase"
+	"go-authentication/pkg"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
@@ -33,8 +37,6 @@ func (h *AuthHandler) SignupHandler(c *gin.Context) {
 					errors[fieldErr.Field()] = fmt.Sprintf("%s is required", fieldErr.Field())
 				case "email":
 					errors[fieldErr.Field()] = "Please provide a valid email address"
-				case "min":
-					errors[fieldErr.Field()] = "Password must be at least 10 characters long"
 				default:
 					errors[fieldErr.Field()] = fmt.Sprintf("Invalid %s", fieldErr.Field())
 				}
@@ -51,12 +53,20 @@ func (h *AuthHandler) SignupHandler(c *gin.Context) {
 
 	fmt.Printf("Received User: %+v\n", user)
 
-	if err := h.AuthUsecase.Signup(context.Background(), &user); err != nil {
+	if err := h.AuthUsecase.Signup(c.Request.Context(), &user); err != nil {
+		var policyErr *pkg.PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Validation failed",
+				"details": map[string]string{"Password": policyErr.Error()},
+			})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "User registered successfully"})
+	c.JSON(http.StatusCreated, gin.H{"message": "User registered successfully. Please check your email to verify your address."})
 }
 
 //login-handler
@@ -64,7 +74,7 @@ func (h *AuthHandler) SignupHandler(c *gin.Context) {
 func (h *AuthHandler) LoginHandler(c *gin.Context) {
 	var req struct {
 		Email    string `json:"email"`
-		Password string `json:"password`
+		Password string `json:"password"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -73,14 +83,218 @@ func (h *AuthHandler) LoginHandler(c *gin.Context) {
 		return
 	}
 
-	token, err := h.AuthUsecase.Login(context.Background(), req.Email, req.Password)
+	tokens, err := h.AuthUsecase.Login(c.Request.Context(), req.Email, req.Password, clientInfo(c))
 	if err != nil {
+		var mfaErr *usecase.MFARequiredError
+		if errors.As(err, &mfaErr) {
+			c.JSON(http.StatusOK, mfaErr.Challenge)
+			return
+		}
+		var lockedErr *usecase.LoginLockedError
+		if errors.As(err, &lockedErr) {
+			respondLocked(c, lockedErr)
+			return
+		}
+		if errors.Is(err, usecase.ErrEmailNotVerified) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": token})
+	c.JSON(http.StatusOK, tokens)
+
+}
+
+// LoginMFAHandler completes a two-step login with the MFA challenge and a TOTP or recovery code
+func (h *AuthHandler) LoginMFAHandler(c *gin.Context) {
+	var req domain.MFALoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mfa_token and code are required"})
+		return
+	}
+
+	tokens, err := h.AuthUsecase.LoginMFA(c.Request.Context(), req.MFAToken, req.Code, clientInfo(c))
+	if err != nil {
+		var lockedErr *usecase.LoginLockedError
+		if errors.As(err, &lockedErr) {
+			respondLocked(c, lockedErr)
+			return
+		}
+		if errors.Is(err, usecase.ErrInvalidMFAChallenge) || errors.Is(err, usecase.ErrInvalidMFACode) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error completing mfa login: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log in"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// LoginPasskeyOptionsHandler starts a passkey login and returns the options for navigator.credentials.get()
+func (h *AuthHandler) LoginPasskeyOptionsHandler(c *gin.Context) {
+	options, err := h.AuthUsecase.Passkeys.BeginLogin(c.Request.Context())
+	if err != nil {
+		log.Printf("Error starting passkey login: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start passkey login"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"publicKey": options})
+}
+
+// LoginPasskeyHandler logs in with the assertion returned by navigator.credentials.get()
+func (h *AuthHandler) LoginPasskeyHandler(c *gin.Context) {
+	var req domain.PasskeyLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id and response are required"})
+		return
+	}
+
+	tokens, err := h.AuthUsecase.LoginPasskey(c.Request.Context(), &req, clientInfo(c))
+	if err != nil {
+		if errors.Is(err, usecase.ErrInvalidPasskeyLogin) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, usecase.ErrEmailNotVerified) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error completing passkey login: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log in"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// respondLocked answers a locked out login with 429 and tells the client when to retry
+func respondLocked(c *gin.Context, err *usecase.LoginLockedError) {
+	seconds := int(math.Ceil(err.RetryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error(), "retry_after": seconds})
+}
+
+// RefreshHandler rotates a refresh token and returns a new token pair
+func (h *AuthHandler) RefreshHandler(c *gin.Context) {
+	var req domain.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token is required"})
+		return
+	}
+
+	tokens, err := h.AuthUsecase.RefreshToken(c.Request.Context(), req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, usecase.ErrInvalidRefreshToken) || errors.Is(err, usecase.ErrRefreshTokenReused) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// LogoutHandler revokes the current access token and, when supplied, its refresh token
+func (h *AuthHandler) LogoutHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	claims, ok := getClaims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	// The refresh token is optional; without it only the access token is revoked
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+	}
+
+	if err := h.AuthUsecase.Logout(c.Request.Context(), userID, claims, req.RefreshToken); err != nil {
+		log.Printf("Error logging out user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// LogoutAllHandler revokes every token issued to the current user on every device
+func (h *AuthHandler) LogoutAllHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	if err := h.AuthUsecase.LogoutEverywhere(c.Request.Context(), userID); err != nil {
+		log.Printf("Error logging out user %d everywhere: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out from all devices"})
+}
+
+// VerifyEmailHandler confirms an email address from the link sent after signup
+func (h *AuthHandler) VerifyEmailHandler(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+		return
+	}
+
+	if err := h.AuthUsecase.Verification.VerifyEmail(c.Request.Context(), token); err != nil {
+		if errors.Is(err, usecase.ErrInvalidVerificationToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error verifying email: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+// ResendVerificationHandler sends a new verification link.
+// The response is the same whether or not the address belongs to an account.
+func (h *AuthHandler) ResendVerificationHandler(c *gin.Context) {
+	var req struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Please provide a valid email address"})
+		return
+	}
+
+	if err := h.AuthUsecase.Verification.ResendVerification(c.Request.Context(), req.Email); err != nil {
+		log.Printf("Error resending verification email: %v", err)
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the address belongs to an unverified account, a new verification email has been sent"})
+}
 
+// JWKSHandler publishes the public keys used to sign access tokens
+func (h *AuthHandler) JWKSHandler(c *gin.Context) {
+	// Let verifiers cache the set, but pick up rotated keys within minutes
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.AuthUsecase.Tokens.Keys().JWKS())
 }
 
 func (h *AuthHandler) ProtectedHandler(c *gin.Context) {
@@ -0,0 +1,97 @@
+package delivery
+
+import (
+	"errors"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/usecase"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BlockHandler handles HTTP requests for the signed in user's block list
+type BlockHandler struct {
+	BlockUsecase *usecase.BlockUsecase
+}
+
+// NewBlockHandler creates a new instance of BlockHandler
+func NewBlockHandler(blockUsecase *usecase.BlockUsecase) *BlockHandler {
+	return &BlockHandler{BlockUsecase: blockUsecase}
+}
+
+// ListHandler lists the users blocked by the current user
+func (h *BlockHandler) ListHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	blocked, err := h.BlockUsecase.ListBlocked(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("Error listing blocked users for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list blocked users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"blocked_users": blocked})
+}
+
+// BlockHandler blocks a user from messaging the current user
+func (h *BlockHandler) BlockHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req domain.BlockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required"})
+		return
+	}
+
+	if err := h.BlockUsecase.BlockUser(c.Request.Context(), userID, req.UserID); err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrCannotBlockSelf):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, usecase.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			log.Printf("Error blocking user %d for user %d: %v", req.UserID, userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to block user"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User blocked"})
+}
+
+// UnblockHandler removes a user from the current user's block list
+func (h *BlockHandler) UnblockHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	blockedID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if err := h.BlockUsecase.UnblockUser(c.Request.Context(), userID, blockedID); err != nil {
+		if errors.Is(err, usecase.ErrBlockNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error unblocking user %d for user %d: %v", blockedID, userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unblock user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unblocked"})
+}
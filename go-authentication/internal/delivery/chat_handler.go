@@ -2,6 +2,7 @@ package delivery
 
 import (
 	"context"
+	"errors"
 	"go-authentication/internal/domain"
 	"go-authentication/internal/usecase"
 	"log"
@@ -49,16 +50,44 @@ func (h *ChatHandler) SendMessageHandler(c *gin.Context) {
 		return
 	}
 
-	// Send message
-	message, err := h.ChatUsecase.SendMessage(
-		context.Background(),
-		senderID,
-		req.ReceiverID,
-		req.Content,
-	)
+	// Send message to the receiver or the conversation
+	message, err := h.ChatUsecase.Send(context.Background(), senderID, &req)
+	if err != nil {
+		respondSendError(c, err)
+		return
+	}
 
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Message sent successfully",
+		"data":    message,
+	})
+}
+
+// SendToConversationHandler posts a message to a direct or group conversation
+func (h *ChatHandler) SendToConversationHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	conversationID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversation ID"})
+		return
+	}
+
+	var req struct {
+		Content string `json:"content" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "content is required"})
+		return
+	}
+
+	message, err := h.ChatUsecase.SendToConversation(c.Request.Context(), userID, conversationID, req.Content)
+	if err != nil {
+		respondSendError(c, err)
 		return
 	}
 
@@ -68,6 +97,59 @@ func (h *ChatHandler) SendMessageHandler(c *gin.Context) {
 	})
 }
 
+// respondSendError maps the errors of sending a message to a status code
+func respondSendError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, usecase.ErrMessagingBlocked):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, usecase.ErrConversationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
+
+// GetConversationHistoryHandler lists the messages of a conversation the current user is a member of
+func (h *ChatHandler) GetConversationHistoryHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	conversationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversation ID"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil {
+		limit = 20
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil {
+		offset = 0
+	}
+
+	messages, err := h.ChatUsecase.GetConversationHistory(c.Request.Context(), userID, conversationID, limit, offset)
+	if err != nil {
+		if errors.Is(err, usecase.ErrConversationNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error getting messages of conversation %d for user %d: %v", conversationID, userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get messages"})
+		return
+	}
+
+	for i := range messages {
+		messages[i].IsSent = messages[i].SenderID == userID
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": messages})
+}
+
 // GetConversationMessagesHandler handles retrieving messages between two users
 func (h *ChatHandler) GetConversationMessagesHandler(c *gin.Context) {
 	// Get user ID from token
@@ -147,3 +229,166 @@ func (h *ChatHandler) GetConversationMessagesHandler(c *gin.Context) {
 		"data": messages,
 	})
 }
+
+// ListMutesHandler lists the conversations muted by the current user
+func (h *ChatHandler) ListMutesHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	mutes, err := h.ChatUsecase.ListMutedConversations(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("Error listing muted conversations for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list muted conversations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"mutes": mutes})
+}
+
+// MuteHandler mutes a conversation; its messages are still stored but not pushed in real time
+func (h *ChatHandler) MuteHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req domain.MuteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "conversation_id is required"})
+		return
+	}
+
+	if err := h.ChatUsecase.MuteConversation(c.Request.Context(), userID, req.ConversationID); err != nil {
+		if errors.Is(err, usecase.ErrConversationNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error muting conversation %d for user %d: %v", req.ConversationID, userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mute conversation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Conversation muted"})
+}
+
+// UnmuteHandler restores real-time notifications of a muted conversation
+func (h *ChatHandler) UnmuteHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	conversationID, err := strconv.Atoi(c.Param("conversation_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversation ID"})
+		return
+	}
+
+	if err := h.ChatUsecase.UnmuteConversation(c.Request.Context(), userID, conversationID); err != nil {
+		if errors.Is(err, usecase.ErrMuteNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error unmuting conversation %d for user %d: %v", conversationID, userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unmute conversation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Conversation unmuted"})
+}
+
+// RemoveMessageHandler lets a moderator delete any message
+func (h *ChatHandler) RemoveMessageHandler(c *gin.Context) {
+	moderatorID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	messageID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID"})
+		return
+	}
+
+	if err := h.ChatUsecase.RemoveMessage(c.Request.Context(), moderatorID, messageID); err != nil {
+		if errors.Is(err, usecase.ErrMessageNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error removing message %d: %v", messageID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove message"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Message removed"})
+}
+
+// InboxHandler lists the current user's conversations, most recently active first
+func (h *ChatHandler) InboxHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var query domain.InboxQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := h.ChatUsecase.Inbox(c.Request.Context(), userID, &query)
+	if err != nil {
+		if errors.Is(err, usecase.ErrInvalidInboxQuery) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error listing inbox of user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list conversations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// MarkReadHandler marks a conversation read up to a message and notifies the senders
+func (h *ChatHandler) MarkReadHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	conversationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversation ID"})
+		return
+	}
+
+	var req domain.MarkReadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "up_to_message_id is required"})
+		return
+	}
+
+	if err := h.ChatUsecase.MarkConversationRead(c.Request.Context(), userID, conversationID, req.UpToMessageID); err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrInvalidReadPosition):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, usecase.ErrConversationNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			log.Printf("Error marking conversation %d read for user %d: %v", conversationID, userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark conversation read"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Conversation marked as read"})
+}
@@ -0,0 +1,207 @@
+package delivery
+
+import (
+	"errors"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/usecase"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GroupHandler handles HTTP requests for group conversations and their members
+type GroupHandler struct {
+	GroupUsecase *usecase.GroupUsecase
+}
+
+// NewGroupHandler creates a new instance of GroupHandler
+func NewGroupHandler(groupUsecase *usecase.GroupUsecase) *GroupHandler {
+	return &GroupHandler{GroupUsecase: groupUsecase}
+}
+
+// CreateHandler starts a group owned by the current user
+func (h *GroupHandler) CreateHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req domain.CreateGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+
+	conversation, err := h.GroupUsecase.CreateGroup(c.Request.Context(), userID, &req)
+	if err != nil {
+		respondGroupError(c, err, "Failed to create group")
+		return
+	}
+
+	c.JSON(http.StatusCreated, conversation)
+}
+
+// GetHandler returns a conversation with its members
+func (h *GroupHandler) GetHandler(c *gin.Context) {
+	userID, conversationID, ok := conversationParams(c)
+	if !ok {
+		return
+	}
+
+	conversation, err := h.GroupUsecase.GetConversation(c.Request.Context(), userID, conversationID)
+	if err != nil {
+		respondGroupError(c, err, "Failed to get conversation")
+		return
+	}
+
+	c.JSON(http.StatusOK, conversation)
+}
+
+// RenameHandler changes the name of a group
+func (h *GroupHandler) RenameHandler(c *gin.Context) {
+	userID, conversationID, ok := conversationParams(c)
+	if !ok {
+		return
+	}
+
+	var req domain.RenameConversationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+
+	conversation, err := h.GroupUsecase.RenameGroup(c.Request.Context(), userID, conversationID, req.Name)
+	if err != nil {
+		respondGroupError(c, err, "Failed to rename group")
+		return
+	}
+
+	c.JSON(http.StatusOK, conversation)
+}
+
+// InviteHandler adds users to a group
+func (h *GroupHandler) InviteHandler(c *gin.Context) {
+	userID, conversationID, ok := conversationParams(c)
+	if !ok {
+		return
+	}
+
+	var req domain.InviteMembersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_ids is required"})
+		return
+	}
+
+	conversation, err := h.GroupUsecase.InviteMembers(c.Request.Context(), userID, conversationID, req.UserIDs)
+	if err != nil {
+		respondGroupError(c, err, "Failed to invite members")
+		return
+	}
+
+	c.JSON(http.StatusOK, conversation)
+}
+
+// UpdateMemberRoleHandler makes a member an admin or a plain member again
+func (h *GroupHandler) UpdateMemberRoleHandler(c *gin.Context) {
+	userID, conversationID, ok := conversationParams(c)
+	if !ok {
+		return
+	}
+
+	memberID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req domain.UpdateMemberRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role is required"})
+		return
+	}
+
+	conversation, err := h.GroupUsecase.UpdateMemberRole(c.Request.Context(), userID, conversationID, memberID, req.Role)
+	if err != nil {
+		respondGroupError(c, err, "Failed to update member role")
+		return
+	}
+
+	c.JSON(http.StatusOK, conversation)
+}
+
+// RemoveMemberHandler removes a member from a group
+func (h *GroupHandler) RemoveMemberHandler(c *gin.Context) {
+	userID, conversationID, ok := conversationParams(c)
+	if !ok {
+		return
+	}
+
+	memberID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if err := h.GroupUsecase.RemoveMember(c.Request.Context(), userID, conversationID, memberID); err != nil {
+		respondGroupError(c, err, "Failed to remove member")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed"})
+}
+
+// LeaveHandler removes the current user from a group
+func (h *GroupHandler) LeaveHandler(c *gin.Context) {
+	userID, conversationID, ok := conversationParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.GroupUsecase.LeaveGroup(c.Request.Context(), userID, conversationID); err != nil {
+		respondGroupError(c, err, "Failed to leave group")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Left the group"})
+}
+
+// conversationParams reads the current user and the conversation ID of the path,
+// answering the request itself when either is missing
+func conversationParams(c *gin.Context) (int, int, bool) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return 0, 0, false
+	}
+
+	conversationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversation ID"})
+		return 0, 0, false
+	}
+	return userID, conversationID, true
+}
+
+// respondGroupError maps the errors of group management to a status code
+func respondGroupError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, usecase.ErrInvalidGroupName),
+		errors.Is(err, usecase.ErrTooManyMembers),
+		errors.Is(err, usecase.ErrInvalidMemberRole),
+		errors.Is(err, usecase.ErrNotGroupConversation):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, usecase.ErrConversationForbidden),
+		errors.Is(err, usecase.ErrMessagingBlocked):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, usecase.ErrConversationNotFound),
+		errors.Is(err, usecase.ErrMemberNotFound),
+		errors.Is(err, usecase.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Error managing group: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -0,0 +1,134 @@
+package delivery
+
+import (
+	"errors"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/usecase"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MFAHandler handles HTTP requests for managing two-factor authentication
+type MFAHandler struct {
+	MFAUsecase *usecase.MFAUsecase
+}
+
+// NewMFAHandler creates a new instance of MFAHandler
+func NewMFAHandler(mfaUsecase *usecase.MFAUsecase) *MFAHandler {
+	return &MFAHandler{MFAUsecase: mfaUsecase}
+}
+
+// EnrollHandler starts TOTP enrollment and returns the secret and provisioning URI
+func (h *MFAHandler) EnrollHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	enrollment, err := h.MFAUsecase.Enroll(c.Request.Context(), userID)
+	if err != nil {
+		if errors.Is(err, usecase.ErrMFAAlreadyEnabled) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error starting mfa enrollment for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start enrollment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// EnableHandler confirms enrollment with a TOTP code and returns the recovery codes
+func (h *MFAHandler) EnableHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req domain.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code is required"})
+		return
+	}
+
+	codes, err := h.MFAUsecase.Enable(c.Request.Context(), userID, req.Code)
+	if err != nil {
+		h.handleError(c, userID, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Two-factor authentication enabled. Store the recovery codes somewhere safe; they will not be shown again.",
+		"recovery_codes": codes,
+	})
+}
+
+// DisableHandler turns off two-factor authentication
+func (h *MFAHandler) DisableHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req domain.MFADisableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code and password are required"})
+		return
+	}
+
+	if err := h.MFAUsecase.Disable(c.Request.Context(), userID, &req); err != nil {
+		h.handleError(c, userID, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RecoveryCodesHandler replaces the recovery codes with a new set
+func (h *MFAHandler) RecoveryCodesHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req domain.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code is required"})
+		return
+	}
+
+	codes, err := h.MFAUsecase.RegenerateRecoveryCodes(c.Request.Context(), userID, req.Code)
+	if err != nil {
+		h.handleError(c, userID, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// handleError maps MFA usecase errors to HTTP responses
+func (h *MFAHandler) handleError(c *gin.Context, userID int, err error) {
+	var lockedErr *usecase.LoginLockedError
+	switch {
+	case errors.As(err, &lockedErr):
+		respondLocked(c, lockedErr)
+	case errors.Is(err, usecase.ErrInvalidMFACode):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, usecase.ErrIncorrectPassword):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, usecase.ErrMFAAlreadyEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, usecase.ErrMFANotEnabled), errors.Is(err, usecase.ErrMFANotEnrolled):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Error managing mfa for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update two-factor authentication"})
+	}
+}
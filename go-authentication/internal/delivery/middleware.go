@@ -1,14 +1,24 @@
 package delivery
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
-	"go-authentication/pkg"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/usecase"
 
 	"log"
 
+	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// Values of the "auth_method" context key set by AuthMiddleware
+const (
+	authMethodToken  = "token"
+	authMethodAPIKey = "api_key"
 )
 
 // ErrorResponse function defines the standard error response structure
@@ -32,11 +42,28 @@ func ErrorHandler() gin.HandlerFunc {
 	}
 }
 
-//AuthMiddleware Function validates the JWT token and extracts user information
+// ClientContext attaches the client's IP and user agent to the request context,
+// so events recorded further down, like audit log entries, know where a request came from
+func ClientContext() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(domain.ContextWithClientInfo(c.Request.Context(), clientInfo(c)))
+		c.Next()
+	}
+}
+
+//AuthMiddleware Function validates the JWT token, rejects revoked tokens and extracts user information
 
-func AuthMiddleware() gin.HandlerFunc {
+func AuthMiddleware(authUsecase *usecase.AuthUsecase) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
+
+		// Browsers cannot set headers on a WebSocket handshake, so the upgrade may carry the token as a query parameter
+		if authHeader == "" && websocket.IsWebSocketUpgrade(c.Request) {
+			if queryToken := c.Query("access_token"); queryToken != "" {
+				authHeader = "Bearer " + queryToken
+			}
+		}
+
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "Authorization header is missing",
@@ -45,8 +72,11 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Debug logging
-		log.Printf("Received Authorization header: %s", authHeader)
+		// Bots and service accounts authenticate with a personal API key (format: "ApiKey <key>")
+		if rawKey, ok := strings.CutPrefix(authHeader, "ApiKey "); ok {
+			authenticateAPIKey(c, authUsecase, rawKey)
+			return
+		}
 
 		// Extract token (format: "Bearer <token>")
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
@@ -56,14 +86,17 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Debug logging
-		log.Printf("Extracted token: %s", tokenString)
-
-		// Validate token and get claims
-		claims, err := pkg.ValidateJWT(tokenString)
+		// Validate token, check the revocation store and get claims
+		claims, err := authUsecase.ValidateAccessToken(c.Request.Context(), tokenString)
 		if err != nil {
 			log.Printf("Token validation error: %v", err)
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			if errors.Is(err, usecase.ErrTokenRevoked) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			} else if errors.Is(err, usecase.ErrInvalidAccessToken) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate token"})
+			}
 			c.Abort()
 			return
 		}
@@ -71,9 +104,142 @@ func AuthMiddleware() gin.HandlerFunc {
 		// Debug logging
 		log.Printf("Token validated successfully. User ID: %v", claims["user_id"])
 
-		// Set user ID and email in context
+		// Set user ID, email, roles, permissions and the raw claims in context
 		c.Set("user_id", claims["user_id"])
 		c.Set("email", claims["email"])
+		c.Set("email_verified", claims["email_verified"] == true)
+		c.Set("roles", claimStrings(claims, "roles"))
+		c.Set("permissions", claimStrings(claims, "permissions"))
+		c.Set("claims", claims)
+		c.Set("auth_method", authMethodToken)
+		setActor(c)
+		c.Next()
+	}
+}
+
+// authenticateAPIKey sets the same identity in the context as a JWT would, with the key's scopes as permissions.
+// No "claims" are set, so handlers that need a token, like logout, reject API key requests.
+func authenticateAPIKey(c *gin.Context, authUsecase *usecase.AuthUsecase, rawKey string) {
+	principal, err := authUsecase.APIKeys.Authenticate(c.Request.Context(), strings.TrimSpace(rawKey))
+	if err != nil {
+		log.Printf("API key validation error: %v", err)
+		if errors.Is(err, usecase.ErrInvalidAPIKey) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate API key"})
+		}
+		c.Abort()
+		return
+	}
+
+	c.Set("user_id", principal.UserID)
+	c.Set("email", principal.Email)
+	c.Set("email_verified", principal.EmailVerified)
+	c.Set("roles", principal.Roles)
+	c.Set("permissions", principal.Permissions)
+	c.Set("api_key_id", principal.APIKeyID)
+	c.Set("auth_method", authMethodAPIKey)
+	setActor(c)
+	c.Next()
+}
+
+// RequireTokenAuth rejects requests authenticated with an API key, so a leaked key cannot
+// manage credentials such as other API keys or two-factor authentication.
+// It must run after AuthMiddleware.
+func RequireTokenAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("auth_method") != authMethodToken {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This endpoint requires signing in with a password"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireVerifiedEmail rejects requests from users who have not verified their email address.
+// It must run after AuthMiddleware.
+func RequireVerifiedEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !c.GetBool("email_verified") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Please verify your email address first"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequirePermission rejects requests whose access token does not grant every listed permission.
+// It must run after AuthMiddleware.
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		granted := make(map[string]bool)
+		for _, permission := range c.GetStringSlice("permissions") {
+			granted[permission] = true
+		}
+
+		for _, permission := range permissions {
+			if !granted[permission] {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Missing permission: " + permission})
+				c.Abort()
+				return
+			}
+		}
 		c.Next()
 	}
 }
+
+// claimStrings reads a claim holding a list of strings
+func claimStrings(claims jwt.MapClaims, name string) []string {
+	values, _ := claims[name].([]interface{})
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if s, ok := value.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// clientInfo describes the client making the request
+func clientInfo(c *gin.Context) domain.ClientInfo {
+	return domain.ClientInfo{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+}
+
+// getUserID reads the authenticated user's ID set by AuthMiddleware
+func getUserID(c *gin.Context) (int, bool) {
+	userIDValue, exists := c.Get("user_id")
+	if !exists {
+		return 0, false
+	}
+
+	// Convert userID to int (might be float64 from JWT claims)
+	switch v := userIDValue.(type) {
+	case int:
+		return v, true
+	case float64:
+		return int(v), true
+	}
+	return 0, false
+}
+
+// setActor records the authenticated user in the request context as the actor of what the request changes
+func setActor(c *gin.Context) {
+	if userID, ok := getUserID(c); ok {
+		c.Request = c.Request.WithContext(domain.ContextWithActor(c.Request.Context(), userID))
+	}
+}
+
+// getClaims reads the validated token claims set by AuthMiddleware
+func getClaims(c *gin.Context) (jwt.MapClaims, bool) {
+	claimsValue, exists := c.Get("claims")
+	if !exists {
+		return nil, false
+	}
+	claims, ok := claimsValue.(jwt.MapClaims)
+	return claims, ok
+}
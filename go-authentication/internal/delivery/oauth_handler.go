@@ -0,0 +1,264 @@
+package delivery
+
+import (
+	"errors"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/usecase"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OAuthHandler handles the endpoints of the OAuth authorization server: client registration,
+// authorization and consent, and the token, introspection and revocation endpoints
+type OAuthHandler struct {
+	OAuthUsecase *usecase.OAuthUsecase
+}
+
+// NewOAuthHandler creates a new instance of OAuthHandler
+func NewOAuthHandler(oauthUsecase *usecase.OAuthUsecase) *OAuthHandler {
+	return &OAuthHandler{OAuthUsecase: oauthUsecase}
+}
+
+// CreateClientHandler registers a client and returns its secret once
+func (h *OAuthHandler) CreateClientHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req domain.CreateOAuthClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+
+	client, err := h.OAuthUsecase.RegisterClient(c.Request.Context(), userID, &req)
+	if err != nil {
+		if errors.Is(err, usecase.ErrInvalidOAuthClient) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error registering oauth client for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register client"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Client registered. Store the client secret somewhere safe; it will not be shown again.",
+		"client":  client,
+	})
+}
+
+// ListClientsHandler lists the registered clients without their secrets
+func (h *OAuthHandler) ListClientsHandler(c *gin.Context) {
+	clients, err := h.OAuthUsecase.ListClients(c.Request.Context())
+	if err != nil {
+		log.Printf("Error listing oauth clients: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list clients"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"clients": clients})
+}
+
+// RevokeClientHandler disables a client and revokes its tokens
+func (h *OAuthHandler) RevokeClientHandler(c *gin.Context) {
+	clientID := c.Param("client_id")
+	if err := h.OAuthUsecase.RevokeClient(c.Request.Context(), clientID); err != nil {
+		if errors.Is(err, usecase.ErrOAuthClientNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error revoking oauth client %s: %v", clientID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke client"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Client revoked"})
+}
+
+// AuthorizeHandler starts an authorization for the signed in user. The response either carries the
+// redirect back to the client, or asks the frontend to show a consent prompt.
+func (h *OAuthHandler) AuthorizeHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req domain.AuthorizationRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		respondOAuthError(c, &usecase.OAuthError{Code: usecase.OAuthErrInvalidRequest, Description: "invalid authorization request"})
+		return
+	}
+
+	response, err := h.OAuthUsecase.Authorize(c.Request.Context(), userID, &req)
+	if err != nil {
+		h.handleError(c, "authorizing oauth client", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ConsentHandler records the user's approval or denial of a client and returns the redirect back to it
+func (h *OAuthHandler) ConsentHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var decision domain.AuthorizationDecision
+	if err := c.ShouldBindJSON(&decision); err != nil {
+		respondOAuthError(c, &usecase.OAuthError{Code: usecase.OAuthErrInvalidRequest, Description: "invalid consent decision"})
+		return
+	}
+
+	response, err := h.OAuthUsecase.Decide(c.Request.Context(), userID, &decision)
+	if err != nil {
+		h.handleError(c, "recording oauth consent", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// TokenHandler is the token endpoint (RFC 6749 section 3.2). Parameters are form encoded.
+func (h *OAuthHandler) TokenHandler(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	client, ok := h.authenticateClient(c)
+	if !ok {
+		return
+	}
+
+	var req domain.TokenRequest
+	if err := c.ShouldBind(&req); err != nil {
+		respondOAuthError(c, &usecase.OAuthError{Code: usecase.OAuthErrInvalidRequest, Description: "invalid token request"})
+		return
+	}
+
+	response, err := h.OAuthUsecase.Token(c.Request.Context(), client, &req)
+	if err != nil {
+		h.handleError(c, "issuing oauth token", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// IntrospectHandler is the introspection endpoint for resource servers (RFC 7662)
+func (h *OAuthHandler) IntrospectHandler(c *gin.Context) {
+	client, ok := h.authenticateClient(c)
+	if !ok {
+		return
+	}
+
+	response, err := h.OAuthUsecase.Introspect(c.Request.Context(), client, c.PostForm("token"))
+	if err != nil {
+		h.handleError(c, "introspecting oauth token", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// RevokeHandler is the revocation endpoint (RFC 7009). It answers 200 even for unknown tokens.
+func (h *OAuthHandler) RevokeHandler(c *gin.Context) {
+	client, ok := h.authenticateClient(c)
+	if !ok {
+		return
+	}
+
+	if err := h.OAuthUsecase.Revoke(c.Request.Context(), client, c.PostForm("token")); err != nil {
+		h.handleError(c, "revoking oauth token", err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// ListConsentsHandler lists the applications the user allowed to access their account
+func (h *OAuthHandler) ListConsentsHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	consents, err := h.OAuthUsecase.ListConsents(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("Error listing oauth consents for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list authorized applications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"consents": consents})
+}
+
+// RevokeConsentHandler withdraws the user's consent for an application
+func (h *OAuthHandler) RevokeConsentHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	clientID := c.Param("client_id")
+	if err := h.OAuthUsecase.RevokeConsent(c.Request.Context(), userID, clientID); err != nil {
+		if errors.Is(err, usecase.ErrConsentNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error revoking oauth consent of user %d for %s: %v", userID, clientID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke access"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Access revoked"})
+}
+
+// authenticateClient reads client credentials from HTTP Basic authentication or the form body (RFC 6749 section 2.3.1)
+func (h *OAuthHandler) authenticateClient(c *gin.Context) (*domain.OAuthClient, bool) {
+	clientID, clientSecret, ok := c.Request.BasicAuth()
+	if ok {
+		// Basic credentials are form encoded before being base64 encoded
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID, clientSecret = c.PostForm("client_id"), c.PostForm("client_secret")
+	}
+
+	client, err := h.OAuthUsecase.AuthenticateClient(c.Request.Context(), clientID, clientSecret)
+	if err != nil {
+		h.handleError(c, "authenticating oauth client", err)
+		return nil, false
+	}
+	return client, true
+}
+
+func (h *OAuthHandler) handleError(c *gin.Context, action string, err error) {
+	var oauthErr *usecase.OAuthError
+	if errors.As(err, &oauthErr) {
+		respondOAuthError(c, oauthErr)
+		return
+	}
+	log.Printf("Error %s: %v", action, err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+}
+
+// respondOAuthError answers with the error format of RFC 6749 section 5.2
+func respondOAuthError(c *gin.Context, err *usecase.OAuthError) {
+	status := http.StatusBadRequest
+	if err.Code == usecase.OAuthErrInvalidClient {
+		status = http.StatusUnauthorized
+		c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+	}
+	c.JSON(status, gin.H{"error": err.Code, "error_description": err.Description})
+}
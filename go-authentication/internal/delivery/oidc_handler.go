@@ -0,0 +1,93 @@
+package delivery
+
+import (
+	"crypto/subtle"
+	"errors"
+	"go-authentication/internal/usecase"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// oidcStateCookie binds a login to the browser that started it, so a callback URL cannot be replayed elsewhere
+const oidcStateCookie = "oidc_state"
+
+// OIDCHandler handles the browser redirects of single sign-on with an OpenID Connect provider
+type OIDCHandler struct {
+	OIDCUsecase *usecase.OIDCUsecase
+}
+
+// NewOIDCHandler creates a new instance of OIDCHandler
+func NewOIDCHandler(oidcUsecase *usecase.OIDCUsecase) *OIDCHandler {
+	return &OIDCHandler{OIDCUsecase: oidcUsecase}
+}
+
+// LoginHandler redirects the browser to the identity provider
+func (h *OIDCHandler) LoginHandler(c *gin.Context) {
+	authorization, err := h.OIDCUsecase.StartLogin(c.Request.Context())
+	if err != nil {
+		if errors.Is(err, usecase.ErrOIDCNotConfigured) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error starting oidc login: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider is unavailable"})
+		return
+	}
+
+	setOIDCStateCookie(c, authorization.State, 600)
+	c.Redirect(http.StatusFound, authorization.URL)
+}
+
+// CallbackHandler completes the login when the identity provider redirects back.
+// It answers like the login endpoint: with a token pair, or with an MFA challenge.
+func (h *OIDCHandler) CallbackHandler(c *gin.Context) {
+	state := c.Query("state")
+	cookie, _ := c.Cookie(oidcStateCookie)
+	setOIDCStateCookie(c, "", -1)
+
+	if providerErr := c.Query("error"); providerErr != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Identity provider denied the login: " + providerErr})
+		return
+	}
+	if state == "" || c.Query("code") == "" || subtle.ConstantTimeCompare([]byte(cookie), []byte(state)) != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": usecase.ErrInvalidOIDCState.Error()})
+		return
+	}
+
+	tokens, err := h.OIDCUsecase.Callback(c.Request.Context(), state, c.Query("code"), clientInfo(c))
+	if err != nil {
+		var mfaErr *usecase.MFARequiredError
+		switch {
+		case errors.As(err, &mfaErr):
+			c.JSON(http.StatusOK, mfaErr.Challenge)
+		case errors.Is(err, usecase.ErrOIDCNotConfigured):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, usecase.ErrInvalidOIDCState):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, usecase.ErrOIDCLoginFailed):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case errors.Is(err, usecase.ErrOIDCEmailNotVerified), errors.Is(err, usecase.ErrOIDCAccountNotVerified):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			log.Printf("Error completing oidc login: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log in"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+func setOIDCStateCookie(c *gin.Context, value string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    value,
+		Path:     "/oidc",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   c.Request.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
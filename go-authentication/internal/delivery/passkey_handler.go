@@ -0,0 +1,120 @@
+package delivery
+
+import (
+	"errors"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/usecase"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PasskeyHandler handles registering and managing the signed in user's passkeys
+type PasskeyHandler struct {
+	PasskeyUsecase *usecase.PasskeyUsecase
+}
+
+// NewPasskeyHandler creates a new instance of PasskeyHandler
+func NewPasskeyHandler(passkeyUsecase *usecase.PasskeyUsecase) *PasskeyHandler {
+	return &PasskeyHandler{PasskeyUsecase: passkeyUsecase}
+}
+
+// RegistrationOptionsHandler starts registering a passkey and returns the options for navigator.credentials.create()
+func (h *PasskeyHandler) RegistrationOptionsHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	options, err := h.PasskeyUsecase.BeginRegistration(c.Request.Context(), userID)
+	if err != nil {
+		if errors.Is(err, usecase.ErrTooManyPasskeys) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error starting passkey registration for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start passkey registration"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"publicKey": options})
+}
+
+// RegisterHandler verifies the new credential and stores it as a passkey
+func (h *PasskeyHandler) RegisterHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req domain.PasskeyRegistrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id and response are required"})
+		return
+	}
+
+	passkey, err := h.PasskeyUsecase.FinishRegistration(c.Request.Context(), userID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrInvalidPasskeyCreate), errors.Is(err, usecase.ErrInvalidPasskeyName):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, usecase.ErrPasskeyExists):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			log.Printf("Error registering passkey for user %d: %v", userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register passkey"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Passkey registered", "passkey": passkey})
+}
+
+// ListHandler lists the user's passkeys
+func (h *PasskeyHandler) ListHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	passkeys, err := h.PasskeyUsecase.ListPasskeys(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("Error listing passkeys for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list passkeys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"passkeys": passkeys})
+}
+
+// DeleteHandler removes one of the user's passkeys
+func (h *PasskeyHandler) DeleteHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	passkeyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid passkey ID"})
+		return
+	}
+
+	if err := h.PasskeyUsecase.DeletePasskey(c.Request.Context(), userID, passkeyID); err != nil {
+		if errors.Is(err, usecase.ErrPasskeyNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error deleting passkey %d for user %d: %v", passkeyID, userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete passkey"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Passkey deleted"})
+}
@@ -0,0 +1,152 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/usecase"
+	"html/template"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PasswordHandler handles HTTP requests for changing a password and recovering an account
+type PasswordHandler struct {
+	PasswordUsecase *usecase.PasswordUsecase
+}
+
+// NewPasswordHandler creates a new instance of PasswordHandler
+func NewPasswordHandler(passwordUsecase *usecase.PasswordUsecase) *PasswordHandler {
+	return &PasswordHandler{PasswordUsecase: passwordUsecase}
+}
+
+// ForgotPasswordHandler emails a reset link.
+// The response is identical whether or not the address belongs to an account, and so is its timing:
+// looking the account up and sending the email happen after the response.
+func (h *PasswordHandler) ForgotPasswordHandler(c *gin.Context) {
+	var req domain.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Please provide a valid email address"})
+		return
+	}
+
+	ctx := context.WithoutCancel(c.Request.Context())
+	go func() {
+		if err := h.PasswordUsecase.ForgotPassword(ctx, req.Email); err != nil {
+			log.Printf("Error handling forgot password request: %v", err)
+		}
+	}()
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the address belongs to an account, a password reset email has been sent"})
+}
+
+// resetPasswordPage is the form the emailed reset link opens; it submits to POST /password/reset
+var resetPasswordPage = template.Must(template.New("reset-password").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Reset your password</title>
+</head>
+<body>
+<h1>Reset your password</h1>
+<form id="reset">
+<input type="hidden" name="token" value="{{.}}">
+<label>New password <input type="password" name="new_password" autocomplete="new-password" required></label>
+<button type="submit">Set password</button>
+</form>
+<p id="result"></p>
+<script>
+document.getElementById("reset").addEventListener("submit", async function (event) {
+	event.preventDefault();
+	const form = new FormData(event.target);
+	const response = await fetch(window.location.pathname, {
+		method: "POST",
+		headers: {"Content-Type": "application/json"},
+		body: JSON.stringify({token: form.get("token"), new_password: form.get("new_password")})
+	});
+	const body = await response.json();
+	document.getElementById("result").textContent = body.message || body.error;
+	if (response.ok) {
+		event.target.remove();
+	}
+});
+</script>
+</body>
+</html>
+`))
+
+// ResetPasswordFormHandler serves the page that the emailed reset link points to
+func (h *PasswordHandler) ResetPasswordFormHandler(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+		return
+	}
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	// The token is in the URL; keep it out of the Referer of anything the page loads
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Status(http.StatusOK)
+	if err := resetPasswordPage.Execute(c.Writer, token); err != nil {
+		log.Printf("Error rendering password reset page: %v", err)
+	}
+}
+
+// ResetPasswordHandler sets a new password from a reset token
+func (h *PasswordHandler) ResetPasswordHandler(c *gin.Context) {
+	var req domain.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token and new_password are required"})
+		return
+	}
+
+	if err := h.PasswordUsecase.ResetPassword(c.Request.Context(), req.Token, req.NewPassword); err != nil {
+		if errors.Is(err, usecase.ErrInvalidResetToken) || errors.Is(err, usecase.ErrInvalidPassword) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error resetting password: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset. Please log in again."})
+}
+
+// ChangePasswordHandler sets a new password for the signed in user and logs them out everywhere
+func (h *PasswordHandler) ChangePasswordHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req domain.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "current_password and new_password are required"})
+		return
+	}
+
+	if err := h.PasswordUsecase.ChangePassword(c.Request.Context(), userID, &req); err != nil {
+		if errors.Is(err, usecase.ErrInvalidPassword) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, usecase.ErrIncorrectPassword) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		var lockedErr *usecase.LoginLockedError
+		if errors.As(err, &lockedErr) {
+			respondLocked(c, lockedErr)
+			return
+		}
+		log.Printf("Error changing password of user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been changed. Please log in again."})
+}
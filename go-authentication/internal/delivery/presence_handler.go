@@ -0,0 +1,49 @@
+package delivery
+
+import (
+	"errors"
+	"go-authentication/internal/usecase"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PresenceHandler handles HTTP requests for the online presence of users
+type PresenceHandler struct {
+	PresenceUsecase *usecase.PresenceUsecase
+}
+
+// NewPresenceHandler creates a new instance of PresenceHandler
+func NewPresenceHandler(presenceUsecase *usecase.PresenceUsecase) *PresenceHandler {
+	return &PresenceHandler{PresenceUsecase: presenceUsecase}
+}
+
+// GetHandler returns whether a user is online, away or offline and when they were last seen
+func (h *PresenceHandler) GetHandler(c *gin.Context) {
+	viewerID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	presence, err := h.PresenceUsecase.GetPresence(c.Request.Context(), viewerID, userID)
+	if err != nil {
+		if errors.Is(err, usecase.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error getting presence of user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get presence"})
+		return
+	}
+
+	c.JSON(http.StatusOK, presence)
+}
@@ -0,0 +1,67 @@
+package delivery
+
+import (
+	"errors"
+	"go-authentication/internal/usecase"
+	"go-authentication/pkg"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionHandler handles HTTP requests for listing and ending login sessions
+type SessionHandler struct {
+	SessionUsecase *usecase.SessionUsecase
+}
+
+// NewSessionHandler creates a new instance of SessionHandler
+func NewSessionHandler(sessionUsecase *usecase.SessionUsecase) *SessionHandler {
+	return &SessionHandler{SessionUsecase: sessionUsecase}
+}
+
+// ListHandler lists the devices the user is logged in on
+func (h *SessionHandler) ListHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	claims, ok := getClaims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	sessions, err := h.SessionUsecase.List(c.Request.Context(), userID, pkg.SessionID(claims))
+	if err != nil {
+		log.Printf("Error listing sessions for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// RevokeHandler logs one of the user's devices out
+func (h *SessionHandler) RevokeHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	sessionID := c.Param("id")
+	if err := h.SessionUsecase.Revoke(c.Request.Context(), userID, sessionID); err != nil {
+		if errors.Is(err, usecase.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error revoking session %s for user %d: %v", sessionID, userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
@@ -9,26 +9,33 @@ import (
 	"log"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"github.com/nats-io/nats.go"
 )
 
 // WebSocketHandler handles WebSocket connections for real-time chat
 type WebSocketHandler struct {
-	ChatUsecase *usecase.ChatUsecase
-	// Track active connections
-	clients    map[int]*pkg.Client
+	ChatUsecase     *usecase.ChatUsecase
+	TypingUsecase   *usecase.TypingUsecase
+	PresenceUsecase *usecase.PresenceUsecase
+	// Track active connections; a user may be connected from several devices
+	clients    map[int]map[*pkg.Client]bool
 	clientsMux sync.RWMutex
 	// WebSocket upgrader
 	upgrader websocket.Upgrader
 }
 
-// NewWebSocketHandler creates a new instance of WebSocketHandler
-func NewWebSocketHandler(chatUsecase *usecase.ChatUsecase) *WebSocketHandler {
-	return &WebSocketHandler{
-		ChatUsecase: chatUsecase,
-		clients:     make(map[int]*pkg.Client),
+// NewWebSocketHandler creates a new instance of WebSocketHandler.
+// Connections are closed as soon as the session they were opened with ends.
+func NewWebSocketHandler(chatUsecase *usecase.ChatUsecase, typingUsecase *usecase.TypingUsecase, presenceUsecase *usecase.PresenceUsecase, sessionUsecase *usecase.SessionUsecase) *WebSocketHandler {
+	h := &WebSocketHandler{
+		ChatUsecase:     chatUsecase,
+		TypingUsecase:   typingUsecase,
+		PresenceUsecase: presenceUsecase,
+		clients:         make(map[int]map[*pkg.Client]bool),
 		upgrader: websocket.Upgrader{
 			ReadBufferSize:  1024,
 			WriteBufferSize: 1024,
@@ -38,6 +45,12 @@ func NewWebSocketHandler(chatUsecase *usecase.ChatUsecase) *WebSocketHandler {
 			},
 		},
 	}
+
+	if err := sessionUsecase.SubscribeToRevocations(h.closeRevokedSessions); err != nil {
+		log.Printf("Error subscribing to session revocations: %v", err)
+	}
+
+	return h
 }
 
 // HandleWebSocket upgrades the HTTP connection to WebSocket
@@ -70,39 +83,87 @@ func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 
 	// Create client
 	client := pkg.NewClient(conn, userID)
+	if claims, ok := getClaims(c); ok {
+		client.SessionID = pkg.SessionID(claims)
+	}
+	if client.ConnectionID, err = pkg.GenerateOpaqueToken(16); err != nil {
+		log.Printf("Error generating connection ID: %v", err)
+		conn.Close()
+		return
+	}
 
 	// Register client
 	h.registerClient(client)
 
-	// Subscribe to messages sent TO this user
-	err = h.ChatUsecase.SubscribeToMessages(userID, func(msg *domain.Message) {
-		// Send received message to client
-		msgData, _ := json.Marshal(msg)
-		client.Send <- pkg.WebSocketMessage{
-			Type: "message_received",
-			Data: msgData,
+	// Subscriptions end with the connection, see handleClientConnection
+	var subscriptions []*nats.Subscription
+	subscribe := func(kind string, sub *nats.Subscription, err error) {
+		if err != nil {
+			log.Printf("Error subscribing to %s: %v", kind, err)
+			return
 		}
-	})
-	if err != nil {
-		log.Printf("Error subscribing to received messages: %v", err)
+		subscriptions = append(subscriptions, sub)
 	}
 
-	// Subscribe to messages sent BY this user
-	err = h.ChatUsecase.SubscribeToSentMessages(userID, func(msg *domain.Message) {
-		// Send sent message to client
+	// Subscribe to the messages of every conversation of this user, including the ones they send.
+	// Muted conversations are not pushed.
+	sub, err := h.ChatUsecase.SubscribeToMessages(userID, func(msg *domain.Message) {
+		if !h.ChatUsecase.ShouldNotify(context.Background(), userID, msg) {
+			return
+		}
 		msgData, _ := json.Marshal(msg)
-		client.Send <- pkg.WebSocketMessage{
+		frame := pkg.WebSocketMessage{
 			Type: "message_sent",
 			Data: msgData,
 		}
+		if msg.SenderID != userID {
+			frame.Type = "message_received"
+			// Reaching any device of the recipient is the delivery
+			frame.Delivered = func() {
+				if err := h.ChatUsecase.MarkDelivered(context.Background(), userID, msg); err != nil {
+					log.Printf("Error marking message %d delivered to user %d: %v", msg.ID, userID, err)
+				}
+			}
+		}
+		push(client, frame)
 	})
-	if err != nil {
-		log.Printf("Error subscribing to sent messages: %v", err)
-	}
+	subscribe("messages", sub, err)
+
+	// Receipts of the messages this user sent arrive as message_delivered and message_read
+	sub, err = h.ChatUsecase.SubscribeToReceipts(userID, func(event *domain.ReceiptEvent) {
+		eventData, _ := json.Marshal(event)
+		push(client, pkg.WebSocketMessage{
+			Type: event.Type,
+			Data: eventData,
+		})
+	})
+	subscribe("receipts", sub, err)
 
-	// Start client handlers
+	// Other members typing arrive as typing_start and typing_stop
+	sub, err = h.TypingUsecase.SubscribeToTyping(userID, func(event *domain.TypingEvent) {
+		eventData, _ := json.Marshal(event)
+		push(client, pkg.WebSocketMessage{
+			Type: event.Type,
+			Data: eventData,
+		})
+	})
+	subscribe("typing indicators", sub, err)
+
+	// Contacts going online, away or offline arrive as presence
+	sub, err = h.PresenceUsecase.SubscribeToPresence(userID, func(presence *domain.Presence) {
+		presenceData, _ := json.Marshal(presence)
+		push(client, pkg.WebSocketMessage{
+			Type: "presence",
+			Data: presenceData,
+		})
+	})
+	subscribe("presence", sub, err)
+
+	// Start client handlers; the read loop reports status changes and closes the channel when it ends
+	statusChanges := make(chan string, 1)
 	go h.handleMessages(client)
-	go h.handleClientConnection(client)
+	go h.keepPresence(client, statusChanges)
+	go h.handleClientConnection(client, statusChanges, subscriptions)
 
 	log.Printf("WebSocket client connected: %d", userID)
 }
@@ -111,7 +172,10 @@ func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 func (h *WebSocketHandler) registerClient(client *pkg.Client) {
 	h.clientsMux.Lock()
 	defer h.clientsMux.Unlock()
-	h.clients[client.ID] = client
+	if h.clients[client.ID] == nil {
+		h.clients[client.ID] = make(map[*pkg.Client]bool)
+	}
+	h.clients[client.ID][client] = true
 	log.Printf("Client connected: %d", client.ID)
 }
 
@@ -119,27 +183,110 @@ func (h *WebSocketHandler) registerClient(client *pkg.Client) {
 func (h *WebSocketHandler) unregisterClient(client *pkg.Client) {
 	h.clientsMux.Lock()
 	defer h.clientsMux.Unlock()
-	if _, ok := h.clients[client.ID]; ok {
-		delete(h.clients, client.ID)
+	if h.clients[client.ID][client] {
+		delete(h.clients[client.ID], client)
+		if len(h.clients[client.ID]) == 0 {
+			delete(h.clients, client.ID)
+		}
 		client.Conn.Close()
 		log.Printf("Client disconnected: %d", client.ID)
 	}
 }
 
-// handleMessages handles sending messages to the client
+// closeRevokedSessions closes the connections of an ended session, or of every session of the user.
+// Closing the connection ends its read loop, which unregisters the client.
+func (h *WebSocketHandler) closeRevokedSessions(event *domain.SessionRevokedEvent) {
+	h.clientsMux.RLock()
+	defer h.clientsMux.RUnlock()
+
+	closeMessage := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "session revoked")
+	for client := range h.clients[event.UserID] {
+		if event.SessionID != "" && client.SessionID != event.SessionID {
+			continue
+		}
+		client.Conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(time.Second))
+		client.Conn.Close()
+		log.Printf("Closed WebSocket of user %d after session revocation", client.ID)
+	}
+}
+
+// handleMessages handles sending messages to the client until the connection is done.
+// After a failed write it keeps draining Send so that no sender blocks on a full buffer.
 func (h *WebSocketHandler) handleMessages(client *pkg.Client) {
-	for message := range client.Send {
-		err := client.Conn.WriteJSON(message)
-		if err != nil {
-			log.Printf("Error sending message to client %d: %v", client.ID, err)
-			client.Conn.Close()
-			break
+	failed := false
+	for {
+		select {
+		case <-client.Done:
+			return
+		case message := <-client.Send:
+			if failed {
+				continue
+			}
+			if err := client.Conn.WriteJSON(message); err != nil {
+				log.Printf("Error sending message to client %d: %v", client.ID, err)
+				client.Conn.Close()
+				failed = true
+				continue
+			}
+			if message.Delivered != nil {
+				message.Delivered()
+			}
+		}
+	}
+}
+
+// keepPresence sends the heartbeats of a connection, with the latest status its client reported,
+// until the read loop closes statusChanges
+func (h *WebSocketHandler) keepPresence(client *pkg.Client, statusChanges <-chan string) {
+	ticker := time.NewTicker(h.PresenceUsecase.HeartbeatInterval())
+	defer ticker.Stop()
+
+	status := domain.PresenceOnline
+	for {
+		if err := h.PresenceUsecase.Heartbeat(context.Background(), client.ID, client.ConnectionID, status); err != nil {
+			log.Printf("Error renewing presence of user %d: %v", client.ID, err)
+		}
+
+		select {
+		case next, ok := <-statusChanges:
+			if !ok {
+				if err := h.PresenceUsecase.Disconnect(context.Background(), client.ID, client.ConnectionID); err != nil {
+					log.Printf("Error clearing presence of user %d: %v", client.ID, err)
+				}
+				return
+			}
+			status = next
+		case <-ticker.C:
 		}
 	}
 }
 
-// handleClientConnection handles reading messages from the client
-func (h *WebSocketHandler) handleClientConnection(client *pkg.Client) {
+// Types of the frames a client sends besides typing_start and typing_stop.
+// Frames without a type are message requests too, as sent by older clients.
+const (
+	frameSendMessage = "send_message"
+	framePresence    = "presence"
+)
+
+// handleClientConnection handles reading frames from the client.
+// When the connection ends it unsubscribes it and stops its writer.
+func (h *WebSocketHandler) handleClientConnection(client *pkg.Client, statusChanges chan<- string, subscriptions []*nats.Subscription) {
+	defer close(statusChanges)
+	defer close(client.Done)
+	defer func() {
+		for _, sub := range subscriptions {
+			if err := sub.Unsubscribe(); err != nil {
+				log.Printf("Error unsubscribing client %d from %s: %v", client.ID, sub.Subject, err)
+			}
+		}
+	}()
+	// Conversations this connection is typing in; they stop when it goes away
+	typingIn := make(map[int]bool)
+	defer func() {
+		for conversationID := range typingIn {
+			h.TypingUsecase.StopTyping(client.ID, conversationID)
+		}
+	}()
 	defer h.unregisterClient(client)
 
 	for {
@@ -151,30 +298,86 @@ func (h *WebSocketHandler) handleClientConnection(client *pkg.Client) {
 			break
 		}
 
-		// Parse message
-		var msgReq domain.MessageRequest
-		if err := json.Unmarshal(message, &msgReq); err != nil {
+		// Parse frame
+		var frame pkg.WebSocketMessage
+		if err := json.Unmarshal(message, &frame); err != nil {
 			log.Printf("Error parsing message: %v", err)
 			continue
 		}
-
-		// Send message through NATS
-		msg := &domain.Message{
-			SenderID:   client.ID,
-			ReceiverID: msgReq.ReceiverID,
-			Content:    msgReq.Content,
+		if frame.Type == "" {
+			frame = pkg.WebSocketMessage{Type: frameSendMessage, Data: message}
 		}
 
-		_, err = h.ChatUsecase.SendMessage(context.Background(), msg.SenderID, msg.ReceiverID, msg.Content)
-		if err != nil {
-			log.Printf("Error sending message: %v", err)
-			// Send error message back to client
-			errorData, _ := json.Marshal(map[string]string{"error": err.Error()})
-			errorMsg := pkg.WebSocketMessage{
-				Type: "error",
-				Data: errorData,
+		switch frame.Type {
+		case frameSendMessage:
+			var msgReq domain.MessageRequest
+			if err := json.Unmarshal(frame.Data, &msgReq); err != nil {
+				sendError(client, "invalid send_message frame")
+				continue
 			}
-			client.Send <- errorMsg
+
+			// Send message to the receiver or the conversation; NATS delivers it to every member
+			sent, err := h.ChatUsecase.Send(context.Background(), client.ID, &msgReq)
+			if err != nil {
+				log.Printf("Error sending message: %v", err)
+				sendError(client, err.Error())
+				continue
+			}
+
+			// The message ends the typing indicator that announced it
+			delete(typingIn, sent.ConversationID)
+			h.TypingUsecase.StopTyping(client.ID, sent.ConversationID)
+
+		case domain.TypingEventStart, domain.TypingEventStop:
+			var typingReq domain.TypingRequest
+			if err := json.Unmarshal(frame.Data, &typingReq); err != nil || typingReq.ConversationID <= 0 {
+				sendError(client, frame.Type+" needs a conversation_id")
+				continue
+			}
+
+			if frame.Type == domain.TypingEventStop {
+				delete(typingIn, typingReq.ConversationID)
+				h.TypingUsecase.StopTyping(client.ID, typingReq.ConversationID)
+				continue
+			}
+			if _, err := h.TypingUsecase.StartTyping(context.Background(), client.ID, typingReq.ConversationID); err != nil {
+				log.Printf("Error relaying typing of user %d: %v", client.ID, err)
+				sendError(client, err.Error())
+				continue
+			}
+			typingIn[typingReq.ConversationID] = true
+
+		case framePresence:
+			var presenceReq domain.PresenceRequest
+			if err := json.Unmarshal(frame.Data, &presenceReq); err != nil {
+				sendError(client, "invalid presence frame")
+				continue
+			}
+			if err := domain.ValidatePresenceStatus(presenceReq.Status); err != nil {
+				sendError(client, err.Error())
+				continue
+			}
+			statusChanges <- presenceReq.Status
+
+		default:
+			sendError(client, "unknown frame type: "+frame.Type)
 		}
 	}
 }
+
+// sendError sends an error frame back to the client
+func sendError(client *pkg.Client, message string) {
+	errorData, _ := json.Marshal(map[string]string{"error": message})
+	push(client, pkg.WebSocketMessage{
+		Type: "error",
+		Data: errorData,
+	})
+}
+
+// push queues a frame for the client, dropping it once the connection is done
+func push(client *pkg.Client, message pkg.WebSocketMessage) {
+	select {
+	case client.Send <- message:
+	case <-client.Done:
+	}
+}
@@ -0,0 +1,49 @@
+package domain
+
+import "time"
+
+// APIKey is a long-lived credential a user creates for bots and integrations.
+// Only the public prefix and the SHA-256 hash of the full key are stored.
+type APIKey struct {
+	ID     int    `json:"id"`
+	UserID int    `json:"user_id"`
+	Name   string `json:"name"`
+	Prefix string `json:"prefix"`
+	// KeyHash is never exposed
+	KeyHash string `json:"-"`
+	// Scopes are the permissions the key may use, limited to those of its owner
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// IsActive reports whether the key can still authenticate requests
+func (k *APIKey) IsActive(now time.Time) bool {
+	return k.RevokedAt == nil && now.Before(k.ExpiresAt)
+}
+
+// CreatedAPIKey is returned once when a key is created; Key is never shown again
+type CreatedAPIKey struct {
+	*APIKey
+	Key string `json:"key"`
+}
+
+// CreateAPIKeyRequest is used for creating an API key
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name" binding:"required"`
+	Scopes []string `json:"scopes"`
+	// ExpiresInDays defaults to 90 days
+	ExpiresInDays int `json:"expires_in_days"`
+}
+
+// Principal is the authenticated identity behind an API key
+type Principal struct {
+	UserID        int
+	Email         string
+	EmailVerified bool
+	Roles         []string
+	Permissions   []string
+	APIKeyID      int
+}
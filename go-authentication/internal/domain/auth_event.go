@@ -0,0 +1,88 @@
+package domain
+
+import "time"
+
+// Auth event types recorded in the audit log
+const (
+	AuthEventSignup                   = "signup"
+	AuthEventEmailVerified            = "email_verified"
+	AuthEventLoginSucceeded           = "login_succeeded"
+	AuthEventLoginFailed              = "login_failed"
+	AuthEventLogout                   = "logout"
+	AuthEventLogoutAll                = "logout_all"
+	AuthEventSessionRevoked           = "session_revoked"
+	AuthEventRefreshTokenReused       = "refresh_token_reused"
+	AuthEventPasswordResetRequested   = "password_reset_requested"
+	AuthEventPasswordChanged          = "password_changed"
+	AuthEventEmailChangeRequested     = "email_change_requested"
+	AuthEventEmailChanged             = "email_changed"
+	AuthEventMFAEnabled               = "mfa_enabled"
+	AuthEventMFADisabled              = "mfa_disabled"
+	AuthEventRecoveryCodesRegenerated = "recovery_codes_regenerated"
+	AuthEventAPIKeyCreated            = "api_key_created"
+	AuthEventAPIKeyRevoked            = "api_key_revoked"
+	AuthEventPasskeyRegistered        = "passkey_registered"
+	AuthEventPasskeyDeleted           = "passkey_deleted"
+	AuthEventRoleAssigned             = "role_assigned"
+	AuthEventRoleRemoved              = "role_removed"
+	AuthEventAccountUnlocked          = "account_unlocked"
+	AuthEventOAuthConsentGranted      = "oauth_consent_granted"
+	AuthEventOAuthConsentRevoked      = "oauth_consent_revoked"
+)
+
+// Login methods recorded with login_succeeded events
+const (
+	LoginMethodPassword = "password"
+	LoginMethodMFA      = "mfa"
+	LoginMethodPasskey  = "passkey"
+	LoginMethodOIDC     = "oidc"
+)
+
+// AuthEvent is an entry of the append-only audit log.
+// UserID is the account the event is about, and nil for failed logins with an unknown email.
+// ActorID is who caused it when that is someone else, e.g. an admin assigning a role.
+type AuthEvent struct {
+	ID        int64                  `json:"id"`
+	Type      string                 `json:"type"`
+	UserID    *int                   `json:"user_id,omitempty"`
+	ActorID   *int                   `json:"actor_id,omitempty"`
+	IP        string                 `json:"ip,omitempty"`
+	UserAgent string                 `json:"user_agent,omitempty"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// AuthEventFilter selects audit log entries, newest first. Zero fields do not filter.
+type AuthEventFilter struct {
+	UserID *int
+	Types  []string
+	IP     string
+	Since  time.Time
+	Until  time.Time
+	// Before is the ID of the last event of the previous page
+	Before int64
+	Limit  int
+}
+
+// SecurityEventsQuery is the query string of a user's own security events
+type SecurityEventsQuery struct {
+	Before int64 `form:"before"`
+	Limit  int   `form:"limit"`
+}
+
+// AuthEventQuery is the query string of the admin audit log endpoint. type is a comma separated list.
+type AuthEventQuery struct {
+	UserID *int      `form:"user_id"`
+	Type   string    `form:"type"`
+	IP     string    `form:"ip"`
+	Since  time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+	Until  time.Time `form:"until" time_format:"2006-01-02T15:04:05Z07:00"`
+	Before int64     `form:"before"`
+	Limit  int       `form:"limit"`
+}
+
+// AuthEventPage is one page of audit log entries. NextBefore is set when more entries may follow.
+type AuthEventPage struct {
+	Events     []AuthEvent `json:"events"`
+	NextBefore int64       `json:"next_before,omitempty"`
+}
@@ -0,0 +1,14 @@
+package domain
+
+import "time"
+
+// BlockedUser is an account the signed in user has blocked
+type BlockedUser struct {
+	PublicProfile
+	BlockedAt time.Time `json:"blocked_at"`
+}
+
+// BlockRequest is the body of POST /me/blocks
+type BlockRequest struct {
+	UserID int `json:"user_id" binding:"required"`
+}
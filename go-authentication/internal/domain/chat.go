@@ -5,29 +5,129 @@ import (
 	"time"
 )
 
-// Message represents a chat message between users
+const (
+	ConversationTypeDirect = "direct"
+	ConversationTypeGroup  = "group"
+)
+
+// Roles of group members. Owners and admins manage the group; only the owner changes roles.
+const (
+	ConversationRoleOwner  = "owner"
+	ConversationRoleAdmin  = "admin"
+	ConversationRoleMember = "member"
+)
+
+// Message represents a chat message posted to a conversation
 type Message struct {
-	ID         int       `json:"id"`
-	SenderID   int       `json:"sender_id"`
-	ReceiverID int       `json:"receiver_id"`
-	Content    string    `json:"content"`
-	CreatedAt  time.Time `json:"created_at"`
-	IsSent     bool      `json:"is_sent"` // true if the current user sent this message
+	ID             int       `json:"id"`
+	ConversationID int       `json:"conversation_id"`
+	SenderID       int       `json:"sender_id"`
+	ReceiverID     int       `json:"receiver_id,omitempty"` // only set on direct messages
+	Content        string    `json:"content"`
+	CreatedAt      time.Time `json:"created_at"`
+	IsSent         bool      `json:"is_sent"` // true if the current user sent this message
+	// Receipts of the other members; only listed on the current user's own messages
+	Receipts []MessageReceipt `json:"receipts,omitempty"`
 }
 
-// Conversation represents a chat conversation between two users
+// MessageReceipt is when a message reached one of a recipient's devices and when they read it
+type MessageReceipt struct {
+	MessageID   int        `json:"message_id"`
+	UserID      int        `json:"user_id"`
+	DeliveredAt time.Time  `json:"delivered_at"`
+	ReadAt      *time.Time `json:"read_at,omitempty"`
+}
+
+// Receipt event types, pushed to the sender of the messages
+const (
+	ReceiptEventDelivered = "message_delivered"
+	ReceiptEventRead      = "message_read"
+)
+
+// ReceiptEvent tells a sender that UserID received MessageID or, for message_read,
+// read every message of the conversation up to MessageID
+type ReceiptEvent struct {
+	Type           string    `json:"type"`
+	ConversationID int       `json:"conversation_id"`
+	MessageID      int       `json:"message_id"`
+	UserID         int       `json:"user_id"`
+	At             time.Time `json:"at"`
+}
+
+// MarkReadRequest is the body of POST /conversations/:id/read
+type MarkReadRequest struct {
+	UpToMessageID int `json:"up_to_message_id" binding:"required"`
+}
+
+// Conversation is a direct chat between two users or a named group of any number of members
 type Conversation struct {
-	ID          int       `json:"id"`
-	User1ID     int       `json:"user1_id"`
-	User2ID     int       `json:"user2_id"`
-	LastMessage string    `json:"last_message"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          int                  `json:"id"`
+	Type        string               `json:"type"`
+	Name        string               `json:"name,omitempty"`
+	LastMessage string               `json:"last_message"`
+	CreatedAt   time.Time            `json:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+	Members     []ConversationMember `json:"members,omitempty"`
+}
+
+// IsGroup reports whether the conversation is a group rather than a direct chat
+func (c *Conversation) IsGroup() bool {
+	return c.Type == ConversationTypeGroup
+}
+
+// ConversationMember is a user taking part in a conversation
+type ConversationMember struct {
+	UserID            int       `json:"user_id"`
+	Name              string    `json:"name"`
+	DisplayName       string    `json:"display_name"`
+	Role              string    `json:"role"`
+	LastReadMessageID int       `json:"last_read_message_id"`
+	JoinedAt          time.Time `json:"joined_at"`
+}
+
+// CanManage reports whether the member may invite and remove members and rename the group
+func (m *ConversationMember) CanManage() bool {
+	return m.Role == ConversationRoleOwner || m.Role == ConversationRoleAdmin
+}
+
+// CreateGroupRequest is the body of POST /chat/conversations
+type CreateGroupRequest struct {
+	Name      string `json:"name" binding:"required"`
+	MemberIDs []int  `json:"member_ids"`
+}
+
+// RenameConversationRequest is the body of PATCH /chat/conversations/:id
+type RenameConversationRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// InviteMembersRequest is the body of POST /chat/conversations/:id/members
+type InviteMembersRequest struct {
+	UserIDs []int `json:"user_ids" binding:"required"`
+}
+
+// UpdateMemberRoleRequest is the body of PATCH /chat/conversations/:id/members/:user_id
+type UpdateMemberRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// ConversationMute silences real-time notifications of a conversation for one of its participants
+type ConversationMute struct {
+	ConversationID int       `json:"conversation_id"`
+	MutedAt        time.Time `json:"muted_at"`
+}
+
+// MuteRequest is the body of POST /chat/mutes
+type MuteRequest struct {
+	ConversationID int `json:"conversation_id" binding:"required"`
 }
 
-// MessageRequest is used for receiving message data from clients
+// MessageRequest is used for receiving message data from clients.
+// A message goes either to a user, in their direct conversation, or to a conversation by ID.
 type MessageRequest struct {
-	ReceiverID int    `json:"receiver_id" binding:"required"`
-	Content    string `json:"content" binding:"required"`
+	ReceiverID     int    `json:"receiver_id"`
+	ConversationID int    `json:"conversation_id"`
+	Content        string `json:"content" binding:"required"`
 }
 
 // ValidateMessage validates the message content
@@ -0,0 +1,32 @@
+package domain
+
+import "context"
+
+type contextKey int
+
+const (
+	clientInfoContextKey contextKey = iota
+	actorContextKey
+)
+
+// ContextWithClientInfo attaches the client a request came from to ctx
+func ContextWithClientInfo(ctx context.Context, client ClientInfo) context.Context {
+	return context.WithValue(ctx, clientInfoContextKey, client)
+}
+
+// ClientInfoFromContext returns the client attached by ContextWithClientInfo, or a zero ClientInfo
+func ClientInfoFromContext(ctx context.Context) ClientInfo {
+	client, _ := ctx.Value(clientInfoContextKey).(ClientInfo)
+	return client
+}
+
+// ContextWithActor attaches the authenticated user making a request to ctx
+func ContextWithActor(ctx context.Context, userID int) context.Context {
+	return context.WithValue(ctx, actorContextKey, userID)
+}
+
+// ActorFromContext returns the user attached by ContextWithActor
+func ActorFromContext(ctx context.Context) (int, bool) {
+	userID, ok := ctx.Value(actorContextKey).(int)
+	return userID, ok
+}
@@ -0,0 +1,30 @@
+package domain
+
+import "time"
+
+// UserIdentity links an account at an external OpenID Connect provider to a local user
+type UserIdentity struct {
+	ID          int        `json:"id"`
+	UserID      int        `json:"user_id"`
+	Provider    string     `json:"provider"`
+	Subject     string     `json:"subject"`
+	Email       string     `json:"email"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+}
+
+// OIDCLoginState is the server side half of an OIDC login in progress.
+// Only the SHA-256 hash of the state parameter is stored.
+type OIDCLoginState struct {
+	StateHash    string
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    time.Time
+	CreatedAt    time.Time
+}
+
+// OIDCAuthorization is where to send the browser to sign in with the provider
+type OIDCAuthorization struct {
+	URL   string
+	State string
+}
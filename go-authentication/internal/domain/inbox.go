@@ -0,0 +1,44 @@
+package domain
+
+import "time"
+
+// InboxQuery is the query string of GET /conversations
+type InboxQuery struct {
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit"`
+}
+
+// InboxFilter selects the conversations of UserID, most recently active first.
+// Results come after the (BeforeUpdatedAt, BeforeID) position of the previous page when BeforeID is set.
+type InboxFilter struct {
+	UserID          int
+	BeforeUpdatedAt time.Time
+	BeforeID        int
+	Limit           int
+}
+
+// MessagePreview is the start of the last message of a conversation
+type MessagePreview struct {
+	ID        int       `json:"id"`
+	SenderID  int       `json:"sender_id"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// InboxEntry is a conversation as listed in the inbox of one of its members
+type InboxEntry struct {
+	ID          int             `json:"id"`
+	Type        string          `json:"type"`
+	Name        string          `json:"name,omitempty"`
+	OtherUser   *PublicProfile  `json:"other_user,omitempty"` // the other participant of a direct conversation
+	LastMessage *MessagePreview `json:"last_message,omitempty"`
+	UnreadCount int             `json:"unread_count"`
+	Muted       bool            `json:"muted"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// InboxPage is one page of the inbox. NextCursor is set when more conversations may follow.
+type InboxPage struct {
+	Conversations []InboxEntry `json:"conversations"`
+	NextCursor    string       `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,41 @@
+package domain
+
+import "time"
+
+// ClientInfo describes where a request came from
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+// LoginThrottle tracks recent failed logins for one key, either an account ("email:<address>") or a client IP ("ip:<address>")
+type LoginThrottle struct {
+	Key           string     `json:"key"`
+	Failures      int        `json:"failures"`
+	LastFailureAt *time.Time `json:"last_failure_at,omitempty"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
+}
+
+// IsLocked reports whether logins for the key are refused at now
+func (t *LoginThrottle) IsLocked(now time.Time) bool {
+	return t.LockedUntil != nil && now.Before(*t.LockedUntil)
+}
+
+// Lockout event actions
+const (
+	LockoutActionLocked   = "locked"
+	LockoutActionUnlocked = "unlocked"
+)
+
+// LockoutEvent records an account or IP being locked out or unlocked
+type LockoutEvent struct {
+	ID          int        `json:"id"`
+	Key         string     `json:"key"`
+	UserID      *int       `json:"user_id,omitempty"`
+	Action      string     `json:"action"`
+	Failures    int        `json:"failures"`
+	IP          string     `json:"ip,omitempty"`
+	UserAgent   string     `json:"user_agent,omitempty"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
@@ -0,0 +1,168 @@
+package domain
+
+import "time"
+
+// Grant types supported by the token endpoint
+const (
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeClientCredentials = "client_credentials"
+	GrantTypeRefreshToken      = "refresh_token"
+)
+
+// ScopeOfflineAccess asks for a refresh token in the authorization code grant
+const ScopeOfflineAccess = "offline_access"
+
+// OAuthClient is a third-party application registered to obtain tokens for our users.
+// Confidential clients authenticate with a secret; only its SHA-256 hash is stored.
+type OAuthClient struct {
+	ID           int        `json:"id"`
+	ClientID     string     `json:"client_id"`
+	Name         string     `json:"name"`
+	SecretHash   string     `json:"-"`
+	RedirectURIs []string   `json:"redirect_uris"`
+	GrantTypes   []string   `json:"grant_types"`
+	Scopes       []string   `json:"scopes"`
+	Confidential bool       `json:"confidential"`
+	CreatedBy    int        `json:"created_by"`
+	CreatedAt    time.Time  `json:"created_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+}
+
+// AllowsGrant reports whether the client was registered for a grant type
+func (c *OAuthClient) AllowsGrant(grantType string) bool {
+	for _, allowed := range c.GrantTypes {
+		if allowed == grantType {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsRedirectURI reports whether uri exactly matches one of the registered redirect URIs
+func (c *OAuthClient) AllowsRedirectURI(uri string) bool {
+	for _, allowed := range c.RedirectURIs {
+		if allowed == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// RegisteredOAuthClient is returned once when a client is registered; ClientSecret is never shown again
+type RegisteredOAuthClient struct {
+	*OAuthClient
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// CreateOAuthClientRequest is used for registering an OAuth client
+type CreateOAuthClientRequest struct {
+	Name         string   `json:"name" binding:"required"`
+	RedirectURIs []string `json:"redirect_uris"`
+	// GrantTypes defaults to the authorization code grant
+	GrantTypes []string `json:"grant_types"`
+	Scopes     []string `json:"scopes"`
+	// Confidential clients receive a secret; public clients such as mobile apps rely on PKCE alone
+	Confidential bool `json:"confidential"`
+}
+
+// OAuthAuthorizationCode is a single-use code redeemed by the client at the token endpoint.
+// Only its hash is stored, together with the PKCE challenge it must be redeemed with.
+type OAuthAuthorizationCode struct {
+	CodeHash      string
+	ClientID      string
+	UserID        int
+	RedirectURI   string
+	Scopes        []string
+	CodeChallenge string
+	ExpiresAt     time.Time
+	CreatedAt     time.Time
+}
+
+// OAuthConsent records the scopes a user allowed a client to access
+type OAuthConsent struct {
+	UserID     int       `json:"user_id"`
+	ClientID   string    `json:"client_id"`
+	ClientName string    `json:"client_name"`
+	Scopes     []string  `json:"scopes"`
+	GrantedAt  time.Time `json:"granted_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// OAuthRefreshToken is a refresh token issued to a client on behalf of a user.
+// It is rotated on every use; presenting a rotated token revokes every token of the grant.
+type OAuthRefreshToken struct {
+	ID        int
+	TokenHash string
+	ClientID  string
+	UserID    int
+	Scopes    []string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	RotatedAt *time.Time
+	RevokedAt *time.Time
+}
+
+// IsUsable reports whether the refresh token can still be exchanged
+func (t *OAuthRefreshToken) IsUsable(now time.Time) bool {
+	return t.RotatedAt == nil && t.RevokedAt == nil && now.Before(t.ExpiresAt)
+}
+
+// AuthorizationRequest holds the parameters of an authorization request (RFC 6749 section 4.1.1, RFC 7636)
+type AuthorizationRequest struct {
+	ResponseType        string `form:"response_type" json:"response_type"`
+	ClientID            string `form:"client_id" json:"client_id"`
+	RedirectURI         string `form:"redirect_uri" json:"redirect_uri"`
+	Scope               string `form:"scope" json:"scope"`
+	State               string `form:"state" json:"state"`
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method"`
+}
+
+// AuthorizationDecision is the user's answer to a consent prompt
+type AuthorizationDecision struct {
+	AuthorizationRequest
+	Approve bool `json:"approve"`
+}
+
+// AuthorizationResponse either sends the user back to the client or asks for consent first
+type AuthorizationResponse struct {
+	// RedirectTo is the client redirect URI with the code, or with an error, and the state
+	RedirectTo      string   `json:"redirect_to,omitempty"`
+	ConsentRequired bool     `json:"consent_required"`
+	ClientID        string   `json:"client_id,omitempty"`
+	ClientName      string   `json:"client_name,omitempty"`
+	Scopes          []string `json:"scopes,omitempty"`
+}
+
+// TokenRequest holds the form parameters of a token request
+type TokenRequest struct {
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	RefreshToken string `form:"refresh_token"`
+	Scope        string `form:"scope"`
+}
+
+// OAuthTokenResponse is the successful response of the token endpoint (RFC 6749 section 5.1)
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope"`
+}
+
+// IntrospectionResponse describes a token to a resource server (RFC 7662 section 2.2)
+type IntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	Iss       string `json:"iss,omitempty"`
+	Jti       string `json:"jti,omitempty"`
+}
@@ -0,0 +1,113 @@
+package domain
+
+import "time"
+
+// Passkey is a WebAuthn credential registered to a user. A user may register several, e.g. one per device.
+type Passkey struct {
+	ID     int `json:"id"`
+	UserID int `json:"user_id"`
+	// CredentialID is the base64url encoded credential ID chosen by the authenticator
+	CredentialID string `json:"credential_id"`
+	// PublicKey is the credential public key in COSE_Key format
+	PublicKey []byte `json:"-"`
+	Algorithm int64  `json:"algorithm"`
+	// SignCount is the last signature counter reported by the authenticator, or 0 if it keeps none
+	SignCount  int64      `json:"-"`
+	Name       string     `json:"name"`
+	Transports []string   `json:"transports,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// WebAuthn ceremonies a challenge can be issued for
+const (
+	PasskeyCeremonyRegistration = "registration"
+	PasskeyCeremonyLogin        = "login"
+)
+
+// WebAuthnChallenge is a single-use challenge of a passkey ceremony in progress.
+// Only the SHA-256 hash of the challenge is stored. UserID is nil for logins, where the user is not known yet.
+type WebAuthnChallenge struct {
+	ChallengeHash string
+	Ceremony      string
+	UserID        *int
+	ExpiresAt     time.Time
+	CreatedAt     time.Time
+}
+
+// PasskeyRegistrationOptions are the PublicKeyCredentialCreationOptions passed to navigator.credentials.create().
+// Binary values are base64url encoded.
+type PasskeyRegistrationOptions struct {
+	Challenge              string                        `json:"challenge"`
+	RP                     PasskeyRelyingParty           `json:"rp"`
+	User                   PasskeyUser                   `json:"user"`
+	PubKeyCredParams       []PasskeyCredentialParameters `json:"pubKeyCredParams"`
+	Timeout                int64                         `json:"timeout"`
+	ExcludeCredentials     []PasskeyCredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection PasskeyAuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                        `json:"attestation"`
+}
+
+// PasskeyLoginOptions are the PublicKeyCredentialRequestOptions passed to navigator.credentials.get().
+// No credentials are listed, so the browser offers every passkey it holds for the relying party.
+type PasskeyLoginOptions struct {
+	Challenge        string `json:"challenge"`
+	Timeout          int64  `json:"timeout"`
+	RPID             string `json:"rpId"`
+	UserVerification string `json:"userVerification"`
+}
+
+type PasskeyRelyingParty struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type PasskeyUser struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type PasskeyCredentialParameters struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+type PasskeyCredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+type PasskeyAuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// PasskeyRegistrationRequest is the PublicKeyCredential returned by navigator.credentials.create(), with a name for the passkey.
+// Binary values are base64url encoded.
+type PasskeyRegistrationRequest struct {
+	Name     string                     `json:"name"`
+	ID       string                     `json:"id" binding:"required"`
+	Response PasskeyAttestationResponse `json:"response" binding:"required"`
+}
+
+type PasskeyAttestationResponse struct {
+	ClientDataJSON    string   `json:"clientDataJSON" binding:"required"`
+	AttestationObject string   `json:"attestationObject" binding:"required"`
+	Transports        []string `json:"transports"`
+}
+
+// PasskeyLoginRequest is the PublicKeyCredential returned by navigator.credentials.get().
+// Binary values are base64url encoded.
+type PasskeyLoginRequest struct {
+	ID       string                   `json:"id" binding:"required"`
+	Response PasskeyAssertionResponse `json:"response" binding:"required"`
+}
+
+type PasskeyAssertionResponse struct {
+	ClientDataJSON    string `json:"clientDataJSON" binding:"required"`
+	AuthenticatorData string `json:"authenticatorData" binding:"required"`
+	Signature         string `json:"signature" binding:"required"`
+	UserHandle        string `json:"userHandle"`
+}
@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// Presence statuses. A user is online when any of their connections is, away when all of them
+// are idle, and offline once the last connection closed or stopped sending heartbeats.
+const (
+	PresenceOnline  = "online"
+	PresenceAway    = "away"
+	PresenceOffline = "offline"
+)
+
+// ErrInvalidPresenceStatus is returned when a client reports a status other than online or away
+var ErrInvalidPresenceStatus = errors.New("status must be online or away")
+
+// Presence is whether a user is connected and when they were last online.
+// It is also the event pushed to their contacts when the status changes.
+type Presence struct {
+	UserID   int        `json:"user_id"`
+	Status   string     `json:"status"`
+	LastSeen *time.Time `json:"last_seen,omitempty"`
+}
+
+// PresenceRequest is the data of the presence frame a client sends when it becomes idle or active again
+type PresenceRequest struct {
+	Status string `json:"status"`
+}
+
+// ValidatePresenceStatus checks a status reported by a client
+func ValidatePresenceStatus(status string) error {
+	if status != PresenceOnline && status != PresenceAway {
+		return ErrInvalidPresenceStatus
+	}
+	return nil
+}
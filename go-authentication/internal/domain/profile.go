@@ -0,0 +1,46 @@
+package domain
+
+import "time"
+
+// Profile is the signed in user's own account, returned by GET /me
+type Profile struct {
+	ID            int       `json:"id"`
+	Name          string    `json:"name"`
+	DisplayName   string    `json:"display_name"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	Bio           string    `json:"bio"`
+	StatusText    string    `json:"status_text"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// PublicProfile is what other users can see of an account
+type PublicProfile struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	StatusText  string    `json:"status_text"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// UpdateProfileRequest is the body of PATCH /me. Omitted fields are left unchanged.
+type UpdateProfileRequest struct {
+	Name        *string `json:"name"`
+	DisplayName *string `json:"display_name"`
+	Bio         *string `json:"bio"`
+	StatusText  *string `json:"status_text"`
+}
+
+// ChangePasswordRequest is the body of a password change by a signed in user
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// ChangeEmailRequest asks for a confirmation link to be sent to a new address
+type ChangeEmailRequest struct {
+	Email    string `json:"email" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
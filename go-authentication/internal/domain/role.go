@@ -0,0 +1,29 @@
+package domain
+
+// Roles every account can hold
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// Permissions granted through roles and checked by RequirePermission
+const (
+	PermissionUsersUnlock        = "users:unlock"
+	PermissionRolesAssign        = "roles:assign"
+	PermissionMessagesModerate   = "messages:moderate"
+	PermissionOAuthClientsManage = "oauth_clients:manage"
+	PermissionAuditRead          = "audit:read"
+)
+
+// UserRoles lists the roles of a user and the permissions they grant
+type UserRoles struct {
+	UserID      int      `json:"user_id"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+}
+
+// AssignRoleRequest is used for granting a role to a user
+type AssignRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
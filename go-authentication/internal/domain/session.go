@@ -0,0 +1,30 @@
+package domain
+
+import "time"
+
+// Session is one login on one device. Its ID is also the family ID of the refresh tokens the login issued
+// and the "sid" claim of its access tokens.
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     int        `json:"user_id"`
+	IP         string     `json:"ip"`
+	UserAgent  string     `json:"user_agent"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	// Current marks the session of the request that listed the sessions
+	Current bool `json:"current"`
+}
+
+// IsActive reports whether the session can still be used
+func (s *Session) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// SessionRevokedEvent announces that a session ended so live connections using it can be closed.
+// An empty SessionID means every session of the user ended.
+type SessionRevokedEvent struct {
+	UserID    int    `json:"user_id"`
+	SessionID string `json:"session_id,omitempty"`
+}
@@ -0,0 +1,24 @@
+package domain
+
+import "time"
+
+// Typing event types, used both for the frames clients send and the events relayed to the other members
+const (
+	TypingEventStart = "typing_start"
+	TypingEventStop  = "typing_stop"
+)
+
+// TypingEvent tells the members of a conversation that a user started or stopped typing.
+// It is relayed in real time only and never stored.
+type TypingEvent struct {
+	Type           string `json:"type"`
+	ConversationID int    `json:"conversation_id"`
+	UserID         int    `json:"user_id"`
+	// When a typing_start lapses unless another one follows; receivers hide the indicator by then
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// TypingRequest is the data of the typing_start and typing_stop frames a client sends
+type TypingRequest struct {
+	ConversationID int `json:"conversation_id"`
+}
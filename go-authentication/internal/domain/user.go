@@ -11,8 +11,46 @@ type User struct {
 	ID        int       `json:"id"`
 	Name      string    `json:"name" binding:"required"`
 	Email     string    `json:"email" binding:"required,email"`
-	Password  string    `json:"password" binding:"required,min=10"`
+	Password  string    `json:"password" binding:"required"`
 	CreatedAt time.Time `json:"created_at"`
+	// EmailVerifiedAt is nil until the user follows the verification link
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	DisplayName     string     `json:"display_name,omitempty"`
+	Bio             string     `json:"bio,omitempty"`
+	StatusText      string     `json:"status_text,omitempty"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// IsEmailVerified reports whether the user confirmed their email address
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// Profile returns the account as shown to the user themselves
+func (u *User) Profile() *Profile {
+	return &Profile{
+		ID:            u.ID,
+		Name:          u.Name,
+		DisplayName:   u.DisplayName,
+		Email:         u.Email,
+		EmailVerified: u.IsEmailVerified(),
+		Bio:           u.Bio,
+		StatusText:    u.StatusText,
+		CreatedAt:     u.CreatedAt,
+		UpdatedAt:     u.UpdatedAt,
+	}
+}
+
+// PublicProfile returns the account as shown to other users, without the email address or any credentials
+func (u *User) PublicProfile() *PublicProfile {
+	return &PublicProfile{
+		ID:          u.ID,
+		Name:        u.Name,
+		DisplayName: u.DisplayName,
+		Bio:         u.Bio,
+		StatusText:  u.StatusText,
+		CreatedAt:   u.CreatedAt,
+	}
 }
 
 func isValidEmail(email string) bool {
@@ -32,11 +70,6 @@ func (u *User) Validate() error {
 		return errors.New("password field is required and cannot be empty")
 	}
 
-	// Check password length
-	if len(u.Password) < 10 {
-		return errors.New("password must be at least 10 characters long")
-	}
-
 	// Validate email format
 	if !isValidEmail(u.Email) {
 		return errors.New("email must be in a valid format (e.g., user@example.com)")
@@ -44,3 +77,11 @@ func (u *User) Validate() error {
 
 	return nil
 }
+
+// ValidateEmail checks the format of an email address
+func ValidateEmail(email string) error {
+	if !isValidEmail(email) {
+		return errors.New("email must be in a valid format (e.g., user@example.com)")
+	}
+	return nil
+}
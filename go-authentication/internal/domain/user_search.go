@@ -0,0 +1,30 @@
+package domain
+
+// UserSearchQuery is the query string of GET /users/search
+type UserSearchQuery struct {
+	Q      string `form:"q"`
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit"`
+}
+
+// UserSearchFilter selects users matching Query for CallerID, best match first.
+// Results come after the (AfterScore, AfterID) position of the previous page when AfterID is set.
+type UserSearchFilter struct {
+	CallerID   int
+	Query      string
+	AfterScore int
+	AfterID    int
+	Limit      int
+}
+
+// UserSearchResult is a matching user and how well it matched; higher scores are better matches
+type UserSearchResult struct {
+	PublicProfile
+	Score int `json:"-"`
+}
+
+// UserSearchPage is one page of search results. NextCursor is set when more results may follow.
+type UserSearchPage struct {
+	Users      []PublicProfile `json:"users"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"go-authentication/db"
+	"go-authentication/internal/domain"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// AuthEventRepository defines the interface for the audit log. It is append-only: events are never updated or deleted.
+type AuthEventRepository interface {
+	Create(ctx context.Context, event *domain.AuthEvent) error
+	List(ctx context.Context, filter *domain.AuthEventFilter) ([]domain.AuthEvent, error)
+}
+
+// authEventRepository implements AuthEventRepository
+type authEventRepository struct{}
+
+// NewAuthEventRepository creates a new instance of authEventRepository
+func NewAuthEventRepository() AuthEventRepository {
+	return &authEventRepository{}
+}
+
+const authEventColumns = `id, type, user_id, actor_id, ip, user_agent, metadata, created_at`
+
+func scanAuthEvent(row pgx.Row) (*domain.AuthEvent, error) {
+	var event domain.AuthEvent
+	err := row.Scan(
+		&event.ID,
+		&event.Type,
+		&event.UserID,
+		&event.ActorID,
+		&event.IP,
+		&event.UserAgent,
+		&event.Metadata,
+		&event.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (r *authEventRepository) Create(ctx context.Context, event *domain.AuthEvent) error {
+	query := `
+		INSERT INTO auth_events (type, user_id, actor_id, ip, user_agent, metadata, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`
+
+	if event.Metadata == nil {
+		event.Metadata = map[string]interface{}{}
+	}
+	event.CreatedAt = time.Now()
+	err := db.DB.QueryRow(ctx, query,
+		event.Type,
+		event.UserID,
+		event.ActorID,
+		event.IP,
+		event.UserAgent,
+		event.Metadata,
+		event.CreatedAt,
+	).Scan(&event.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record auth event: %w", err)
+	}
+
+	return nil
+}
+
+func (r *authEventRepository) List(ctx context.Context, filter *domain.AuthEventFilter) ([]domain.AuthEvent, error) {
+	var conditions []string
+	var args []interface{}
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.UserID != nil {
+		where("user_id = $%d", *filter.UserID)
+	}
+	if len(filter.Types) > 0 {
+		where("type = ANY($%d)", filter.Types)
+	}
+	if filter.IP != "" {
+		where("ip = $%d", filter.IP)
+	}
+	if !filter.Since.IsZero() {
+		where("created_at >= $%d", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		where("created_at < $%d", filter.Until)
+	}
+	if filter.Before > 0 {
+		where("id < $%d", filter.Before)
+	}
+
+	query := `SELECT ` + authEventColumns + ` FROM auth_events`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(` ORDER BY id DESC LIMIT $%d`, len(args))
+
+	rows, err := db.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list auth events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []domain.AuthEvent{}
+	for rows.Next() {
+		event, err := scanAuthEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, *event)
+	}
+
+	return events, rows.Err()
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-authentication/db"
+	"go-authentication/internal/domain"
+)
+
+// ErrBlockNotFound is returned when removing a block that does not exist
+var ErrBlockNotFound = errors.New("block not found")
+
+// BlockRepository defines the interface for the users' block lists
+type BlockRepository interface {
+	Block(ctx context.Context, blockerID, blockedID int) error
+	Unblock(ctx context.Context, blockerID, blockedID int) error
+	ListBlocked(ctx context.Context, blockerID int) ([]domain.BlockedUser, error)
+	IsBlocked(ctx context.Context, blockerID, blockedID int) (bool, error)
+}
+
+// blockRepository implements BlockRepository
+type blockRepository struct{}
+
+// NewBlockRepository creates a new instance of blockRepository
+func NewBlockRepository() BlockRepository {
+	return &blockRepository{}
+}
+
+// Block adds blockedID to the block list of blockerID; blocking twice keeps the original date
+func (r *blockRepository) Block(ctx context.Context, blockerID, blockedID int) error {
+	query := `
+		INSERT INTO user_blocks (blocker_id, blocked_id)
+		VALUES ($1, $2)
+		ON CONFLICT (blocker_id, blocked_id) DO NOTHING
+	`
+
+	if _, err := db.DB.Exec(ctx, query, blockerID, blockedID); err != nil {
+		return fmt.Errorf("failed to block user: %w", err)
+	}
+	return nil
+}
+
+func (r *blockRepository) Unblock(ctx context.Context, blockerID, blockedID int) error {
+	tag, err := db.DB.Exec(ctx, `DELETE FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2`, blockerID, blockedID)
+	if err != nil {
+		return fmt.Errorf("failed to unblock user: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrBlockNotFound
+	}
+
+	return nil
+}
+
+// ListBlocked returns the users blocked by blockerID, most recently blocked first
+func (r *blockRepository) ListBlocked(ctx context.Context, blockerID int) ([]domain.BlockedUser, error) {
+	query := `
+		SELECT u.id, u.name, u.display_name, u.bio, u.status_text, u.created_at, b.created_at
+		FROM user_blocks b
+		JOIN users u ON u.id = b.blocked_id
+		WHERE b.blocker_id = $1
+		ORDER BY b.created_at DESC, u.id
+	`
+
+	rows, err := db.DB.Query(ctx, query, blockerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list blocked users: %w", err)
+	}
+	defer rows.Close()
+
+	blocked := []domain.BlockedUser{}
+	for rows.Next() {
+		var user domain.BlockedUser
+		err := rows.Scan(
+			&user.ID,
+			&user.Name,
+			&user.DisplayName,
+			&user.Bio,
+			&user.StatusText,
+			&user.CreatedAt,
+			&user.BlockedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		blocked = append(blocked, user)
+	}
+
+	return blocked, rows.Err()
+}
+
+func (r *blockRepository) IsBlocked(ctx context.Context, blockerID, blockedID int) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2)`
+
+	var blocked bool
+	if err := db.DB.QueryRow(ctx, query, blockerID, blockedID).Scan(&blocked); err != nil {
+		return false, fmt.Errorf("failed to check block: %w", err)
+	}
+	return blocked, nil
+}
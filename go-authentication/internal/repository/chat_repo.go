@@ -2,19 +2,46 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"go-authentication/db"
 	"go-authentication/internal/domain"
 	"log"
 	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	// ErrConversationNotFound is returned when a conversation does not exist
+	ErrConversationNotFound = errors.New("conversation not found")
+	// ErrMemberNotFound is returned when a user is not a member of a conversation
+	ErrMemberNotFound = errors.New("conversation member not found")
+	// ErrMessageNotFound is returned when a message does not exist
+	ErrMessageNotFound = errors.New("message not found")
 )
 
 // ChatRepository defines the interface for chat-related operations
 type ChatRepository interface {
 	StoresMsg(ctx context.Context, message *domain.Message) error
 	GetMsgbyConvo(ctx context.Context, user1ID, user2ID int, limit, offset int) ([]*domain.Message, error)
+	GetMessagesByConversation(ctx context.Context, conversationID int, limit, offset int) ([]*domain.Message, error)
 	GetOrCreateConversation(ctx context.Context, user1ID, user2ID int) (*domain.Conversation, error)
+	CreateGroup(ctx context.Context, conversation *domain.Conversation, ownerID int, memberIDs []int) error
 	UpdateConvo(ctx context.Context, conversationID int, lastMessage string) error
+	GetConversationByID(ctx context.Context, conversationID int) (*domain.Conversation, error)
+	RenameConversation(ctx context.Context, conversationID int, name string) error
+	ListMembers(ctx context.Context, conversationID int) ([]domain.ConversationMember, error)
+	GetMember(ctx context.Context, conversationID, userID int) (*domain.ConversationMember, error)
+	AddMember(ctx context.Context, conversationID, userID int, role string) error
+	RemoveMember(ctx context.Context, conversationID, userID int) error
+	LeaveGroup(ctx context.Context, conversationID, userID int) error
+	UpdateMemberRole(ctx context.Context, conversationID, userID int, role string) error
+	MarkRead(ctx context.Context, conversationID, userID, messageID int) error
+	LatestMessageID(ctx context.Context, conversationID int) (int, error)
+	DeleteMessage(ctx context.Context, messageID int) (*domain.Message, error)
+	ListInbox(ctx context.Context, filter *domain.InboxFilter) ([]domain.InboxEntry, error)
+	ListContacts(ctx context.Context, userID int) ([]int, error)
 }
 
 // chatRepository implements ChatRepository
@@ -25,33 +52,96 @@ func NewChatRepository() ChatRepository {
 	return &chatRepository{}
 }
 
+// directConversationKey identifies the direct conversation of two users regardless of who wrote first
+func directConversationKey(user1ID, user2ID int) string {
+	if user1ID > user2ID {
+		user1ID, user2ID = user2ID, user1ID
+	}
+	return fmt.Sprintf("%d:%d", user1ID, user2ID)
+}
+
+const messageColumns = `id, conversation_id, sender_id, COALESCE(receiver_id, 0), content, created_at`
+
+func scanMessage(row pgx.Row) (*domain.Message, error) {
+	var msg domain.Message
+	err := row.Scan(
+		&msg.ID,
+		&msg.ConversationID,
+		&msg.SenderID,
+		&msg.ReceiverID,
+		&msg.Content,
+		&msg.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+const conversationColumns = `id, type, name, last_message, created_at, updated_at`
+
+func scanConversation(row pgx.Row) (*domain.Conversation, error) {
+	var conversation domain.Conversation
+	err := row.Scan(
+		&conversation.ID,
+		&conversation.Type,
+		&conversation.Name,
+		&conversation.LastMessage,
+		&conversation.CreatedAt,
+		&conversation.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &conversation, nil
+}
+
+const memberColumns = `m.user_id, u.name, u.display_name, m.role, m.last_read_message_id, m.joined_at`
+
+func scanMember(row pgx.Row) (*domain.ConversationMember, error) {
+	var member domain.ConversationMember
+	err := row.Scan(
+		&member.UserID,
+		&member.Name,
+		&member.DisplayName,
+		&member.Role,
+		&member.LastReadMessageID,
+		&member.JoinedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
 // StoresMsg stores a new message in the database
 func (r *chatRepository) StoresMsg(ctx context.Context, message *domain.Message) error {
 	log.Printf("Storing message: sender_id=%d, receiver_id=%d, content=%s",
 		message.SenderID, message.ReceiverID, message.Content)
 
-	// Check if sender and receiver exist
-	checkSenderQuery := `SELECT id FROM users WHERE id = $1`
-	checkReceiverQuery := `SELECT id FROM users WHERE id = $1`
+	// Check if sender and, for a direct message, receiver exist
+	checkUserQuery := `SELECT id FROM users WHERE id = $1`
 
 	var senderID, receiverID int
-	err := db.DB.QueryRow(ctx, checkSenderQuery, message.SenderID).Scan(&senderID)
+	err := db.DB.QueryRow(ctx, checkUserQuery, message.SenderID).Scan(&senderID)
 	if err != nil {
 		log.Printf("Error checking sender existence: %v", err)
 		return fmt.Errorf("sender not found: %w", err)
 	}
 
-	err = db.DB.QueryRow(ctx, checkReceiverQuery, message.ReceiverID).Scan(&receiverID)
-	if err != nil {
-		log.Printf("Error checking receiver existence: %v", err)
-		return fmt.Errorf("receiver not found: %w", err)
+	if message.ReceiverID != 0 {
+		err = db.DB.QueryRow(ctx, checkUserQuery, message.ReceiverID).Scan(&receiverID)
+		if err != nil {
+			log.Printf("Error checking receiver existence: %v", err)
+			return fmt.Errorf("receiver not found: %w", err)
+		}
 	}
 
-	log.Printf("Both sender and receiver exist: sender=%d, receiver=%d", senderID, receiverID)
+	log.Printf("Sender and receiver exist: sender=%d, receiver=%d", senderID, receiverID)
 
 	query := `
-		INSERT INTO messages (sender_id, receiver_id, content, created_at)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO messages (conversation_id, sender_id, receiver_id, content, created_at)
+		VALUES ($1, $2, NULLIF($3, 0), $4, $5)
 		RETURNING id
 	`
 
@@ -59,6 +149,7 @@ func (r *chatRepository) StoresMsg(ctx context.Context, message *domain.Message)
 	err = db.DB.QueryRow(
 		ctx,
 		query,
+		message.ConversationID,
 		message.SenderID,
 		message.ReceiverID,
 		message.Content,
@@ -94,7 +185,7 @@ func (r *chatRepository) GetMsgbyConvo(ctx context.Context, user1ID, user2ID int
 	log.Printf("Total messages found between users: %d", count)
 
 	query := `
-		SELECT id, sender_id, receiver_id, content, created_at
+		SELECT ` + messageColumns + `
 		FROM messages
 		WHERE (sender_id = $1 AND receiver_id = $2) OR (sender_id = $2 AND receiver_id = $1)
 		ORDER BY created_at DESC
@@ -113,14 +204,7 @@ func (r *chatRepository) GetMsgbyConvo(ctx context.Context, user1ID, user2ID int
 
 	messages := []*domain.Message{}
 	for rows.Next() {
-		msg := &domain.Message{}
-		err := rows.Scan(
-			&msg.ID,
-			&msg.SenderID,
-			&msg.ReceiverID,
-			&msg.Content,
-			&msg.CreatedAt,
-		)
+		msg, err := scanMessage(rows)
 		if err != nil {
 			log.Printf("Error scanning message row: %v", err)
 			return nil, fmt.Errorf("failed to scan message: %w", err)
@@ -139,60 +223,55 @@ func (r *chatRepository) GetMsgbyConvo(ctx context.Context, user1ID, user2ID int
 	return messages, nil
 }
 
+// GetOrCreateConversation returns the direct conversation of two users, creating it on their first message
 func (r *chatRepository) GetOrCreateConversation(ctx context.Context, user1ID, user2ID int) (*domain.Conversation, error) {
 	log.Printf("Getting or creating conversation between users %d and %d", user1ID, user2ID)
 
-	query := `
-		SELECT id, user1_id, user2_id, last_message, updated_at
-		FROM conversations
-		WHERE (user1_id = $1 AND user2_id = $2) OR (user1_id = $2 AND user2_id = $1)
-	`
-
-	conversation := &domain.Conversation{}
-	err := db.DB.QueryRow(ctx, query, user1ID, user2ID).Scan(
-		&conversation.ID,
-		&conversation.User1ID,
-		&conversation.User2ID,
-		&conversation.LastMessage,
-		&conversation.UpdatedAt,
-	)
+	key := directConversationKey(user1ID, user2ID)
+	conversation, err := scanConversation(db.DB.QueryRow(ctx, `SELECT `+conversationColumns+` FROM conversations WHERE direct_key = $1`, key))
+	if err == nil {
+		log.Printf("Found existing conversation: id=%d", conversation.ID)
+		return conversation, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("failed to get conversation: %w", err)
+	}
 
+	log.Printf("No existing conversation found, creating new one")
+	tx, err := db.DB.Begin(ctx)
 	if err != nil {
-		log.Printf("No existing conversation found, creating new one")
-		createQuery := `
-			INSERT INTO conversations (user1_id, user2_id, last_message, updated_at)
-			VALUES ($1, $2, $3, $4)
-			RETURNING id, user1_id, user2_id, last_message, updated_at
-		`
-
-		now := time.Now()
-		err = db.DB.QueryRow(
-			ctx,
-			createQuery,
-			user1ID,
-			user2ID,
-			"",
-			now,
-		).Scan(
-			&conversation.ID,
-			&conversation.User1ID,
-			&conversation.User2ID,
-			&conversation.LastMessage,
-			&conversation.UpdatedAt,
-		)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// A concurrent first message may have created the conversation since; the upsert then returns it
+	createQuery := `
+		INSERT INTO conversations (type, direct_key, last_message, created_at, updated_at)
+		VALUES ($1, $2, '', $3, $3)
+		ON CONFLICT (direct_key) DO UPDATE SET direct_key = EXCLUDED.direct_key
+		RETURNING ` + conversationColumns
+	conversation, err = scanConversation(tx.QueryRow(ctx, createQuery, domain.ConversationTypeDirect, key, time.Now()))
+	if err != nil {
+		log.Printf("Error creating conversation: %v", err)
+		return nil, fmt.Errorf("failed to create conversation: %w", err)
+	}
 
-		if err != nil {
-			log.Printf("Error creating conversation: %v", err)
-			return nil, fmt.Errorf("failed to create conversation: %w", err)
+	memberQuery := `
+		INSERT INTO conversation_members (conversation_id, user_id, role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (conversation_id, user_id) DO NOTHING
+	`
+	for _, userID := range []int{user1ID, user2ID} {
+		if _, err := tx.Exec(ctx, memberQuery, conversation.ID, userID, domain.ConversationRoleMember); err != nil {
+			return nil, fmt.Errorf("failed to add conversation member: %w", err)
 		}
+	}
 
-		log.Printf("Created new conversation: id=%d, user1=%d, user2=%d",
-			conversation.ID, conversation.User1ID, conversation.User2ID)
-	} else {
-		log.Printf("Found existing conversation: id=%d, user1=%d, user2=%d",
-			conversation.ID, conversation.User1ID, conversation.User2ID)
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit conversation: %w", err)
 	}
 
+	log.Printf("Created new conversation: id=%d, user1=%d, user2=%d", conversation.ID, user1ID, user2ID)
 	return conversation, nil
 }
 
@@ -213,3 +292,426 @@ func (r *chatRepository) UpdateConvo(ctx context.Context, conversationID int, la
 	log.Printf("Updated conversation %d with last message: %s", conversationID, lastMessage)
 	return nil
 }
+
+func (r *chatRepository) GetConversationByID(ctx context.Context, conversationID int) (*domain.Conversation, error) {
+	conversation, err := scanConversation(db.DB.QueryRow(ctx, `SELECT `+conversationColumns+` FROM conversations WHERE id = $1`, conversationID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrConversationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation: %w", err)
+	}
+
+	return conversation, nil
+}
+
+// GetMessagesByConversation retrieves the messages of a conversation, newest first
+func (r *chatRepository) GetMessagesByConversation(ctx context.Context, conversationID int, limit, offset int) ([]*domain.Message, error) {
+	query := `
+		SELECT ` + messageColumns + `
+		FROM messages
+		WHERE conversation_id = $1
+		ORDER BY id DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := db.DB.Query(ctx, query, conversationID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages by conversation: %w", err)
+	}
+	defer rows.Close()
+
+	messages := []*domain.Message{}
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan message: %w", err)
+		}
+		messages = append(messages, msg)
+	}
+
+	return messages, rows.Err()
+}
+
+// CreateGroup stores a group conversation with ownerID as its owner and memberIDs as plain members
+func (r *chatRepository) CreateGroup(ctx context.Context, conversation *domain.Conversation, ownerID int, memberIDs []int) error {
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	createQuery := `
+		INSERT INTO conversations (type, name, last_message, created_at, updated_at)
+		VALUES ($1, $2, '', $3, $3)
+		RETURNING ` + conversationColumns
+	created, err := scanConversation(tx.QueryRow(ctx, createQuery, domain.ConversationTypeGroup, conversation.Name, time.Now()))
+	if err != nil {
+		return fmt.Errorf("failed to create group: %w", err)
+	}
+
+	memberQuery := `INSERT INTO conversation_members (conversation_id, user_id, role, joined_at) VALUES ($1, $2, $3, $4)`
+	if _, err := tx.Exec(ctx, memberQuery, created.ID, ownerID, domain.ConversationRoleOwner, created.CreatedAt); err != nil {
+		return fmt.Errorf("failed to add group owner: %w", err)
+	}
+	for _, userID := range memberIDs {
+		if _, err := tx.Exec(ctx, memberQuery, created.ID, userID, domain.ConversationRoleMember, created.CreatedAt); err != nil {
+			return fmt.Errorf("failed to add group member: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit group: %w", err)
+	}
+
+	*conversation = *created
+	return nil
+}
+
+func (r *chatRepository) RenameConversation(ctx context.Context, conversationID int, name string) error {
+	tag, err := db.DB.Exec(ctx, `UPDATE conversations SET name = $2, updated_at = $3 WHERE id = $1`, conversationID, name, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to rename conversation: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrConversationNotFound
+	}
+
+	return nil
+}
+
+// ListMembers returns the members of a conversation in the order they joined
+func (r *chatRepository) ListMembers(ctx context.Context, conversationID int) ([]domain.ConversationMember, error) {
+	query := `
+		SELECT ` + memberColumns + `
+		FROM conversation_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.conversation_id = $1
+		ORDER BY m.joined_at, m.user_id
+	`
+
+	rows, err := db.DB.Query(ctx, query, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list conversation members: %w", err)
+	}
+	defer rows.Close()
+
+	members := []domain.ConversationMember{}
+	for rows.Next() {
+		member, err := scanMember(rows)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, *member)
+	}
+
+	return members, rows.Err()
+}
+
+func (r *chatRepository) GetMember(ctx context.Context, conversationID, userID int) (*domain.ConversationMember, error) {
+	query := `
+		SELECT ` + memberColumns + `
+		FROM conversation_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.conversation_id = $1 AND m.user_id = $2
+	`
+
+	member, err := scanMember(db.DB.QueryRow(ctx, query, conversationID, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrMemberNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation member: %w", err)
+	}
+
+	return member, nil
+}
+
+// AddMember adds a user to a conversation with the messages sent before they joined counted as read; adding an existing member keeps their role
+func (r *chatRepository) AddMember(ctx context.Context, conversationID, userID int, role string) error {
+	query := `
+		INSERT INTO conversation_members (conversation_id, user_id, role, last_read_message_id)
+		VALUES ($1, $2, $3, (SELECT COALESCE(MAX(id), 0) FROM messages WHERE conversation_id = $1))
+		ON CONFLICT (conversation_id, user_id) DO NOTHING
+	`
+
+	if _, err := db.DB.Exec(ctx, query, conversationID, userID, role); err != nil {
+		return fmt.Errorf("failed to add conversation member: %w", err)
+	}
+	return nil
+}
+
+func (r *chatRepository) RemoveMember(ctx context.Context, conversationID, userID int) error {
+	tag, err := db.DB.Exec(ctx, `DELETE FROM conversation_members WHERE conversation_id = $1 AND user_id = $2`, conversationID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to remove conversation member: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrMemberNotFound
+	}
+
+	return nil
+}
+
+// LeaveGroup removes userID from a group in one transaction. When the owner leaves, the longest standing
+// admin, or else member, becomes the owner; the group is deleted when its last member leaves.
+func (r *chatRepository) LeaveGroup(ctx context.Context, conversationID, userID int) error {
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Members leaving the same group at once take turns, so the group never ends up with two owners or none
+	if _, err := tx.Exec(ctx, `SELECT id FROM conversations WHERE id = $1 FOR UPDATE`, conversationID); err != nil {
+		return fmt.Errorf("failed to lock conversation: %w", err)
+	}
+
+	var role string
+	err = tx.QueryRow(ctx, `DELETE FROM conversation_members WHERE conversation_id = $1 AND user_id = $2 RETURNING role`, conversationID, userID).Scan(&role)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrMemberNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to remove conversation member: %w", err)
+	}
+
+	successorQuery := `
+		SELECT user_id
+		FROM conversation_members
+		WHERE conversation_id = $1
+		ORDER BY role = $2 DESC, joined_at, user_id
+		LIMIT 1
+	`
+	var successorID int
+	err = tx.QueryRow(ctx, successorQuery, conversationID, domain.ConversationRoleAdmin).Scan(&successorID)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		if _, err := tx.Exec(ctx, `DELETE FROM conversations WHERE id = $1`, conversationID); err != nil {
+			return fmt.Errorf("failed to delete conversation: %w", err)
+		}
+	case err != nil:
+		return fmt.Errorf("failed to find the next owner: %w", err)
+	case role == domain.ConversationRoleOwner:
+		if _, err := tx.Exec(ctx, `UPDATE conversation_members SET role = $3 WHERE conversation_id = $1 AND user_id = $2`, conversationID, successorID, domain.ConversationRoleOwner); err != nil {
+			return fmt.Errorf("failed to promote the next owner: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit leaving the group: %w", err)
+	}
+	return nil
+}
+
+func (r *chatRepository) UpdateMemberRole(ctx context.Context, conversationID, userID int, role string) error {
+	tag, err := db.DB.Exec(ctx, `UPDATE conversation_members SET role = $3 WHERE conversation_id = $1 AND user_id = $2`, conversationID, userID, role)
+	if err != nil {
+		return fmt.Errorf("failed to update conversation member role: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrMemberNotFound
+	}
+
+	return nil
+}
+
+// MarkRead records that userID has read the conversation up to messageID; the position never moves back
+func (r *chatRepository) MarkRead(ctx context.Context, conversationID, userID, messageID int) error {
+	query := `
+		UPDATE conversation_members
+		SET last_read_message_id = GREATEST(last_read_message_id, $3)
+		WHERE conversation_id = $1 AND user_id = $2
+	`
+
+	tag, err := db.DB.Exec(ctx, query, conversationID, userID, messageID)
+	if err != nil {
+		return fmt.Errorf("failed to mark conversation read: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrMemberNotFound
+	}
+
+	return nil
+}
+
+// LatestMessageID returns the ID of the newest message of a conversation, or 0 when it has none
+func (r *chatRepository) LatestMessageID(ctx context.Context, conversationID int) (int, error) {
+	query := `SELECT COALESCE(MAX(id), 0) FROM messages WHERE conversation_id = $1`
+
+	var messageID int
+	if err := db.DB.QueryRow(ctx, query, conversationID).Scan(&messageID); err != nil {
+		return 0, fmt.Errorf("failed to get latest message: %w", err)
+	}
+
+	return messageID, nil
+}
+
+// DeleteMessage removes a message with its receipts and returns it.
+// The last message of its conversation falls back to the one before.
+func (r *chatRepository) DeleteMessage(ctx context.Context, messageID int) (*domain.Message, error) {
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	deleted, err := scanMessage(tx.QueryRow(ctx, `DELETE FROM messages WHERE id = $1 RETURNING `+messageColumns, messageID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete message: %w", err)
+	}
+
+	lastMessageQuery := `
+		UPDATE conversations
+		SET last_message = COALESCE((SELECT content FROM messages WHERE conversation_id = $1 ORDER BY id DESC LIMIT 1), '')
+		WHERE id = $1
+	`
+	if _, err := tx.Exec(ctx, lastMessageQuery, deleted.ConversationID); err != nil {
+		return nil, fmt.Errorf("failed to update last message: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit message deletion: %w", err)
+	}
+
+	return deleted, nil
+}
+
+// ListContacts returns the users who share a conversation with userID, leaving out
+// anyone blocked by or blocking them
+func (r *chatRepository) ListContacts(ctx context.Context, userID int) ([]int, error) {
+	query := `
+		SELECT DISTINCT other.user_id
+		FROM conversation_members self
+		JOIN conversation_members other
+			ON other.conversation_id = self.conversation_id AND other.user_id <> self.user_id
+		WHERE self.user_id = $1
+			AND NOT EXISTS (
+				SELECT 1 FROM user_blocks b
+				WHERE (b.blocker_id = $1 AND b.blocked_id = other.user_id)
+					OR (b.blocker_id = other.user_id AND b.blocked_id = $1)
+			)
+		ORDER BY other.user_id
+	`
+
+	rows, err := db.DB.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list contacts: %w", err)
+	}
+	defer rows.Close()
+
+	contactIDs := []int{}
+	for rows.Next() {
+		var contactID int
+		if err := rows.Scan(&contactID); err != nil {
+			return nil, err
+		}
+		contactIDs = append(contactIDs, contactID)
+	}
+
+	return contactIDs, rows.Err()
+}
+
+// messagePreviewLength is how much of the last message the inbox shows
+const messagePreviewLength = 100
+
+// ListInbox returns the conversations of a user, most recently active first, with the other participant
+// of direct conversations, a preview of the last message and the number of unread messages from others
+func (r *chatRepository) ListInbox(ctx context.Context, filter *domain.InboxFilter) ([]domain.InboxEntry, error) {
+	query := `
+		SELECT c.id, c.type, c.name, c.updated_at,
+			other.id, other.name, other.display_name, other.bio, other.status_text, other.created_at,
+			lm.id, lm.sender_id, left(lm.content, $5), lm.created_at,
+			(SELECT COUNT(*) FROM messages m
+				WHERE m.conversation_id = c.id AND m.id > cm.last_read_message_id AND m.sender_id <> cm.user_id),
+			EXISTS (SELECT 1 FROM conversation_mutes mu WHERE mu.user_id = cm.user_id AND mu.conversation_id = c.id)
+		FROM conversation_members cm
+		JOIN conversations c ON c.id = cm.conversation_id
+		LEFT JOIN LATERAL (
+			SELECT u.id, u.name, u.display_name, u.bio, u.status_text, u.created_at
+			FROM conversation_members om
+			JOIN users u ON u.id = om.user_id
+			WHERE om.conversation_id = c.id AND om.user_id <> cm.user_id
+			LIMIT 1
+		) other ON c.type = 'direct'
+		LEFT JOIN LATERAL (
+			SELECT id, sender_id, content, created_at
+			FROM messages
+			WHERE conversation_id = c.id
+			ORDER BY id DESC
+			LIMIT 1
+		) lm ON true
+		WHERE cm.user_id = $1
+			AND ($2 = 0 OR (c.updated_at, c.id) < ($3, $2))
+		ORDER BY c.updated_at DESC, c.id DESC
+		LIMIT $4
+	`
+
+	rows, err := db.DB.Query(ctx, query, filter.UserID, filter.BeforeID, filter.BeforeUpdatedAt, filter.Limit, messagePreviewLength)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list inbox: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []domain.InboxEntry{}
+	for rows.Next() {
+		var (
+			entry            domain.InboxEntry
+			otherID          *int
+			otherName        *string
+			otherDisplayName *string
+			otherBio         *string
+			otherStatusText  *string
+			otherCreatedAt   *time.Time
+			lastID           *int
+			lastSenderID     *int
+			lastContent      *string
+			lastCreatedAt    *time.Time
+		)
+		err := rows.Scan(
+			&entry.ID,
+			&entry.Type,
+			&entry.Name,
+			&entry.UpdatedAt,
+			&otherID,
+			&otherName,
+			&otherDisplayName,
+			&otherBio,
+			&otherStatusText,
+			&otherCreatedAt,
+			&lastID,
+			&lastSenderID,
+			&lastContent,
+			&lastCreatedAt,
+			&entry.UnreadCount,
+			&entry.Muted,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if otherID != nil {
+			entry.OtherUser = &domain.PublicProfile{
+				ID:          *otherID,
+				Name:        *otherName,
+				DisplayName: *otherDisplayName,
+				Bio:         *otherBio,
+				StatusText:  *otherStatusText,
+				CreatedAt:   *otherCreatedAt,
+			}
+		}
+		if lastID != nil {
+			entry.LastMessage = &domain.MessagePreview{
+				ID:        *lastID,
+				SenderID:  *lastSenderID,
+				Content:   *lastContent,
+				CreatedAt: *lastCreatedAt,
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-authentication/db"
+	"go-authentication/internal/domain"
+)
+
+// ErrMuteNotFound is returned when unmuting a conversation that is not muted
+var ErrMuteNotFound = errors.New("conversation mute not found")
+
+// ConversationMuteRepository defines the interface for the users' muted conversations
+type ConversationMuteRepository interface {
+	Mute(ctx context.Context, userID, conversationID int) error
+	Unmute(ctx context.Context, userID, conversationID int) error
+	ListByUser(ctx context.Context, userID int) ([]domain.ConversationMute, error)
+	IsMuted(ctx context.Context, userID, conversationID int) (bool, error)
+}
+
+// conversationMuteRepository implements ConversationMuteRepository
+type conversationMuteRepository struct{}
+
+// NewConversationMuteRepository creates a new instance of conversationMuteRepository
+func NewConversationMuteRepository() ConversationMuteRepository {
+	return &conversationMuteRepository{}
+}
+
+// Mute silences a conversation for userID; muting twice keeps the original date
+func (r *conversationMuteRepository) Mute(ctx context.Context, userID, conversationID int) error {
+	query := `
+		INSERT INTO conversation_mutes (user_id, conversation_id)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id, conversation_id) DO NOTHING
+	`
+
+	if _, err := db.DB.Exec(ctx, query, userID, conversationID); err != nil {
+		return fmt.Errorf("failed to mute conversation: %w", err)
+	}
+	return nil
+}
+
+func (r *conversationMuteRepository) Unmute(ctx context.Context, userID, conversationID int) error {
+	tag, err := db.DB.Exec(ctx, `DELETE FROM conversation_mutes WHERE user_id = $1 AND conversation_id = $2`, userID, conversationID)
+	if err != nil {
+		return fmt.Errorf("failed to unmute conversation: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrMuteNotFound
+	}
+
+	return nil
+}
+
+// ListByUser returns the conversations muted by userID, most recently muted first
+func (r *conversationMuteRepository) ListByUser(ctx context.Context, userID int) ([]domain.ConversationMute, error) {
+	query := `
+		SELECT conversation_id, created_at
+		FROM conversation_mutes
+		WHERE user_id = $1
+		ORDER BY created_at DESC, conversation_id
+	`
+
+	rows, err := db.DB.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list muted conversations: %w", err)
+	}
+	defer rows.Close()
+
+	mutes := []domain.ConversationMute{}
+	for rows.Next() {
+		var mute domain.ConversationMute
+		if err := rows.Scan(&mute.ConversationID, &mute.MutedAt); err != nil {
+			return nil, err
+		}
+		mutes = append(mutes, mute)
+	}
+
+	return mutes, rows.Err()
+}
+
+func (r *conversationMuteRepository) IsMuted(ctx context.Context, userID, conversationID int) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM conversation_mutes WHERE user_id = $1 AND conversation_id = $2)`
+
+	var muted bool
+	if err := db.DB.QueryRow(ctx, query, userID, conversationID).Scan(&muted); err != nil {
+		return false, fmt.Errorf("failed to check conversation mute: %w", err)
+	}
+	return muted, nil
+}
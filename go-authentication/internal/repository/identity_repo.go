@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-authentication/db"
+	"go-authentication/internal/domain"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	// ErrIdentityNotFound is returned when no user is linked to an external identity
+	ErrIdentityNotFound = errors.New("identity not found")
+	// ErrOIDCStateNotFound is returned when a login state is unknown or was already used
+	ErrOIDCStateNotFound = errors.New("oidc login state not found")
+)
+
+// UserIdentityRepository defines the interface for external identities and OIDC logins in progress
+type UserIdentityRepository interface {
+	GetByProviderSubject(ctx context.Context, provider, subject string) (*domain.UserIdentity, error)
+	Create(ctx context.Context, identity *domain.UserIdentity) error
+	TouchLastLogin(ctx context.Context, id int, at time.Time) error
+	SaveLoginState(ctx context.Context, state *domain.OIDCLoginState) error
+	// ConsumeLoginState returns and deletes a login state so it can only be used once
+	ConsumeLoginState(ctx context.Context, stateHash string) (*domain.OIDCLoginState, error)
+}
+
+// userIdentityRepository implements UserIdentityRepository
+type userIdentityRepository struct{}
+
+// NewUserIdentityRepository creates a new instance of userIdentityRepository
+func NewUserIdentityRepository() UserIdentityRepository {
+	return &userIdentityRepository{}
+}
+
+func (r *userIdentityRepository) GetByProviderSubject(ctx context.Context, provider, subject string) (*domain.UserIdentity, error) {
+	query := `
+		SELECT id, user_id, provider, subject, email, created_at, last_login_at
+		FROM user_identities
+		WHERE provider = $1 AND subject = $2
+	`
+
+	var identity domain.UserIdentity
+	err := db.DB.QueryRow(ctx, query, provider, subject).Scan(
+		&identity.ID,
+		&identity.UserID,
+		&identity.Provider,
+		&identity.Subject,
+		&identity.Email,
+		&identity.CreatedAt,
+		&identity.LastLoginAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrIdentityNotFound
+		}
+		return nil, err
+	}
+
+	return &identity, nil
+}
+
+func (r *userIdentityRepository) Create(ctx context.Context, identity *domain.UserIdentity) error {
+	query := `
+		INSERT INTO user_identities (user_id, provider, subject, email, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+
+	identity.CreatedAt = time.Now()
+	err := db.DB.QueryRow(ctx, query,
+		identity.UserID,
+		identity.Provider,
+		identity.Subject,
+		identity.Email,
+		identity.CreatedAt,
+	).Scan(&identity.ID)
+	if err != nil {
+		return fmt.Errorf("failed to link identity: %w", err)
+	}
+
+	return nil
+}
+
+func (r *userIdentityRepository) TouchLastLogin(ctx context.Context, id int, at time.Time) error {
+	query := `UPDATE user_identities SET last_login_at = $1 WHERE id = $2`
+
+	if _, err := db.DB.Exec(ctx, query, at, id); err != nil {
+		return fmt.Errorf("failed to update identity: %w", err)
+	}
+
+	return nil
+}
+
+func (r *userIdentityRepository) SaveLoginState(ctx context.Context, state *domain.OIDCLoginState) error {
+	query := `
+		INSERT INTO oidc_login_states (state_hash, nonce, code_verifier, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	state.CreatedAt = time.Now()
+	_, err := db.DB.Exec(ctx, query, state.StateHash, state.Nonce, state.CodeVerifier, state.ExpiresAt, state.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to store oidc login state: %w", err)
+	}
+
+	// Abandoned logins are never consumed, so drop them once they expire
+	if _, err := db.DB.Exec(ctx, `DELETE FROM oidc_login_states WHERE expires_at < $1`, state.CreatedAt); err != nil {
+		return fmt.Errorf("failed to prune oidc login states: %w", err)
+	}
+
+	return nil
+}
+
+func (r *userIdentityRepository) ConsumeLoginState(ctx context.Context, stateHash string) (*domain.OIDCLoginState, error) {
+	query := `
+		DELETE FROM oidc_login_states
+		WHERE state_hash = $1
+		RETURNING state_hash, nonce, code_verifier, expires_at, created_at
+	`
+
+	var state domain.OIDCLoginState
+	err := db.DB.QueryRow(ctx, query, stateHash).Scan(
+		&state.StateHash,
+		&state.Nonce,
+		&state.CodeVerifier,
+		&state.ExpiresAt,
+		&state.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrOIDCStateNotFound
+		}
+		return nil, err
+	}
+
+	return &state, nil
+}
@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-authentication/db"
+	"go-authentication/internal/domain"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// LoginThrottleRepository defines the interface for tracking failed logins and lockouts
+type LoginThrottleRepository interface {
+	// Get returns the throttle for key; a key without failures yields an empty throttle
+	Get(ctx context.Context, key string) (*domain.LoginThrottle, error)
+	// RecordFailure counts a failed login. Earlier failures are forgotten once both the last failure
+	// and any lockout ended before windowStart.
+	RecordFailure(ctx context.Context, key string, at, windowStart time.Time) (*domain.LoginThrottle, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+	RecordEvent(ctx context.Context, event *domain.LockoutEvent) error
+}
+
+// loginThrottleRepository implements LoginThrottleRepository
+type loginThrottleRepository struct{}
+
+// NewLoginThrottleRepository creates a new instance of loginThrottleRepository
+func NewLoginThrottleRepository() LoginThrottleRepository {
+	return &loginThrottleRepository{}
+}
+
+func (r *loginThrottleRepository) Get(ctx context.Context, key string) (*domain.LoginThrottle, error) {
+	query := `
+		SELECT key, failures, last_failure_at, locked_until
+		FROM login_throttles
+		WHERE key = $1
+	`
+
+	var throttle domain.LoginThrottle
+	err := db.DB.QueryRow(ctx, query, key).Scan(
+		&throttle.Key,
+		&throttle.Failures,
+		&throttle.LastFailureAt,
+		&throttle.LockedUntil,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return &domain.LoginThrottle{Key: key}, nil
+		}
+		return nil, err
+	}
+
+	return &throttle, nil
+}
+
+// RecordFailure increments the counter in a single statement so concurrent attempts are all counted
+func (r *loginThrottleRepository) RecordFailure(ctx context.Context, key string, at, windowStart time.Time) (*domain.LoginThrottle, error) {
+	query := `
+		INSERT INTO login_throttles (key, failures, last_failure_at)
+		VALUES ($1, 1, $2)
+		ON CONFLICT (key) DO UPDATE
+		SET failures = CASE
+				WHEN GREATEST(login_throttles.last_failure_at, login_throttles.locked_until) < $3 THEN 1
+				ELSE login_throttles.failures + 1
+			END,
+			last_failure_at = EXCLUDED.last_failure_at
+		RETURNING key, failures, last_failure_at, locked_until
+	`
+
+	var throttle domain.LoginThrottle
+	err := db.DB.QueryRow(ctx, query, key, at, windowStart).Scan(
+		&throttle.Key,
+		&throttle.Failures,
+		&throttle.LastFailureAt,
+		&throttle.LockedUntil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record login failure: %w", err)
+	}
+
+	return &throttle, nil
+}
+
+func (r *loginThrottleRepository) Lock(ctx context.Context, key string, until time.Time) error {
+	query := `UPDATE login_throttles SET locked_until = $1 WHERE key = $2`
+
+	if _, err := db.DB.Exec(ctx, query, until, key); err != nil {
+		return fmt.Errorf("failed to lock %s: %w", key, err)
+	}
+
+	return nil
+}
+
+func (r *loginThrottleRepository) Reset(ctx context.Context, key string) error {
+	query := `DELETE FROM login_throttles WHERE key = $1`
+
+	if _, err := db.DB.Exec(ctx, query, key); err != nil {
+		return fmt.Errorf("failed to reset %s: %w", key, err)
+	}
+
+	return nil
+}
+
+func (r *loginThrottleRepository) RecordEvent(ctx context.Context, event *domain.LockoutEvent) error {
+	query := `
+		INSERT INTO lockout_events (key, user_id, action, failures, ip, user_agent, locked_until, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`
+
+	event.CreatedAt = time.Now()
+	err := db.DB.QueryRow(ctx, query,
+		event.Key,
+		event.UserID,
+		event.Action,
+		event.Failures,
+		event.IP,
+		event.UserAgent,
+		event.LockedUntil,
+		event.CreatedAt,
+	).Scan(&event.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record lockout event: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-authentication/db"
+	"go-authentication/internal/domain"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	// ErrMFANotFound is returned when the user never started TOTP enrollment
+	ErrMFANotFound = errors.New("mfa enrollment not found")
+	// ErrMFAAlreadyEnabled is returned when a confirmed enrollment would be overwritten
+	ErrMFAAlreadyEnabled = errors.New("mfa is already enabled")
+	// ErrTOTPStepUsed is returned by UseStep when a code for the same or a later time step was already accepted
+	ErrTOTPStepUsed = errors.New("totp code already used")
+	// ErrRecoveryCodeInvalid is returned by UseRecoveryCode for unknown or already used codes
+	ErrRecoveryCodeInvalid = errors.New("recovery code is invalid or already used")
+)
+
+// MFARepository defines the interface for TOTP enrollments and recovery codes
+type MFARepository interface {
+	GetByUserID(ctx context.Context, userID int) (*domain.UserMFA, error)
+	SavePending(ctx context.Context, mfa *domain.UserMFA) error
+	Enable(ctx context.Context, userID int, step int64, recoveryCodeHashes []string) error
+	Delete(ctx context.Context, userID int) error
+	UseStep(ctx context.Context, userID int, step int64) error
+	ReplaceRecoveryCodes(ctx context.Context, userID int, recoveryCodeHashes []string) error
+	UseRecoveryCode(ctx context.Context, userID int, codeHash string) error
+}
+
+// mfaRepository implements MFARepository
+type mfaRepository struct{}
+
+// NewMFARepository creates a new instance of mfaRepository
+func NewMFARepository() MFARepository {
+	return &mfaRepository{}
+}
+
+func (r *mfaRepository) GetByUserID(ctx context.Context, userID int) (*domain.UserMFA, error) {
+	query := `
+		SELECT user_id, secret, enabled_at, last_used_step, created_at
+		FROM user_mfa
+		WHERE user_id = $1
+	`
+
+	var mfa domain.UserMFA
+	err := db.DB.QueryRow(ctx, query, userID).Scan(
+		&mfa.UserID,
+		&mfa.Secret,
+		&mfa.EnabledAt,
+		&mfa.LastUsedStep,
+		&mfa.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrMFANotFound
+		}
+		return nil, err
+	}
+
+	return &mfa, nil
+}
+
+// SavePending stores a new unconfirmed secret, replacing an earlier unconfirmed one
+func (r *mfaRepository) SavePending(ctx context.Context, mfa *domain.UserMFA) error {
+	query := `
+		INSERT INTO user_mfa (user_id, secret, enabled_at, last_used_step, created_at)
+		VALUES ($1, $2, NULL, 0, $3)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, last_used_step = 0, created_at = EXCLUDED.created_at
+		WHERE user_mfa.enabled_at IS NULL
+	`
+
+	mfa.CreatedAt = time.Now()
+	mfa.EnabledAt = nil
+	mfa.LastUsedStep = 0
+	tag, err := db.DB.Exec(ctx, query, mfa.UserID, mfa.Secret, mfa.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to store mfa secret: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrMFAAlreadyEnabled
+	}
+
+	return nil
+}
+
+// Enable confirms a pending enrollment and stores its first set of recovery codes in one transaction
+func (r *mfaRepository) Enable(ctx context.Context, userID int, step int64, recoveryCodeHashes []string) error {
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	updateQuery := `
+		UPDATE user_mfa
+		SET enabled_at = $1, last_used_step = $2
+		WHERE user_id = $3 AND enabled_at IS NULL
+	`
+	tag, err := tx.Exec(ctx, updateQuery, time.Now(), step, userID)
+	if err != nil {
+		return fmt.Errorf("failed to enable mfa: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrMFAAlreadyEnabled
+	}
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Delete removes the enrollment and its recovery codes
+func (r *mfaRepository) Delete(ctx context.Context, userID int) error {
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM user_mfa WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete mfa enrollment: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// UseStep records an accepted code; the conditional update rejects replays and concurrent use of one code
+func (r *mfaRepository) UseStep(ctx context.Context, userID int, step int64) error {
+	query := `UPDATE user_mfa SET last_used_step = $1 WHERE user_id = $2 AND last_used_step < $1`
+
+	tag, err := db.DB.Exec(ctx, query, step, userID)
+	if err != nil {
+		return fmt.Errorf("failed to record totp code: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTOTPStepUsed
+	}
+
+	return nil
+}
+
+// ReplaceRecoveryCodes discards every existing recovery code and stores a new set
+func (r *mfaRepository) ReplaceRecoveryCodes(ctx context.Context, userID int, recoveryCodeHashes []string) error {
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// UseRecoveryCode consumes a recovery code so it can only be used once
+func (r *mfaRepository) UseRecoveryCode(ctx context.Context, userID int, codeHash string) error {
+	query := `
+		UPDATE mfa_recovery_codes
+		SET used_at = $1
+		WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL
+	`
+
+	tag, err := db.DB.Exec(ctx, query, time.Now(), userID, codeHash)
+	if err != nil {
+		return fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrRecoveryCodeInvalid
+	}
+
+	return nil
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx pgx.Tx, userID int, recoveryCodeHashes []string) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	insertQuery := `INSERT INTO mfa_recovery_codes (user_id, code_hash, created_at) VALUES ($1, $2, $3)`
+	now := time.Now()
+	for _, hash := range recoveryCodeHashes {
+		if _, err := tx.Exec(ctx, insertQuery, userID, hash, now); err != nil {
+			return fmt.Errorf("failed to store recovery code: %w", err)
+		}
+	}
+
+	return nil
+}
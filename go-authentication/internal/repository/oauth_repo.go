@@ -0,0 +1,413 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-authentication/db"
+	"go-authentication/internal/domain"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	// ErrOAuthClientNotFound is returned when a client does not exist
+	ErrOAuthClientNotFound = errors.New("oauth client not found")
+	// ErrAuthorizationCodeNotFound is returned when a code is unknown or was already redeemed
+	ErrAuthorizationCodeNotFound = errors.New("authorization code not found")
+	// ErrConsentNotFound is returned when a user never allowed a client
+	ErrConsentNotFound = errors.New("consent not found")
+	// ErrOAuthRefreshTokenNotFound is returned when a refresh token is unknown
+	ErrOAuthRefreshTokenNotFound = errors.New("oauth refresh token not found")
+	// ErrOAuthRefreshTokenConsumed is returned when a refresh token was rotated or revoked concurrently
+	ErrOAuthRefreshTokenConsumed = errors.New("oauth refresh token already used")
+)
+
+// OAuthRepository defines the interface for the storage of the OAuth authorization server:
+// registered clients, authorization codes, user consents and refresh tokens
+type OAuthRepository interface {
+	CreateClient(ctx context.Context, client *domain.OAuthClient) error
+	GetClient(ctx context.Context, clientID string) (*domain.OAuthClient, error)
+	ListClients(ctx context.Context) ([]domain.OAuthClient, error)
+	RevokeClient(ctx context.Context, clientID string) error
+
+	SaveAuthorizationCode(ctx context.Context, code *domain.OAuthAuthorizationCode) error
+	// ConsumeAuthorizationCode returns and deletes a code so it can only be redeemed once
+	ConsumeAuthorizationCode(ctx context.Context, codeHash string) (*domain.OAuthAuthorizationCode, error)
+
+	GetConsent(ctx context.Context, userID int, clientID string) (*domain.OAuthConsent, error)
+	// SaveConsent creates or replaces the scopes a user allowed a client
+	SaveConsent(ctx context.Context, consent *domain.OAuthConsent) error
+	ListConsents(ctx context.Context, userID int) ([]domain.OAuthConsent, error)
+	DeleteConsent(ctx context.Context, userID int, clientID string) error
+
+	CreateRefreshToken(ctx context.Context, token *domain.OAuthRefreshToken) error
+	GetRefreshToken(ctx context.Context, tokenHash string) (*domain.OAuthRefreshToken, error)
+	// RotateRefreshToken marks oldTokenID as rotated and stores next, failing if oldTokenID was already used
+	RotateRefreshToken(ctx context.Context, oldTokenID int, next *domain.OAuthRefreshToken) error
+	RevokeRefreshToken(ctx context.Context, id int) error
+	// RevokeGrant revokes every refresh token a client holds for a user
+	RevokeGrant(ctx context.Context, userID int, clientID string) error
+}
+
+// oauthRepository implements OAuthRepository
+type oauthRepository struct{}
+
+// NewOAuthRepository creates a new instance of oauthRepository
+func NewOAuthRepository() OAuthRepository {
+	return &oauthRepository{}
+}
+
+const oauthClientColumns = `id, client_id, name, secret_hash, redirect_uris, grant_types, scopes, confidential, created_by, created_at, revoked_at`
+
+func scanOAuthClient(row pgx.Row) (*domain.OAuthClient, error) {
+	var client domain.OAuthClient
+	err := row.Scan(
+		&client.ID,
+		&client.ClientID,
+		&client.Name,
+		&client.SecretHash,
+		&client.RedirectURIs,
+		&client.GrantTypes,
+		&client.Scopes,
+		&client.Confidential,
+		&client.CreatedBy,
+		&client.CreatedAt,
+		&client.RevokedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+func (r *oauthRepository) CreateClient(ctx context.Context, client *domain.OAuthClient) error {
+	query := `
+		INSERT INTO oauth_clients (client_id, name, secret_hash, redirect_uris, grant_types, scopes, confidential, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id
+	`
+
+	client.CreatedAt = time.Now()
+	err := db.DB.QueryRow(ctx, query,
+		client.ClientID,
+		client.Name,
+		client.SecretHash,
+		client.RedirectURIs,
+		client.GrantTypes,
+		client.Scopes,
+		client.Confidential,
+		client.CreatedBy,
+		client.CreatedAt,
+	).Scan(&client.ID)
+	if err != nil {
+		return fmt.Errorf("failed to store oauth client: %w", err)
+	}
+
+	return nil
+}
+
+func (r *oauthRepository) GetClient(ctx context.Context, clientID string) (*domain.OAuthClient, error) {
+	query := `SELECT ` + oauthClientColumns + ` FROM oauth_clients WHERE client_id = $1`
+
+	client, err := scanOAuthClient(db.DB.QueryRow(ctx, query, clientID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrOAuthClientNotFound
+		}
+		return nil, err
+	}
+
+	return client, nil
+}
+
+func (r *oauthRepository) ListClients(ctx context.Context) ([]domain.OAuthClient, error) {
+	query := `SELECT ` + oauthClientColumns + ` FROM oauth_clients ORDER BY created_at DESC`
+
+	rows, err := db.DB.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	clients := []domain.OAuthClient{}
+	for rows.Next() {
+		client, err := scanOAuthClient(rows)
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, *client)
+	}
+
+	return clients, rows.Err()
+}
+
+// RevokeClient disables a client and revokes its refresh tokens
+func (r *oauthRepository) RevokeClient(ctx context.Context, clientID string) error {
+	now := time.Now()
+	tag, err := db.DB.Exec(ctx, `UPDATE oauth_clients SET revoked_at = $1 WHERE client_id = $2 AND revoked_at IS NULL`, now, clientID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke oauth client: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrOAuthClientNotFound
+	}
+
+	query := `UPDATE oauth_refresh_tokens SET revoked_at = $1 WHERE client_id = $2 AND revoked_at IS NULL`
+	if _, err := db.DB.Exec(ctx, query, now, clientID); err != nil {
+		return fmt.Errorf("failed to revoke oauth refresh tokens: %w", err)
+	}
+
+	return nil
+}
+
+func (r *oauthRepository) SaveAuthorizationCode(ctx context.Context, code *domain.OAuthAuthorizationCode) error {
+	// Codes nobody redeemed are pruned as new ones are issued
+	if _, err := db.DB.Exec(ctx, `DELETE FROM oauth_authorization_codes WHERE expires_at < $1`, time.Now()); err != nil {
+		return fmt.Errorf("failed to prune authorization codes: %w", err)
+	}
+
+	query := `
+		INSERT INTO oauth_authorization_codes (code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	code.CreatedAt = time.Now()
+	_, err := db.DB.Exec(ctx, query,
+		code.CodeHash,
+		code.ClientID,
+		code.UserID,
+		code.RedirectURI,
+		code.Scopes,
+		code.CodeChallenge,
+		code.ExpiresAt,
+		code.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store authorization code: %w", err)
+	}
+
+	return nil
+}
+
+func (r *oauthRepository) ConsumeAuthorizationCode(ctx context.Context, codeHash string) (*domain.OAuthAuthorizationCode, error) {
+	query := `
+		DELETE FROM oauth_authorization_codes
+		WHERE code_hash = $1
+		RETURNING code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, expires_at, created_at
+	`
+
+	var code domain.OAuthAuthorizationCode
+	err := db.DB.QueryRow(ctx, query, codeHash).Scan(
+		&code.CodeHash,
+		&code.ClientID,
+		&code.UserID,
+		&code.RedirectURI,
+		&code.Scopes,
+		&code.CodeChallenge,
+		&code.ExpiresAt,
+		&code.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrAuthorizationCodeNotFound
+		}
+		return nil, err
+	}
+
+	return &code, nil
+}
+
+const oauthConsentQuery = `
+	SELECT c.user_id, c.client_id, cl.name, c.scopes, c.granted_at, c.updated_at
+	FROM oauth_consents c
+	JOIN oauth_clients cl ON cl.client_id = c.client_id
+`
+
+func scanOAuthConsent(row pgx.Row) (*domain.OAuthConsent, error) {
+	var consent domain.OAuthConsent
+	err := row.Scan(
+		&consent.UserID,
+		&consent.ClientID,
+		&consent.ClientName,
+		&consent.Scopes,
+		&consent.GrantedAt,
+		&consent.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &consent, nil
+}
+
+func (r *oauthRepository) GetConsent(ctx context.Context, userID int, clientID string) (*domain.OAuthConsent, error) {
+	query := oauthConsentQuery + ` WHERE c.user_id = $1 AND c.client_id = $2`
+
+	consent, err := scanOAuthConsent(db.DB.QueryRow(ctx, query, userID, clientID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrConsentNotFound
+		}
+		return nil, err
+	}
+
+	return consent, nil
+}
+
+func (r *oauthRepository) SaveConsent(ctx context.Context, consent *domain.OAuthConsent) error {
+	query := `
+		INSERT INTO oauth_consents (user_id, client_id, scopes, granted_at, updated_at)
+		VALUES ($1, $2, $3, $4, $4)
+		ON CONFLICT (user_id, client_id) DO UPDATE SET scopes = EXCLUDED.scopes, updated_at = EXCLUDED.updated_at
+		RETURNING granted_at, updated_at
+	`
+
+	err := db.DB.QueryRow(ctx, query, consent.UserID, consent.ClientID, consent.Scopes, time.Now()).
+		Scan(&consent.GrantedAt, &consent.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to store consent: %w", err)
+	}
+
+	return nil
+}
+
+func (r *oauthRepository) ListConsents(ctx context.Context, userID int) ([]domain.OAuthConsent, error) {
+	query := oauthConsentQuery + ` WHERE c.user_id = $1 AND cl.revoked_at IS NULL ORDER BY c.updated_at DESC`
+
+	rows, err := db.DB.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	consents := []domain.OAuthConsent{}
+	for rows.Next() {
+		consent, err := scanOAuthConsent(rows)
+		if err != nil {
+			return nil, err
+		}
+		consents = append(consents, *consent)
+	}
+
+	return consents, rows.Err()
+}
+
+func (r *oauthRepository) DeleteConsent(ctx context.Context, userID int, clientID string) error {
+	tag, err := db.DB.Exec(ctx, `DELETE FROM oauth_consents WHERE user_id = $1 AND client_id = $2`, userID, clientID)
+	if err != nil {
+		return fmt.Errorf("failed to delete consent: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrConsentNotFound
+	}
+
+	return nil
+}
+
+const oauthRefreshTokenColumns = `id, token_hash, client_id, user_id, scopes, expires_at, created_at, rotated_at, revoked_at`
+
+func (r *oauthRepository) CreateRefreshToken(ctx context.Context, token *domain.OAuthRefreshToken) error {
+	query := `
+		INSERT INTO oauth_refresh_tokens (token_hash, client_id, user_id, scopes, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`
+
+	token.CreatedAt = time.Now()
+	err := db.DB.QueryRow(ctx, query,
+		token.TokenHash,
+		token.ClientID,
+		token.UserID,
+		token.Scopes,
+		token.ExpiresAt,
+		token.CreatedAt,
+	).Scan(&token.ID)
+	if err != nil {
+		return fmt.Errorf("failed to store oauth refresh token: %w", err)
+	}
+
+	return nil
+}
+
+func (r *oauthRepository) GetRefreshToken(ctx context.Context, tokenHash string) (*domain.OAuthRefreshToken, error) {
+	query := `SELECT ` + oauthRefreshTokenColumns + ` FROM oauth_refresh_tokens WHERE token_hash = $1`
+
+	var token domain.OAuthRefreshToken
+	err := db.DB.QueryRow(ctx, query, tokenHash).Scan(
+		&token.ID,
+		&token.TokenHash,
+		&token.ClientID,
+		&token.UserID,
+		&token.Scopes,
+		&token.ExpiresAt,
+		&token.CreatedAt,
+		&token.RotatedAt,
+		&token.RevokedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrOAuthRefreshTokenNotFound
+		}
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func (r *oauthRepository) RotateRefreshToken(ctx context.Context, oldTokenID int, next *domain.OAuthRefreshToken) error {
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	next.CreatedAt = time.Now()
+	insertQuery := `
+		INSERT INTO oauth_refresh_tokens (token_hash, client_id, user_id, scopes, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`
+	err = tx.QueryRow(ctx, insertQuery,
+		next.TokenHash,
+		next.ClientID,
+		next.UserID,
+		next.Scopes,
+		next.ExpiresAt,
+		next.CreatedAt,
+	).Scan(&next.ID)
+	if err != nil {
+		return fmt.Errorf("failed to store oauth refresh token: %w", err)
+	}
+
+	updateQuery := `UPDATE oauth_refresh_tokens SET rotated_at = $1 WHERE id = $2 AND rotated_at IS NULL AND revoked_at IS NULL`
+	tag, err := tx.Exec(ctx, updateQuery, next.CreatedAt, oldTokenID)
+	if err != nil {
+		return fmt.Errorf("failed to rotate oauth refresh token: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrOAuthRefreshTokenConsumed
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *oauthRepository) RevokeRefreshToken(ctx context.Context, id int) error {
+	query := `UPDATE oauth_refresh_tokens SET revoked_at = $1 WHERE id = $2 AND revoked_at IS NULL`
+
+	if _, err := db.DB.Exec(ctx, query, time.Now(), id); err != nil {
+		return fmt.Errorf("failed to revoke oauth refresh token: %w", err)
+	}
+
+	return nil
+}
+
+func (r *oauthRepository) RevokeGrant(ctx context.Context, userID int, clientID string) error {
+	query := `UPDATE oauth_refresh_tokens SET revoked_at = $1 WHERE user_id = $2 AND client_id = $3 AND revoked_at IS NULL`
+
+	if _, err := db.DB.Exec(ctx, query, time.Now(), userID, clientID); err != nil {
+		return fmt.Errorf("failed to revoke oauth grant: %w", err)
+	}
+
+	return nil
+}
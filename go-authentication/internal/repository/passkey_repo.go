@@ -0,0 +1,208 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-authentication/db"
+	"go-authentication/internal/domain"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	// ErrPasskeyNotFound is returned when a credential is unknown or belongs to another user
+	ErrPasskeyNotFound = errors.New("passkey not found")
+	// ErrPasskeyExists is returned when a credential ID is already registered
+	ErrPasskeyExists = errors.New("passkey is already registered")
+	// ErrPasskeySignCountStale is returned by UpdateSignCount when a login with the same or a higher counter was already recorded
+	ErrPasskeySignCountStale = errors.New("passkey signature counter did not increase")
+	// ErrWebAuthnChallengeNotFound is returned when a challenge is unknown or was already used
+	ErrWebAuthnChallengeNotFound = errors.New("webauthn challenge not found")
+)
+
+// PasskeyRepository defines the interface for WebAuthn credentials and ceremonies in progress
+type PasskeyRepository interface {
+	Create(ctx context.Context, passkey *domain.Passkey) error
+	GetByCredentialID(ctx context.Context, credentialID string) (*domain.Passkey, error)
+	ListByUser(ctx context.Context, userID int) ([]domain.Passkey, error)
+	Delete(ctx context.Context, id, userID int) error
+	UpdateSignCount(ctx context.Context, id int, signCount int64, usedAt time.Time) error
+	SaveChallenge(ctx context.Context, challenge *domain.WebAuthnChallenge) error
+	ConsumeChallenge(ctx context.Context, challengeHash string) (*domain.WebAuthnChallenge, error)
+}
+
+// passkeyRepository implements PasskeyRepository
+type passkeyRepository struct{}
+
+// NewPasskeyRepository creates a new instance of passkeyRepository
+func NewPasskeyRepository() PasskeyRepository {
+	return &passkeyRepository{}
+}
+
+const passkeyColumns = `id, user_id, credential_id, public_key, algorithm, sign_count, name, transports, created_at, last_used_at`
+
+func scanPasskey(row pgx.Row) (*domain.Passkey, error) {
+	var passkey domain.Passkey
+	err := row.Scan(
+		&passkey.ID,
+		&passkey.UserID,
+		&passkey.CredentialID,
+		&passkey.PublicKey,
+		&passkey.Algorithm,
+		&passkey.SignCount,
+		&passkey.Name,
+		&passkey.Transports,
+		&passkey.CreatedAt,
+		&passkey.LastUsedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &passkey, nil
+}
+
+func (r *passkeyRepository) Create(ctx context.Context, passkey *domain.Passkey) error {
+	query := `
+		INSERT INTO passkeys (user_id, credential_id, public_key, algorithm, sign_count, name, transports, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (credential_id) DO NOTHING
+		RETURNING id
+	`
+
+	if passkey.Transports == nil {
+		passkey.Transports = []string{}
+	}
+	passkey.CreatedAt = time.Now()
+	err := db.DB.QueryRow(ctx, query,
+		passkey.UserID,
+		passkey.CredentialID,
+		passkey.PublicKey,
+		passkey.Algorithm,
+		passkey.SignCount,
+		passkey.Name,
+		passkey.Transports,
+		passkey.CreatedAt,
+	).Scan(&passkey.ID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrPasskeyExists
+		}
+		return fmt.Errorf("failed to store passkey: %w", err)
+	}
+
+	return nil
+}
+
+func (r *passkeyRepository) GetByCredentialID(ctx context.Context, credentialID string) (*domain.Passkey, error) {
+	query := `SELECT ` + passkeyColumns + ` FROM passkeys WHERE credential_id = $1`
+
+	passkey, err := scanPasskey(db.DB.QueryRow(ctx, query, credentialID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrPasskeyNotFound
+		}
+		return nil, err
+	}
+
+	return passkey, nil
+}
+
+func (r *passkeyRepository) ListByUser(ctx context.Context, userID int) ([]domain.Passkey, error) {
+	query := `SELECT ` + passkeyColumns + ` FROM passkeys WHERE user_id = $1 ORDER BY created_at DESC`
+
+	rows, err := db.DB.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list passkeys: %w", err)
+	}
+	defer rows.Close()
+
+	passkeys := []domain.Passkey{}
+	for rows.Next() {
+		passkey, err := scanPasskey(rows)
+		if err != nil {
+			return nil, err
+		}
+		passkeys = append(passkeys, *passkey)
+	}
+
+	return passkeys, rows.Err()
+}
+
+func (r *passkeyRepository) Delete(ctx context.Context, id, userID int) error {
+	tag, err := db.DB.Exec(ctx, `DELETE FROM passkeys WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete passkey: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrPasskeyNotFound
+	}
+
+	return nil
+}
+
+// UpdateSignCount records a login. The counter must increase unless the authenticator keeps none (0),
+// so of two concurrent logins with the same counter only one succeeds.
+func (r *passkeyRepository) UpdateSignCount(ctx context.Context, id int, signCount int64, usedAt time.Time) error {
+	query := `
+		UPDATE passkeys
+		SET sign_count = $2, last_used_at = $3
+		WHERE id = $1 AND (sign_count < $2 OR $2 = 0)
+	`
+
+	tag, err := db.DB.Exec(ctx, query, id, signCount, usedAt)
+	if err != nil {
+		return fmt.Errorf("failed to update passkey: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrPasskeySignCountStale
+	}
+
+	return nil
+}
+
+func (r *passkeyRepository) SaveChallenge(ctx context.Context, challenge *domain.WebAuthnChallenge) error {
+	query := `
+		INSERT INTO webauthn_challenges (challenge_hash, ceremony, user_id, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	challenge.CreatedAt = time.Now()
+	_, err := db.DB.Exec(ctx, query, challenge.ChallengeHash, challenge.Ceremony, challenge.UserID, challenge.ExpiresAt, challenge.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to store webauthn challenge: %w", err)
+	}
+
+	// Abandoned ceremonies are never consumed, so drop them once they expire
+	if _, err := db.DB.Exec(ctx, `DELETE FROM webauthn_challenges WHERE expires_at < $1`, challenge.CreatedAt); err != nil {
+		return fmt.Errorf("failed to prune webauthn challenges: %w", err)
+	}
+
+	return nil
+}
+
+func (r *passkeyRepository) ConsumeChallenge(ctx context.Context, challengeHash string) (*domain.WebAuthnChallenge, error) {
+	query := `
+		DELETE FROM webauthn_challenges
+		WHERE challenge_hash = $1
+		RETURNING challenge_hash, ceremony, user_id, expires_at, created_at
+	`
+
+	var challenge domain.WebAuthnChallenge
+	err := db.DB.QueryRow(ctx, query, challengeHash).Scan(
+		&challenge.ChallengeHash,
+		&challenge.Ceremony,
+		&challenge.UserID,
+		&challenge.ExpiresAt,
+		&challenge.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrWebAuthnChallengeNotFound
+		}
+		return nil, err
+	}
+
+	return &challenge, nil
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-authentication/db"
+	"go-authentication/internal/domain"
+	"time"
+)
+
+// ErrResetTokenUsed is returned by MarkUsed when the token was already consumed
+var ErrResetTokenUsed = errors.New("password reset token already used")
+
+// PasswordResetRepository defines the interface for password reset token storage
+type PasswordResetRepository interface {
+	Create(ctx context.Context, token *domain.PasswordResetToken) error
+	GetByHash(ctx context.Context, tokenHash string) (*domain.PasswordResetToken, error)
+	MarkUsed(ctx context.Context, id int) error
+	InvalidateForUser(ctx context.Context, userID int) error
+}
+
+// passwordResetRepository implements PasswordResetRepository
+type passwordResetRepository struct{}
+
+// NewPasswordResetRepository creates a new instance of passwordResetRepository
+func NewPasswordResetRepository() PasswordResetRepository {
+	return &passwordResetRepository{}
+}
+
+func (r *passwordResetRepository) Create(ctx context.Context, token *domain.PasswordResetToken) error {
+	query := `
+		INSERT INTO password_reset_tokens (user_id, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`
+
+	token.CreatedAt = time.Now()
+	err := db.DB.QueryRow(ctx, query, token.UserID, token.TokenHash, token.ExpiresAt, token.CreatedAt).Scan(&token.ID)
+	if err != nil {
+		return fmt.Errorf("failed to store password reset token: %w", err)
+	}
+
+	return nil
+}
+
+func (r *passwordResetRepository) GetByHash(ctx context.Context, tokenHash string) (*domain.PasswordResetToken, error) {
+	query := `
+		SELECT id, user_id, token_hash, expires_at, created_at, used_at
+		FROM password_reset_tokens
+		WHERE token_hash = $1
+	`
+
+	var token domain.PasswordResetToken
+	err := db.DB.QueryRow(ctx, query, tokenHash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&token.CreatedAt,
+		&token.UsedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// MarkUsed consumes a token; the conditional update makes concurrent resets with the same token fail
+func (r *passwordResetRepository) MarkUsed(ctx context.Context, id int) error {
+	query := `UPDATE password_reset_tokens SET used_at = $1 WHERE id = $2 AND used_at IS NULL`
+
+	tag, err := db.DB.Exec(ctx, query, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to consume password reset token: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrResetTokenUsed
+	}
+
+	return nil
+}
+
+// InvalidateForUser consumes every outstanding reset token of a user
+func (r *passwordResetRepository) InvalidateForUser(ctx context.Context, userID int) error {
+	query := `UPDATE password_reset_tokens SET used_at = $1 WHERE user_id = $2 AND used_at IS NULL`
+
+	if _, err := db.DB.Exec(ctx, query, time.Now(), userID); err != nil {
+		return fmt.Errorf("failed to invalidate password reset tokens: %w", err)
+	}
+
+	return nil
+}
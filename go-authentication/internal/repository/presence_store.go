@@ -0,0 +1,366 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-authentication/internal/domain"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// maxPresenceUpdateAttempts bounds the retries when other server instances update the same user concurrently
+const maxPresenceUpdateAttempts = 5
+
+// PresenceRecordMaxAge is how long the bucket of live connections keeps an entry. Heartbeats rewrite the
+// records of connected users well within it; it clears the markers of deleted records and the records
+// of crashed server instances that no sweep reached.
+const PresenceRecordMaxAge = 10 * time.Minute
+
+// PresenceStore keeps the live connections of every user and when they were last online.
+// A connection lapses unless its heartbeat is renewed, so a crashed server instance cannot leave users online.
+// Only users with connections have a record; the last seen time of the others is kept apart.
+// Heartbeat and Disconnect return the presence of the user afterwards and whether its status changed;
+// every change is reported to exactly one caller, even across server instances.
+type PresenceStore interface {
+	// Heartbeat records a connection of userID with its status until ttl passes without another heartbeat
+	Heartbeat(ctx context.Context, userID int, connectionID, status string, ttl time.Duration) (*domain.Presence, bool, error)
+	Disconnect(ctx context.Context, userID int, connectionID string) (*domain.Presence, bool, error)
+	Get(ctx context.Context, userID int) (*domain.Presence, error)
+	// Sweep drops the lapsed connections of users with a record and returns the users whose status changed because of it
+	Sweep(ctx context.Context) ([]domain.Presence, error)
+}
+
+// presenceRecord is everything stored about the presence of one user
+type presenceRecord struct {
+	Connections map[string]presenceConnection `json:"connections,omitempty"`
+	// Status is the last status reported as a change, so that each change is reported once
+	Status   string     `json:"status,omitempty"`
+	LastSeen *time.Time `json:"last_seen,omitempty"`
+}
+
+type presenceConnection struct {
+	Status    string    `json:"status"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (r *presenceRecord) heartbeat(connectionID, status string, ttl time.Duration, now time.Time) {
+	if r.Connections == nil {
+		r.Connections = make(map[string]presenceConnection)
+	}
+	r.Connections[connectionID] = presenceConnection{Status: status, ExpiresAt: now.Add(ttl)}
+	if status == domain.PresenceOnline {
+		r.LastSeen = &now
+	}
+}
+
+func (r *presenceRecord) disconnect(connectionID string, now time.Time) {
+	if connection, ok := r.Connections[connectionID]; ok {
+		delete(r.Connections, connectionID)
+		if connection.Status == domain.PresenceOnline {
+			r.LastSeen = &now
+		}
+	}
+}
+
+// hasLapsed reports whether a connection stopped sending heartbeats
+func (r *presenceRecord) hasLapsed(now time.Time) bool {
+	for _, connection := range r.Connections {
+		if !connection.ExpiresAt.After(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// presence derives the status from the connections that have not lapsed
+func (r *presenceRecord) presence(userID int, now time.Time) *domain.Presence {
+	status := domain.PresenceOffline
+	for _, connection := range r.Connections {
+		switch {
+		case !connection.ExpiresAt.After(now):
+		case connection.Status == domain.PresenceOnline:
+			status = domain.PresenceOnline
+		case status == domain.PresenceOffline:
+			status = domain.PresenceAway
+		}
+	}
+	return &domain.Presence{UserID: userID, Status: status, LastSeen: r.LastSeen}
+}
+
+// settle drops lapsed connections and records the status as reported.
+// It reports whether the status differs from the one reported last.
+func (r *presenceRecord) settle(userID int, now time.Time) (*domain.Presence, bool) {
+	presence := r.presence(userID, now)
+	for connectionID, connection := range r.Connections {
+		if !connection.ExpiresAt.After(now) {
+			delete(r.Connections, connectionID)
+		}
+	}
+
+	previous := r.Status
+	if previous == "" {
+		previous = domain.PresenceOffline
+	}
+	r.Status = presence.Status
+	return presence, presence.Status != previous
+}
+
+// memoryPresenceStore implements PresenceStore in process memory, for single instance deployments and tests
+type memoryPresenceStore struct {
+	mu      sync.Mutex
+	records map[int]*presenceRecord
+	seen    map[int]time.Time
+}
+
+// NewMemoryPresenceStore creates a PresenceStore local to this server instance
+func NewMemoryPresenceStore() PresenceStore {
+	return &memoryPresenceStore{records: make(map[int]*presenceRecord), seen: make(map[int]time.Time)}
+}
+
+func (s *memoryPresenceStore) Heartbeat(ctx context.Context, userID int, connectionID, status string, ttl time.Duration) (*domain.Presence, bool, error) {
+	now := time.Now()
+	presence, changed := s.update(userID, now, func(r *presenceRecord) { r.heartbeat(connectionID, status, ttl, now) })
+	return presence, changed, nil
+}
+
+func (s *memoryPresenceStore) Disconnect(ctx context.Context, userID int, connectionID string) (*domain.Presence, bool, error) {
+	now := time.Now()
+	presence, changed := s.update(userID, now, func(r *presenceRecord) { r.disconnect(connectionID, now) })
+	return presence, changed, nil
+}
+
+// Get derives the presence without settling it; lapsed connections are left for Sweep to report
+func (s *memoryPresenceStore) Get(ctx context.Context, userID int) (*domain.Presence, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[userID]
+	if !ok {
+		return s.newRecord(userID).presence(userID, time.Now()), nil
+	}
+	return record.presence(userID, time.Now()), nil
+}
+
+func (s *memoryPresenceStore) Sweep(ctx context.Context) ([]domain.Presence, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	changes := []domain.Presence{}
+	for userID, record := range s.records {
+		if presence, changed := s.settle(userID, record, now); changed {
+			changes = append(changes, *presence)
+		}
+	}
+	return changes, nil
+}
+
+func (s *memoryPresenceStore) update(userID int, now time.Time, change func(r *presenceRecord)) (*domain.Presence, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[userID]
+	if !ok {
+		record = s.newRecord(userID)
+		s.records[userID] = record
+	}
+	change(record)
+	return s.settle(userID, record, now)
+}
+
+// newRecord starts the record of a user without connections from when they were last seen
+func (s *memoryPresenceStore) newRecord(userID int) *presenceRecord {
+	record := &presenceRecord{}
+	if lastSeen, ok := s.seen[userID]; ok {
+		record.LastSeen = &lastSeen
+	}
+	return record
+}
+
+// settle settles a record, dropping it once its last connection is gone
+func (s *memoryPresenceStore) settle(userID int, record *presenceRecord, now time.Time) (*domain.Presence, bool) {
+	presence, changed := record.settle(userID, now)
+	if len(record.Connections) == 0 {
+		if record.LastSeen != nil {
+			s.seen[userID] = *record.LastSeen
+		}
+		delete(s.records, userID)
+	}
+	return presence, changed
+}
+
+// natsPresenceStore implements PresenceStore on NATS JetStream key-value buckets shared by every
+// server instance. Each connected user has one key in the live bucket, written with compare-and-set
+// on its revision and deleted when their last connection goes, so sweeps only read connected users.
+type natsPresenceStore struct {
+	live nats.KeyValue
+	seen nats.KeyValue
+}
+
+// NewNATSPresenceStore creates a PresenceStore shared by every server instance. live holds the
+// connections and should drop entries after PresenceRecordMaxAge; seen keeps when users were last online.
+func NewNATSPresenceStore(live, seen nats.KeyValue) PresenceStore {
+	return &natsPresenceStore{live: live, seen: seen}
+}
+
+func presenceKey(userID int) string {
+	return "user." + strconv.Itoa(userID)
+}
+
+func (s *natsPresenceStore) Heartbeat(ctx context.Context, userID int, connectionID, status string, ttl time.Duration) (*domain.Presence, bool, error) {
+	return s.update(userID, func(r *presenceRecord, now time.Time) bool {
+		r.heartbeat(connectionID, status, ttl, now)
+		return true
+	})
+}
+
+func (s *natsPresenceStore) Disconnect(ctx context.Context, userID int, connectionID string) (*domain.Presence, bool, error) {
+	return s.update(userID, func(r *presenceRecord, now time.Time) bool {
+		r.disconnect(connectionID, now)
+		return true
+	})
+}
+
+// Get derives the presence without writing; lapsed connections are left for Sweep to report
+func (s *natsPresenceStore) Get(ctx context.Context, userID int) (*domain.Presence, error) {
+	record, _, err := s.load(userID)
+	if err != nil {
+		return nil, err
+	}
+	return record.presence(userID, time.Now()), nil
+}
+
+// Sweep reads the users with connections, writing only the records that have lapsed connections
+func (s *natsPresenceStore) Sweep(ctx context.Context) ([]domain.Presence, error) {
+	keys, err := s.live.Keys()
+	if errors.Is(err, nats.ErrNoKeysFound) {
+		return []domain.Presence{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list presence keys: %w", err)
+	}
+
+	changes := []domain.Presence{}
+	for _, key := range keys {
+		userID, err := strconv.Atoi(strings.TrimPrefix(key, "user."))
+		if err != nil {
+			continue
+		}
+		presence, changed, err := s.update(userID, func(r *presenceRecord, now time.Time) bool {
+			return r.hasLapsed(now)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if changed {
+			changes = append(changes, *presence)
+		}
+	}
+	return changes, nil
+}
+
+// load returns the record of userID with its revision, or a new record from when they were last seen and revision 0
+func (s *natsPresenceStore) load(userID int) (*presenceRecord, uint64, error) {
+	entry, err := s.live.Get(presenceKey(userID))
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		lastSeen, err := s.lastSeen(userID)
+		if err != nil {
+			return nil, 0, err
+		}
+		return &presenceRecord{LastSeen: lastSeen}, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get presence: %w", err)
+	}
+
+	var record presenceRecord
+	if err := json.Unmarshal(entry.Value(), &record); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode presence: %w", err)
+	}
+	return &record, entry.Revision(), nil
+}
+
+func (s *natsPresenceStore) lastSeen(userID int) (*time.Time, error) {
+	entry, err := s.seen.Get(presenceKey(userID))
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last seen: %w", err)
+	}
+
+	var lastSeen time.Time
+	if err := json.Unmarshal(entry.Value(), &lastSeen); err != nil {
+		return nil, fmt.Errorf("failed to decode last seen: %w", err)
+	}
+	return &lastSeen, nil
+}
+
+// store writes the record of a connected user over the given revision, or creates it when the revision is 0
+func (s *natsPresenceStore) store(userID int, record *presenceRecord, revision uint64) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if revision == 0 {
+		_, err = s.live.Create(presenceKey(userID), data)
+	} else {
+		_, err = s.live.Update(presenceKey(userID), data, revision)
+	}
+	return err
+}
+
+// remove deletes the record of a user whose last connection went, keeping when they were last seen
+func (s *natsPresenceStore) remove(userID int, record *presenceRecord, revision uint64) error {
+	if record.LastSeen != nil {
+		data, err := json.Marshal(record.LastSeen)
+		if err != nil {
+			return err
+		}
+		if _, err := s.seen.Put(presenceKey(userID), data); err != nil {
+			return err
+		}
+	}
+	if revision == 0 {
+		return nil
+	}
+	return s.live.Delete(presenceKey(userID), nats.LastRevision(revision))
+}
+
+// update applies change to the record of userID and stores it unless change reports there is nothing to write.
+// A write that lost the race against another server instance is retried on the newer record.
+func (s *natsPresenceStore) update(userID int, change func(r *presenceRecord, now time.Time) bool) (*domain.Presence, bool, error) {
+	for attempt := 0; attempt < maxPresenceUpdateAttempts; attempt++ {
+		record, revision, err := s.load(userID)
+		if err != nil {
+			return nil, false, err
+		}
+
+		now := time.Now()
+		if !change(record, now) {
+			return record.presence(userID, now), false, nil
+		}
+		presence, changed := record.settle(userID, now)
+
+		if len(record.Connections) == 0 {
+			err = s.remove(userID, record, revision)
+		} else {
+			err = s.store(userID, record, revision)
+		}
+		// Writing or deleting a stale revision fails like creating an existing key
+		if errors.Is(err, nats.ErrKeyExists) {
+			continue
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to store presence: %w", err)
+		}
+		return presence, changed, nil
+	}
+	return nil, false, errors.New("failed to store presence: too many concurrent updates")
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"go-authentication/db"
+	"go-authentication/internal/domain"
+	"time"
+)
+
+// ReceiptRepository defines the interface for the delivery and read receipts of messages
+type ReceiptRepository interface {
+	MarkDelivered(ctx context.Context, messageID, userID int, at time.Time) (bool, error)
+	MarkRead(ctx context.Context, conversationID, userID, upToMessageID int, at time.Time) (map[int]int, error)
+	ListByMessages(ctx context.Context, messageIDs []int) ([]domain.MessageReceipt, error)
+}
+
+// receiptRepository implements ReceiptRepository
+type receiptRepository struct{}
+
+// NewReceiptRepository creates a new instance of receiptRepository
+func NewReceiptRepository() ReceiptRepository {
+	return &receiptRepository{}
+}
+
+// MarkDelivered records that a message reached userID. It reports false when the
+// message had already been delivered to, or read by, the user.
+func (r *receiptRepository) MarkDelivered(ctx context.Context, messageID, userID int, at time.Time) (bool, error) {
+	query := `
+		INSERT INTO message_receipts (message_id, user_id, delivered_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (message_id, user_id) DO NOTHING
+	`
+
+	tag, err := db.DB.Exec(ctx, query, messageID, userID, at)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark message delivered: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// MarkRead records that userID read the messages of others in a conversation up to upToMessageID.
+// Messages before the user's read position are skipped as they are read already.
+// It returns, per sender, the newest of their messages that was newly read.
+func (r *receiptRepository) MarkRead(ctx context.Context, conversationID, userID, upToMessageID int, at time.Time) (map[int]int, error) {
+	query := `
+		WITH marked AS (
+			INSERT INTO message_receipts (message_id, user_id, delivered_at, read_at)
+			SELECT m.id, $2, $4, $4
+			FROM messages m
+			WHERE m.conversation_id = $1 AND m.id <= $3 AND m.sender_id <> $2
+				AND m.id > COALESCE((
+					SELECT last_read_message_id FROM conversation_members
+					WHERE conversation_id = $1 AND user_id = $2
+				), 0)
+			ON CONFLICT (message_id, user_id) DO UPDATE SET read_at = EXCLUDED.read_at
+				WHERE message_receipts.read_at IS NULL
+			RETURNING message_id
+		)
+		SELECT m.sender_id, MAX(m.id)
+		FROM marked
+		JOIN messages m ON m.id = marked.message_id
+		GROUP BY m.sender_id
+	`
+
+	rows, err := db.DB.Query(ctx, query, conversationID, userID, upToMessageID, at)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark messages read: %w", err)
+	}
+	defer rows.Close()
+
+	latestBySender := make(map[int]int)
+	for rows.Next() {
+		var senderID, messageID int
+		if err := rows.Scan(&senderID, &messageID); err != nil {
+			return nil, err
+		}
+		latestBySender[senderID] = messageID
+	}
+
+	return latestBySender, rows.Err()
+}
+
+// ListByMessages returns the receipts of the given messages, in the order they were delivered
+func (r *receiptRepository) ListByMessages(ctx context.Context, messageIDs []int) ([]domain.MessageReceipt, error) {
+	query := `
+		SELECT message_id, user_id, delivered_at, read_at
+		FROM message_receipts
+		WHERE message_id = ANY($1)
+		ORDER BY message_id, delivered_at, user_id
+	`
+
+	rows, err := db.DB.Query(ctx, query, messageIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list message receipts: %w", err)
+	}
+	defer rows.Close()
+
+	receipts := []domain.MessageReceipt{}
+	for rows.Next() {
+		var receipt domain.MessageReceipt
+		if err := rows.Scan(&receipt.MessageID, &receipt.UserID, &receipt.DeliveredAt, &receipt.ReadAt); err != nil {
+			return nil, err
+		}
+		receipts = append(receipts, receipt)
+	}
+
+	return receipts, rows.Err()
+}
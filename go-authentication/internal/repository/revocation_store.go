@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"go-authentication/db"
+	"sync"
+	"time"
+)
+
+// RevocationStore keeps track of access tokens that must be rejected before they expire.
+// Single tokens are revoked by their jti, a session's tokens by their sid, and "logout everywhere"
+// revokes every token a user was issued up to a point in time.
+type RevocationStore interface {
+	RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error
+	// RevokeSession rejects every token of a session; expiresAt is when the last of them expires
+	RevokeSession(ctx context.Context, sessionID string, expiresAt time.Time) error
+	RevokeUserTokens(ctx context.Context, userID int, issuedBefore time.Time) error
+	// IsRevoked checks a token; sessionID is empty for tokens that do not belong to a session
+	IsRevoked(ctx context.Context, jti, sessionID string, userID int, issuedAt time.Time) (bool, error)
+}
+
+// revocationCutoff returns the instant up to which tokens are revoked.
+// Issue times have millisecond precision, so a token issued in the same millisecond as the revocation is also rejected.
+func revocationCutoff(t time.Time) time.Time {
+	return t.Truncate(time.Millisecond)
+}
+
+// postgresRevocationStore implements RevocationStore on top of PostgreSQL
+type postgresRevocationStore struct{}
+
+// NewPostgresRevocationStore creates a RevocationStore shared by every server instance
+func NewPostgresRevocationStore() RevocationStore {
+	return &postgresRevocationStore{}
+}
+
+func (s *postgresRevocationStore) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	query := `
+		INSERT INTO revoked_tokens (jti, expires_at)
+		VALUES ($1, $2)
+		ON CONFLICT (jti) DO NOTHING
+	`
+	if _, err := db.DB.Exec(ctx, query, jti, expiresAt); err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+
+	// Expired tokens are rejected by signature validation anyway, so there is no need to keep them
+	if _, err := db.DB.Exec(ctx, `DELETE FROM revoked_tokens WHERE expires_at < $1`, time.Now()); err != nil {
+		return fmt.Errorf("failed to prune revoked tokens: %w", err)
+	}
+
+	return nil
+}
+
+func (s *postgresRevocationStore) RevokeSession(ctx context.Context, sessionID string, expiresAt time.Time) error {
+	query := `
+		INSERT INTO revoked_sessions (session_id, expires_at)
+		VALUES ($1, $2)
+		ON CONFLICT (session_id) DO UPDATE SET expires_at = GREATEST(revoked_sessions.expires_at, EXCLUDED.expires_at)
+	`
+	if _, err := db.DB.Exec(ctx, query, sessionID, expiresAt); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	if _, err := db.DB.Exec(ctx, `DELETE FROM revoked_sessions WHERE expires_at < $1`, time.Now()); err != nil {
+		return fmt.Errorf("failed to prune revoked sessions: %w", err)
+	}
+
+	return nil
+}
+
+func (s *postgresRevocationStore) RevokeUserTokens(ctx context.Context, userID int, issuedBefore time.Time) error {
+	query := `
+		INSERT INTO user_token_revocations (user_id, revoked_before)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET revoked_before = GREATEST(user_token_revocations.revoked_before, EXCLUDED.revoked_before)
+	`
+	if _, err := db.DB.Exec(ctx, query, userID, revocationCutoff(issuedBefore)); err != nil {
+		return fmt.Errorf("failed to revoke user tokens: %w", err)
+	}
+
+	return nil
+}
+
+func (s *postgresRevocationStore) IsRevoked(ctx context.Context, jti, sessionID string, userID int, issuedAt time.Time) (bool, error) {
+	query := `
+		SELECT
+			EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)
+			OR EXISTS (SELECT 1 FROM revoked_sessions WHERE session_id = $2)
+			OR EXISTS (SELECT 1 FROM user_token_revocations WHERE user_id = $3 AND revoked_before >= $4)
+	`
+
+	var revoked bool
+	if err := db.DB.QueryRow(ctx, query, jti, sessionID, userID, issuedAt).Scan(&revoked); err != nil {
+		return false, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+
+	return revoked, nil
+}
+
+// memoryRevocationStore implements RevocationStore in process memory.
+// It is meant for single instance deployments and tests; revocations are lost on restart.
+type memoryRevocationStore struct {
+	mu         sync.RWMutex
+	tokens     map[string]time.Time
+	sessions   map[string]time.Time
+	userCutoff map[int]time.Time
+}
+
+// NewMemoryRevocationStore creates an in-memory RevocationStore
+func NewMemoryRevocationStore() RevocationStore {
+	return &memoryRevocationStore{
+		tokens:     make(map[string]time.Time),
+		sessions:   make(map[string]time.Time),
+		userCutoff: make(map[int]time.Time),
+	}
+}
+
+func (s *memoryRevocationStore) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, exp := range s.tokens {
+		if exp.Before(now) {
+			delete(s.tokens, id)
+		}
+	}
+
+	s.tokens[jti] = expiresAt
+	return nil
+}
+
+func (s *memoryRevocationStore) RevokeSession(ctx context.Context, sessionID string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, exp := range s.sessions {
+		if exp.Before(now) {
+			delete(s.sessions, id)
+		}
+	}
+
+	if expiresAt.After(s.sessions[sessionID]) {
+		s.sessions[sessionID] = expiresAt
+	}
+	return nil
+}
+
+func (s *memoryRevocationStore) RevokeUserTokens(ctx context.Context, userID int, issuedBefore time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := revocationCutoff(issuedBefore)
+	if cutoff.After(s.userCutoff[userID]) {
+		s.userCutoff[userID] = cutoff
+	}
+	return nil
+}
+
+func (s *memoryRevocationStore) IsRevoked(ctx context.Context, jti, sessionID string, userID int, issuedAt time.Time) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.tokens[jti]; ok {
+		return true, nil
+	}
+	if _, ok := s.sessions[sessionID]; ok && sessionID != "" {
+		return true, nil
+	}
+	if cutoff, ok := s.userCutoff[userID]; ok && !issuedAt.After(cutoff) {
+		return true, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-authentication/db"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrRoleNotFound is returned when a role name does not exist
+var ErrRoleNotFound = errors.New("role not found")
+
+// RoleRepository defines the interface for role and permission storage
+type RoleRepository interface {
+	GetUserRoles(ctx context.Context, userID int) ([]string, error)
+	GetUserPermissions(ctx context.Context, userID int) ([]string, error)
+	AssignRole(ctx context.Context, userID int, role string) error
+	RemoveRole(ctx context.Context, userID int, role string) error
+}
+
+// roleRepository implements RoleRepository
+type roleRepository struct{}
+
+// NewRoleRepository creates a new instance of roleRepository
+func NewRoleRepository() RoleRepository {
+	return &roleRepository{}
+}
+
+func (r *roleRepository) GetUserRoles(ctx context.Context, userID int) ([]string, error) {
+	query := `
+		SELECT r.name
+		FROM user_roles ur
+		JOIN roles r ON r.id = ur.role_id
+		WHERE ur.user_id = $1
+		ORDER BY r.name
+	`
+
+	return queryNames(ctx, query, userID)
+}
+
+func (r *roleRepository) GetUserPermissions(ctx context.Context, userID int) ([]string, error) {
+	query := `
+		SELECT DISTINCT p.name
+		FROM user_roles ur
+		JOIN role_permissions rp ON rp.role_id = ur.role_id
+		JOIN permissions p ON p.id = rp.permission_id
+		WHERE ur.user_id = $1
+		ORDER BY p.name
+	`
+
+	return queryNames(ctx, query, userID)
+}
+
+// AssignRole grants a role; granting a role the user already holds is a no-op
+func (r *roleRepository) AssignRole(ctx context.Context, userID int, role string) error {
+	var roleID int
+	err := db.DB.QueryRow(ctx, `SELECT id FROM roles WHERE name = $1`, role).Scan(&roleID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrRoleNotFound
+		}
+		return err
+	}
+
+	query := `
+		INSERT INTO user_roles (user_id, role_id)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id, role_id) DO NOTHING
+	`
+	if _, err := db.DB.Exec(ctx, query, userID, roleID); err != nil {
+		return fmt.Errorf("failed to assign role %s: %w", role, err)
+	}
+
+	return nil
+}
+
+func (r *roleRepository) RemoveRole(ctx context.Context, userID int, role string) error {
+	var roleID int
+	err := db.DB.QueryRow(ctx, `SELECT id FROM roles WHERE name = $1`, role).Scan(&roleID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrRoleNotFound
+		}
+		return err
+	}
+
+	query := `DELETE FROM user_roles WHERE user_id = $1 AND role_id = $2`
+	if _, err := db.DB.Exec(ctx, query, userID, roleID); err != nil {
+		return fmt.Errorf("failed to remove role %s: %w", role, err)
+	}
+
+	return nil
+}
+
+// queryNames runs a query returning a single text column
+func queryNames(ctx context.Context, query string, args ...interface{}) ([]string, error) {
+	rows, err := db.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+
+	return names, rows.Err()
+}
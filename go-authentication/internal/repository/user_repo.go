@@ -2,15 +2,30 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"go-authentication/db"
 	"go-authentication/internal/domain"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
+// ErrEmailTaken is returned by UpdateEmail when another account uses the address
+var ErrEmailTaken = errors.New("email is already in use")
+
 // UserRepository defines the interface for user operations
 type UserRepository interface {
 	Create(ctx context.Context, user *domain.User) error
 	GetByEmail(ctx context.Context, email string) (*domain.User, error)
 	GetByID(ctx context.Context, id int) (*domain.User, error)
+	MarkEmailVerified(ctx context.Context, id int, verifiedAt time.Time) error
+	UpdatePassword(ctx context.Context, id int, passwordHash string) error
+	// UpdateProfile saves the name, display name, bio and status text of user
+	UpdateProfile(ctx context.Context, user *domain.User) error
+	// UpdateEmail replaces the address of an account with one verified at verifiedAt
+	UpdateEmail(ctx context.Context, id int, email string, verifiedAt time.Time) error
 }
 
 // userRepository implements UserRepository
@@ -21,34 +36,81 @@ func NewUserRepository() UserRepository {
 	return &userRepository{}
 }
 
-func (r *userRepository) Create(ctx context.Context, user *domain.User) error {
-	query := `INSERT INTO users (name, email, password) VALUES ($1, $2, $3)`
-	_, err := db.DB.Exec(ctx, query, user.Name, user.Email, user.Password)
-	return err
-}
-
-func (r *userRepository) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
-	query := `SELECT id, name, email, password, created_at FROM users WHERE email = $1`
-	row := db.DB.QueryRow(ctx, query, email)
+const userColumns = `id, name, email, password, created_at, email_verified_at, display_name, bio, status_text, updated_at`
 
+func scanUser(row pgx.Row) (*domain.User, error) {
 	var user domain.User
-	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.CreatedAt)
+	err := row.Scan(
+		&user.ID,
+		&user.Name,
+		&user.Email,
+		&user.Password,
+		&user.CreatedAt,
+		&user.EmailVerifiedAt,
+		&user.DisplayName,
+		&user.Bio,
+		&user.StatusText,
+		&user.UpdatedAt,
+	)
 	if err != nil {
 		return nil, err
 	}
-
 	return &user, nil
 }
 
+func (r *userRepository) Create(ctx context.Context, user *domain.User) error {
+	query := `INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id, created_at, updated_at`
+	return db.DB.QueryRow(ctx, query, user.Name, user.Email, user.Password).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+}
+
+// GetByEmail finds a user by email address, ignoring case
+func (r *userRepository) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE lower(email) = lower($1)`
+	return scanUser(db.DB.QueryRow(ctx, query, email))
+}
+
 func (r *userRepository) GetByID(ctx context.Context, id int) (*domain.User, error) {
-	query := `SELECT id, name, email, password, created_at FROM users WHERE id = $1`
-	row := db.DB.QueryRow(ctx, query, id)
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
+	return scanUser(db.DB.QueryRow(ctx, query, id))
+}
 
-	var user domain.User
-	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.CreatedAt)
+func (r *userRepository) MarkEmailVerified(ctx context.Context, id int, verifiedAt time.Time) error {
+	query := `UPDATE users SET email_verified_at = $1 WHERE id = $2 AND email_verified_at IS NULL`
+	_, err := db.DB.Exec(ctx, query, verifiedAt, id)
+	return err
+}
+
+func (r *userRepository) UpdatePassword(ctx context.Context, id int, passwordHash string) error {
+	query := `UPDATE users SET password = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`
+	_, err := db.DB.Exec(ctx, query, passwordHash, id)
+	return err
+}
+
+func (r *userRepository) UpdateProfile(ctx context.Context, user *domain.User) error {
+	query := `
+		UPDATE users SET name = $1, display_name = $2, bio = $3, status_text = $4, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $5
+		RETURNING updated_at
+	`
+
+	err := db.DB.QueryRow(ctx, query, user.Name, user.DisplayName, user.Bio, user.StatusText, user.ID).Scan(&user.UpdatedAt)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("failed to update profile: %w", err)
 	}
 
-	return &user, nil
+	return nil
+}
+
+func (r *userRepository) UpdateEmail(ctx context.Context, id int, email string, verifiedAt time.Time) error {
+	query := `UPDATE users SET email = $1, email_verified_at = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $3`
+
+	if _, err := db.DB.Exec(ctx, query, email, verifiedAt, id); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrEmailTaken
+		}
+		return fmt.Errorf("failed to update email: %w", err)
+	}
+
+	return nil
 }
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"go-authentication/db"
+	"go-authentication/internal/domain"
+	"strings"
+)
+
+// UserSearchRepository defines the interface for the user directory search
+type UserSearchRepository interface {
+	Search(ctx context.Context, filter *domain.UserSearchFilter) ([]domain.UserSearchResult, error)
+}
+
+// userSearchRepository implements UserSearchRepository
+type userSearchRepository struct{}
+
+// NewUserSearchRepository creates a new instance of userSearchRepository
+func NewUserSearchRepository() UserSearchRepository {
+	return &userSearchRepository{}
+}
+
+// likeEscaper escapes the LIKE wildcards in a search term so it only matches literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Search matches the query against the start of the name, of any word in the name and of the email,
+// and by trigram similarity to tolerate typos. Prefix matches rank above similar ones.
+// The email is only matched, never returned.
+func (r *userSearchRepository) Search(ctx context.Context, filter *domain.UserSearchFilter) ([]domain.UserSearchResult, error) {
+	query := `
+		SELECT id, name, display_name, bio, status_text, created_at, score FROM (
+			SELECT u.id, u.name, u.display_name, u.bio, u.status_text, u.created_at,
+				CASE WHEN lower(u.name) LIKE $2 || '%' OR lower(u.email) LIKE $2 || '%' THEN 1000 ELSE 0 END
+					+ (GREATEST(similarity(lower(u.name), $3), similarity(lower(u.email), $3)) * 1000)::int AS score
+			FROM users u
+			WHERE u.id <> $1
+				AND (
+					lower(u.name) LIKE $2 || '%'
+					OR lower(u.name) LIKE '% ' || $2 || '%'
+					OR lower(u.email) LIKE $2 || '%'
+					OR lower(u.name) % $3
+					OR lower(u.email) % $3
+				)
+				AND NOT EXISTS (SELECT 1 FROM user_blocks b WHERE b.blocker_id = u.id AND b.blocked_id = $1)
+		) matches
+		WHERE $4 = 0 OR score < $5 OR (score = $5 AND id > $4)
+		ORDER BY score DESC, id ASC
+		LIMIT $6
+	`
+
+	term := strings.ToLower(filter.Query)
+	rows, err := db.DB.Query(ctx, query, filter.CallerID, likeEscaper.Replace(term), term, filter.AfterID, filter.AfterScore, filter.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search users: %w", err)
+	}
+	defer rows.Close()
+
+	results := []domain.UserSearchResult{}
+	for rows.Next() {
+		var result domain.UserSearchResult
+		err := rows.Scan(
+			&result.ID,
+			&result.Name,
+			&result.DisplayName,
+			&result.Bio,
+			&result.StatusText,
+			&result.CreatedAt,
+			&result.Score,
+		)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	return results, rows.Err()
+}
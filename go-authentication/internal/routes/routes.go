@@ -3,28 +3,147 @@ package routes
 import (
 	"go-authentication/handlers"
 	"go-authentication/internal/delivery"
+	"go-authentication/internal/domain"
 
 	"github.com/gin-gonic/gin"
 )
 
 // SetupRoutes defines API routes
-func SetupRoutes(router *gin.Engine, authHandler *delivery.AuthHandler, chatHandler *delivery.ChatHandler, wsHandler *delivery.WebSocketHandler, messageHandler *handlers.MessageHandler) {
+func SetupRoutes(router *gin.Engine, authHandler *delivery.AuthHandler, passwordHandler *delivery.PasswordHandler, profileHandler *delivery.ProfileHandler, mfaHandler *delivery.MFAHandler, passkeyHandler *delivery.PasskeyHandler, adminHandler *delivery.AdminHandler, apiKeyHandler *delivery.APIKeyHandler, sessionHandler *delivery.SessionHandler, oidcHandler *delivery.OIDCHandler, oauthHandler *delivery.OAuthHandler, auditHandler *delivery.AuditHandler, blockHandler *delivery.BlockHandler, presenceHandler *delivery.PresenceHandler, chatHandler *delivery.ChatHandler, groupHandler *delivery.GroupHandler, wsHandler *delivery.WebSocketHandler, messageHandler *handlers.MessageHandler) {
 	// Public routes
 	router.POST("/signup", authHandler.SignupHandler)
 	router.POST("/login", authHandler.LoginHandler)
+	router.POST("/login/mfa", authHandler.LoginMFAHandler)
+	router.POST("/login/passkey/options", authHandler.LoginPasskeyOptionsHandler)
+	router.POST("/login/passkey", authHandler.LoginPasskeyHandler)
+	router.POST("/token/refresh", authHandler.RefreshHandler)
+	router.GET("/.well-known/jwks.json", authHandler.JWKSHandler)
+	router.GET("/verify-email", authHandler.VerifyEmailHandler)
+	router.POST("/verify-email/resend", authHandler.ResendVerificationHandler)
+	router.POST("/password/forgot", passwordHandler.ForgotPasswordHandler)
+	router.GET("/password/reset", passwordHandler.ResetPasswordFormHandler)
+	router.POST("/password/reset", passwordHandler.ResetPasswordHandler)
+	router.GET("/email/change/confirm", profileHandler.ConfirmEmailChangeHandler)
+	router.GET("/oidc/login", oidcHandler.LoginHandler)
+	router.GET("/oidc/callback", oidcHandler.CallbackHandler)
+
+	// OAuth endpoints called by client applications, which authenticate with their own credentials
+	router.POST("/oauth/token", oauthHandler.TokenHandler)
+	router.POST("/oauth/introspect", oauthHandler.IntrospectHandler)
+	router.POST("/oauth/revoke", oauthHandler.RevokeHandler)
 
 	// Protected routes
 	auth := router.Group("/")
-	auth.Use(delivery.AuthMiddleware())
+	auth.Use(delivery.AuthMiddleware(authHandler.AuthUsecase))
 	{
+		auth.POST("/logout", authHandler.LogoutHandler)
+		auth.POST("/logout/all", delivery.RequireTokenAuth(), authHandler.LogoutAllHandler)
+
+		// Profile routes
+		auth.GET("/me", profileHandler.GetMeHandler)
+		auth.PATCH("/me", profileHandler.UpdateMeHandler)
+		auth.POST("/me/password", delivery.RequireTokenAuth(), passwordHandler.ChangePasswordHandler)
+		auth.POST("/me/email", delivery.RequireTokenAuth(), profileHandler.ChangeEmailHandler)
+		auth.GET("/me/security-events", delivery.RequireTokenAuth(), auditHandler.SecurityEventsHandler)
+		auth.GET("/me/blocks", blockHandler.ListHandler)
+		auth.POST("/me/blocks", blockHandler.BlockHandler)
+		auth.DELETE("/me/blocks/:user_id", blockHandler.UnblockHandler)
+		auth.GET("/users/search", profileHandler.SearchUsersHandler)
+		auth.GET("/users/:id", profileHandler.GetUserHandler)
+		auth.GET("/users/:id/presence", presenceHandler.GetHandler)
+
+		// Two-factor authentication routes
+		mfa := auth.Group("/mfa")
+		mfa.Use(delivery.RequireTokenAuth())
+		{
+			mfa.POST("/totp/enroll", mfaHandler.EnrollHandler)
+			mfa.POST("/totp/enable", mfaHandler.EnableHandler)
+			mfa.POST("/totp/disable", mfaHandler.DisableHandler)
+			mfa.POST("/recovery-codes", mfaHandler.RecoveryCodesHandler)
+		}
+
+		// Passkey routes
+		passkeys := auth.Group("/passkeys")
+		passkeys.Use(delivery.RequireTokenAuth())
+		{
+			passkeys.POST("/register/options", passkeyHandler.RegistrationOptionsHandler)
+			passkeys.POST("/register", passkeyHandler.RegisterHandler)
+			passkeys.GET("", passkeyHandler.ListHandler)
+			passkeys.DELETE("/:id", passkeyHandler.DeleteHandler)
+		}
+
+		// Session routes
+		sessions := auth.Group("/sessions")
+		sessions.Use(delivery.RequireTokenAuth())
+		{
+			sessions.GET("", sessionHandler.ListHandler)
+			sessions.DELETE("/:id", sessionHandler.RevokeHandler)
+		}
+
+		// API key routes; keys cannot be used to manage keys
+		apiKeys := auth.Group("/api-keys")
+		apiKeys.Use(delivery.RequireTokenAuth())
+		{
+			apiKeys.POST("", apiKeyHandler.CreateHandler)
+			apiKeys.GET("", apiKeyHandler.ListHandler)
+			apiKeys.DELETE("/:id", apiKeyHandler.RevokeHandler)
+		}
+
+		// OAuth authorization and consent routes for the signed in user
+		oauth := auth.Group("/oauth")
+		oauth.Use(delivery.RequireTokenAuth())
+		{
+			oauth.GET("/authorize", delivery.RequireVerifiedEmail(), oauthHandler.AuthorizeHandler)
+			oauth.POST("/authorize", delivery.RequireVerifiedEmail(), oauthHandler.ConsentHandler)
+			oauth.GET("/consents", oauthHandler.ListConsentsHandler)
+			oauth.DELETE("/consents/:client_id", oauthHandler.RevokeConsentHandler)
+		}
+
+		// Admin routes
+		admin := auth.Group("/admin")
+		{
+			admin.POST("/users/:id/unlock", delivery.RequirePermission(domain.PermissionUsersUnlock), adminHandler.UnlockUserHandler)
+			admin.GET("/users/:id/roles", delivery.RequirePermission(domain.PermissionRolesAssign), adminHandler.GetUserRolesHandler)
+			admin.POST("/users/:id/roles", delivery.RequirePermission(domain.PermissionRolesAssign), adminHandler.AssignRoleHandler)
+			admin.DELETE("/users/:id/roles/:role", delivery.RequirePermission(domain.PermissionRolesAssign), adminHandler.RemoveRoleHandler)
+			admin.POST("/oauth/clients", delivery.RequirePermission(domain.PermissionOAuthClientsManage), oauthHandler.CreateClientHandler)
+			admin.GET("/oauth/clients", delivery.RequirePermission(domain.PermissionOAuthClientsManage), oauthHandler.ListClientsHandler)
+			admin.DELETE("/oauth/clients/:client_id", delivery.RequirePermission(domain.PermissionOAuthClientsManage), oauthHandler.RevokeClientHandler)
+			admin.GET("/auth-events", delivery.RequirePermission(domain.PermissionAuditRead), auditHandler.QueryHandler)
+		}
+
+		// Moderation routes, open to moderators and admins
+		moderation := auth.Group("/moderation")
+		moderation.Use(delivery.RequirePermission(domain.PermissionMessagesModerate))
+		{
+			moderation.DELETE("/messages/:id", chatHandler.RemoveMessageHandler)
+		}
+
 		// Chat routes
 		chat := auth.Group("/chat")
+		chat.Use(delivery.RequireVerifiedEmail())
 		{
 			chat.POST("/send", chatHandler.SendMessageHandler)
 			chat.GET("/messages/:user_id", chatHandler.GetConversationMessagesHandler)
+			chat.POST("/conversations", groupHandler.CreateHandler)
+			chat.GET("/conversations/:id", groupHandler.GetHandler)
+			chat.PATCH("/conversations/:id", groupHandler.RenameHandler)
+			chat.GET("/conversations/:id/messages", chatHandler.GetConversationHistoryHandler)
+			chat.POST("/conversations/:id/messages", chatHandler.SendToConversationHandler)
+			chat.POST("/conversations/:id/members", groupHandler.InviteHandler)
+			chat.PATCH("/conversations/:id/members/:user_id", groupHandler.UpdateMemberRoleHandler)
+			chat.DELETE("/conversations/:id/members/:user_id", groupHandler.RemoveMemberHandler)
+			chat.POST("/conversations/:id/leave", groupHandler.LeaveHandler)
+			chat.GET("/mutes", chatHandler.ListMutesHandler)
+			chat.POST("/mutes", chatHandler.MuteHandler)
+			chat.DELETE("/mutes/:conversation_id", chatHandler.UnmuteHandler)
 		}
 
+		// Inbox of the signed in user and read receipts
+		auth.GET("/conversations", delivery.RequireVerifiedEmail(), chatHandler.InboxHandler)
+		auth.POST("/conversations/:id/read", delivery.RequireVerifiedEmail(), chatHandler.MarkReadHandler)
+
 		// WebSocket route
-		auth.GET("/ws", wsHandler.HandleWebSocket)
+		auth.GET("/ws", delivery.RequireVerifiedEmail(), wsHandler.HandleWebSocket)
 	}
 }
@@ -2,6 +2,7 @@ package services
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -57,44 +58,146 @@ func NewNatsService() (*NatsService, error) {
 	return natsService, nil
 }
 
-// GetPrivateSubject creates a unique subject for private messaging between two users
-func GetPrivateSubject(user1ID, user2ID int) string {
-	// Ensure consistent subject ordering by comparing user IDs
-	if user1ID < user2ID {
-		return fmt.Sprintf("chat.private.%d.%d", user1ID, user2ID)
+// UserSubject is the subject carrying every message addressed to a user, whatever the conversation
+func UserSubject(userID int) string {
+	return fmt.Sprintf("chat.user.%d", userID)
+}
+
+// SubscribeToUserMessages subscribes to the messages of every conversation the user is a member of
+func (s *NatsService) SubscribeToUserMessages(userID int, messageHandler func(msg *domain.Message)) (*nats.Subscription, error) {
+	return s.SubscribeToSubject(UserSubject(userID), messageHandler)
+}
+
+// PublishMessage fans a message out to the subject of every recipient, the sender included
+// so that their other devices see it too
+func (s *NatsService) PublishMessage(message *domain.Message, recipientIDs []int) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("error marshaling message: %v", err)
 	}
-	return fmt.Sprintf("chat.private.%d.%d", user2ID, user1ID)
+
+	for _, userID := range recipientIDs {
+		if err := s.nc.Publish(UserSubject(userID), data); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// SubscribeToPrivateMessages subscribes to private messages for a specific user
-func (s *NatsService) SubscribeToPrivateMessages(userID int, messageHandler func(msg *domain.Message)) error {
-	// Subscribe to all private messages where this user is either sender or receiver
-	pattern := fmt.Sprintf("chat.private.%d.*", userID)
-	_, err := s.nc.Subscribe(pattern, func(msg *nats.Msg) {
-		var message domain.Message
-		if err := json.Unmarshal(msg.Data, &message); err != nil {
-			log.Printf("Error unmarshaling message: %v", err)
+// ReceiptSubject is the subject carrying the receipts of the messages a user sent
+func ReceiptSubject(userID int) string {
+	return fmt.Sprintf("chat.receipts.%d", userID)
+}
+
+// PublishReceipt pushes a delivery or read receipt to the sender of the messages
+func (s *NatsService) PublishReceipt(senderID int, event *domain.ReceiptEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("error marshaling receipt: %v", err)
+	}
+
+	return s.nc.Publish(ReceiptSubject(senderID), data)
+}
+
+// SubscribeToReceipts calls handler for every receipt of the messages userID sent
+func (s *NatsService) SubscribeToReceipts(userID int, handler func(event *domain.ReceiptEvent)) (*nats.Subscription, error) {
+	return s.nc.Subscribe(ReceiptSubject(userID), func(msg *nats.Msg) {
+		var event domain.ReceiptEvent
+		if err := json.Unmarshal(msg.Data, &event); err != nil {
+			log.Printf("Error unmarshaling receipt: %v", err)
 			return
 		}
-		messageHandler(&message)
+		handler(&event)
 	})
-	return err
 }
 
-// SendPrivateMessage sends a private message from one user to another
-func (s *NatsService) SendPrivateMessage(message *domain.Message) error {
-	data, err := json.Marshal(message)
+// TypingSubject is the subject carrying the typing indicators of the conversations of a user
+func TypingSubject(userID int) string {
+	return fmt.Sprintf("chat.typing.%d", userID)
+}
+
+// PublishTyping relays a typing indicator to every recipient. Core NATS keeps nothing,
+// so recipients who are offline never see it.
+func (s *NatsService) PublishTyping(event *domain.TypingEvent, recipientIDs []int) error {
+	data, err := json.Marshal(event)
 	if err != nil {
-		return fmt.Errorf("error marshaling message: %v", err)
+		return fmt.Errorf("error marshaling typing event: %v", err)
 	}
 
-	subject := GetPrivateSubject(message.SenderID, message.ReceiverID)
-	return s.nc.Publish(subject, data)
+	for _, userID := range recipientIDs {
+		if err := s.nc.Publish(TypingSubject(userID), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SubscribeToTyping calls handler for every typing indicator of the conversations of userID
+func (s *NatsService) SubscribeToTyping(userID int, handler func(event *domain.TypingEvent)) (*nats.Subscription, error) {
+	return s.nc.Subscribe(TypingSubject(userID), func(msg *nats.Msg) {
+		var event domain.TypingEvent
+		if err := json.Unmarshal(msg.Data, &event); err != nil {
+			log.Printf("Error unmarshaling typing event: %v", err)
+			return
+		}
+		handler(&event)
+	})
+}
+
+// PresenceSubject is the subject carrying the presence changes of the contacts of a user
+func PresenceSubject(userID int) string {
+	return fmt.Sprintf("chat.presence.%d", userID)
+}
+
+// PublishPresence pushes a presence change to every contact of the user
+func (s *NatsService) PublishPresence(presence *domain.Presence, contactIDs []int) error {
+	data, err := json.Marshal(presence)
+	if err != nil {
+		return fmt.Errorf("error marshaling presence: %v", err)
+	}
+
+	for _, userID := range contactIDs {
+		if err := s.nc.Publish(PresenceSubject(userID), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SubscribeToPresence calls handler for every presence change of the contacts of userID
+func (s *NatsService) SubscribeToPresence(userID int, handler func(presence *domain.Presence)) (*nats.Subscription, error) {
+	return s.nc.Subscribe(PresenceSubject(userID), func(msg *nats.Msg) {
+		var presence domain.Presence
+		if err := json.Unmarshal(msg.Data, &presence); err != nil {
+			log.Printf("Error unmarshaling presence: %v", err)
+			return
+		}
+		handler(&presence)
+	})
+}
+
+// KeyValue opens a JetStream key-value bucket, creating it when it does not exist yet.
+// Entries of a new bucket are dropped after maxAge unless it is zero.
+// It fails when the NATS server runs without JetStream.
+func (s *NatsService) KeyValue(bucket string, maxAge time.Duration) (nats.KeyValue, error) {
+	js, err := s.nc.JetStream()
+	if err != nil {
+		return nil, fmt.Errorf("error opening JetStream: %v", err)
+	}
+
+	kv, err := js.KeyValue(bucket)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		kv, err = js.CreateKeyValue(&nats.KeyValueConfig{Bucket: bucket, History: 1, TTL: maxAge})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening key-value bucket %s: %v", bucket, err)
+	}
+	return kv, nil
 }
 
 // SubscribeToSubject subscribes to a specific NATS subject
-func (s *NatsService) SubscribeToSubject(subject string, callback func(*domain.Message)) error {
-	_, err := s.nc.Subscribe(subject, func(msg *nats.Msg) {
+func (s *NatsService) SubscribeToSubject(subject string, callback func(*domain.Message)) (*nats.Subscription, error) {
+	return s.nc.Subscribe(subject, func(msg *nats.Msg) {
 		var message domain.Message
 		if err := json.Unmarshal(msg.Data, &message); err != nil {
 			log.Printf("Error unmarshaling message: %v", err)
@@ -102,6 +205,31 @@ func (s *NatsService) SubscribeToSubject(subject string, callback func(*domain.M
 		}
 		callback(&message)
 	})
+}
+
+// sessionRevokedSubject carries SessionRevokedEvents to every server instance
+const sessionRevokedSubject = "auth.sessions.revoked"
+
+// PublishSessionRevoked announces an ended session to every server instance
+func (s *NatsService) PublishSessionRevoked(event *domain.SessionRevokedEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("error marshaling session event: %v", err)
+	}
+
+	return s.nc.Publish(sessionRevokedSubject, data)
+}
+
+// SubscribeToSessionRevocations calls handler for every session ended on any server instance
+func (s *NatsService) SubscribeToSessionRevocations(handler func(event *domain.SessionRevokedEvent)) error {
+	_, err := s.nc.Subscribe(sessionRevokedSubject, func(msg *nats.Msg) {
+		var event domain.SessionRevokedEvent
+		if err := json.Unmarshal(msg.Data, &event); err != nil {
+			log.Printf("Error unmarshaling session event: %v", err)
+			return
+		}
+		handler(&event)
+	})
 	return err
 }
 
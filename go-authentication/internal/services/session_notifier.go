@@ -0,0 +1,41 @@
+package services
+
+import (
+	"go-authentication/internal/domain"
+	"sync"
+)
+
+// SessionNotifier broadcasts ended sessions so every server instance can close the live connections using them
+type SessionNotifier interface {
+	PublishSessionRevoked(event *domain.SessionRevokedEvent) error
+	SubscribeToSessionRevocations(handler func(event *domain.SessionRevokedEvent)) error
+}
+
+// LocalSessionNotifier delivers session revocations within a single process
+type LocalSessionNotifier struct {
+	mu       sync.RWMutex
+	handlers []func(event *domain.SessionRevokedEvent)
+}
+
+// NewLocalSessionNotifier creates an in-process SessionNotifier for single instance deployments and tests
+func NewLocalSessionNotifier() *LocalSessionNotifier {
+	return &LocalSessionNotifier{}
+}
+
+func (n *LocalSessionNotifier) PublishSessionRevoked(event *domain.SessionRevokedEvent) error {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	for _, handler := range n.handlers {
+		handler(event)
+	}
+	return nil
+}
+
+func (n *LocalSessionNotifier) SubscribeToSessionRevocations(handler func(event *domain.SessionRevokedEvent)) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.handlers = append(n.handlers, handler)
+	return nil
+}
@@ -0,0 +1,226 @@
+package usecase
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/repository"
+	"go-authentication/pkg"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	// apiKeyPrefix marks our keys so they are easy to recognise, e.g. by secret scanners
+	apiKeyPrefix            = "gak_"
+	apiKeyPrefixBytes       = 6
+	apiKeySecretBytes       = 32
+	defaultAPIKeyExpiryDays = 90
+	maxAPIKeyExpiryDays     = 365
+	maxAPIKeyNameLength     = 100
+	apiKeyTouchThreshold    = time.Minute
+)
+
+var (
+	ErrInvalidAPIKey       = errors.New("invalid or expired api key")
+	ErrAPIKeyNotFound      = errors.New("api key not found")
+	ErrInvalidAPIKeyName   = fmt.Errorf("api key name must be between 1 and %d characters", maxAPIKeyNameLength)
+	ErrInvalidAPIKeyExpiry = fmt.Errorf("api key expiry must be between 1 and %d days", maxAPIKeyExpiryDays)
+)
+
+// InvalidScopeError is returned when a key is requested with scopes its owner does not hold
+type InvalidScopeError struct {
+	Scopes []string
+}
+
+func (e *InvalidScopeError) Error() string {
+	return "scopes not granted to you: " + strings.Join(e.Scopes, ", ")
+}
+
+// APIKeyUsecase manages personal API keys for bots and service accounts.
+// A key has the form gak_<prefix>.<secret>; only the prefix and a hash of the whole key are stored.
+type APIKeyUsecase struct {
+	APIKeyRepo repository.APIKeyRepository
+	UserRepo   repository.UserRepository
+	Roles      *RoleUsecase
+	Audit      *AuditUsecase
+}
+
+// NewAPIKeyUsecase creates a new instance of APIKeyUsecase
+func NewAPIKeyUsecase(apiKeyRepository repository.APIKeyRepository, userRepository repository.UserRepository, roleUsecase *RoleUsecase, auditUsecase *AuditUsecase) *APIKeyUsecase {
+	return &APIKeyUsecase{
+		APIKeyRepo: apiKeyRepository,
+		UserRepo:   userRepository,
+		Roles:      roleUsecase,
+		Audit:      auditUsecase,
+	}
+}
+
+// Create issues a new key. The scopes must be permissions the user currently holds.
+// The returned key is shown once and cannot be recovered later.
+func (uc *APIKeyUsecase) Create(ctx context.Context, userID int, req *domain.CreateAPIKeyRequest) (*domain.CreatedAPIKey, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len([]rune(name)) > maxAPIKeyNameLength {
+		return nil, ErrInvalidAPIKeyName
+	}
+
+	days := req.ExpiresInDays
+	if days == 0 {
+		days = defaultAPIKeyExpiryDays
+	}
+	if days < 1 || days > maxAPIKeyExpiryDays {
+		return nil, ErrInvalidAPIKeyExpiry
+	}
+
+	userRoles, err := uc.Roles.UserRoles(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes, err := grantableScopes(req.Scopes, userRoles.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix, err := pkg.GenerateOpaqueToken(apiKeyPrefixBytes)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := pkg.GenerateOpaqueToken(apiKeySecretBytes)
+	if err != nil {
+		return nil, err
+	}
+	prefix = apiKeyPrefix + prefix
+	rawKey := prefix + "." + secret
+
+	key := &domain.APIKey{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    prefix,
+		KeyHash:   pkg.HashToken(rawKey),
+		Scopes:    scopes,
+		ExpiresAt: time.Now().AddDate(0, 0, days),
+	}
+	if err := uc.APIKeyRepo.Create(ctx, key); err != nil {
+		return nil, err
+	}
+
+	log.Printf("API key %s created for user %d", key.Prefix, userID)
+	uc.Audit.RecordUser(ctx, domain.AuthEventAPIKeyCreated, userID, map[string]interface{}{"api_key_id": key.ID, "prefix": key.Prefix, "scopes": key.Scopes})
+	return &domain.CreatedAPIKey{APIKey: key, Key: rawKey}, nil
+}
+
+// List returns all keys of a user, including revoked and expired ones
+func (uc *APIKeyUsecase) List(ctx context.Context, userID int) ([]domain.APIKey, error) {
+	return uc.APIKeyRepo.ListByUser(ctx, userID)
+}
+
+// Revoke disables one of the user's keys immediately
+func (uc *APIKeyUsecase) Revoke(ctx context.Context, userID, keyID int) error {
+	if err := uc.APIKeyRepo.Revoke(ctx, keyID, userID); err != nil {
+		if errors.Is(err, repository.ErrAPIKeyNotFound) {
+			return ErrAPIKeyNotFound
+		}
+		return err
+	}
+
+	log.Printf("API key %d revoked by user %d", keyID, userID)
+	uc.Audit.RecordUser(ctx, domain.AuthEventAPIKeyRevoked, userID, map[string]interface{}{"api_key_id": keyID})
+	return nil
+}
+
+// Authenticate resolves a raw key to the identity of its owner. The permissions are the key's scopes
+// that the owner still holds, so removing a role also narrows the keys created with it.
+func (uc *APIKeyUsecase) Authenticate(ctx context.Context, rawKey string) (*domain.Principal, error) {
+	prefix, _, found := strings.Cut(rawKey, ".")
+	if !found || !strings.HasPrefix(prefix, apiKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	key, err := uc.APIKeyRepo.GetByPrefix(ctx, prefix)
+	if err != nil {
+		if errors.Is(err, repository.ErrAPIKeyNotFound) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	if subtle.ConstantTimeCompare([]byte(pkg.HashToken(rawKey)), []byte(key.KeyHash)) != 1 || !key.IsActive(now) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	user, err := uc.UserRepo.GetByID(ctx, key.UserID)
+	if err != nil {
+		return nil, ErrInvalidAPIKey
+	}
+
+	userRoles, err := uc.Roles.UserRoles(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Avoid a write on every request from busy bots
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > apiKeyTouchThreshold {
+		if err := uc.APIKeyRepo.TouchLastUsed(ctx, key.ID, now); err != nil {
+			log.Printf("Error updating last use of api key %d: %v", key.ID, err)
+		}
+	}
+
+	return &domain.Principal{
+		UserID:        user.ID,
+		Email:         user.Email,
+		EmailVerified: user.IsEmailVerified(),
+		Roles:         userRoles.Roles,
+		Permissions:   intersectScopes(key.Scopes, userRoles.Permissions),
+		APIKeyID:      key.ID,
+	}, nil
+}
+
+// grantableScopes deduplicates requested and rejects any the user does not hold
+func grantableScopes(requested, permissions []string) ([]string, error) {
+	held := make(map[string]bool, len(permissions))
+	for _, permission := range permissions {
+		held[permission] = true
+	}
+
+	seen := make(map[string]bool, len(requested))
+	scopes := []string{}
+	var missing []string
+	for _, scope := range requested {
+		scope = strings.TrimSpace(scope)
+		if scope == "" || seen[scope] {
+			continue
+		}
+		seen[scope] = true
+		if !held[scope] {
+			missing = append(missing, scope)
+			continue
+		}
+		scopes = append(scopes, scope)
+	}
+
+	if len(missing) > 0 {
+		return nil, &InvalidScopeError{Scopes: missing}
+	}
+	return scopes, nil
+}
+
+// intersectScopes returns the scopes that are still among permissions
+func intersectScopes(scopes, permissions []string) []string {
+	held := make(map[string]bool, len(permissions))
+	for _, permission := range permissions {
+		held[permission] = true
+	}
+
+	result := []string{}
+	for _, scope := range scopes {
+		if held[scope] {
+			result = append(result, scope)
+		}
+	}
+	return result
+}
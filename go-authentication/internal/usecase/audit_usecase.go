@@ -0,0 +1,97 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/repository"
+	"log"
+	"strings"
+)
+
+const (
+	defaultAuthEventPageSize = 50
+	maxAuthEventPageSize     = 200
+)
+
+var ErrInvalidAuthEventQuery = errors.New("invalid audit log query")
+
+// AuditUsecase records security relevant events in the append-only audit log and queries it
+type AuditUsecase struct {
+	AuditRepo repository.AuthEventRepository
+}
+
+// NewAuditUsecase creates a new instance of AuditUsecase
+func NewAuditUsecase(auditRepository repository.AuthEventRepository) *AuditUsecase {
+	return &AuditUsecase{AuditRepo: auditRepository}
+}
+
+// Record appends an event. IP and user agent default to the client of the request in ctx, and the actor
+// to its authenticated user when that is not the user the event is about.
+// A storage failure is logged; it must not fail the operation being audited.
+func (uc *AuditUsecase) Record(ctx context.Context, event *domain.AuthEvent) {
+	if event.IP == "" && event.UserAgent == "" {
+		client := domain.ClientInfoFromContext(ctx)
+		event.IP, event.UserAgent = client.IP, client.UserAgent
+	}
+	if actorID, ok := domain.ActorFromContext(ctx); ok && event.ActorID == nil && (event.UserID == nil || *event.UserID != actorID) {
+		event.ActorID = &actorID
+	}
+
+	if err := uc.AuditRepo.Create(ctx, event); err != nil {
+		log.Printf("Error recording %s event: %v", event.Type, err)
+	}
+}
+
+// RecordUser appends an event about userID with optional metadata
+func (uc *AuditUsecase) RecordUser(ctx context.Context, eventType string, userID int, metadata map[string]interface{}) {
+	uc.Record(ctx, &domain.AuthEvent{Type: eventType, UserID: &userID, Metadata: metadata})
+}
+
+// ListForUser returns a page of the events about a user, newest first
+func (uc *AuditUsecase) ListForUser(ctx context.Context, userID int, before int64, limit int) (*domain.AuthEventPage, error) {
+	return uc.list(ctx, &domain.AuthEventFilter{UserID: &userID, Before: before, Limit: limit})
+}
+
+// Query returns a page of events matching an admin's filter, newest first
+func (uc *AuditUsecase) Query(ctx context.Context, query *domain.AuthEventQuery) (*domain.AuthEventPage, error) {
+	filter := &domain.AuthEventFilter{
+		UserID: query.UserID,
+		IP:     strings.TrimSpace(query.IP),
+		Since:  query.Since,
+		Until:  query.Until,
+		Before: query.Before,
+		Limit:  query.Limit,
+	}
+	for _, eventType := range strings.Split(query.Type, ",") {
+		if eventType = strings.TrimSpace(eventType); eventType != "" {
+			filter.Types = append(filter.Types, eventType)
+		}
+	}
+
+	if !filter.Since.IsZero() && !filter.Until.IsZero() && !filter.Since.Before(filter.Until) {
+		return nil, ErrInvalidAuthEventQuery
+	}
+
+	return uc.list(ctx, filter)
+}
+
+func (uc *AuditUsecase) list(ctx context.Context, filter *domain.AuthEventFilter) (*domain.AuthEventPage, error) {
+	if filter.Limit == 0 {
+		filter.Limit = defaultAuthEventPageSize
+	}
+	if filter.Limit < 0 || filter.Limit > maxAuthEventPageSize || filter.Before < 0 {
+		return nil, ErrInvalidAuthEventQuery
+	}
+
+	events, err := uc.AuditRepo.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &domain.AuthEventPage{Events: events}
+	if len(events) == filter.Limit {
+		page.NextBefore = events[len(events)-1].ID
+	}
+	return page, nil
+}
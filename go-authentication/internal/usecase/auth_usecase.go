@@ -6,16 +6,54 @@ import (
 	"go-authentication/internal/domain"
 	"go-authentication/internal/repository"
 	"go-authentication/pkg"
+	"log"
+	"time"
 
-	"golang.org/x/crypto/bcrypt"
+	"github.com/dgrijalva/jwt-go"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected, all sessions for this token were revoked")
+	ErrInvalidAccessToken  = errors.New("invalid or expired token")
+	ErrTokenRevoked        = errors.New("token has been revoked")
+	ErrEmailNotVerified    = errors.New("email address has not been verified")
 )
 
 type AuthUsecase struct {
-	UserRepo repository.UserRepository
+	UserRepo         repository.UserRepository
+	RefreshTokenRepo repository.RefreshTokenRepository
+	Revocations      repository.RevocationStore
+	Tokens           *pkg.TokenService
+	Verification     *VerificationUsecase
+	MFA              *MFAUsecase
+	Lockout          *LockoutUsecase
+	PasswordPolicy   *pkg.PasswordPolicy
+	Hasher           pkg.PasswordHasher
+	Roles            *RoleUsecase
+	APIKeys          *APIKeyUsecase
+	Sessions         *SessionUsecase
+	Passkeys         *PasskeyUsecase
+	Audit            *AuditUsecase
 }
 
-func NewAuthorizaationcase(userRepository repository.UserRepository) *AuthUsecase {
-	return &AuthUsecase{UserRepo: userRepository}
+func NewAuthorizaationcase(userRepository repository.UserRepository, refreshTokenRepository repository.RefreshTokenRepository, revocationStore repository.RevocationStore, tokenService *pkg.TokenService, verificationUsecase *VerificationUsecase, mfaUsecase *MFAUsecase, lockoutUsecase *LockoutUsecase, passwordPolicy *pkg.PasswordPolicy, passwordHasher pkg.PasswordHasher, roleUsecase *RoleUsecase, apiKeyUsecase *APIKeyUsecase, sessionUsecase *SessionUsecase, passkeyUsecase *PasskeyUsecase, auditUsecase *AuditUsecase) *AuthUsecase {
+	return &AuthUsecase{
+		UserRepo:         userRepository,
+		RefreshTokenRepo: refreshTokenRepository,
+		Revocations:      revocationStore,
+		Tokens:           tokenService,
+		Verification:     verificationUsecase,
+		MFA:              mfaUsecase,
+		Lockout:          lockoutUsecase,
+		PasswordPolicy:   passwordPolicy,
+		Hasher:           passwordHasher,
+		Roles:            roleUsecase,
+		APIKeys:          apiKeyUsecase,
+		Sessions:         sessionUsecase,
+		Passkeys:         passkeyUsecase,
+		Audit:            auditUsecase,
+	}
 }
 
 // Signup-handler
@@ -24,37 +62,405 @@ func (uc *AuthUsecase) Signup(ctx context.Context, user *domain.User) error {
 		return err
 	}
 
+	if err := uc.PasswordPolicy.Validate(user.Password, user.Name, user.Email); err != nil {
+		return err
+	}
+
 	existingUser, _ := uc.UserRepo.GetByEmail(ctx, user.Email)
 	if existingUser != nil {
 		return errors.New("user already exists")
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	hashedPassword, err := uc.Hasher.Hash(user.Password)
 	if err != nil {
 		return err
 	}
-	user.Password = string(hashedPassword)
+	user.Password = hashedPassword
+	user.EmailVerifiedAt = nil
 
-	return uc.UserRepo.Create(ctx, user)
+	if err := uc.UserRepo.Create(ctx, user); err != nil {
+		return err
+	}
+	uc.Audit.RecordUser(ctx, domain.AuthEventSignup, user.ID, nil)
+
+	if err := uc.Roles.AssignDefaultRoles(ctx, user); err != nil {
+		log.Printf("Error assigning default roles to user %d: %v", user.ID, err)
+	}
+
+	// The account exists at this point; a failed delivery can be retried through the resend endpoint
+	if err := uc.Verification.SendVerificationEmail(ctx, user); err != nil {
+		log.Printf("Error sending verification email to user %d: %v", user.ID, err)
+	}
+
+	return nil
 }
 
-// Login-authenticates a user and returns a JWT token
-func (uc *AuthUsecase) Login(ctx context.Context, email, password string) (string, error) {
+// Login-authenticates a user and returns an access token together with a refresh token.
+// If the user enabled two-factor authentication it returns an *MFARequiredError carrying a challenge instead.
+// Repeated failures lock out the account and the client IP, reported as a *LoginLockedError.
+func (uc *AuthUsecase) Login(ctx context.Context, email, password string, client domain.ClientInfo) (*domain.TokenPair, error) {
+	if err := uc.Lockout.Check(ctx, email, client); err != nil {
+		// The response does not depend on the account, but its owner should see the attempt in their log
+		var userID *int
+		if user, err := uc.UserRepo.GetByEmail(ctx, email); err == nil {
+			userID = &user.ID
+		}
+		uc.auditLoginFailure(ctx, email, userID, client, "locked_out")
+		return nil, err
+	}
+
 	user, err := uc.UserRepo.GetByEmail(ctx, email)
 
 	if err != nil {
-		return "", errors.New("invalid Email or password")
+		uc.recordLoginFailure(ctx, email, nil, client, "unknown_email")
+		return nil, errors.New("invalid Email or password")
+	}
+
+	match, err := uc.Hasher.Verify(user.Password, password)
+	if err != nil {
+		log.Printf("Error verifying password of user %d: %v", user.ID, err)
+	}
+	if !match {
+		uc.recordLoginFailure(ctx, email, &user.ID, client, "invalid_password")
+		return nil, errors.New("Invalid Email or password")
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
-		return "", errors.New("Invalid Email or password")
+	// The plaintext is only available here, so this is the one chance to upgrade a stale hash
+	if uc.Hasher.NeedsRehash(user.Password) {
+		uc.rehashPassword(ctx, user, password)
 	}
 
-	token, err := pkg.GenerateJWT(user.ID, user.Email)
+	if !user.IsEmailVerified() {
+		uc.auditLoginFailure(ctx, email, &user.ID, client, "email_not_verified")
+		return nil, ErrEmailNotVerified
+	}
+
+	return uc.completeLogin(ctx, user, client, domain.LoginMethodPassword)
+}
+
+// completeLogin finishes a login whose first factor succeeded: it asks for the second factor
+// if the user enabled one, and otherwise starts a session
+func (uc *AuthUsecase) completeLogin(ctx context.Context, user *domain.User, client domain.ClientInfo, method string) (*domain.TokenPair, error) {
+	mfaEnabled, err := uc.MFA.IsEnabled(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if mfaEnabled {
+		return nil, uc.mfaChallenge(user)
+	}
+
+	return uc.startSession(ctx, user, client, method)
+}
+
+// LoginPasskey authenticates a user with a passkey instead of a password. The authenticator already verified
+// the user with a PIN or biometric, so no TOTP code is asked for. A passkey cannot be guessed, so failures do
+// not count towards the login lockout, and a locked account can still sign in this way.
+func (uc *AuthUsecase) LoginPasskey(ctx context.Context, req *domain.PasskeyLoginRequest, client domain.ClientInfo) (*domain.TokenPair, error) {
+	passkey, err := uc.Passkeys.Authenticate(ctx, req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return token, nil
+	user, err := uc.UserRepo.GetByID(ctx, passkey.UserID)
+	if err != nil {
+		return nil, ErrInvalidPasskeyLogin
+	}
+
+	if !user.IsEmailVerified() {
+		uc.auditLoginFailure(ctx, user.Email, &user.ID, client, "email_not_verified")
+		return nil, ErrEmailNotVerified
+	}
+
+	return uc.startSession(ctx, user, client, domain.LoginMethodPasskey)
+}
+
+// LoginMFA completes a login that was answered with an MFA challenge.
+// The challenge is single-use and code may be a TOTP code or a recovery code.
+func (uc *AuthUsecase) LoginMFA(ctx context.Context, challengeToken, code string, client domain.ClientInfo) (*domain.TokenPair, error) {
+	claims, err := uc.Tokens.ValidatePurposeToken(pkg.TokenTypeMFAChallenge, challengeToken)
+	if err != nil {
+		return nil, ErrInvalidMFAChallenge
+	}
+
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return nil, ErrInvalidMFAChallenge
+	}
+
+	// A password reset or logout everywhere also invalidates outstanding challenges
+	jti := pkg.TokenID(claims)
+	revoked, err := uc.Revocations.IsRevoked(ctx, jti, "", int(userID), pkg.IssuedAt(claims))
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrInvalidMFAChallenge
+	}
+
+	user, err := uc.UserRepo.GetByID(ctx, int(userID))
+	if err != nil || !user.IsEmailVerified() {
+		return nil, ErrInvalidMFAChallenge
+	}
+
+	// Wrong codes count towards the same lockout as wrong passwords
+	if err := uc.Lockout.Check(ctx, user.Email, client); err != nil {
+		uc.auditLoginFailure(ctx, user.Email, &user.ID, client, "locked_out")
+		return nil, err
+	}
+
+	if err := uc.MFA.VerifyCode(ctx, user.ID, code); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			uc.recordLoginFailure(ctx, user.Email, &user.ID, client, "invalid_mfa_code")
+		}
+		if errors.Is(err, ErrMFANotEnabled) {
+			// Disabled after the challenge was issued; the password alone is not enough to finish this login
+			return nil, ErrInvalidMFAChallenge
+		}
+		return nil, err
+	}
+
+	if err := uc.Revocations.RevokeToken(ctx, jti, pkg.ClaimTime(claims, "exp")); err != nil {
+		return nil, err
+	}
+
+	return uc.startSession(ctx, user, client, domain.LoginMethodMFA)
+}
+
+// mfaChallenge issues the short-lived token that proves the password step succeeded
+func (uc *AuthUsecase) mfaChallenge(user *domain.User) error {
+	token, err := uc.Tokens.GeneratePurposeToken(pkg.TokenTypeMFAChallenge, user.ID, user.Email, mfaChallengeTTL)
+	if err != nil {
+		return err
+	}
+
+	return &MFARequiredError{Challenge: &domain.MFAChallenge{
+		MFARequired: true,
+		MFAToken:    token,
+		ExpiresAt:   time.Now().Add(mfaChallengeTTL),
+	}}
+}
+
+// rehashPassword stores the password hashed with the current algorithm and parameters.
+// Failing to upgrade is not a reason to fail the login.
+func (uc *AuthUsecase) rehashPassword(ctx context.Context, user *domain.User, password string) {
+	hashedPassword, err := uc.Hasher.Hash(password)
+	if err != nil {
+		log.Printf("Error rehashing password of user %d: %v", user.ID, err)
+		return
+	}
+
+	if err := uc.UserRepo.UpdatePassword(ctx, user.ID, hashedPassword); err != nil {
+		log.Printf("Error storing rehashed password of user %d: %v", user.ID, err)
+		return
+	}
+	user.Password = hashedPassword
+}
+
+// recordLoginFailure counts a failed attempt; a storage error must not turn into a different login response
+func (uc *AuthUsecase) recordLoginFailure(ctx context.Context, email string, userID *int, client domain.ClientInfo, reason string) {
+	uc.auditLoginFailure(ctx, email, userID, client, reason)
+	if err := uc.Lockout.RecordFailure(ctx, email, userID, client); err != nil {
+		log.Printf("Error recording failed login for %s: %v", email, err)
+	}
+}
+
+// auditLoginFailure records a rejected login in the audit log without counting it towards a lockout
+func (uc *AuthUsecase) auditLoginFailure(ctx context.Context, email string, userID *int, client domain.ClientInfo, reason string) {
+	uc.Audit.Record(ctx, &domain.AuthEvent{
+		Type:      domain.AuthEventLoginFailed,
+		UserID:    userID,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		Metadata:  map[string]interface{}{"email": email, "reason": reason},
+	})
+}
+
+// startSession records a session for a fully authenticated user and starts its refresh token family.
+// method is how the user authenticated, e.g. domain.LoginMethodPassword, and is kept in the audit log.
+func (uc *AuthUsecase) startSession(ctx context.Context, user *domain.User, client domain.ClientInfo, method string) (*domain.TokenPair, error) {
+	if err := uc.Lockout.RecordSuccess(ctx, user.Email); err != nil {
+		log.Printf("Error clearing failed logins for user %d: %v", user.ID, err)
+	}
+
+	// Every login starts a new session, whose ID is the family of its refresh tokens
+	session, err := uc.Sessions.Start(ctx, user.ID, client)
+	if err != nil {
+		return nil, err
+	}
+
+	rawRefreshToken, refreshToken, err := newRefreshToken(user.ID, session.ID, uc.Tokens.RefreshTTL())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.RefreshTokenRepo.Create(ctx, refreshToken); err != nil {
+		return nil, err
+	}
+
+	uc.Audit.Record(ctx, &domain.AuthEvent{
+		Type:      domain.AuthEventLoginSucceeded,
+		UserID:    &user.ID,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		Metadata:  map[string]interface{}{"method": method, "session_id": session.ID},
+	})
+
+	return uc.tokenPair(ctx, user, rawRefreshToken, refreshToken)
+}
+
+// RefreshToken exchanges a refresh token for a new token pair.
+// The presented token is rotated; presenting an already rotated token revokes its whole family.
+func (uc *AuthUsecase) RefreshToken(ctx context.Context, rawToken string) (*domain.TokenPair, error) {
+	current, err := uc.RefreshTokenRepo.GetByHash(ctx, pkg.HashToken(rawToken))
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if current.RotatedAt != nil || current.RevokedAt != nil {
+		return nil, uc.revokeReusedFamily(ctx, current)
+	}
+
+	if !current.IsUsable(time.Now()) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	user, err := uc.UserRepo.GetByID(ctx, current.UserID)
+	if err != nil || !user.IsEmailVerified() {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	rawRefreshToken, next, err := newRefreshToken(user.ID, current.FamilyID, uc.Tokens.RefreshTTL())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.RefreshTokenRepo.Rotate(ctx, current.ID, next); err != nil {
+		if errors.Is(err, repository.ErrRefreshTokenConsumed) {
+			// Lost a race against another refresh with the same token
+			return nil, uc.revokeReusedFamily(ctx, current)
+		}
+		return nil, err
+	}
+
+	uc.Sessions.Touch(ctx, next.FamilyID, next.ExpiresAt)
+	return uc.tokenPair(ctx, user, rawRefreshToken, next)
+}
+
+// ValidateAccessToken verifies an access token and rejects it if it was revoked
+func (uc *AuthUsecase) ValidateAccessToken(ctx context.Context, tokenString string) (jwt.MapClaims, error) {
+	claims, err := uc.Tokens.ValidateJWT(tokenString)
+	if err != nil {
+		return nil, ErrInvalidAccessToken
+	}
+
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return nil, ErrInvalidAccessToken
+	}
+
+	revoked, err := uc.Revocations.IsRevoked(ctx, pkg.TokenID(claims), pkg.SessionID(claims), int(userID), pkg.IssuedAt(claims))
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+
+	return claims, nil
+}
+
+// Logout revokes the access token described by claims and ends its session.
+// If a refresh token of another session is given, that session ends too.
+func (uc *AuthUsecase) Logout(ctx context.Context, userID int, claims jwt.MapClaims, rawRefreshToken string) error {
+	jti := pkg.TokenID(claims)
+	if jti == "" {
+		return ErrInvalidAccessToken
+	}
+
+	if err := uc.Revocations.RevokeToken(ctx, jti, pkg.ClaimTime(claims, "exp")); err != nil {
+		return err
+	}
+
+	sessionID := pkg.SessionID(claims)
+	if sessionID != "" {
+		if err := uc.Sessions.end(ctx, userID, sessionID); err != nil {
+			return err
+		}
+	}
+	uc.Audit.RecordUser(ctx, domain.AuthEventLogout, userID, map[string]interface{}{"session_id": sessionID})
+
+	if rawRefreshToken == "" {
+		return nil
+	}
+
+	refreshToken, err := uc.RefreshTokenRepo.GetByHash(ctx, pkg.HashToken(rawRefreshToken))
+	if err != nil || refreshToken.UserID != userID || refreshToken.FamilyID == sessionID {
+		// The access token is already revoked; an unknown refresh token has nothing left to revoke
+		return nil
+	}
+
+	return uc.Sessions.end(ctx, userID, refreshToken.FamilyID)
+}
+
+// LogoutEverywhere ends every session of a user and revokes every token issued to them so far
+func (uc *AuthUsecase) LogoutEverywhere(ctx context.Context, userID int) error {
+	if err := uc.Sessions.RevokeAll(ctx, userID); err != nil {
+		return err
+	}
+
+	uc.Audit.RecordUser(ctx, domain.AuthEventLogoutAll, userID, nil)
+	return nil
+}
+
+// revokeReusedFamily ends the session whose refresh token was replayed.
+// A token that was already revoked belongs to a session that has ended before.
+func (uc *AuthUsecase) revokeReusedFamily(ctx context.Context, token *domain.RefreshToken) error {
+	log.Printf("Refresh token reuse detected: user_id=%d, family=%s", token.UserID, token.FamilyID)
+	uc.Audit.RecordUser(ctx, domain.AuthEventRefreshTokenReused, token.UserID, map[string]interface{}{"session_id": token.FamilyID})
+	if token.RevokedAt != nil {
+		return ErrRefreshTokenReused
+	}
+	if err := uc.Sessions.end(ctx, token.UserID, token.FamilyID); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+// tokenPair signs a fresh access token carrying the user's current roles and bundles it with the given refresh token
+func (uc *AuthUsecase) tokenPair(ctx context.Context, user *domain.User, rawRefreshToken string, refreshToken *domain.RefreshToken) (*domain.TokenPair, error) {
+	roles, err := uc.Roles.UserRoles(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := uc.Tokens.GenerateJWT(user.ID, user.Email, jwt.MapClaims{
+		"email_verified": user.IsEmailVerified(),
+		"roles":          roles.Roles,
+		"permissions":    roles.Permissions,
+		"sid":            refreshToken.FamilyID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.TokenPair{
+		AccessToken:           accessToken,
+		RefreshToken:          rawRefreshToken,
+		RefreshTokenExpiresAt: refreshToken.ExpiresAt,
+	}, nil
+}
+
+// newRefreshToken generates a raw refresh token and the hashed record to store for it
+func newRefreshToken(userID int, familyID string, ttl time.Duration) (string, *domain.RefreshToken, error) {
+	raw, err := pkg.GenerateOpaqueToken(32)
+	if err != nil {
+		return "", nil, err
+	}
 
+	return raw, &domain.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: pkg.HashToken(raw),
+		ExpiresAt: time.Now().Add(ttl),
+	}, nil
 }
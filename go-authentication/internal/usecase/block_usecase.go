@@ -0,0 +1,71 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/repository"
+)
+
+var (
+	ErrCannotBlockSelf  = errors.New("you cannot block yourself")
+	ErrBlockNotFound    = errors.New("user is not blocked")
+	ErrMessagingBlocked = errors.New("messaging between these users is blocked")
+)
+
+// BlockUsecase manages the users' block lists.
+// A block stops messages in both directions and hides the blocker from the blocked user's directory search.
+type BlockUsecase struct {
+	BlockRepo repository.BlockRepository
+	UserRepo  repository.UserRepository
+}
+
+// NewBlockUsecase creates a new instance of BlockUsecase
+func NewBlockUsecase(blockRepository repository.BlockRepository, userRepository repository.UserRepository) *BlockUsecase {
+	return &BlockUsecase{
+		BlockRepo: blockRepository,
+		UserRepo:  userRepository,
+	}
+}
+
+// BlockUser adds blockedID to the block list of userID
+func (uc *BlockUsecase) BlockUser(ctx context.Context, userID, blockedID int) error {
+	if userID == blockedID {
+		return ErrCannotBlockSelf
+	}
+	if user, err := uc.UserRepo.GetByID(ctx, blockedID); err != nil || user == nil {
+		return ErrUserNotFound
+	}
+
+	return uc.BlockRepo.Block(ctx, userID, blockedID)
+}
+
+// UnblockUser removes blockedID from the block list of userID
+func (uc *BlockUsecase) UnblockUser(ctx context.Context, userID, blockedID int) error {
+	if err := uc.BlockRepo.Unblock(ctx, userID, blockedID); err != nil {
+		if errors.Is(err, repository.ErrBlockNotFound) {
+			return ErrBlockNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// ListBlocked returns the users blocked by userID, most recently blocked first
+func (uc *BlockUsecase) ListBlocked(ctx context.Context, userID int) ([]domain.BlockedUser, error) {
+	return uc.BlockRepo.ListBlocked(ctx, userID)
+}
+
+// CheckMessaging returns ErrMessagingBlocked when either user has blocked the other
+func (uc *BlockUsecase) CheckMessaging(ctx context.Context, senderID, receiverID int) error {
+	for _, pair := range [][2]int{{receiverID, senderID}, {senderID, receiverID}} {
+		blocked, err := uc.BlockRepo.IsBlocked(ctx, pair[0], pair[1])
+		if err != nil {
+			return err
+		}
+		if blocked {
+			return ErrMessagingBlocked
+		}
+	}
+	return nil
+}
@@ -2,26 +2,52 @@ package usecase
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"go-authentication/internal/domain"
 	"go-authentication/internal/repository"
 	"go-authentication/internal/services"
 	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	defaultInboxPageSize = 20
+	maxInboxPageSize     = 50
+)
+
+var (
+	ErrConversationNotFound = errors.New("conversation not found")
+	ErrMuteNotFound         = errors.New("conversation is not muted")
+	ErrInvalidMessageTarget = errors.New("a message needs either a receiver_id or a conversation_id")
+	ErrInvalidInboxQuery    = errors.New("invalid inbox query")
+	ErrInvalidReadPosition  = errors.New("up_to_message_id must be a message of the conversation")
+	ErrMessageNotFound      = errors.New("message not found")
 )
 
 // ChatUsecase handles business logic for chat operations
 type ChatUsecase struct {
 	ChatRepo    repository.ChatRepository
 	UserRepo    repository.UserRepository
+	MuteRepo    repository.ConversationMuteRepository
+	ReceiptRepo repository.ReceiptRepository
+	Blocks      *BlockUsecase
 	NatsService *services.NatsService
 }
 
 // NewChatUsecase creates a new instance of ChatUsecase
-func NewChatUsecase(chatRepository repository.ChatRepository, userRepository repository.UserRepository, natsService *services.NatsService) *ChatUsecase {
+func NewChatUsecase(chatRepository repository.ChatRepository, userRepository repository.UserRepository, muteRepository repository.ConversationMuteRepository, receiptRepository repository.ReceiptRepository, blockUsecase *BlockUsecase, natsService *services.NatsService) *ChatUsecase {
 	return &ChatUsecase{
 		ChatRepo:    chatRepository,
 		UserRepo:    userRepository,
+		MuteRepo:    muteRepository,
+		ReceiptRepo: receiptRepository,
+		Blocks:      blockUsecase,
 		NatsService: natsService,
 	}
 }
@@ -50,63 +76,414 @@ func (uc *ChatUsecase) SendMessage(ctx context.Context, senderID int, receiverID
 
 	log.Printf("Receiver found: id=%d, name=%s", receiver.ID, receiver.Name)
 
-	// Create message object
+	// Refuse delivery when either user has blocked the other
+	if err := uc.Blocks.CheckMessaging(ctx, senderID, receiverID); err != nil {
+		log.Printf("Message from user %d to user %d refused: %v", senderID, receiverID, err)
+		return nil, err
+	}
+
+	// Direct messages live in the conversation of the two users
+	conversation, err := uc.ChatRepo.GetOrCreateConversation(ctx, senderID, receiverID)
+	if err != nil {
+		log.Printf("Error getting/creating conversation: %v", err)
+		return nil, fmt.Errorf("failed to get/create conversation: %w", err)
+	}
+
+	log.Printf("Conversation found/created: id=%d", conversation.ID)
+
 	message := &domain.Message{
-		SenderID:   senderID,
-		ReceiverID: receiverID,
-		Content:    content,
+		ConversationID: conversation.ID,
+		SenderID:       senderID,
+		ReceiverID:     receiverID,
+		Content:        content,
+	}
+	if err := uc.deliver(ctx, message, []int{senderID, receiverID}); err != nil {
+		return nil, err
 	}
 
-	log.Printf("Attempting to store message: sender=%d, receiver=%d, content=%s",
-		message.SenderID, message.ReceiverID, message.Content)
+	log.Printf("Message sent successfully from user %d to user %d", senderID, receiverID)
+	return message, nil
+}
 
-	// Save message to database
-	err = uc.ChatRepo.StoresMsg(ctx, message)
+// SendToConversation posts a message to a direct or group conversation the sender is a member of
+func (uc *ChatUsecase) SendToConversation(ctx context.Context, senderID, conversationID int, content string) (*domain.Message, error) {
+	if err := domain.ValidateMessage(content); err != nil {
+		return nil, err
+	}
+
+	conversation, _, err := loadConversation(ctx, uc.ChatRepo, senderID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	members, err := uc.ChatRepo.ListMembers(ctx, conversationID)
 	if err != nil {
+		return nil, err
+	}
+
+	message := &domain.Message{
+		ConversationID: conversation.ID,
+		SenderID:       senderID,
+		Content:        content,
+	}
+	recipientIDs := make([]int, 0, len(members))
+	for _, member := range members {
+		recipientIDs = append(recipientIDs, member.UserID)
+		if !conversation.IsGroup() && member.UserID != senderID {
+			message.ReceiverID = member.UserID
+		}
+	}
+
+	// A direct message is refused when either user blocked the other;
+	// group members who blocked the sender only miss the real-time push
+	if message.ReceiverID != 0 {
+		if err := uc.Blocks.CheckMessaging(ctx, senderID, message.ReceiverID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := uc.deliver(ctx, message, recipientIDs); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Message sent successfully from user %d to conversation %d", senderID, conversationID)
+	return message, nil
+}
+
+// Send posts a message to the conversation or the user named in the request
+func (uc *ChatUsecase) Send(ctx context.Context, senderID int, req *domain.MessageRequest) (*domain.Message, error) {
+	switch {
+	case req.ConversationID != 0 && req.ReceiverID == 0:
+		return uc.SendToConversation(ctx, senderID, req.ConversationID, req.Content)
+	case req.ReceiverID != 0 && req.ConversationID == 0:
+		return uc.SendMessage(ctx, senderID, req.ReceiverID, req.Content)
+	}
+	return nil, ErrInvalidMessageTarget
+}
+
+// deliver stores a message and pushes it to every recipient in real time
+func (uc *ChatUsecase) deliver(ctx context.Context, message *domain.Message, recipientIDs []int) error {
+	log.Printf("Attempting to store message: conversation=%d, sender=%d, content=%s",
+		message.ConversationID, message.SenderID, message.Content)
+
+	if err := uc.ChatRepo.StoresMsg(ctx, message); err != nil {
 		log.Printf("Error storing message: %v", err)
-		return nil, fmt.Errorf("failed to store message: %w", err)
+		return fmt.Errorf("failed to store message: %w", err)
 	}
 
 	log.Printf("Message stored successfully in database with ID: %d", message.ID)
 
-	// Update conversation
-	conversation, err := uc.ChatRepo.GetOrCreateConversation(ctx, senderID, receiverID)
+	if err := uc.ChatRepo.UpdateConvo(ctx, message.ConversationID, message.Content); err != nil {
+		log.Printf("Error updating conversation: %v", err)
+		return fmt.Errorf("failed to update conversation: %w", err)
+	}
+
+	// Your own message does not count as unread, and replying reads what came before it
+	if err := uc.markRead(ctx, message.SenderID, message.ConversationID, message.ID); err != nil {
+		log.Printf("Error marking conversation %d read for user %d: %v", message.ConversationID, message.SenderID, err)
+	}
+
+	// Send message through NATS for real-time delivery
+	if err := uc.NatsService.PublishMessage(message, recipientIDs); err != nil {
+		log.Printf("Error sending message through NATS: %v", err)
+		// Don't return error here as the message is already saved
+	}
+	return nil
+}
+
+// loadConversation returns a conversation and the membership of userID in it.
+// Conversations of others are reported as not found so their existence is not revealed.
+func loadConversation(ctx context.Context, chatRepo repository.ChatRepository, userID, conversationID int) (*domain.Conversation, *domain.ConversationMember, error) {
+	conversation, err := chatRepo.GetConversationByID(ctx, conversationID)
 	if err != nil {
-		log.Printf("Error getting/creating conversation: %v", err)
-		return nil, fmt.Errorf("failed to get/create conversation: %w", err)
+		if errors.Is(err, repository.ErrConversationNotFound) {
+			return nil, nil, ErrConversationNotFound
+		}
+		return nil, nil, err
 	}
 
-	log.Printf("Conversation found/created: id=%d, user1=%d, user2=%d",
-		conversation.ID, conversation.User1ID, conversation.User2ID)
+	member, err := chatRepo.GetMember(ctx, conversationID, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrMemberNotFound) {
+			return nil, nil, ErrConversationNotFound
+		}
+		return nil, nil, err
+	}
+	return conversation, member, nil
+}
+
+// GetConversationHistory retrieves the messages of a conversation userID is a member of, newest first
+func (uc *ChatUsecase) GetConversationHistory(ctx context.Context, userID, conversationID int, limit, offset int) ([]*domain.Message, error) {
+	if _, _, err := loadConversation(ctx, uc.ChatRepo, userID, conversationID); err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	if offset < 0 {
+		offset = 0
+	}
 
-	err = uc.ChatRepo.UpdateConvo(ctx, conversation.ID, content)
+	messages, err := uc.ChatRepo.GetMessagesByConversation(ctx, conversationID, limit, offset)
 	if err != nil {
-		log.Printf("Error updating conversation: %v", err)
-		return nil, fmt.Errorf("failed to update conversation: %w", err)
+		return nil, err
 	}
 
-	log.Printf("Conversation updated with last message")
+	// Opening the latest page reads the conversation
+	if offset == 0 && len(messages) > 0 {
+		if err := uc.markRead(ctx, userID, conversationID, messages[0].ID); err != nil {
+			log.Printf("Error marking conversation %d read for user %d: %v", conversationID, userID, err)
+		}
+	}
 
-	// Send message through NATS for real-time delivery
-	err = uc.NatsService.SendPrivateMessage(message)
+	if err := uc.attachReceipts(ctx, userID, messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// attachReceipts lists the receipts of the other members on the messages userID sent
+func (uc *ChatUsecase) attachReceipts(ctx context.Context, userID int, messages []*domain.Message) error {
+	var sentIDs []int
+	sent := make(map[int]*domain.Message)
+	for _, message := range messages {
+		if message.SenderID == userID {
+			sentIDs = append(sentIDs, message.ID)
+			sent[message.ID] = message
+		}
+	}
+	if len(sentIDs) == 0 {
+		return nil
+	}
+
+	receipts, err := uc.ReceiptRepo.ListByMessages(ctx, sentIDs)
 	if err != nil {
-		log.Printf("Error sending message through NATS: %v", err)
-		// Don't return error here as the message is already saved
+		return err
+	}
+	for _, receipt := range receipts {
+		message := sent[receipt.MessageID]
+		message.Receipts = append(message.Receipts, receipt)
 	}
+	return nil
+}
 
-	log.Printf("Message sent successfully from user %d to user %d", senderID, receiverID)
-	return message, nil
+// MarkDelivered records that a message reached one of the devices of userID
+// and tells the sender, the first time only
+func (uc *ChatUsecase) MarkDelivered(ctx context.Context, userID int, message *domain.Message) error {
+	if message.SenderID == userID {
+		return nil
+	}
+
+	now := time.Now()
+	delivered, err := uc.ReceiptRepo.MarkDelivered(ctx, message.ID, userID, now)
+	if err != nil || !delivered {
+		return err
+	}
+
+	uc.publishReceipt(message.SenderID, &domain.ReceiptEvent{
+		Type:           domain.ReceiptEventDelivered,
+		ConversationID: message.ConversationID,
+		MessageID:      message.ID,
+		UserID:         userID,
+		At:             now,
+	})
+	return nil
+}
+
+// MarkConversationRead records that userID read a conversation up to a message
+// and tells the senders of the messages that were unread until now
+func (uc *ChatUsecase) MarkConversationRead(ctx context.Context, userID, conversationID, upToMessageID int) error {
+	if upToMessageID <= 0 {
+		return ErrInvalidReadPosition
+	}
+	if _, err := uc.ChatRepo.GetMember(ctx, conversationID, userID); err != nil {
+		if errors.Is(err, repository.ErrMemberNotFound) {
+			return ErrConversationNotFound
+		}
+		return err
+	}
+
+	// A position past the newest message would leave later messages read before they arrive
+	latestMessageID, err := uc.ChatRepo.LatestMessageID(ctx, conversationID)
+	if err != nil {
+		return err
+	}
+	if upToMessageID > latestMessageID {
+		return ErrInvalidReadPosition
+	}
+
+	return uc.markRead(ctx, userID, conversationID, upToMessageID)
+}
+
+// markRead stores the receipts, then moves the read position that the unread counts derive from;
+// receipts only cover messages after the previous position
+func (uc *ChatUsecase) markRead(ctx context.Context, userID, conversationID, upToMessageID int) error {
+	now := time.Now()
+	latestBySender, err := uc.ReceiptRepo.MarkRead(ctx, conversationID, userID, upToMessageID, now)
+	if err != nil {
+		return err
+	}
+
+	if err := uc.ChatRepo.MarkRead(ctx, conversationID, userID, upToMessageID); err != nil {
+		if errors.Is(err, repository.ErrMemberNotFound) {
+			return ErrConversationNotFound
+		}
+		return err
+	}
+
+	for senderID, messageID := range latestBySender {
+		uc.publishReceipt(senderID, &domain.ReceiptEvent{
+			Type:           domain.ReceiptEventRead,
+			ConversationID: conversationID,
+			MessageID:      messageID,
+			UserID:         userID,
+			At:             now,
+		})
+	}
+	return nil
+}
+
+func (uc *ChatUsecase) publishReceipt(senderID int, event *domain.ReceiptEvent) {
+	if err := uc.NatsService.PublishReceipt(senderID, event); err != nil {
+		log.Printf("Error sending %s receipt to user %d through NATS: %v", event.Type, senderID, err)
+	}
+}
+
+// Inbox lists the conversations of userID, most recently active first, with their unread counts
+func (uc *ChatUsecase) Inbox(ctx context.Context, userID int, query *domain.InboxQuery) (*domain.InboxPage, error) {
+	limit := query.Limit
+	if limit == 0 {
+		limit = defaultInboxPageSize
+	}
+	if limit < 0 || limit > maxInboxPageSize {
+		return nil, fmt.Errorf("%w: limit must be at most %d", ErrInvalidInboxQuery, maxInboxPageSize)
+	}
+
+	filter := &domain.InboxFilter{UserID: userID, Limit: limit}
+	if query.Cursor != "" {
+		updatedAt, id, err := decodeInboxCursor(query.Cursor)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid cursor", ErrInvalidInboxQuery)
+		}
+		filter.BeforeUpdatedAt, filter.BeforeID = updatedAt, id
+	}
+
+	entries, err := uc.ChatRepo.ListInbox(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &domain.InboxPage{Conversations: entries}
+	if len(entries) == limit {
+		last := entries[len(entries)-1]
+		page.NextCursor = encodeInboxCursor(last.UpdatedAt, last.ID)
+	}
+	return page, nil
+}
+
+// encodeInboxCursor returns an opaque cursor for the position after a conversation.
+// Postgres keeps microseconds, so that is the precision of the cursor.
+func encodeInboxCursor(updatedAt time.Time, id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(updatedAt.UnixMicro(), 10) + ":" + strconv.Itoa(id)))
 }
 
-// SubscribeToMessages subscribes to messages for a user
-func (uc *ChatUsecase) SubscribeToMessages(userID int, callback func(*domain.Message)) error {
-	return uc.NatsService.SubscribeToPrivateMessages(userID, callback)
+func decodeInboxCursor(cursor string) (updatedAt time.Time, id int, err error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	timePart, idPart, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return time.Time{}, 0, errors.New("malformed cursor")
+	}
+	micros, err := strconv.ParseInt(timePart, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	if id, err = strconv.Atoi(idPart); err != nil || id <= 0 {
+		return time.Time{}, 0, errors.New("malformed cursor")
+	}
+	// updated_at has no time zone and pgx sends the wall clock, so the cursor must be in UTC
+	return time.UnixMicro(micros).UTC(), id, nil
+}
+
+// RemoveMessage deletes a message on behalf of a moderator, whatever conversation it belongs to
+func (uc *ChatUsecase) RemoveMessage(ctx context.Context, moderatorID, messageID int) error {
+	removed, err := uc.ChatRepo.DeleteMessage(ctx, messageID)
+	if err != nil {
+		if errors.Is(err, repository.ErrMessageNotFound) {
+			return ErrMessageNotFound
+		}
+		return err
+	}
+
+	log.Printf("Moderator %d removed message %d from conversation %d", moderatorID, removed.ID, removed.ConversationID)
+	return nil
+}
+
+// SubscribeToMessages subscribes to the messages of every conversation of a user, including the ones they send
+func (uc *ChatUsecase) SubscribeToMessages(userID int, callback func(*domain.Message)) (*nats.Subscription, error) {
+	return uc.NatsService.SubscribeToUserMessages(userID, callback)
+}
+
+// SubscribeToReceipts subscribes to the delivery and read receipts of the messages a user sent
+func (uc *ChatUsecase) SubscribeToReceipts(userID int, callback func(*domain.ReceiptEvent)) (*nats.Subscription, error) {
+	return uc.NatsService.SubscribeToReceipts(userID, callback)
+}
+
+// ShouldNotify reports whether a message should be pushed to userID in real time.
+// Incoming messages of a conversation the user muted, or from a user they blocked, are only stored.
+func (uc *ChatUsecase) ShouldNotify(ctx context.Context, userID int, message *domain.Message) bool {
+	if message.SenderID == userID {
+		return true
+	}
+
+	muted, err := uc.MuteRepo.IsMuted(ctx, userID, message.ConversationID)
+	if err != nil {
+		log.Printf("Error checking mute of conversation %d for user %d: %v", message.ConversationID, userID, err)
+		return true
+	}
+	if muted {
+		return false
+	}
+
+	blocked, err := uc.Blocks.BlockRepo.IsBlocked(ctx, userID, message.SenderID)
+	if err != nil {
+		log.Printf("Error checking block of user %d by user %d: %v", message.SenderID, userID, err)
+		return true
+	}
+	return !blocked
+}
+
+// MuteConversation stops real-time notifications of a conversation userID takes part in
+func (uc *ChatUsecase) MuteConversation(ctx context.Context, userID, conversationID int) error {
+	if _, err := uc.ChatRepo.GetMember(ctx, conversationID, userID); err != nil {
+		if errors.Is(err, repository.ErrMemberNotFound) {
+			return ErrConversationNotFound
+		}
+		return err
+	}
+
+	return uc.MuteRepo.Mute(ctx, userID, conversationID)
+}
+
+// UnmuteConversation restores real-time notifications of a conversation
+func (uc *ChatUsecase) UnmuteConversation(ctx context.Context, userID, conversationID int) error {
+	if err := uc.MuteRepo.Unmute(ctx, userID, conversationID); err != nil {
+		if errors.Is(err, repository.ErrMuteNotFound) {
+			return ErrMuteNotFound
+		}
+		return err
+	}
+	return nil
 }
 
-// SubscribeToSentMessages subscribes to messages sent BY a user
-func (uc *ChatUsecase) SubscribeToSentMessages(userID int, callback func(*domain.Message)) error {
-	subject := fmt.Sprintf("chat.sent.%d", userID)
-	return uc.NatsService.SubscribeToSubject(subject, callback)
+// ListMutedConversations returns the conversations muted by userID, most recently muted first
+func (uc *ChatUsecase) ListMutedConversations(ctx context.Context, userID int) ([]domain.ConversationMute, error) {
+	return uc.MuteRepo.ListByUser(ctx, userID)
 }
 
 // GetConversationMessages retrieves messages between two users
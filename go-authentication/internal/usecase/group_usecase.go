@@ -0,0 +1,250 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/repository"
+	"slices"
+	"strings"
+)
+
+const (
+	maxGroupNameLength = 100
+	maxGroupMembers    = 100
+)
+
+var (
+	ErrNotGroupConversation  = errors.New("only group conversations have members to manage")
+	ErrConversationForbidden = errors.New("you are not allowed to manage this conversation")
+	ErrInvalidGroupName      = fmt.Errorf("group name must be 1 to %d characters", maxGroupNameLength)
+	ErrTooManyMembers        = fmt.Errorf("a group can have at most %d members", maxGroupMembers)
+	ErrMemberNotFound        = errors.New("user is not a member of this conversation")
+	ErrInvalidMemberRole     = errors.New("role must be admin or member")
+)
+
+// GroupUsecase manages group conversations and their members.
+// The owner and admins invite, remove and rename; only the owner changes roles.
+type GroupUsecase struct {
+	ChatRepo repository.ChatRepository
+	UserRepo repository.UserRepository
+	Blocks   *BlockUsecase
+}
+
+// NewGroupUsecase creates a new instance of GroupUsecase
+func NewGroupUsecase(chatRepository repository.ChatRepository, userRepository repository.UserRepository, blockUsecase *BlockUsecase) *GroupUsecase {
+	return &GroupUsecase{
+		ChatRepo: chatRepository,
+		UserRepo: userRepository,
+		Blocks:   blockUsecase,
+	}
+}
+
+// CreateGroup starts a group owned by ownerID with the given members
+func (uc *GroupUsecase) CreateGroup(ctx context.Context, ownerID int, req *domain.CreateGroupRequest) (*domain.Conversation, error) {
+	name, err := validateGroupName(req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	var memberIDs []int
+	for _, userID := range req.MemberIDs {
+		if userID != ownerID && !slices.Contains(memberIDs, userID) {
+			memberIDs = append(memberIDs, userID)
+		}
+	}
+	if len(memberIDs)+1 > maxGroupMembers {
+		return nil, ErrTooManyMembers
+	}
+	if err := uc.checkInvitees(ctx, ownerID, memberIDs); err != nil {
+		return nil, err
+	}
+
+	conversation := &domain.Conversation{Name: name}
+	if err := uc.ChatRepo.CreateGroup(ctx, conversation, ownerID, memberIDs); err != nil {
+		return nil, err
+	}
+
+	return uc.withMembers(ctx, conversation)
+}
+
+// GetConversation returns a conversation with its members, provided userID is one of them
+func (uc *GroupUsecase) GetConversation(ctx context.Context, userID, conversationID int) (*domain.Conversation, error) {
+	conversation, _, err := loadConversation(ctx, uc.ChatRepo, userID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	return uc.withMembers(ctx, conversation)
+}
+
+// RenameGroup changes the name of a group
+func (uc *GroupUsecase) RenameGroup(ctx context.Context, userID, conversationID int, name string) (*domain.Conversation, error) {
+	conversation, actor, err := uc.loadGroup(ctx, userID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if !actor.CanManage() {
+		return nil, ErrConversationForbidden
+	}
+
+	name, err = validateGroupName(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := uc.ChatRepo.RenameConversation(ctx, conversationID, name); err != nil {
+		return nil, err
+	}
+
+	conversation.Name = name
+	return uc.withMembers(ctx, conversation)
+}
+
+// InviteMembers adds users to a group; users who already are members are left as they are.
+// New members start with the earlier messages read, so they do not count as unread.
+func (uc *GroupUsecase) InviteMembers(ctx context.Context, userID, conversationID int, userIDs []int) (*domain.Conversation, error) {
+	conversation, actor, err := uc.loadGroup(ctx, userID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if !actor.CanManage() {
+		return nil, ErrConversationForbidden
+	}
+
+	members, err := uc.ChatRepo.ListMembers(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	var invitees []int
+	for _, inviteeID := range userIDs {
+		isMember := slices.ContainsFunc(members, func(m domain.ConversationMember) bool { return m.UserID == inviteeID })
+		if !isMember && !slices.Contains(invitees, inviteeID) {
+			invitees = append(invitees, inviteeID)
+		}
+	}
+	if len(members)+len(invitees) > maxGroupMembers {
+		return nil, ErrTooManyMembers
+	}
+	if err := uc.checkInvitees(ctx, userID, invitees); err != nil {
+		return nil, err
+	}
+
+	for _, inviteeID := range invitees {
+		if err := uc.ChatRepo.AddMember(ctx, conversationID, inviteeID, domain.ConversationRoleMember); err != nil {
+			return nil, err
+		}
+	}
+
+	return uc.withMembers(ctx, conversation)
+}
+
+// RemoveMember removes a member from a group. Admins can only remove plain members,
+// the owner can remove anyone; removing yourself is leaving.
+func (uc *GroupUsecase) RemoveMember(ctx context.Context, userID, conversationID, memberID int) error {
+	if memberID == userID {
+		return uc.LeaveGroup(ctx, userID, conversationID)
+	}
+
+	_, actor, err := uc.loadGroup(ctx, userID, conversationID)
+	if err != nil {
+		return err
+	}
+	target, err := uc.member(ctx, conversationID, memberID)
+	if err != nil {
+		return err
+	}
+	if !actor.CanManage() || target.Role == domain.ConversationRoleOwner ||
+		(target.Role == domain.ConversationRoleAdmin && actor.Role != domain.ConversationRoleOwner) {
+		return ErrConversationForbidden
+	}
+
+	return uc.ChatRepo.RemoveMember(ctx, conversationID, memberID)
+}
+
+// LeaveGroup removes userID from a group. When the owner leaves, the longest standing admin,
+// or else member, becomes the owner; the group is deleted when its last member leaves.
+func (uc *GroupUsecase) LeaveGroup(ctx context.Context, userID, conversationID int) error {
+	if _, _, err := uc.loadGroup(ctx, userID, conversationID); err != nil {
+		return err
+	}
+	if err := uc.ChatRepo.LeaveGroup(ctx, conversationID, userID); err != nil {
+		if errors.Is(err, repository.ErrMemberNotFound) {
+			return ErrConversationNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// UpdateMemberRole promotes a member to admin or demotes an admin; only the owner can
+func (uc *GroupUsecase) UpdateMemberRole(ctx context.Context, userID, conversationID, memberID int, role string) (*domain.Conversation, error) {
+	if role != domain.ConversationRoleAdmin && role != domain.ConversationRoleMember {
+		return nil, ErrInvalidMemberRole
+	}
+
+	conversation, actor, err := uc.loadGroup(ctx, userID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if actor.Role != domain.ConversationRoleOwner || memberID == userID {
+		return nil, ErrConversationForbidden
+	}
+	if _, err := uc.member(ctx, conversationID, memberID); err != nil {
+		return nil, err
+	}
+
+	if err := uc.ChatRepo.UpdateMemberRole(ctx, conversationID, memberID, role); err != nil {
+		return nil, err
+	}
+	return uc.withMembers(ctx, conversation)
+}
+
+// checkInvitees makes sure every invitee exists and that no block stands between them and the inviter
+func (uc *GroupUsecase) checkInvitees(ctx context.Context, inviterID int, userIDs []int) error {
+	for _, userID := range userIDs {
+		if user, err := uc.UserRepo.GetByID(ctx, userID); err != nil || user == nil {
+			return fmt.Errorf("%w: %d", ErrUserNotFound, userID)
+		}
+		if err := uc.Blocks.CheckMessaging(ctx, inviterID, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadGroup is loadConversation for operations that only apply to groups
+func (uc *GroupUsecase) loadGroup(ctx context.Context, userID, conversationID int) (*domain.Conversation, *domain.ConversationMember, error) {
+	conversation, actor, err := loadConversation(ctx, uc.ChatRepo, userID, conversationID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !conversation.IsGroup() {
+		return nil, nil, ErrNotGroupConversation
+	}
+	return conversation, actor, nil
+}
+
+func (uc *GroupUsecase) member(ctx context.Context, conversationID, userID int) (*domain.ConversationMember, error) {
+	member, err := uc.ChatRepo.GetMember(ctx, conversationID, userID)
+	if errors.Is(err, repository.ErrMemberNotFound) {
+		return nil, ErrMemberNotFound
+	}
+	return member, err
+}
+
+func (uc *GroupUsecase) withMembers(ctx context.Context, conversation *domain.Conversation) (*domain.Conversation, error) {
+	members, err := uc.ChatRepo.ListMembers(ctx, conversation.ID)
+	if err != nil {
+		return nil, err
+	}
+	conversation.Members = members
+	return conversation, nil
+}
+
+func validateGroupName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > maxGroupNameLength {
+		return "", ErrInvalidGroupName
+	}
+	return name, nil
+}
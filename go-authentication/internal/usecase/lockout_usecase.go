@@ -0,0 +1,263 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-authentication/config"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/repository"
+	"go-authentication/pkg"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultMaxAccountFailures = 5
+	defaultMaxIPFailures      = 20
+	defaultBaseLockout        = time.Minute
+	defaultMaxLockout         = time.Hour
+	defaultFailureWindow      = 15 * time.Minute
+)
+
+var ErrUserNotFound = errors.New("user not found")
+
+// LoginLockedError is returned when an account or client IP is temporarily locked out
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginLockedError) Error() string {
+	return "too many failed login attempts, please try again later"
+}
+
+// LockoutPolicy controls when failed logins lock an account or client IP, and for how long.
+// Reaching a threshold locks for BaseLockout; every further failure doubles the lockout up to MaxLockout.
+type LockoutPolicy struct {
+	MaxAccountFailures int
+	MaxIPFailures      int
+	BaseLockout        time.Duration
+	MaxLockout         time.Duration
+	// FailureWindow is how long failures are remembered after the last failure or lockout
+	FailureWindow time.Duration
+}
+
+// NewLockoutPolicy reads the lockout thresholds from cfg, using defaults for unset values
+func NewLockoutPolicy(cfg *config.Config) (LockoutPolicy, error) {
+	maxAccountFailures, err := parseCount(cfg.LoginMaxAccountFailures, defaultMaxAccountFailures)
+	if err != nil {
+		return LockoutPolicy{}, fmt.Errorf("invalid LOGIN_MAX_ACCOUNT_FAILURES: %w", err)
+	}
+
+	maxIPFailures, err := parseCount(cfg.LoginMaxIPFailures, defaultMaxIPFailures)
+	if err != nil {
+		return LockoutPolicy{}, fmt.Errorf("invalid LOGIN_MAX_IP_FAILURES: %w", err)
+	}
+
+	baseLockout, err := pkg.ParseDuration(cfg.LoginLockoutSeconds, time.Second, 1, defaultBaseLockout)
+	if err != nil {
+		return LockoutPolicy{}, fmt.Errorf("invalid LOGIN_LOCKOUT_SECONDS: %w", err)
+	}
+
+	maxLockout, err := pkg.ParseDuration(cfg.LoginMaxLockoutSeconds, time.Second, 1, defaultMaxLockout)
+	if err != nil {
+		return LockoutPolicy{}, fmt.Errorf("invalid LOGIN_MAX_LOCKOUT_SECONDS: %w", err)
+	}
+
+	failureWindow, err := pkg.ParseDuration(cfg.LoginFailureWindowSeconds, time.Second, 1, defaultFailureWindow)
+	if err != nil {
+		return LockoutPolicy{}, fmt.Errorf("invalid LOGIN_FAILURE_WINDOW_SECONDS: %w", err)
+	}
+
+	if maxLockout < baseLockout {
+		return LockoutPolicy{}, errors.New("LOGIN_MAX_LOCKOUT_SECONDS must not be less than LOGIN_LOCKOUT_SECONDS")
+	}
+
+	return LockoutPolicy{
+		MaxAccountFailures: maxAccountFailures,
+		MaxIPFailures:      maxIPFailures,
+		BaseLockout:        baseLockout,
+		MaxLockout:         maxLockout,
+		FailureWindow:      failureWindow,
+	}, nil
+}
+
+// parseCount reads a positive integer, falling back when value is empty
+func parseCount(value string, fallback int) (int, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if n < 1 {
+		return 0, errors.New("must be at least 1")
+	}
+	return n, nil
+}
+
+// lockoutDuration returns how long to lock after failures, given the threshold that was reached
+func (p LockoutPolicy) lockoutDuration(failures, threshold int) time.Duration {
+	d := p.BaseLockout
+	for i := threshold; i < failures && d < p.MaxLockout; i++ {
+		d *= 2
+	}
+	if d > p.MaxLockout {
+		d = p.MaxLockout
+	}
+	return d
+}
+
+// LockoutUsecase tracks failed logins per account and per client IP and locks them out temporarily
+type LockoutUsecase struct {
+	ThrottleRepo repository.LoginThrottleRepository
+	UserRepo     repository.UserRepository
+	Policy       LockoutPolicy
+	Audit        *AuditUsecase
+}
+
+// NewLockoutUsecase creates a new instance of LockoutUsecase
+func NewLockoutUsecase(throttleRepository repository.LoginThrottleRepository, userRepository repository.UserRepository, policy LockoutPolicy, auditUsecase *AuditUsecase) *LockoutUsecase {
+	return &LockoutUsecase{
+		ThrottleRepo: throttleRepository,
+		UserRepo:     userRepository,
+		Policy:       policy,
+		Audit:        auditUsecase,
+	}
+}
+
+// Check returns a *LoginLockedError if the account or the client IP is currently locked out
+func (uc *LockoutUsecase) Check(ctx context.Context, email string, client domain.ClientInfo) error {
+	now := time.Now()
+	var retryAfter time.Duration
+
+	for _, key := range throttleKeys(email, client) {
+		throttle, err := uc.ThrottleRepo.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+		if throttle.IsLocked(now) {
+			if wait := throttle.LockedUntil.Sub(now); wait > retryAfter {
+				retryAfter = wait
+			}
+		}
+	}
+
+	if retryAfter > 0 {
+		return &LoginLockedError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// RecordFailure counts a failed attempt against the account and the client IP, locking whichever reached its threshold.
+// userID is nil when the email does not belong to an account.
+func (uc *LockoutUsecase) RecordFailure(ctx context.Context, email string, userID *int, client domain.ClientInfo) error {
+	now := time.Now()
+
+	for _, key := range throttleKeys(email, client) {
+		threshold := uc.Policy.MaxAccountFailures
+		keyUserID := userID
+		if strings.HasPrefix(key, "ip:") {
+			threshold = uc.Policy.MaxIPFailures
+			keyUserID = nil
+		}
+
+		throttle, err := uc.ThrottleRepo.RecordFailure(ctx, key, now, now.Add(-uc.Policy.FailureWindow))
+		if err != nil {
+			return err
+		}
+		if throttle.Failures < threshold {
+			continue
+		}
+
+		lockedUntil := now.Add(uc.Policy.lockoutDuration(throttle.Failures, threshold))
+		if err := uc.ThrottleRepo.Lock(ctx, key, lockedUntil); err != nil {
+			return err
+		}
+
+		log.Printf("Login locked out: key=%s, failures=%d, until=%s", key, throttle.Failures, lockedUntil.Format(time.RFC3339))
+		err = uc.ThrottleRepo.RecordEvent(ctx, &domain.LockoutEvent{
+			Key:         key,
+			UserID:      keyUserID,
+			Action:      domain.LockoutActionLocked,
+			Failures:    throttle.Failures,
+			IP:          client.IP,
+			UserAgent:   client.UserAgent,
+			LockedUntil: &lockedUntil,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RecordSuccess clears the failure count of an account after a complete login.
+// The IP counter is kept so one valid account cannot be used to reset it.
+func (uc *LockoutUsecase) RecordSuccess(ctx context.Context, email string) error {
+	return uc.ThrottleRepo.Reset(ctx, accountThrottleKey(email))
+}
+
+// Verify guards a secret that a signed in user presents again, e.g. their password before a sensitive change.
+// A locked out account is refused with a *LoginLockedError before verify runs, and a secret verify rejects
+// counts towards the same lockout as a failed login from the client in ctx.
+func (uc *LockoutUsecase) Verify(ctx context.Context, user *domain.User, verify func() (bool, error)) (bool, error) {
+	client := domain.ClientInfoFromContext(ctx)
+	if err := uc.Check(ctx, user.Email, client); err != nil {
+		return false, err
+	}
+
+	ok, err := verify()
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		if err := uc.RecordFailure(ctx, user.Email, &user.ID, client); err != nil {
+			log.Printf("Error recording failed verification of user %d: %v", user.ID, err)
+		}
+		return false, nil
+	}
+
+	if err := uc.RecordSuccess(ctx, user.Email); err != nil {
+		log.Printf("Error clearing failed logins for user %d: %v", user.ID, err)
+	}
+	return true, nil
+}
+
+// Unlock lifts the lockout of an account and records an unlock event
+func (uc *LockoutUsecase) Unlock(ctx context.Context, userID int, adminID int) error {
+	user, err := uc.UserRepo.GetByID(ctx, userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+
+	key := accountThrottleKey(user.Email)
+	if err := uc.ThrottleRepo.Reset(ctx, key); err != nil {
+		return err
+	}
+
+	log.Printf("Login unlocked: key=%s, by user_id=%d", key, adminID)
+	uc.Audit.Record(ctx, &domain.AuthEvent{Type: domain.AuthEventAccountUnlocked, UserID: &user.ID, ActorID: &adminID})
+	return uc.ThrottleRepo.RecordEvent(ctx, &domain.LockoutEvent{
+		Key:    key,
+		UserID: &user.ID,
+		Action: domain.LockoutActionUnlocked,
+	})
+}
+
+// throttleKeys returns the account key and, when the client IP is known, the IP key
+func throttleKeys(email string, client domain.ClientInfo) []string {
+	keys := []string{accountThrottleKey(email)}
+	if client.IP != "" {
+		keys = append(keys, "ip:"+client.IP)
+	}
+	return keys
+}
+
+func accountThrottleKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
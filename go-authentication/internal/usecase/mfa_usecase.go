@@ -0,0 +1,272 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/repository"
+	"go-authentication/pkg"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	// mfaChallengeTTL is how long the second login step may take after the password was accepted
+	mfaChallengeTTL = 5 * time.Minute
+	// totpSkewSteps is how many 30 second steps of clock drift are tolerated either way
+	totpSkewSteps     = 1
+	recoveryCodeCount = 10
+)
+
+var (
+	ErrInvalidMFACode      = errors.New("invalid authentication code")
+	ErrInvalidMFAChallenge = errors.New("invalid or expired mfa challenge")
+	ErrMFANotEnabled       = errors.New("two-factor authentication is not enabled")
+	ErrMFAAlreadyEnabled   = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnrolled      = errors.New("two-factor enrollment has not been started")
+)
+
+// MFARequiredError is returned by Login when the password was correct but a second factor is still needed
+type MFARequiredError struct {
+	Challenge *domain.MFAChallenge
+}
+
+func (e *MFARequiredError) Error() string {
+	return "two-factor authentication required"
+}
+
+// MFAUsecase handles TOTP enrollment and verification of second factors
+type MFAUsecase struct {
+	MFARepo  repository.MFARepository
+	UserRepo repository.UserRepository
+	// Lockout limits the codes and passwords tried when changing an enabled second factor
+	Lockout *LockoutUsecase
+	Hasher  pkg.PasswordHasher
+	Issuer  string
+	Audit   *AuditUsecase
+}
+
+// NewMFAUsecase creates a new instance of MFAUsecase.
+// issuer is the account label shown in authenticator apps.
+func NewMFAUsecase(mfaRepository repository.MFARepository, userRepository repository.UserRepository, lockoutUsecase *LockoutUsecase, passwordHasher pkg.PasswordHasher, issuer string, auditUsecase *AuditUsecase) *MFAUsecase {
+	return &MFAUsecase{
+		MFARepo:  mfaRepository,
+		UserRepo: userRepository,
+		Lockout:  lockoutUsecase,
+		Hasher:   passwordHasher,
+		Issuer:   issuer,
+		Audit:    auditUsecase,
+	}
+}
+
+// Enroll creates a new pending TOTP secret. It has no effect on login until confirmed with Enable.
+func (uc *MFAUsecase) Enroll(ctx context.Context, userID int) (*domain.MFAEnrollment, error) {
+	user, err := uc.UserRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := pkg.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.MFARepo.SavePending(ctx, &domain.UserMFA{UserID: userID, Secret: secret}); err != nil {
+		if errors.Is(err, repository.ErrMFAAlreadyEnabled) {
+			return nil, ErrMFAAlreadyEnabled
+		}
+		return nil, err
+	}
+
+	return &domain.MFAEnrollment{
+		Secret:          secret,
+		ProvisioningURI: pkg.TOTPProvisioningURI(uc.Issuer, user.Email, secret),
+	}, nil
+}
+
+// Enable confirms a pending enrollment with a code from the authenticator app
+// and returns the recovery codes. They are only ever shown this once.
+func (uc *MFAUsecase) Enable(ctx context.Context, userID int, code string) ([]string, error) {
+	mfa, err := uc.MFARepo.GetByUserID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrMFANotFound) {
+			return nil, ErrMFANotEnrolled
+		}
+		return nil, err
+	}
+	if mfa.IsEnabled() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	step, ok := pkg.ValidateTOTP(mfa.Secret, code, time.Now(), totpSkewSteps)
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.MFARepo.Enable(ctx, userID, step, hashes); err != nil {
+		if errors.Is(err, repository.ErrMFAAlreadyEnabled) {
+			return nil, ErrMFAAlreadyEnabled
+		}
+		return nil, err
+	}
+
+	uc.Audit.RecordUser(ctx, domain.AuthEventMFAEnabled, userID, nil)
+	return codes, nil
+}
+
+// Disable turns off two-factor authentication after checking the user's password
+// and a current code or a recovery code, so a stolen session alone cannot remove it
+func (uc *MFAUsecase) Disable(ctx context.Context, userID int, req *domain.MFADisableRequest) error {
+	user, err := uc.UserRepo.GetByID(ctx, userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+
+	// Both are checked as one attempt, so a known password does not clear the failures of guessed codes
+	wrong := ErrInvalidMFACode
+	ok, err := uc.Lockout.Verify(ctx, user, func() (bool, error) {
+		match, err := uc.Hasher.Verify(user.Password, req.Password)
+		if err != nil {
+			log.Printf("Error verifying password of user %d: %v", user.ID, err)
+		}
+		if !match {
+			wrong = ErrIncorrectPassword
+			return false, nil
+		}
+		return uc.checkCode(ctx, user.ID, req.Code)
+	})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return wrong
+	}
+
+	if err := uc.MFARepo.Delete(ctx, userID); err != nil {
+		return err
+	}
+
+	uc.Audit.RecordUser(ctx, domain.AuthEventMFADisabled, userID, nil)
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes, e.g. after the old ones were used up or lost
+func (uc *MFAUsecase) RegenerateRecoveryCodes(ctx context.Context, userID int, code string) ([]string, error) {
+	user, err := uc.UserRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	ok, err := uc.Lockout.Verify(ctx, user, func() (bool, error) {
+		return uc.checkCode(ctx, user.ID, code)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.MFARepo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	uc.Audit.RecordUser(ctx, domain.AuthEventRecoveryCodesRegenerated, userID, nil)
+
+	return codes, nil
+}
+
+// IsEnabled reports whether login requires a second factor for the user
+func (uc *MFAUsecase) IsEnabled(ctx context.Context, userID int) (bool, error) {
+	mfa, err := uc.MFARepo.GetByUserID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrMFANotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return mfa.IsEnabled(), nil
+}
+
+// VerifyCode accepts either a TOTP code or an unused recovery code.
+// Every code is accepted at most once.
+func (uc *MFAUsecase) VerifyCode(ctx context.Context, userID int, code string) error {
+	mfa, err := uc.MFARepo.GetByUserID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrMFANotFound) {
+			return ErrMFANotEnabled
+		}
+		return err
+	}
+	if !mfa.IsEnabled() {
+		return ErrMFANotEnabled
+	}
+
+	if step, ok := pkg.ValidateTOTP(mfa.Secret, code, time.Now(), totpSkewSteps); ok {
+		if err := uc.MFARepo.UseStep(ctx, userID, step); err != nil {
+			if errors.Is(err, repository.ErrTOTPStepUsed) {
+				return ErrInvalidMFACode
+			}
+			return err
+		}
+		return nil
+	}
+
+	if err := uc.MFARepo.UseRecoveryCode(ctx, userID, hashRecoveryCode(code)); err != nil {
+		if errors.Is(err, repository.ErrRecoveryCodeInvalid) {
+			return ErrInvalidMFACode
+		}
+		return err
+	}
+
+	log.Printf("Recovery code used: user_id=%d", userID)
+	return nil
+}
+
+// checkCode runs VerifyCode for a LockoutUsecase.Verify attempt, reporting a wrong code as a mismatch
+func (uc *MFAUsecase) checkCode(ctx context.Context, userID int, code string) (bool, error) {
+	err := uc.VerifyCode(ctx, userID, code)
+	if errors.Is(err, ErrInvalidMFACode) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// generateRecoveryCodes returns recovery codes formatted as xxxxx-xxxxx together with the hashes to store
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+
+	for i := range codes {
+		buf := make([]byte, 7)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(recoveryCodeEncoding.EncodeToString(buf))[:10]
+		codes[i] = raw[:5] + "-" + raw[5:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode normalizes a recovery code as typed by a user before hashing it
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return pkg.HashToken(normalized)
+}
@@ -0,0 +1,747 @@
+package usecase
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/repository"
+	"go-authentication/pkg"
+	"log"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	oauthAccessTokenTTL      = time.Hour
+	oauthRefreshTokenTTL     = 30 * 24 * time.Hour
+	authorizationCodeTTL     = time.Minute
+	oauthClientIDBytes       = 16
+	oauthClientSecretBytes   = 32
+	maxOAuthClientNameLength = 100
+	oauthClientSecretPrefix  = "gcs_"
+)
+
+// Error codes of the OAuth protocol (RFC 6749 sections 4.1.2.1 and 5.2)
+const (
+	OAuthErrInvalidRequest          = "invalid_request"
+	OAuthErrInvalidClient           = "invalid_client"
+	OAuthErrInvalidGrant            = "invalid_grant"
+	OAuthErrUnauthorizedClient      = "unauthorized_client"
+	OAuthErrUnsupportedGrantType    = "unsupported_grant_type"
+	OAuthErrUnsupportedResponseType = "unsupported_response_type"
+	OAuthErrInvalidScope            = "invalid_scope"
+	OAuthErrAccessDenied            = "access_denied"
+)
+
+// oauthScopePattern restricts scopes to simple tokens such as "profile" or "reports:read"
+var oauthScopePattern = regexp.MustCompile(`^[a-z0-9_.:-]{1,64}$`)
+
+var (
+	ErrOAuthClientNotFound = errors.New("oauth client not found")
+	ErrInvalidOAuthClient  = errors.New("invalid oauth client")
+	ErrConsentNotFound     = errors.New("consent not found")
+)
+
+// OAuthError is an error of the OAuth protocol. It is reported to clients as
+// {"error": Code, "error_description": Description}.
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+func (e *OAuthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+func oauthError(code, description string) *OAuthError {
+	return &OAuthError{Code: code, Description: description}
+}
+
+// OAuthUsecase lets this service act as an OAuth 2.0 authorization server for other applications.
+// It supports the authorization code grant with PKCE, the client credentials grant and refresh tokens,
+// and lets resource servers introspect tokens (RFC 7662) and clients revoke them (RFC 7009).
+// Access tokens are JWTs of type oauth_access signed with the service keys, so our own API does not accept them.
+type OAuthUsecase struct {
+	OAuthRepo   repository.OAuthRepository
+	UserRepo    repository.UserRepository
+	Revocations repository.RevocationStore
+	Tokens      *pkg.TokenService
+	Audit       *AuditUsecase
+}
+
+// NewOAuthUsecase creates a new instance of OAuthUsecase
+func NewOAuthUsecase(oauthRepository repository.OAuthRepository, userRepository repository.UserRepository, revocationStore repository.RevocationStore, tokenService *pkg.TokenService, auditUsecase *AuditUsecase) *OAuthUsecase {
+	return &OAuthUsecase{
+		OAuthRepo:   oauthRepository,
+		UserRepo:    userRepository,
+		Revocations: revocationStore,
+		Tokens:      tokenService,
+		Audit:       auditUsecase,
+	}
+}
+
+// RegisterClient registers an application. Confidential clients receive a secret that is shown once.
+func (uc *OAuthUsecase) RegisterClient(ctx context.Context, createdBy int, req *domain.CreateOAuthClientRequest) (*domain.RegisteredOAuthClient, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len([]rune(name)) > maxOAuthClientNameLength {
+		return nil, fmt.Errorf("%w: name must be between 1 and %d characters", ErrInvalidOAuthClient, maxOAuthClientNameLength)
+	}
+
+	grantTypes := req.GrantTypes
+	if len(grantTypes) == 0 {
+		grantTypes = []string{domain.GrantTypeAuthorizationCode}
+	}
+	grantTypes = uniqueStrings(grantTypes)
+	allowed := map[string]bool{}
+	for _, grantType := range grantTypes {
+		switch grantType {
+		case domain.GrantTypeAuthorizationCode, domain.GrantTypeClientCredentials, domain.GrantTypeRefreshToken:
+			allowed[grantType] = true
+		default:
+			return nil, fmt.Errorf("%w: unsupported grant type %q", ErrInvalidOAuthClient, grantType)
+		}
+	}
+	if allowed[domain.GrantTypeClientCredentials] && !req.Confidential {
+		return nil, fmt.Errorf("%w: the client credentials grant requires a confidential client", ErrInvalidOAuthClient)
+	}
+	if allowed[domain.GrantTypeRefreshToken] && !allowed[domain.GrantTypeAuthorizationCode] {
+		return nil, fmt.Errorf("%w: the refresh token grant requires the authorization code grant", ErrInvalidOAuthClient)
+	}
+
+	redirectURIs := uniqueStrings(req.RedirectURIs)
+	if allowed[domain.GrantTypeAuthorizationCode] && len(redirectURIs) == 0 {
+		return nil, fmt.Errorf("%w: the authorization code grant requires a redirect URI", ErrInvalidOAuthClient)
+	}
+	for _, redirectURI := range redirectURIs {
+		if err := validateRedirectURI(redirectURI); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidOAuthClient, err)
+		}
+	}
+
+	scopes := uniqueStrings(req.Scopes)
+	for _, scope := range scopes {
+		if !oauthScopePattern.MatchString(scope) {
+			return nil, fmt.Errorf("%w: invalid scope %q", ErrInvalidOAuthClient, scope)
+		}
+	}
+
+	clientID, err := pkg.GenerateOpaqueToken(oauthClientIDBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &domain.OAuthClient{
+		ClientID:     clientID,
+		Name:         name,
+		RedirectURIs: redirectURIs,
+		GrantTypes:   grantTypes,
+		Scopes:       scopes,
+		Confidential: req.Confidential,
+		CreatedBy:    createdBy,
+	}
+
+	var secret string
+	if req.Confidential {
+		random, err := pkg.GenerateOpaqueToken(oauthClientSecretBytes)
+		if err != nil {
+			return nil, err
+		}
+		secret = oauthClientSecretPrefix + random
+		client.SecretHash = pkg.HashToken(secret)
+	}
+
+	if err := uc.OAuthRepo.CreateClient(ctx, client); err != nil {
+		return nil, err
+	}
+
+	log.Printf("User %d registered oauth client %s (%s)", createdBy, client.ClientID, client.Name)
+	return &domain.RegisteredOAuthClient{OAuthClient: client, ClientSecret: secret}, nil
+}
+
+// validateRedirectURI accepts https URIs, http on the loopback interface and private-use schemes
+// of native apps such as com.example.app:/callback (RFC 8252)
+func validateRedirectURI(raw string) error {
+	uri, err := url.Parse(raw)
+	if err != nil || !uri.IsAbs() {
+		return fmt.Errorf("redirect URI %q must be an absolute URI", raw)
+	}
+	if uri.Fragment != "" || strings.Contains(raw, "#") {
+		return fmt.Errorf("redirect URI %q must not contain a fragment", raw)
+	}
+
+	switch uri.Scheme {
+	case "https":
+		if uri.Host == "" {
+			return fmt.Errorf("redirect URI %q has no host", raw)
+		}
+	case "http":
+		if host := uri.Hostname(); host != "localhost" && host != "127.0.0.1" && host != "::1" {
+			return fmt.Errorf("redirect URI %q must use https", raw)
+		}
+	default:
+		if !strings.Contains(uri.Scheme, ".") {
+			return fmt.Errorf("redirect URI %q must use https or a reverse domain name scheme", raw)
+		}
+	}
+	return nil
+}
+
+// ListClients returns every registered client without secrets
+func (uc *OAuthUsecase) ListClients(ctx context.Context) ([]domain.OAuthClient, error) {
+	return uc.OAuthRepo.ListClients(ctx)
+}
+
+// RevokeClient disables a client. Its refresh tokens are revoked and its access tokens stop introspecting as active.
+func (uc *OAuthUsecase) RevokeClient(ctx context.Context, clientID string) error {
+	if err := uc.OAuthRepo.RevokeClient(ctx, clientID); err != nil {
+		if errors.Is(err, repository.ErrOAuthClientNotFound) {
+			return ErrOAuthClientNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// AuthenticateClient checks the credentials a client presents at the token, introspection and revocation endpoints.
+// Public clients have no secret and must not send one.
+func (uc *OAuthUsecase) AuthenticateClient(ctx context.Context, clientID, clientSecret string) (*domain.OAuthClient, error) {
+	if clientID == "" {
+		return nil, oauthError(OAuthErrInvalidClient, "client authentication failed")
+	}
+
+	client, err := uc.OAuthRepo.GetClient(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, repository.ErrOAuthClientNotFound) {
+			return nil, oauthError(OAuthErrInvalidClient, "client authentication failed")
+		}
+		return nil, err
+	}
+	if client.RevokedAt != nil {
+		return nil, oauthError(OAuthErrInvalidClient, "client authentication failed")
+	}
+
+	if client.Confidential {
+		if subtle.ConstantTimeCompare([]byte(pkg.HashToken(clientSecret)), []byte(client.SecretHash)) != 1 {
+			return nil, oauthError(OAuthErrInvalidClient, "client authentication failed")
+		}
+	} else if clientSecret != "" {
+		return nil, oauthError(OAuthErrInvalidClient, "public clients must not send a secret")
+	}
+
+	return client, nil
+}
+
+// Authorize handles an authorization request of a signed in user. If the user already allowed the
+// client every requested scope it redirects back with a code; otherwise it asks for consent.
+// Problems with the client or redirect URI are returned as an *OAuthError because the user must not be
+// sent to a URI that was not verified; every other problem is reported to the client through the redirect.
+func (uc *OAuthUsecase) Authorize(ctx context.Context, userID int, req *domain.AuthorizationRequest) (*domain.AuthorizationResponse, error) {
+	client, scopes, rejected, err := uc.checkAuthorization(ctx, req)
+	if err != nil || rejected != nil {
+		return rejected, err
+	}
+
+	consent, err := uc.OAuthRepo.GetConsent(ctx, userID, client.ClientID)
+	if err != nil && !errors.Is(err, repository.ErrConsentNotFound) {
+		return nil, err
+	}
+	if consent != nil && containsAllStrings(consent.Scopes, scopes) {
+		return uc.issueCode(ctx, userID, client, req, scopes)
+	}
+
+	return &domain.AuthorizationResponse{
+		ConsentRequired: true,
+		ClientID:        client.ClientID,
+		ClientName:      client.Name,
+		Scopes:          scopes,
+	}, nil
+}
+
+// Decide records the user's answer to a consent prompt and redirects back to the client
+func (uc *OAuthUsecase) Decide(ctx context.Context, userID int, decision *domain.AuthorizationDecision) (*domain.AuthorizationResponse, error) {
+	req := &decision.AuthorizationRequest
+	client, scopes, rejected, err := uc.checkAuthorization(ctx, req)
+	if err != nil || rejected != nil {
+		return rejected, err
+	}
+
+	if !decision.Approve {
+		return authorizationError(client, req, OAuthErrAccessDenied, "the user denied access"), nil
+	}
+
+	// Consent accumulates, so approving more scopes later keeps the ones granted before
+	granted := scopes
+	consent, err := uc.OAuthRepo.GetConsent(ctx, userID, client.ClientID)
+	if err != nil && !errors.Is(err, repository.ErrConsentNotFound) {
+		return nil, err
+	}
+	if consent != nil {
+		granted = uniqueStrings(append(append([]string{}, consent.Scopes...), scopes...))
+	}
+
+	if err := uc.OAuthRepo.SaveConsent(ctx, &domain.OAuthConsent{UserID: userID, ClientID: client.ClientID, Scopes: granted}); err != nil {
+		return nil, err
+	}
+	uc.Audit.RecordUser(ctx, domain.AuthEventOAuthConsentGranted, userID, map[string]interface{}{"client_id": client.ClientID, "scopes": granted})
+
+	return uc.issueCode(ctx, userID, client, req, scopes)
+}
+
+// checkAuthorization validates an authorization request. rejected is set when the request must be
+// answered with an error redirect to the client.
+func (uc *OAuthUsecase) checkAuthorization(ctx context.Context, req *domain.AuthorizationRequest) (client *domain.OAuthClient, scopes []string, rejected *domain.AuthorizationResponse, err error) {
+	client, err = uc.OAuthRepo.GetClient(ctx, req.ClientID)
+	if err != nil {
+		if errors.Is(err, repository.ErrOAuthClientNotFound) {
+			return nil, nil, nil, oauthError(OAuthErrInvalidClient, "unknown client")
+		}
+		return nil, nil, nil, err
+	}
+	if client.RevokedAt != nil || !client.AllowsGrant(domain.GrantTypeAuthorizationCode) {
+		return nil, nil, nil, oauthError(OAuthErrUnauthorizedClient, "the client may not use the authorization code grant")
+	}
+	if resolveRedirectURI(client, req.RedirectURI) == "" {
+		return nil, nil, nil, oauthError(OAuthErrInvalidRequest, "redirect_uri is not registered for this client")
+	}
+
+	// From here on errors are sent back to the client
+	if req.ResponseType != "code" {
+		return nil, nil, authorizationError(client, req, OAuthErrUnsupportedResponseType, "only the code response type is supported"), nil
+	}
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != "S256" {
+		return nil, nil, authorizationError(client, req, OAuthErrInvalidRequest, "PKCE with code_challenge_method S256 is required"), nil
+	}
+
+	scopes = strings.Fields(req.Scope)
+	if !containsAllStrings(client.Scopes, scopes) {
+		return nil, nil, authorizationError(client, req, OAuthErrInvalidScope, "the client may not request these scopes"), nil
+	}
+
+	return client, uniqueStrings(scopes), nil, nil
+}
+
+// issueCode stores a single-use authorization code and redirects back to the client with it
+func (uc *OAuthUsecase) issueCode(ctx context.Context, userID int, client *domain.OAuthClient, req *domain.AuthorizationRequest, scopes []string) (*domain.AuthorizationResponse, error) {
+	code, err := pkg.GenerateOpaqueToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	err = uc.OAuthRepo.SaveAuthorizationCode(ctx, &domain.OAuthAuthorizationCode{
+		CodeHash:      pkg.HashToken(code),
+		ClientID:      client.ClientID,
+		UserID:        userID,
+		RedirectURI:   req.RedirectURI,
+		Scopes:        scopes,
+		CodeChallenge: req.CodeChallenge,
+		ExpiresAt:     time.Now().Add(authorizationCodeTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{"code": {code}}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	return &domain.AuthorizationResponse{RedirectTo: withQuery(resolveRedirectURI(client, req.RedirectURI), params)}, nil
+}
+
+// resolveRedirectURI returns the URI to send the user back to, or "" if requested is not registered.
+// requested may be omitted when the client registered exactly one redirect URI.
+func resolveRedirectURI(client *domain.OAuthClient, requested string) string {
+	if requested == "" {
+		if len(client.RedirectURIs) == 1 {
+			return client.RedirectURIs[0]
+		}
+		return ""
+	}
+	if client.AllowsRedirectURI(requested) {
+		return requested
+	}
+	return ""
+}
+
+// authorizationError redirects back to the client with an error (RFC 6749 section 4.1.2.1)
+func authorizationError(client *domain.OAuthClient, req *domain.AuthorizationRequest, code, description string) *domain.AuthorizationResponse {
+	params := url.Values{"error": {code}, "error_description": {description}}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	return &domain.AuthorizationResponse{RedirectTo: withQuery(resolveRedirectURI(client, req.RedirectURI), params)}
+}
+
+// withQuery adds params to the query of a registered redirect URI
+func withQuery(rawURI string, params url.Values) string {
+	uri, err := url.Parse(rawURI)
+	if err != nil {
+		return rawURI
+	}
+	query := uri.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	uri.RawQuery = query.Encode()
+	return uri.String()
+}
+
+// Token handles a request to the token endpoint by an authenticated client
+func (uc *OAuthUsecase) Token(ctx context.Context, client *domain.OAuthClient, req *domain.TokenRequest) (*domain.OAuthTokenResponse, error) {
+	switch req.GrantType {
+	case "":
+		return nil, oauthError(OAuthErrInvalidRequest, "grant_type is required")
+	case domain.GrantTypeAuthorizationCode, domain.GrantTypeClientCredentials, domain.GrantTypeRefreshToken:
+	default:
+		return nil, oauthError(OAuthErrUnsupportedGrantType, "unsupported grant type")
+	}
+
+	if !client.AllowsGrant(req.GrantType) {
+		return nil, oauthError(OAuthErrUnauthorizedClient, "the client may not use this grant type")
+	}
+
+	switch req.GrantType {
+	case domain.GrantTypeAuthorizationCode:
+		return uc.exchangeCode(ctx, client, req)
+	case domain.GrantTypeRefreshToken:
+		return uc.refresh(ctx, client, req)
+	default:
+		return uc.clientCredentials(ctx, client, req)
+	}
+}
+
+// exchangeCode redeems an authorization code. The code is consumed even when the request fails.
+func (uc *OAuthUsecase) exchangeCode(ctx context.Context, client *domain.OAuthClient, req *domain.TokenRequest) (*domain.OAuthTokenResponse, error) {
+	if req.Code == "" || req.CodeVerifier == "" {
+		return nil, oauthError(OAuthErrInvalidRequest, "code and code_verifier are required")
+	}
+
+	code, err := uc.OAuthRepo.ConsumeAuthorizationCode(ctx, pkg.HashToken(req.Code))
+	if err != nil {
+		if errors.Is(err, repository.ErrAuthorizationCodeNotFound) {
+			return nil, oauthError(OAuthErrInvalidGrant, "invalid or expired authorization code")
+		}
+		return nil, err
+	}
+
+	if code.ClientID != client.ClientID || time.Now().After(code.ExpiresAt) || code.RedirectURI != req.RedirectURI {
+		return nil, oauthError(OAuthErrInvalidGrant, "invalid or expired authorization code")
+	}
+	if subtle.ConstantTimeCompare([]byte(pkg.PKCEChallenge(req.CodeVerifier)), []byte(code.CodeChallenge)) != 1 {
+		return nil, oauthError(OAuthErrInvalidGrant, "code_verifier does not match the code challenge")
+	}
+
+	user, err := uc.UserRepo.GetByID(ctx, code.UserID)
+	if err != nil {
+		return nil, oauthError(OAuthErrInvalidGrant, "the user no longer exists")
+	}
+
+	response, err := uc.issueAccessToken(client, user, code.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	if containsAllStrings(code.Scopes, []string{domain.ScopeOfflineAccess}) && client.AllowsGrant(domain.GrantTypeRefreshToken) {
+		rawToken, token, err := newOAuthRefreshToken(client.ClientID, user.ID, code.Scopes)
+		if err != nil {
+			return nil, err
+		}
+		if err := uc.OAuthRepo.CreateRefreshToken(ctx, token); err != nil {
+			return nil, err
+		}
+		response.RefreshToken = rawToken
+	}
+
+	return response, nil
+}
+
+// refresh rotates a refresh token. Presenting a token that was already rotated revokes the whole grant,
+// since either the client or an attacker holds a stolen copy.
+func (uc *OAuthUsecase) refresh(ctx context.Context, client *domain.OAuthClient, req *domain.TokenRequest) (*domain.OAuthTokenResponse, error) {
+	token, err := uc.OAuthRepo.GetRefreshToken(ctx, pkg.HashToken(req.RefreshToken))
+	if err != nil {
+		if errors.Is(err, repository.ErrOAuthRefreshTokenNotFound) {
+			return nil, oauthError(OAuthErrInvalidGrant, "invalid or expired refresh token")
+		}
+		return nil, err
+	}
+	if token.ClientID != client.ClientID {
+		return nil, oauthError(OAuthErrInvalidGrant, "invalid or expired refresh token")
+	}
+	if token.RotatedAt != nil && token.RevokedAt == nil {
+		return nil, uc.revokeReusedGrant(ctx, token)
+	}
+	if !token.IsUsable(time.Now()) {
+		return nil, oauthError(OAuthErrInvalidGrant, "invalid or expired refresh token")
+	}
+
+	// The client may ask for fewer scopes than it was granted, but never for more
+	scopes := token.Scopes
+	if requested := strings.Fields(req.Scope); len(requested) > 0 {
+		if !containsAllStrings(token.Scopes, requested) {
+			return nil, oauthError(OAuthErrInvalidScope, "the requested scope exceeds the granted scope")
+		}
+		scopes = uniqueStrings(requested)
+	}
+
+	user, err := uc.UserRepo.GetByID(ctx, token.UserID)
+	if err != nil {
+		return nil, oauthError(OAuthErrInvalidGrant, "the user no longer exists")
+	}
+
+	rawToken, next, err := newOAuthRefreshToken(client.ClientID, user.ID, token.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	if err := uc.OAuthRepo.RotateRefreshToken(ctx, token.ID, next); err != nil {
+		if errors.Is(err, repository.ErrOAuthRefreshTokenConsumed) {
+			return nil, uc.revokeReusedGrant(ctx, token)
+		}
+		return nil, err
+	}
+
+	response, err := uc.issueAccessToken(client, user, scopes)
+	if err != nil {
+		return nil, err
+	}
+	response.RefreshToken = rawToken
+	return response, nil
+}
+
+func (uc *OAuthUsecase) revokeReusedGrant(ctx context.Context, token *domain.OAuthRefreshToken) error {
+	log.Printf("OAuth refresh token reuse detected: user_id=%d, client_id=%s", token.UserID, token.ClientID)
+	if err := uc.OAuthRepo.RevokeGrant(ctx, token.UserID, token.ClientID); err != nil {
+		return err
+	}
+	return oauthError(OAuthErrInvalidGrant, "refresh token reuse detected, the grant was revoked")
+}
+
+// clientCredentials issues a token to a confidential client acting on its own behalf.
+// Without a scope parameter it receives every scope it was registered with.
+func (uc *OAuthUsecase) clientCredentials(ctx context.Context, client *domain.OAuthClient, req *domain.TokenRequest) (*domain.OAuthTokenResponse, error) {
+	scopes := client.Scopes
+	if requested := strings.Fields(req.Scope); len(requested) > 0 {
+		if !containsAllStrings(client.Scopes, requested) {
+			return nil, oauthError(OAuthErrInvalidScope, "the client may not request these scopes")
+		}
+		scopes = uniqueStrings(requested)
+	}
+
+	return uc.issueAccessToken(client, nil, scopes)
+}
+
+// issueAccessToken signs an access token for a user, or for the client itself when user is nil.
+// Following RFC 9068, sub is the user ID or the client ID.
+func (uc *OAuthUsecase) issueAccessToken(client *domain.OAuthClient, user *domain.User, scopes []string) (*domain.OAuthTokenResponse, error) {
+	userID, email, subject := 0, "", client.ClientID
+	if user != nil {
+		userID, email, subject = user.ID, user.Email, strconv.Itoa(user.ID)
+	}
+
+	scope := strings.Join(scopes, " ")
+	accessToken, err := uc.Tokens.GenerateOAuthAccessToken(userID, email, oauthAccessTokenTTL, jwt.MapClaims{
+		"sub":       subject,
+		"client_id": client.ClientID,
+		"scope":     scope,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(oauthAccessTokenTTL.Seconds()),
+		Scope:       scope,
+	}, nil
+}
+
+func newOAuthRefreshToken(clientID string, userID int, scopes []string) (string, *domain.OAuthRefreshToken, error) {
+	rawToken, err := pkg.GenerateOpaqueToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+	return rawToken, &domain.OAuthRefreshToken{
+		TokenHash: pkg.HashToken(rawToken),
+		ClientID:  clientID,
+		UserID:    userID,
+		Scopes:    scopes,
+		ExpiresAt: time.Now().Add(oauthRefreshTokenTTL),
+	}, nil
+}
+
+// Introspect tells a resource server whether a token is active (RFC 7662).
+// Only confidential clients may introspect; refresh tokens are only described to the client holding them.
+// Access tokens are JWTs and refresh tokens are opaque, so no token_type_hint is needed to tell them apart.
+func (uc *OAuthUsecase) Introspect(ctx context.Context, client *domain.OAuthClient, token string) (*domain.IntrospectionResponse, error) {
+	if !client.Confidential {
+		return nil, oauthError(OAuthErrUnauthorizedClient, "only confidential clients may introspect tokens")
+	}
+
+	inactive := &domain.IntrospectionResponse{Active: false}
+	if token == "" {
+		return inactive, nil
+	}
+
+	if isJWT(token) {
+		return uc.introspectAccessToken(ctx, token)
+	}
+
+	refreshToken, err := uc.OAuthRepo.GetRefreshToken(ctx, pkg.HashToken(token))
+	if err != nil {
+		if errors.Is(err, repository.ErrOAuthRefreshTokenNotFound) {
+			return inactive, nil
+		}
+		return nil, err
+	}
+	if refreshToken.ClientID != client.ClientID || !refreshToken.IsUsable(time.Now()) {
+		return inactive, nil
+	}
+
+	user, err := uc.UserRepo.GetByID(ctx, refreshToken.UserID)
+	if err != nil {
+		return inactive, nil
+	}
+
+	return &domain.IntrospectionResponse{
+		Active:    true,
+		Scope:     strings.Join(refreshToken.Scopes, " "),
+		ClientID:  refreshToken.ClientID,
+		Username:  user.Email,
+		TokenType: "refresh_token",
+		Exp:       refreshToken.ExpiresAt.Unix(),
+		Iat:       refreshToken.CreatedAt.Unix(),
+		Sub:       strconv.Itoa(user.ID),
+	}, nil
+}
+
+// introspectAccessToken describes an OAuth access token
+func (uc *OAuthUsecase) introspectAccessToken(ctx context.Context, token string) (*domain.IntrospectionResponse, error) {
+	inactive := &domain.IntrospectionResponse{Active: false}
+	claims, err := uc.Tokens.ValidatePurposeToken(pkg.TokenTypeOAuthAccess, token)
+	if err != nil {
+		return inactive, nil
+	}
+
+	userID, _ := claims["user_id"].(float64)
+	revoked, err := uc.Revocations.IsRevoked(ctx, pkg.TokenID(claims), "", int(userID), pkg.IssuedAt(claims))
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return inactive, nil
+	}
+
+	// Tokens of a revoked client stop working before they expire
+	clientID, _ := claims["client_id"].(string)
+	issuer, err := uc.OAuthRepo.GetClient(ctx, clientID)
+	if err != nil || issuer.RevokedAt != nil {
+		return inactive, nil
+	}
+
+	response := &domain.IntrospectionResponse{
+		Active:    true,
+		ClientID:  clientID,
+		TokenType: "Bearer",
+		Exp:       pkg.ClaimTime(claims, "exp").Unix(),
+		Iat:       pkg.ClaimTime(claims, "iat").Unix(),
+		Jti:       pkg.TokenID(claims),
+	}
+	response.Scope, _ = claims["scope"].(string)
+	response.Sub, _ = claims["sub"].(string)
+	response.Iss, _ = claims["iss"].(string)
+	response.Username, _ = claims["email"].(string)
+	return response, nil
+}
+
+// Revoke revokes an access or refresh token the client holds (RFC 7009).
+// Unknown tokens and tokens of other clients are ignored, as the RFC requires.
+// Revoking a refresh token does not revoke the access tokens issued with it; they expire within an hour.
+func (uc *OAuthUsecase) Revoke(ctx context.Context, client *domain.OAuthClient, token string) error {
+	if token == "" {
+		return oauthError(OAuthErrInvalidRequest, "token is required")
+	}
+
+	if isJWT(token) {
+		claims, err := uc.Tokens.ValidatePurposeToken(pkg.TokenTypeOAuthAccess, token)
+		if err != nil {
+			return nil
+		}
+		if clientID, _ := claims["client_id"].(string); clientID != client.ClientID {
+			return nil
+		}
+		return uc.Revocations.RevokeToken(ctx, pkg.TokenID(claims), pkg.ClaimTime(claims, "exp"))
+	}
+
+	refreshToken, err := uc.OAuthRepo.GetRefreshToken(ctx, pkg.HashToken(token))
+	if err != nil {
+		if errors.Is(err, repository.ErrOAuthRefreshTokenNotFound) {
+			return nil
+		}
+		return err
+	}
+	if refreshToken.ClientID != client.ClientID {
+		return nil
+	}
+	return uc.OAuthRepo.RevokeRefreshToken(ctx, refreshToken.ID)
+}
+
+// ListConsents returns the applications a user allowed to access their account
+func (uc *OAuthUsecase) ListConsents(ctx context.Context, userID int) ([]domain.OAuthConsent, error) {
+	return uc.OAuthRepo.ListConsents(ctx, userID)
+}
+
+// RevokeConsent withdraws a user's consent and revokes the refresh tokens the client holds for them.
+// The client has to ask for consent again on its next authorization request.
+func (uc *OAuthUsecase) RevokeConsent(ctx context.Context, userID int, clientID string) error {
+	if err := uc.OAuthRepo.DeleteConsent(ctx, userID, clientID); err != nil {
+		if errors.Is(err, repository.ErrConsentNotFound) {
+			return ErrConsentNotFound
+		}
+		return err
+	}
+	if err := uc.OAuthRepo.RevokeGrant(ctx, userID, clientID); err != nil {
+		return err
+	}
+
+	uc.Audit.RecordUser(ctx, domain.AuthEventOAuthConsentRevoked, userID, map[string]interface{}{"client_id": clientID})
+	return nil
+}
+
+// isJWT tells signed access tokens apart from opaque refresh tokens, which never contain a dot
+func isJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// containsAllStrings reports whether every value of subset is in set
+func containsAllStrings(set, subset []string) bool {
+	present := make(map[string]bool, len(set))
+	for _, value := range set {
+		present[value] = true
+	}
+	for _, value := range subset {
+		if !present[value] {
+			return false
+		}
+	}
+	return true
+}
+
+// uniqueStrings removes empty and duplicate values, keeping the first occurrence
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" && !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,200 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/repository"
+	"go-authentication/pkg"
+	"log"
+	"strings"
+	"time"
+)
+
+// oidcLoginTTL is how long the user has to sign in at the provider
+const oidcLoginTTL = 10 * time.Minute
+
+var (
+	ErrOIDCNotConfigured = errors.New("single sign-on is not configured")
+	ErrInvalidOIDCState  = errors.New("invalid or expired login state, please start the login again")
+	ErrOIDCLoginFailed   = errors.New("single sign-on failed")
+	// ErrOIDCEmailNotVerified is returned when the provider does not vouch for the email of a new identity
+	ErrOIDCEmailNotVerified = errors.New("your email address is not verified by the identity provider")
+	// ErrOIDCAccountNotVerified protects local accounts whose owner never proved control of the email
+	ErrOIDCAccountNotVerified = errors.New("an account with this email exists but is not verified; verify it or reset its password first")
+)
+
+// OIDCUsecase signs users in through an external OpenID Connect provider using the authorization code flow with PKCE.
+// Identities are linked to local accounts by verified email on first login.
+type OIDCUsecase struct {
+	Provider     *pkg.OIDCProvider
+	IdentityRepo repository.UserIdentityRepository
+	UserRepo     repository.UserRepository
+	Auth         *AuthUsecase
+}
+
+// NewOIDCUsecase creates a new instance of OIDCUsecase. provider is nil when OIDC login is disabled.
+func NewOIDCUsecase(provider *pkg.OIDCProvider, identityRepository repository.UserIdentityRepository, userRepository repository.UserRepository, authUsecase *AuthUsecase) *OIDCUsecase {
+	return &OIDCUsecase{
+		Provider:     provider,
+		IdentityRepo: identityRepository,
+		UserRepo:     userRepository,
+		Auth:         authUsecase,
+	}
+}
+
+// StartLogin creates the state, nonce and PKCE verifier of a new login and returns the provider URL to redirect to
+func (uc *OIDCUsecase) StartLogin(ctx context.Context) (*domain.OIDCAuthorization, error) {
+	if uc.Provider == nil {
+		return nil, ErrOIDCNotConfigured
+	}
+
+	state, err := pkg.GenerateOpaqueToken(32)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := pkg.GenerateOpaqueToken(32)
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := pkg.GenerateOpaqueToken(48)
+	if err != nil {
+		return nil, err
+	}
+
+	authURL, err := uc.Provider.AuthCodeURL(ctx, state, nonce, pkg.PKCEChallenge(verifier))
+	if err != nil {
+		return nil, err
+	}
+
+	err = uc.IdentityRepo.SaveLoginState(ctx, &domain.OIDCLoginState{
+		StateHash:    pkg.HashToken(state),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(oidcLoginTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.OIDCAuthorization{URL: authURL, State: state}, nil
+}
+
+// Callback completes a login with the code the provider redirected back with.
+// Like Login it may return an *MFARequiredError when the user enabled two-factor authentication.
+func (uc *OIDCUsecase) Callback(ctx context.Context, state, code string, client domain.ClientInfo) (*domain.TokenPair, error) {
+	if uc.Provider == nil {
+		return nil, ErrOIDCNotConfigured
+	}
+
+	loginState, err := uc.IdentityRepo.ConsumeLoginState(ctx, pkg.HashToken(state))
+	if err != nil {
+		if errors.Is(err, repository.ErrOIDCStateNotFound) {
+			return nil, ErrInvalidOIDCState
+		}
+		return nil, err
+	}
+	if time.Now().After(loginState.ExpiresAt) {
+		return nil, ErrInvalidOIDCState
+	}
+
+	rawIDToken, err := uc.Provider.Exchange(ctx, code, loginState.CodeVerifier)
+	if err != nil {
+		log.Printf("OIDC code exchange with %s failed: %v", uc.Provider.Name, err)
+		return nil, ErrOIDCLoginFailed
+	}
+
+	identity, err := uc.Provider.VerifyIDToken(ctx, rawIDToken, loginState.Nonce)
+	if err != nil {
+		log.Printf("OIDC id token from %s rejected: %v", uc.Provider.Name, err)
+		return nil, ErrOIDCLoginFailed
+	}
+
+	user, err := uc.resolveUser(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	return uc.Auth.completeLogin(ctx, user, client, domain.LoginMethodOIDC)
+}
+
+// resolveUser finds the user linked to identity, linking or creating an account by verified email on first login
+func (uc *OIDCUsecase) resolveUser(ctx context.Context, identity *pkg.OIDCIdentity) (*domain.User, error) {
+	provider := uc.Provider.Name
+
+	linked, err := uc.IdentityRepo.GetByProviderSubject(ctx, provider, identity.Subject)
+	if err == nil {
+		if err := uc.IdentityRepo.TouchLastLogin(ctx, linked.ID, time.Now()); err != nil {
+			log.Printf("Error updating identity %d: %v", linked.ID, err)
+		}
+		user, err := uc.UserRepo.GetByID(ctx, linked.UserID)
+		if err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+	if !errors.Is(err, repository.ErrIdentityNotFound) {
+		return nil, err
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, ErrOIDCEmailNotVerified
+	}
+	email := strings.ToLower(strings.TrimSpace(identity.Email))
+
+	user, err := uc.UserRepo.GetByEmail(ctx, email)
+	if err == nil {
+		if !user.IsEmailVerified() {
+			return nil, ErrOIDCAccountNotVerified
+		}
+	} else if user, err = uc.createUser(ctx, email, identity.Name); err != nil {
+		return nil, err
+	}
+
+	err = uc.IdentityRepo.Create(ctx, &domain.UserIdentity{
+		UserID:   user.ID,
+		Provider: provider,
+		Subject:  identity.Subject,
+		Email:    email,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Linked %s identity %s to user %d", provider, identity.Subject, user.ID)
+	return user, nil
+}
+
+// createUser signs up a user whose email the provider verified.
+// The account gets a random password; its owner can set one through the password reset flow.
+func (uc *OIDCUsecase) createUser(ctx context.Context, email, name string) (*domain.User, error) {
+	if name = strings.TrimSpace(name); name == "" {
+		name, _, _ = strings.Cut(email, "@")
+	}
+
+	password, err := pkg.GenerateOpaqueToken(32)
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := uc.Auth.Hasher.Hash(password)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &domain.User{Name: name, Email: email, Password: hashedPassword}
+	if err := uc.UserRepo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+
+	verifiedAt := time.Now()
+	if err := uc.UserRepo.MarkEmailVerified(ctx, user.ID, verifiedAt); err != nil {
+		return nil, err
+	}
+	user.EmailVerifiedAt = &verifiedAt
+
+	if err := uc.Auth.Roles.AssignDefaultRoles(ctx, user); err != nil {
+		log.Printf("Error assigning default roles to user %d: %v", user.ID, err)
+	}
+
+	return user, nil
+}
@@ -0,0 +1,316 @@
+package usecase
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/repository"
+	"go-authentication/pkg"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// passkeyCeremonyTTL is how long the browser has to complete a registration or login
+	passkeyCeremonyTTL    = 5 * time.Minute
+	passkeyChallengeBytes = 32
+	maxPasskeysPerUser    = 10
+	maxPasskeyNameLength  = 100
+	defaultPasskeyName    = "Passkey"
+)
+
+var (
+	ErrPasskeyNotFound      = errors.New("passkey not found")
+	ErrPasskeyExists        = errors.New("passkey is already registered")
+	ErrTooManyPasskeys      = fmt.Errorf("at most %d passkeys can be registered", maxPasskeysPerUser)
+	ErrInvalidPasskeyName   = fmt.Errorf("passkey name must be at most %d characters", maxPasskeyNameLength)
+	ErrInvalidPasskeyLogin  = errors.New("passkey could not be verified")
+	ErrInvalidPasskeyCreate = errors.New("passkey registration could not be verified")
+)
+
+// PasskeyUsecase registers WebAuthn credentials and verifies logins made with them.
+// Every ceremony requires user verification, so a passkey is phishing resistant and a second factor on its own.
+type PasskeyUsecase struct {
+	PasskeyRepo  repository.PasskeyRepository
+	UserRepo     repository.UserRepository
+	RelyingParty *pkg.WebAuthnRelyingParty
+	Audit        *AuditUsecase
+}
+
+// NewPasskeyUsecase creates a new instance of PasskeyUsecase
+func NewPasskeyUsecase(passkeyRepository repository.PasskeyRepository, userRepository repository.UserRepository, relyingParty *pkg.WebAuthnRelyingParty, auditUsecase *AuditUsecase) *PasskeyUsecase {
+	return &PasskeyUsecase{
+		PasskeyRepo:  passkeyRepository,
+		UserRepo:     userRepository,
+		RelyingParty: relyingParty,
+		Audit:        auditUsecase,
+	}
+}
+
+// BeginRegistration starts registering a new passkey and returns the options for navigator.credentials.create()
+func (uc *PasskeyUsecase) BeginRegistration(ctx context.Context, userID int) (*domain.PasskeyRegistrationOptions, error) {
+	user, err := uc.UserRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	passkeys, err := uc.PasskeyRepo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(passkeys) >= maxPasskeysPerUser {
+		return nil, ErrTooManyPasskeys
+	}
+
+	challenge, err := uc.newChallenge(ctx, domain.PasskeyCeremonyRegistration, &userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Listing the existing credentials stops an authenticator from registering twice for the same account
+	exclude := make([]domain.PasskeyCredentialDescriptor, 0, len(passkeys))
+	for _, passkey := range passkeys {
+		exclude = append(exclude, domain.PasskeyCredentialDescriptor{
+			Type:       "public-key",
+			ID:         passkey.CredentialID,
+			Transports: passkey.Transports,
+		})
+	}
+
+	params := make([]domain.PasskeyCredentialParameters, 0, len(pkg.WebAuthnAlgorithms))
+	for _, alg := range pkg.WebAuthnAlgorithms {
+		params = append(params, domain.PasskeyCredentialParameters{Type: "public-key", Alg: alg})
+	}
+
+	return &domain.PasskeyRegistrationOptions{
+		Challenge: challenge,
+		RP:        domain.PasskeyRelyingParty{ID: uc.RelyingParty.ID, Name: uc.RelyingParty.Name},
+		User: domain.PasskeyUser{
+			ID:          base64.RawURLEncoding.EncodeToString(passkeyUserHandle(user.ID)),
+			Name:        user.Email,
+			DisplayName: user.Name,
+		},
+		PubKeyCredParams:   params,
+		Timeout:            passkeyCeremonyTTL.Milliseconds(),
+		ExcludeCredentials: exclude,
+		AuthenticatorSelection: domain.PasskeyAuthenticatorSelection{
+			// A discoverable credential lets the user sign in without typing their email first
+			ResidentKey:      "required",
+			UserVerification: "required",
+		},
+		Attestation: "none",
+	}, nil
+}
+
+// FinishRegistration verifies the authenticator's response to BeginRegistration and stores the new passkey
+func (uc *PasskeyUsecase) FinishRegistration(ctx context.Context, userID int, req *domain.PasskeyRegistrationRequest) (*domain.Passkey, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = defaultPasskeyName
+	}
+	if len([]rune(name)) > maxPasskeyNameLength {
+		return nil, ErrInvalidPasskeyName
+	}
+
+	clientDataJSON, err := decodeBase64URL(req.Response.ClientDataJSON)
+	if err != nil {
+		return nil, ErrInvalidPasskeyCreate
+	}
+	attestationObject, err := decodeBase64URL(req.Response.AttestationObject)
+	if err != nil {
+		return nil, ErrInvalidPasskeyCreate
+	}
+
+	clientData, err := uc.RelyingParty.ParseClientData(clientDataJSON, pkg.WebAuthnCeremonyCreate)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPasskeyCreate, err)
+	}
+	if err := uc.consumeChallenge(ctx, clientData.Challenge, domain.PasskeyCeremonyRegistration, &userID); err != nil {
+		return nil, ErrInvalidPasskeyCreate
+	}
+
+	authData, key, err := uc.RelyingParty.VerifyAttestation(attestationObject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPasskeyCreate, err)
+	}
+
+	credentialID := base64.RawURLEncoding.EncodeToString(authData.CredentialID)
+	if subtle.ConstantTimeCompare([]byte(credentialID), []byte(strings.TrimRight(req.ID, "="))) != 1 {
+		return nil, fmt.Errorf("%w: credential ID does not match", ErrInvalidPasskeyCreate)
+	}
+
+	passkey := &domain.Passkey{
+		UserID:       userID,
+		CredentialID: credentialID,
+		PublicKey:    authData.CredentialPublicKey,
+		Algorithm:    key.Algorithm,
+		SignCount:    int64(authData.SignCount),
+		Name:         name,
+		Transports:   req.Response.Transports,
+	}
+	if err := uc.PasskeyRepo.Create(ctx, passkey); err != nil {
+		if errors.Is(err, repository.ErrPasskeyExists) {
+			return nil, ErrPasskeyExists
+		}
+		return nil, err
+	}
+
+	uc.Audit.RecordUser(ctx, domain.AuthEventPasskeyRegistered, userID, map[string]interface{}{"passkey_id": passkey.ID, "name": passkey.Name})
+	return passkey, nil
+}
+
+// ListPasskeys returns the user's passkeys without their keys
+func (uc *PasskeyUsecase) ListPasskeys(ctx context.Context, userID int) ([]domain.Passkey, error) {
+	return uc.PasskeyRepo.ListByUser(ctx, userID)
+}
+
+// DeletePasskey removes one of the user's passkeys; it can no longer be used to log in
+func (uc *PasskeyUsecase) DeletePasskey(ctx context.Context, userID, id int) error {
+	if err := uc.PasskeyRepo.Delete(ctx, id, userID); err != nil {
+		if errors.Is(err, repository.ErrPasskeyNotFound) {
+			return ErrPasskeyNotFound
+		}
+		return err
+	}
+
+	uc.Audit.RecordUser(ctx, domain.AuthEventPasskeyDeleted, userID, map[string]interface{}{"passkey_id": id})
+	return nil
+}
+
+// BeginLogin starts a passkey login and returns the options for navigator.credentials.get()
+func (uc *PasskeyUsecase) BeginLogin(ctx context.Context) (*domain.PasskeyLoginOptions, error) {
+	challenge, err := uc.newChallenge(ctx, domain.PasskeyCeremonyLogin, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.PasskeyLoginOptions{
+		Challenge:        challenge,
+		Timeout:          passkeyCeremonyTTL.Milliseconds(),
+		RPID:             uc.RelyingParty.ID,
+		UserVerification: "required",
+	}, nil
+}
+
+// Authenticate verifies a login assertion and returns the passkey it was made with.
+// Every failure is reported as ErrInvalidPasskeyLogin; the reason is only logged.
+func (uc *PasskeyUsecase) Authenticate(ctx context.Context, req *domain.PasskeyLoginRequest) (*domain.Passkey, error) {
+	passkey, err := uc.verifyAssertion(ctx, req)
+	if err != nil {
+		log.Printf("Passkey login rejected: %v", err)
+		return nil, ErrInvalidPasskeyLogin
+	}
+	return passkey, nil
+}
+
+func (uc *PasskeyUsecase) verifyAssertion(ctx context.Context, req *domain.PasskeyLoginRequest) (*domain.Passkey, error) {
+	clientDataJSON, err := decodeBase64URL(req.Response.ClientDataJSON)
+	if err != nil {
+		return nil, errors.New("malformed client data")
+	}
+	authenticatorData, err := decodeBase64URL(req.Response.AuthenticatorData)
+	if err != nil {
+		return nil, errors.New("malformed authenticator data")
+	}
+	signature, err := decodeBase64URL(req.Response.Signature)
+	if err != nil {
+		return nil, errors.New("malformed signature")
+	}
+
+	clientData, err := uc.RelyingParty.ParseClientData(clientDataJSON, pkg.WebAuthnCeremonyGet)
+	if err != nil {
+		return nil, err
+	}
+	// The challenge is spent before anything else is checked, so every response can be tried only once
+	if err := uc.consumeChallenge(ctx, clientData.Challenge, domain.PasskeyCeremonyLogin, nil); err != nil {
+		return nil, err
+	}
+
+	passkey, err := uc.PasskeyRepo.GetByCredentialID(ctx, strings.TrimRight(req.ID, "="))
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Response.UserHandle != "" {
+		userHandle, err := decodeBase64URL(req.Response.UserHandle)
+		if err != nil || subtle.ConstantTimeCompare(userHandle, passkeyUserHandle(passkey.UserID)) != 1 {
+			return nil, fmt.Errorf("user handle does not match passkey %d", passkey.ID)
+		}
+	}
+
+	key, err := pkg.ParseCOSEKey(passkey.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	authData, err := uc.RelyingParty.VerifyAssertion(key, authenticatorData, clientDataJSON, signature)
+	if err != nil {
+		return nil, err
+	}
+
+	// A counter that did not increase means the credential's key may have been copied to another authenticator
+	signCount := int64(authData.SignCount)
+	if (signCount != 0 || passkey.SignCount != 0) && signCount <= passkey.SignCount {
+		return nil, fmt.Errorf("signature counter of passkey %d went from %d to %d, the authenticator may be cloned", passkey.ID, passkey.SignCount, signCount)
+	}
+
+	now := time.Now()
+	if err := uc.PasskeyRepo.UpdateSignCount(ctx, passkey.ID, signCount, now); err != nil {
+		return nil, err
+	}
+	passkey.SignCount = signCount
+	passkey.LastUsedAt = &now
+
+	return passkey, nil
+}
+
+// newChallenge issues a single-use challenge for a ceremony and stores its hash
+func (uc *PasskeyUsecase) newChallenge(ctx context.Context, ceremony string, userID *int) (string, error) {
+	challenge, err := pkg.GenerateOpaqueToken(passkeyChallengeBytes)
+	if err != nil {
+		return "", err
+	}
+
+	err = uc.PasskeyRepo.SaveChallenge(ctx, &domain.WebAuthnChallenge{
+		ChallengeHash: pkg.HashToken(challenge),
+		Ceremony:      ceremony,
+		UserID:        userID,
+		ExpiresAt:     time.Now().Add(passkeyCeremonyTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return challenge, nil
+}
+
+// consumeChallenge spends a challenge, which must have been issued for the same ceremony and user
+func (uc *PasskeyUsecase) consumeChallenge(ctx context.Context, challenge, ceremony string, userID *int) error {
+	stored, err := uc.PasskeyRepo.ConsumeChallenge(ctx, pkg.HashToken(challenge))
+	if err != nil {
+		return err
+	}
+
+	if stored.Ceremony != ceremony || time.Now().After(stored.ExpiresAt) {
+		return errors.New("challenge expired or was issued for another ceremony")
+	}
+	if (userID == nil) != (stored.UserID == nil) || (userID != nil && *userID != *stored.UserID) {
+		return errors.New("challenge was issued to another user")
+	}
+
+	return nil
+}
+
+// passkeyUserHandle is the WebAuthn user handle of an account. It holds no personal information, only the user ID.
+func passkeyUserHandle(userID int) []byte {
+	return []byte(strconv.Itoa(userID))
+}
+
+// decodeBase64URL decodes the base64url values of WebAuthn responses, with or without padding
+func decodeBase64URL(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
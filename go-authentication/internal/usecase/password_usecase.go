@@ -0,0 +1,190 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/repository"
+	"go-authentication/internal/services"
+	"go-authentication/pkg"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// passwordResetTTL is how long a password reset link stays valid
+const passwordResetTTL = time.Hour
+
+var (
+	ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+	ErrInvalidPassword   = errors.New("invalid password")
+	ErrIncorrectPassword = errors.New("current password is incorrect")
+)
+
+// PasswordUsecase handles recovering accounts through emailed reset links
+type PasswordUsecase struct {
+	UserRepo  repository.UserRepository
+	ResetRepo repository.PasswordResetRepository
+	Sessions  *SessionUsecase
+	Mailer    services.Mailer
+	Policy    *pkg.PasswordPolicy
+	Hasher    pkg.PasswordHasher
+	Lockout   *LockoutUsecase
+	BaseURL   string
+	Audit     *AuditUsecase
+}
+
+// NewPasswordUsecase creates a new instance of PasswordUsecase
+func NewPasswordUsecase(userRepository repository.UserRepository, resetRepository repository.PasswordResetRepository, sessionUsecase *SessionUsecase, mailer services.Mailer, passwordPolicy *pkg.PasswordPolicy, passwordHasher pkg.PasswordHasher, lockoutUsecase *LockoutUsecase, baseURL string, auditUsecase *AuditUsecase) *PasswordUsecase {
+	return &PasswordUsecase{
+		UserRepo:  userRepository,
+		ResetRepo: resetRepository,
+		Sessions:  sessionUsecase,
+		Mailer:    mailer,
+		Policy:    passwordPolicy,
+		Hasher:    passwordHasher,
+		Lockout:   lockoutUsecase,
+		BaseURL:   strings.TrimRight(baseURL, "/"),
+		Audit:     auditUsecase,
+	}
+}
+
+// ForgotPassword emails a reset link if the address belongs to an account.
+// It returns nil for unknown addresses so the endpoint cannot be used to enumerate accounts.
+func (uc *PasswordUsecase) ForgotPassword(ctx context.Context, email string) error {
+	user, err := uc.UserRepo.GetByEmail(ctx, email)
+	if err != nil {
+		return nil
+	}
+
+	raw, err := pkg.GenerateOpaqueToken(32)
+	if err != nil {
+		return err
+	}
+
+	token := &domain.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: pkg.HashToken(raw),
+		ExpiresAt: time.Now().Add(passwordResetTTL),
+	}
+	if err := uc.ResetRepo.Create(ctx, token); err != nil {
+		return err
+	}
+	uc.Audit.RecordUser(ctx, domain.AuthEventPasswordResetRequested, user.ID, nil)
+
+	link := fmt.Sprintf("%s/password/reset?token=%s", uc.BaseURL, url.QueryEscape(raw))
+	err = uc.Mailer.Send(ctx, services.Email{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nWe received a request to reset your password. Use the link below to choose a new one:\n\n%s\n\n"+
+			"The link expires in %d minutes and can only be used once. If you did not ask for a reset, you can ignore this email.\n",
+			user.Name, link, int(passwordResetTTL.Minutes())),
+	})
+	if err != nil {
+		log.Printf("Error sending password reset email to user %d: %v", user.ID, err)
+	}
+
+	return nil
+}
+
+// ResetPassword sets a new password using a reset token and signs the user out everywhere
+func (uc *PasswordUsecase) ResetPassword(ctx context.Context, rawToken, newPassword string) error {
+	token, err := uc.ResetRepo.GetByHash(ctx, pkg.HashToken(rawToken))
+	if err != nil || token.UsedAt != nil || time.Now().After(token.ExpiresAt) {
+		return ErrInvalidResetToken
+	}
+
+	user, err := uc.UserRepo.GetByID(ctx, token.UserID)
+	if err != nil {
+		return ErrInvalidResetToken
+	}
+
+	if err := uc.Policy.Validate(newPassword, user.Name, user.Email); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidPassword, err)
+	}
+
+	// Consume the token before changing anything so it cannot be replayed
+	if err := uc.ResetRepo.MarkUsed(ctx, token.ID); err != nil {
+		if errors.Is(err, repository.ErrResetTokenUsed) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+
+	hashedPassword, err := uc.Hasher.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+	if err := uc.UserRepo.UpdatePassword(ctx, user.ID, hashedPassword); err != nil {
+		return err
+	}
+
+	// Following the emailed link proves ownership of the address
+	if !user.IsEmailVerified() {
+		if err := uc.UserRepo.MarkEmailVerified(ctx, user.ID, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	if err := uc.ResetRepo.InvalidateForUser(ctx, user.ID); err != nil {
+		return err
+	}
+	uc.Audit.RecordUser(ctx, domain.AuthEventPasswordChanged, user.ID, map[string]interface{}{"via": "reset"})
+
+	// Sign the user out of every device now that their password changed
+	return uc.Sessions.RevokeAll(ctx, user.ID)
+}
+
+// ChangePassword replaces the password of a signed in user who knows the current one.
+// Like a reset it signs the user out everywhere, so sessions opened with the old password end.
+func (uc *PasswordUsecase) ChangePassword(ctx context.Context, userID int, req *domain.ChangePasswordRequest) error {
+	user, err := uc.UserRepo.GetByID(ctx, userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+
+	if err := verifyPassword(ctx, uc.Lockout, uc.Hasher, user, req.CurrentPassword); err != nil {
+		return err
+	}
+
+	if err := uc.Policy.Validate(req.NewPassword, user.Name, user.Email); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidPassword, err)
+	}
+
+	hashedPassword, err := uc.Hasher.Hash(req.NewPassword)
+	if err != nil {
+		return err
+	}
+	if err := uc.UserRepo.UpdatePassword(ctx, user.ID, hashedPassword); err != nil {
+		return err
+	}
+
+	// Reset links requested before the change must not undo it
+	if err := uc.ResetRepo.InvalidateForUser(ctx, user.ID); err != nil {
+		return err
+	}
+	uc.Audit.RecordUser(ctx, domain.AuthEventPasswordChanged, user.ID, map[string]interface{}{"via": "change"})
+
+	return uc.Sessions.RevokeAll(ctx, user.ID)
+}
+
+// verifyPassword checks the password of a signed in user before a sensitive change.
+// Wrong passwords count towards the login lockout, so a hijacked session cannot be used to guess it.
+func verifyPassword(ctx context.Context, lockout *LockoutUsecase, hasher pkg.PasswordHasher, user *domain.User, password string) error {
+	match, err := lockout.Verify(ctx, user, func() (bool, error) {
+		match, err := hasher.Verify(user.Password, password)
+		if err != nil {
+			log.Printf("Error verifying password of user %d: %v", user.ID, err)
+		}
+		return match, nil
+	})
+	if err != nil {
+		return err
+	}
+	if !match {
+		return ErrIncorrectPassword
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/repository"
+	"go-authentication/internal/services"
+	"log"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// defaultPresenceTTL is how long a connection counts as live without a heartbeat
+const defaultPresenceTTL = 60 * time.Second
+
+// PresenceUsecase tracks which users are connected and tells their contacts when that changes.
+// Every live WebSocket sends heartbeats; a connection whose heartbeats stop, for instance because
+// its server instance crashed, lapses after TTL and is reported by the next sweep of any instance.
+type PresenceUsecase struct {
+	Store       repository.PresenceStore
+	ChatRepo    repository.ChatRepository
+	UserRepo    repository.UserRepository
+	Blocks      *BlockUsecase
+	NatsService *services.NatsService
+	// TTL is how long a connection counts without a heartbeat; connections send one every third of it
+	TTL time.Duration
+}
+
+// NewPresenceUsecase creates a new instance of PresenceUsecase
+func NewPresenceUsecase(presenceStore repository.PresenceStore, chatRepository repository.ChatRepository, userRepository repository.UserRepository, blockUsecase *BlockUsecase, natsService *services.NatsService) *PresenceUsecase {
+	return &PresenceUsecase{
+		Store:       presenceStore,
+		ChatRepo:    chatRepository,
+		UserRepo:    userRepository,
+		Blocks:      blockUsecase,
+		NatsService: natsService,
+		TTL:         defaultPresenceTTL,
+	}
+}
+
+// HeartbeatInterval is how often a live connection renews its presence
+func (uc *PresenceUsecase) HeartbeatInterval() time.Duration {
+	return uc.TTL / 3
+}
+
+// Heartbeat keeps a connection of userID live with the status reported by its client
+func (uc *PresenceUsecase) Heartbeat(ctx context.Context, userID int, connectionID, status string) error {
+	if err := domain.ValidatePresenceStatus(status); err != nil {
+		return err
+	}
+
+	presence, changed, err := uc.Store.Heartbeat(ctx, userID, connectionID, status, uc.TTL)
+	if err != nil {
+		return err
+	}
+	if changed {
+		uc.announce(ctx, presence)
+	}
+	return nil
+}
+
+// Disconnect forgets a closed connection of userID
+func (uc *PresenceUsecase) Disconnect(ctx context.Context, userID int, connectionID string) error {
+	presence, changed, err := uc.Store.Disconnect(ctx, userID, connectionID)
+	if err != nil {
+		return err
+	}
+	if changed {
+		uc.announce(ctx, presence)
+	}
+	return nil
+}
+
+// GetPresence returns the presence of userID as seen by viewerID.
+// Users who blocked each other always see each other offline.
+func (uc *PresenceUsecase) GetPresence(ctx context.Context, viewerID, userID int) (*domain.Presence, error) {
+	if user, err := uc.UserRepo.GetByID(ctx, userID); err != nil || user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	if viewerID != userID {
+		if err := uc.Blocks.CheckMessaging(ctx, viewerID, userID); err != nil {
+			if errors.Is(err, ErrMessagingBlocked) {
+				return &domain.Presence{UserID: userID, Status: domain.PresenceOffline}, nil
+			}
+			return nil, err
+		}
+	}
+
+	return uc.Store.Get(ctx, userID)
+}
+
+// Sweep reports the users whose last connections lapsed
+func (uc *PresenceUsecase) Sweep(ctx context.Context) error {
+	changes, err := uc.Store.Sweep(ctx)
+	if err != nil {
+		return err
+	}
+	for i := range changes {
+		uc.announce(ctx, &changes[i])
+	}
+	return nil
+}
+
+// SweepEvery runs Sweep at every interval until ctx is done. Every server instance can run it;
+// the store makes sure each change is announced once.
+func (uc *PresenceUsecase) SweepEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := uc.Sweep(ctx); err != nil {
+				log.Printf("Error sweeping presence: %v", err)
+			}
+		}
+	}
+}
+
+// SubscribeToPresence subscribes to the presence changes of the contacts of a user
+func (uc *PresenceUsecase) SubscribeToPresence(userID int, callback func(*domain.Presence)) (*nats.Subscription, error) {
+	return uc.NatsService.SubscribeToPresence(userID, callback)
+}
+
+// announce pushes a presence change to the users who share a conversation with the user
+func (uc *PresenceUsecase) announce(ctx context.Context, presence *domain.Presence) {
+	contactIDs, err := uc.ChatRepo.ListContacts(ctx, presence.UserID)
+	if err != nil {
+		log.Printf("Error listing contacts of user %d: %v", presence.UserID, err)
+		return
+	}
+
+	if err := uc.NatsService.PublishPresence(presence, contactIDs); err != nil {
+		log.Printf("Error sending presence of user %d through NATS: %v", presence.UserID, err)
+	}
+}
@@ -0,0 +1,304 @@
+package usecase
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/repository"
+	"go-authentication/internal/services"
+	"go-authentication/pkg"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Profile field limits, matching the users table
+const (
+	maxNameLength        = 100
+	maxDisplayNameLength = 100
+	maxBioLength         = 500
+	maxStatusTextLength  = 140
+)
+
+const (
+	maxSearchQueryLength  = 100
+	defaultSearchPageSize = 20
+	maxSearchPageSize     = 50
+)
+
+var (
+	ErrInvalidProfile = errors.New("invalid profile")
+	ErrInvalidEmail   = errors.New("email must be in a valid format (e.g., user@example.com)")
+	ErrEmailUnchanged = errors.New("new email is the same as the current one")
+	ErrEmailTaken     = errors.New("email is already in use")
+
+	ErrInvalidSearchQuery = errors.New("invalid search query")
+)
+
+// ProfileUsecase lets users read and edit their own account and look up other users
+type ProfileUsecase struct {
+	UserRepo   repository.UserRepository
+	SearchRepo repository.UserSearchRepository
+	Tokens     *pkg.TokenService
+	// Revocations rejects email change links issued before a password change or logout everywhere
+	Revocations repository.RevocationStore
+	Mailer      services.Mailer
+	Hasher      pkg.PasswordHasher
+	Lockout     *LockoutUsecase
+	BaseURL     string
+	Audit       *AuditUsecase
+}
+
+// NewProfileUsecase creates a new instance of ProfileUsecase.
+// baseURL is the public address of this service used to build email change links.
+func NewProfileUsecase(userRepository repository.UserRepository, userSearchRepository repository.UserSearchRepository, tokenService *pkg.TokenService, revocationStore repository.RevocationStore, mailer services.Mailer, passwordHasher pkg.PasswordHasher, lockoutUsecase *LockoutUsecase, baseURL string, auditUsecase *AuditUsecase) *ProfileUsecase {
+	return &ProfileUsecase{
+		UserRepo:    userRepository,
+		SearchRepo:  userSearchRepository,
+		Tokens:      tokenService,
+		Revocations: revocationStore,
+		Mailer:      mailer,
+		Hasher:      passwordHasher,
+		Lockout:     lockoutUsecase,
+		BaseURL:     strings.TrimRight(baseURL, "/"),
+		Audit:       auditUsecase,
+	}
+}
+
+// GetProfile returns the user's own account
+func (uc *ProfileUsecase) GetProfile(ctx context.Context, userID int) (*domain.Profile, error) {
+	user, err := uc.UserRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	return user.Profile(), nil
+}
+
+// GetPublicProfile returns what other users may see of an account
+func (uc *ProfileUsecase) GetPublicProfile(ctx context.Context, userID int) (*domain.PublicProfile, error) {
+	user, err := uc.UserRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	return user.PublicProfile(), nil
+}
+
+// SearchUsers finds people to start a conversation with by name or email, best match first.
+// Users who blocked the caller are never returned.
+func (uc *ProfileUsecase) SearchUsers(ctx context.Context, callerID int, query *domain.UserSearchQuery) (*domain.UserSearchPage, error) {
+	term := strings.TrimSpace(query.Q)
+	if term == "" || len([]rune(term)) > maxSearchQueryLength {
+		return nil, fmt.Errorf("%w: q must be 1 to %d characters", ErrInvalidSearchQuery, maxSearchQueryLength)
+	}
+
+	limit := query.Limit
+	if limit == 0 {
+		limit = defaultSearchPageSize
+	}
+	if limit < 0 || limit > maxSearchPageSize {
+		return nil, fmt.Errorf("%w: limit must be at most %d", ErrInvalidSearchQuery, maxSearchPageSize)
+	}
+
+	filter := &domain.UserSearchFilter{CallerID: callerID, Query: term, Limit: limit}
+	if query.Cursor != "" {
+		score, id, err := decodeSearchCursor(query.Cursor)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid cursor", ErrInvalidSearchQuery)
+		}
+		filter.AfterScore, filter.AfterID = score, id
+	}
+
+	results, err := uc.SearchRepo.Search(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &domain.UserSearchPage{Users: make([]domain.PublicProfile, 0, len(results))}
+	for _, result := range results {
+		page.Users = append(page.Users, result.PublicProfile)
+	}
+	if len(results) == limit {
+		last := results[len(results)-1]
+		page.NextCursor = encodeSearchCursor(last.Score, last.ID)
+	}
+	return page, nil
+}
+
+// encodeSearchCursor returns an opaque cursor for the position after a result
+func encodeSearchCursor(score, id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(score) + ":" + strconv.Itoa(id)))
+}
+
+func decodeSearchCursor(cursor string) (score, id int, err error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, 0, err
+	}
+	scorePart, idPart, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return 0, 0, errors.New("malformed cursor")
+	}
+	if score, err = strconv.Atoi(scorePart); err != nil {
+		return 0, 0, err
+	}
+	if id, err = strconv.Atoi(idPart); err != nil || id <= 0 {
+		return 0, 0, errors.New("malformed cursor")
+	}
+	return score, id, nil
+}
+
+// UpdateProfile changes the fields present in req and returns the updated account
+func (uc *ProfileUsecase) UpdateProfile(ctx context.Context, userID int, req *domain.UpdateProfileRequest) (*domain.Profile, error) {
+	user, err := uc.UserRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" || len([]rune(name)) > maxNameLength {
+			return nil, fmt.Errorf("%w: name must be 1 to %d characters", ErrInvalidProfile, maxNameLength)
+		}
+		user.Name = name
+	}
+	if req.DisplayName != nil {
+		displayName := strings.TrimSpace(*req.DisplayName)
+		if len([]rune(displayName)) > maxDisplayNameLength {
+			return nil, fmt.Errorf("%w: display name must be at most %d characters", ErrInvalidProfile, maxDisplayNameLength)
+		}
+		user.DisplayName = displayName
+	}
+	if req.Bio != nil {
+		bio := strings.TrimSpace(*req.Bio)
+		if len([]rune(bio)) > maxBioLength {
+			return nil, fmt.Errorf("%w: bio must be at most %d characters", ErrInvalidProfile, maxBioLength)
+		}
+		user.Bio = bio
+	}
+	if req.StatusText != nil {
+		statusText := strings.TrimSpace(*req.StatusText)
+		if len([]rune(statusText)) > maxStatusTextLength {
+			return nil, fmt.Errorf("%w: status text must be at most %d characters", ErrInvalidProfile, maxStatusTextLength)
+		}
+		user.StatusText = statusText
+	}
+
+	if err := uc.UserRepo.UpdateProfile(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return user.Profile(), nil
+}
+
+// RequestEmailChange mails a confirmation link to the new address after checking the user's password.
+// The current address keeps working for login until the link is followed, and is told about the request.
+func (uc *ProfileUsecase) RequestEmailChange(ctx context.Context, userID int, req *domain.ChangeEmailRequest) error {
+	email := strings.TrimSpace(req.Email)
+	if err := domain.ValidateEmail(email); err != nil {
+		return ErrInvalidEmail
+	}
+
+	user, err := uc.UserRepo.GetByID(ctx, userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+
+	if err := verifyPassword(ctx, uc.Lockout, uc.Hasher, user, req.Password); err != nil {
+		return err
+	}
+
+	if strings.EqualFold(email, user.Email) {
+		return ErrEmailUnchanged
+	}
+	if existing, _ := uc.UserRepo.GetByEmail(ctx, email); existing != nil {
+		return ErrEmailTaken
+	}
+
+	// The token carries the new address; following the link proves the user owns it
+	token, err := uc.Tokens.GeneratePurposeToken(pkg.TokenTypeEmailChange, user.ID, email, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	// Warn the current address first, so a hijacked session cannot move the account away unnoticed
+	err = uc.Mailer.Send(ctx, services.Email{
+		To:      user.Email,
+		Subject: "Your email address is being changed",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone signed in to your account asked to change its email address to %s. "+
+			"The change takes effect once the new address is confirmed.\n\nIf this was not you, change your password right away.\n",
+			user.Name, email),
+	})
+	if err != nil {
+		log.Printf("Error sending email change notice to user %d: %v", user.ID, err)
+	}
+
+	link := fmt.Sprintf("%s/email/change/confirm?token=%s", uc.BaseURL, url.QueryEscape(token))
+	if err := uc.Mailer.Send(ctx, services.Email{
+		To:      email,
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm that you want to use this address for your account by opening the link below:\n\n%s\n\n"+
+			"The link expires in %d hours. If you did not ask for this change, you can ignore this email.\n",
+			user.Name, link, int(emailVerificationTTL.Hours())),
+	}); err != nil {
+		return err
+	}
+
+	uc.Audit.RecordUser(ctx, domain.AuthEventEmailChangeRequested, user.ID, map[string]interface{}{"new_email": email})
+	return nil
+}
+
+// ConfirmEmailChange switches the account to the address in an email change token, which is verified by following it
+func (uc *ProfileUsecase) ConfirmEmailChange(ctx context.Context, token string) error {
+	claims, err := uc.Tokens.ValidatePurposeToken(pkg.TokenTypeEmailChange, token)
+	if err != nil {
+		return ErrInvalidVerificationToken
+	}
+
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return ErrInvalidVerificationToken
+	}
+	email, _ := claims["email"].(string)
+	if email == "" {
+		return ErrInvalidVerificationToken
+	}
+
+	user, err := uc.UserRepo.GetByID(ctx, int(userID))
+	if err != nil {
+		return ErrInvalidVerificationToken
+	}
+	// Following the link again while the address is in use is harmless
+	if user.Email == email {
+		return nil
+	}
+
+	// Changing the password or logging out everywhere cancels pending changes, so the owner
+	// of a hijacked account can stop the address from being moved away
+	revoked, err := uc.Revocations.IsRevoked(ctx, pkg.TokenID(claims), "", int(userID), pkg.IssuedAt(claims))
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return ErrInvalidVerificationToken
+	}
+
+	oldEmail := user.Email
+	if err := uc.UserRepo.UpdateEmail(ctx, user.ID, email, time.Now()); err != nil {
+		if errors.Is(err, repository.ErrEmailTaken) {
+			return ErrEmailTaken
+		}
+		return err
+	}
+
+	// A used link must not move the account back after a later change
+	if err := uc.Revocations.RevokeToken(ctx, pkg.TokenID(claims), pkg.ClaimTime(claims, "exp")); err != nil {
+		return err
+	}
+
+	uc.Audit.RecordUser(ctx, domain.AuthEventEmailChanged, user.ID, map[string]interface{}{"old_email": oldEmail, "new_email": email})
+	return nil
+}
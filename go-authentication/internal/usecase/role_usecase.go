@@ -0,0 +1,112 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/repository"
+	"log"
+	"strings"
+	"time"
+)
+
+var ErrUnknownRole = errors.New("unknown role")
+
+// RoleUsecase manages the roles of users and the permissions they grant
+type RoleUsecase struct {
+	RoleRepo    repository.RoleRepository
+	UserRepo    repository.UserRepository
+	Revocations repository.RevocationStore
+	Audit       *AuditUsecase
+	// bootstrapAdmins are emails that receive the admin role when they sign up
+	bootstrapAdmins map[string]bool
+}
+
+// NewRoleUsecase creates a new instance of RoleUsecase.
+// adminEmails is a comma separated list of accounts granted the admin role at signup.
+func NewRoleUsecase(roleRepository repository.RoleRepository, userRepository repository.UserRepository, revocationStore repository.RevocationStore, adminEmails string, auditUsecase *AuditUsecase) *RoleUsecase {
+	admins := make(map[string]bool)
+	for _, email := range strings.Split(adminEmails, ",") {
+		if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+			admins[email] = true
+		}
+	}
+
+	return &RoleUsecase{
+		RoleRepo:        roleRepository,
+		UserRepo:        userRepository,
+		Revocations:     revocationStore,
+		Audit:           auditUsecase,
+		bootstrapAdmins: admins,
+	}
+}
+
+// AssignDefaultRoles gives a new account the user role, and the admin role if it is a bootstrap admin.
+// Bootstrap admins still have to verify their email before they can log in.
+func (uc *RoleUsecase) AssignDefaultRoles(ctx context.Context, user *domain.User) error {
+	if err := uc.RoleRepo.AssignRole(ctx, user.ID, domain.RoleUser); err != nil {
+		return err
+	}
+
+	if uc.bootstrapAdmins[strings.ToLower(user.Email)] {
+		log.Printf("Granting admin role to bootstrap admin user %d", user.ID)
+		return uc.RoleRepo.AssignRole(ctx, user.ID, domain.RoleAdmin)
+	}
+
+	return nil
+}
+
+// UserRoles returns the roles of a user and the permissions they grant
+func (uc *RoleUsecase) UserRoles(ctx context.Context, userID int) (*domain.UserRoles, error) {
+	roles, err := uc.RoleRepo.GetUserRoles(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions, err := uc.RoleRepo.GetUserPermissions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.UserRoles{UserID: userID, Roles: roles, Permissions: permissions}, nil
+}
+
+// AssignRole grants a role to a user. Tokens issued from now on, including by a refresh, carry it.
+func (uc *RoleUsecase) AssignRole(ctx context.Context, userID int, role string) (*domain.UserRoles, error) {
+	if _, err := uc.UserRepo.GetByID(ctx, userID); err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	if err := uc.RoleRepo.AssignRole(ctx, userID, role); err != nil {
+		if errors.Is(err, repository.ErrRoleNotFound) {
+			return nil, ErrUnknownRole
+		}
+		return nil, err
+	}
+
+	uc.Audit.RecordUser(ctx, domain.AuthEventRoleAssigned, userID, map[string]interface{}{"role": role})
+	return uc.UserRoles(ctx, userID)
+}
+
+// RemoveRole takes a role away from a user.
+// Their access tokens still carry the role, so they are revoked; refresh tokens stay valid
+// and the next refresh issues tokens without it.
+func (uc *RoleUsecase) RemoveRole(ctx context.Context, userID int, role string) (*domain.UserRoles, error) {
+	if _, err := uc.UserRepo.GetByID(ctx, userID); err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	if err := uc.RoleRepo.RemoveRole(ctx, userID, role); err != nil {
+		if errors.Is(err, repository.ErrRoleNotFound) {
+			return nil, ErrUnknownRole
+		}
+		return nil, err
+	}
+
+	if err := uc.Revocations.RevokeUserTokens(ctx, userID, time.Now()); err != nil {
+		return nil, err
+	}
+
+	uc.Audit.RecordUser(ctx, domain.AuthEventRoleRemoved, userID, map[string]interface{}{"role": role})
+	return uc.UserRoles(ctx, userID)
+}
@@ -0,0 +1,149 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/repository"
+	"go-authentication/internal/services"
+	"go-authentication/pkg"
+	"log"
+	"time"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionUsecase tracks the devices a user is logged in on and ends their sessions.
+// Ending a session revokes its refresh token family and access tokens and notifies
+// live connections so they can be closed.
+type SessionUsecase struct {
+	SessionRepo      repository.SessionRepository
+	RefreshTokenRepo repository.RefreshTokenRepository
+	Revocations      repository.RevocationStore
+	Notifier         services.SessionNotifier
+	Tokens           *pkg.TokenService
+	Audit            *AuditUsecase
+}
+
+// NewSessionUsecase creates a new instance of SessionUsecase
+func NewSessionUsecase(sessionRepository repository.SessionRepository, refreshTokenRepository repository.RefreshTokenRepository, revocationStore repository.RevocationStore, notifier services.SessionNotifier, tokenService *pkg.TokenService, auditUsecase *AuditUsecase) *SessionUsecase {
+	return &SessionUsecase{
+		SessionRepo:      sessionRepository,
+		RefreshTokenRepo: refreshTokenRepository,
+		Revocations:      revocationStore,
+		Notifier:         notifier,
+		Tokens:           tokenService,
+		Audit:            auditUsecase,
+	}
+}
+
+// Start records a new session for a login from client
+func (uc *SessionUsecase) Start(ctx context.Context, userID int, client domain.ClientInfo) (*domain.Session, error) {
+	id, err := pkg.GenerateOpaqueToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &domain.Session{
+		ID:        id,
+		UserID:    userID,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		ExpiresAt: time.Now().Add(uc.Tokens.RefreshTTL()),
+	}
+	if err := uc.SessionRepo.Create(ctx, session); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// Touch marks a session as seen when its refresh token is rotated.
+// Failing to record it is not a reason to fail the refresh.
+func (uc *SessionUsecase) Touch(ctx context.Context, sessionID string, expiresAt time.Time) {
+	if err := uc.SessionRepo.Touch(ctx, sessionID, time.Now(), expiresAt); err != nil {
+		log.Printf("Error updating session %s: %v", sessionID, err)
+	}
+}
+
+// List returns the user's active sessions, marking currentID as the current one
+func (uc *SessionUsecase) List(ctx context.Context, userID int, currentID string) ([]domain.Session, error) {
+	sessions, err := uc.SessionRepo.ListActiveByUser(ctx, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentID
+	}
+	return sessions, nil
+}
+
+// Revoke ends one of the user's sessions
+func (uc *SessionUsecase) Revoke(ctx context.Context, userID int, sessionID string) error {
+	session, err := uc.SessionRepo.GetByID(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			return ErrSessionNotFound
+		}
+		return err
+	}
+	if session.UserID != userID || !session.IsActive(time.Now()) {
+		return ErrSessionNotFound
+	}
+
+	if err := uc.end(ctx, userID, sessionID); err != nil {
+		return err
+	}
+
+	uc.Audit.RecordUser(ctx, domain.AuthEventSessionRevoked, userID, map[string]interface{}{"session_id": sessionID})
+	return nil
+}
+
+// RevokeAll ends every session of a user and revokes every token issued to them so far
+func (uc *SessionUsecase) RevokeAll(ctx context.Context, userID int) error {
+	if err := uc.Revocations.RevokeUserTokens(ctx, userID, time.Now()); err != nil {
+		return err
+	}
+
+	if err := uc.RefreshTokenRepo.RevokeAllForUser(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := uc.SessionRepo.RevokeAllForUser(ctx, userID); err != nil {
+		return err
+	}
+
+	uc.notify(&domain.SessionRevokedEvent{UserID: userID})
+	return nil
+}
+
+// SubscribeToRevocations calls handler whenever a session ends on any server instance
+func (uc *SessionUsecase) SubscribeToRevocations(handler func(event *domain.SessionRevokedEvent)) error {
+	return uc.Notifier.SubscribeToSessionRevocations(handler)
+}
+
+// end revokes a session's refresh tokens and the access tokens it issued, which live at most one access TTL
+func (uc *SessionUsecase) end(ctx context.Context, userID int, sessionID string) error {
+	if err := uc.Revocations.RevokeSession(ctx, sessionID, time.Now().Add(uc.Tokens.AccessTTL())); err != nil {
+		return err
+	}
+
+	if err := uc.RefreshTokenRepo.RevokeFamily(ctx, sessionID); err != nil {
+		return err
+	}
+
+	if err := uc.SessionRepo.Revoke(ctx, sessionID); err != nil {
+		return err
+	}
+
+	uc.notify(&domain.SessionRevokedEvent{UserID: userID, SessionID: sessionID})
+	return nil
+}
+
+// notify announces an ended session; the tokens are already revoked, so a failure only delays closing sockets
+func (uc *SessionUsecase) notify(event *domain.SessionRevokedEvent) {
+	if err := uc.Notifier.PublishSessionRevoked(event); err != nil {
+		log.Printf("Error publishing revocation of session %q for user %d: %v", event.SessionID, event.UserID, err)
+	}
+}
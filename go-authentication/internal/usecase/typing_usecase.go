@@ -0,0 +1,189 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/repository"
+	"go-authentication/internal/services"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	defaultTypingThrottle = 3 * time.Second
+	defaultTypingTTL      = 6 * time.Second
+)
+
+// typingKey identifies a user typing in a conversation, from any of their devices
+type typingKey struct {
+	userID         int
+	conversationID int
+}
+
+type typingState struct {
+	relayedAt    time.Time
+	recipientIDs []int
+	expiry       *time.Timer
+}
+
+// TypingUsecase relays typing indicators to the other members of a conversation.
+// Indicators only live in memory: a typing_start is relayed at most once per Throttle
+// and a typing_stop follows by itself when no typing_start renews it within TTL.
+type TypingUsecase struct {
+	ChatRepo    repository.ChatRepository
+	Blocks      *BlockUsecase
+	NatsService *services.NatsService
+	// Throttle is the shortest interval between two relayed typing_start of a user in a conversation
+	Throttle time.Duration
+	// TTL is how long a typing_start lasts; it must be longer than Throttle
+	TTL time.Duration
+
+	mu     sync.Mutex
+	typing map[typingKey]*typingState
+}
+
+// NewTypingUsecase creates a new instance of TypingUsecase
+func NewTypingUsecase(chatRepository repository.ChatRepository, blockUsecase *BlockUsecase, natsService *services.NatsService) *TypingUsecase {
+	return &TypingUsecase{
+		ChatRepo:    chatRepository,
+		Blocks:      blockUsecase,
+		NatsService: natsService,
+		Throttle:    defaultTypingThrottle,
+		TTL:         defaultTypingTTL,
+		typing:      make(map[typingKey]*typingState),
+	}
+}
+
+// StartTyping tells the other members of a conversation that userID is typing.
+// It reports whether the indicator was relayed, or only renewed because the last one is too recent.
+func (uc *TypingUsecase) StartTyping(ctx context.Context, userID, conversationID int) (bool, error) {
+	key := typingKey{userID: userID, conversationID: conversationID}
+	now := time.Now()
+
+	uc.mu.Lock()
+	if state, ok := uc.typing[key]; ok && now.Sub(state.relayedAt) < uc.Throttle {
+		state.expiry.Reset(uc.TTL)
+		uc.mu.Unlock()
+		return false, nil
+	}
+	uc.mu.Unlock()
+
+	recipientIDs, err := uc.recipients(ctx, userID, conversationID)
+	if err != nil {
+		return false, err
+	}
+
+	state := &typingState{relayedAt: now, recipientIDs: recipientIDs}
+	uc.mu.Lock()
+	if previous, ok := uc.typing[key]; ok {
+		previous.expiry.Stop()
+	}
+	state.expiry = time.AfterFunc(uc.TTL, func() { uc.expire(key, state) })
+	uc.typing[key] = state
+	uc.mu.Unlock()
+
+	expiresAt := now.Add(uc.TTL)
+	uc.publish(recipientIDs, &domain.TypingEvent{
+		Type:           domain.TypingEventStart,
+		ConversationID: conversationID,
+		UserID:         userID,
+		ExpiresAt:      &expiresAt,
+	})
+	return true, nil
+}
+
+// StopTyping clears the indicator of userID in a conversation and reports whether there was one
+func (uc *TypingUsecase) StopTyping(userID, conversationID int) bool {
+	key := typingKey{userID: userID, conversationID: conversationID}
+
+	uc.mu.Lock()
+	state, ok := uc.typing[key]
+	if ok {
+		state.expiry.Stop()
+		delete(uc.typing, key)
+	}
+	uc.mu.Unlock()
+
+	if ok {
+		uc.publishStop(key, state)
+	}
+	return ok
+}
+
+// SubscribeToTyping subscribes to the typing indicators of the conversations of a user
+func (uc *TypingUsecase) SubscribeToTyping(userID int, callback func(*domain.TypingEvent)) (*nats.Subscription, error) {
+	return uc.NatsService.SubscribeToTyping(userID, callback)
+}
+
+// expire clears an indicator that was not renewed in time, unless a newer one replaced it
+func (uc *TypingUsecase) expire(key typingKey, state *typingState) {
+	uc.mu.Lock()
+	current := uc.typing[key] == state
+	if current {
+		delete(uc.typing, key)
+	}
+	uc.mu.Unlock()
+
+	if current {
+		uc.publishStop(key, state)
+	}
+}
+
+// recipients returns the members of a conversation who should see userID typing.
+// A block between the two users of a direct conversation refuses the indicator;
+// group members who blocked userID are left out.
+func (uc *TypingUsecase) recipients(ctx context.Context, userID, conversationID int) ([]int, error) {
+	conversation, err := uc.ChatRepo.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		if errors.Is(err, repository.ErrConversationNotFound) {
+			return nil, ErrConversationNotFound
+		}
+		return nil, err
+	}
+
+	members, err := uc.ChatRepo.ListMembers(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	isMember := false
+	recipientIDs := []int{}
+	for _, member := range members {
+		if member.UserID == userID {
+			isMember = true
+			continue
+		}
+		if !conversation.IsGroup() {
+			if err := uc.Blocks.CheckMessaging(ctx, userID, member.UserID); err != nil {
+				return nil, err
+			}
+		} else if blocked, err := uc.Blocks.BlockRepo.IsBlocked(ctx, member.UserID, userID); err != nil {
+			return nil, err
+		} else if blocked {
+			continue
+		}
+		recipientIDs = append(recipientIDs, member.UserID)
+	}
+	if !isMember {
+		return nil, ErrConversationNotFound
+	}
+	return recipientIDs, nil
+}
+
+func (uc *TypingUsecase) publishStop(key typingKey, state *typingState) {
+	uc.publish(state.recipientIDs, &domain.TypingEvent{
+		Type:           domain.TypingEventStop,
+		ConversationID: key.conversationID,
+		UserID:         key.userID,
+	})
+}
+
+func (uc *TypingUsecase) publish(recipientIDs []int, event *domain.TypingEvent) {
+	if err := uc.NatsService.PublishTyping(event, recipientIDs); err != nil {
+		log.Printf("Error relaying %s of user %d through NATS: %v", event.Type, event.UserID, err)
+	}
+}
@@ -0,0 +1,103 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/repository"
+	"go-authentication/internal/services"
+	"go-authentication/pkg"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// emailVerificationTTL is how long a verification link stays valid
+const emailVerificationTTL = 24 * time.Hour
+
+var ErrInvalidVerificationToken = errors.New("invalid or expired verification link")
+
+// VerificationUsecase handles confirming that a user owns their email address
+type VerificationUsecase struct {
+	UserRepo repository.UserRepository
+	Tokens   *pkg.TokenService
+	Mailer   services.Mailer
+	BaseURL  string
+	Audit    *AuditUsecase
+}
+
+// NewVerificationUsecase creates a new instance of VerificationUsecase.
+// baseURL is the public address of this service used to build verification links.
+func NewVerificationUsecase(userRepository repository.UserRepository, tokenService *pkg.TokenService, mailer services.Mailer, baseURL string, auditUsecase *AuditUsecase) *VerificationUsecase {
+	return &VerificationUsecase{
+		UserRepo: userRepository,
+		Tokens:   tokenService,
+		Mailer:   mailer,
+		BaseURL:  strings.TrimRight(baseURL, "/"),
+		Audit:    auditUsecase,
+	}
+}
+
+// SendVerificationEmail mails a signed verification link to the user's current address
+func (uc *VerificationUsecase) SendVerificationEmail(ctx context.Context, user *domain.User) error {
+	// The token is bound to the address so it stops working if the email changes
+	token, err := uc.Tokens.GeneratePurposeToken(pkg.TokenTypeEmailVerification, user.ID, user.Email, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", uc.BaseURL, url.QueryEscape(token))
+	return uc.Mailer.Send(ctx, services.Email{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below:\n\n%s\n\n"+
+			"The link expires in %d hours. If you did not create an account, you can ignore this email.\n",
+			user.Name, link, int(emailVerificationTTL.Hours())),
+	})
+}
+
+// VerifyEmail marks the address in a verification token as verified
+func (uc *VerificationUsecase) VerifyEmail(ctx context.Context, token string) error {
+	claims, err := uc.Tokens.ValidatePurposeToken(pkg.TokenTypeEmailVerification, token)
+	if err != nil {
+		return ErrInvalidVerificationToken
+	}
+
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return ErrInvalidVerificationToken
+	}
+	email, _ := claims["email"].(string)
+
+	user, err := uc.UserRepo.GetByID(ctx, int(userID))
+	if err != nil || !strings.EqualFold(user.Email, email) {
+		return ErrInvalidVerificationToken
+	}
+
+	if user.IsEmailVerified() {
+		return nil
+	}
+
+	if err := uc.UserRepo.MarkEmailVerified(ctx, user.ID, time.Now()); err != nil {
+		return err
+	}
+
+	uc.Audit.RecordUser(ctx, domain.AuthEventEmailVerified, user.ID, nil)
+	return nil
+}
+
+// ResendVerification sends a new link to an unverified address.
+// It never reports whether the address exists so it cannot be used to enumerate accounts.
+func (uc *VerificationUsecase) ResendVerification(ctx context.Context, email string) error {
+	user, err := uc.UserRepo.GetByEmail(ctx, email)
+	if err != nil || user.IsEmailVerified() {
+		return nil
+	}
+
+	if err := uc.SendVerificationEmail(ctx, user); err != nil {
+		log.Printf("Error resending verification email to user %d: %v", user.ID, err)
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package pkg
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"go-authentication/config"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password hashing algorithms selectable with PASSWORD_HASH_ALGORITHM
+const (
+	HashAlgorithmArgon2id = "argon2id"
+	HashAlgorithmBcrypt   = "bcrypt"
+)
+
+// Argon2id defaults follow the OWASP password storage recommendation
+const (
+	defaultArgon2Memory      = 19 * 1024
+	defaultArgon2Iterations  = 2
+	defaultArgon2Parallelism = 1
+	argon2SaltLength         = 16
+	argon2KeyLength          = 32
+)
+
+// ErrUnknownHashFormat is returned when a stored hash was not produced by a supported algorithm
+var ErrUnknownHashFormat = errors.New("unknown password hash format")
+
+// PasswordHasher hashes passwords into a self-describing encoded string
+// that records the algorithm and its parameters.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// Verify reports whether password matches the encoded hash
+	Verify(encoded, password string) (bool, error)
+	// NeedsRehash reports whether encoded was produced with a different algorithm or parameters
+	NeedsRehash(encoded string) bool
+}
+
+// NewPasswordHasher creates the PasswordHasher selected by PASSWORD_HASH_ALGORITHM.
+// It verifies hashes of every supported algorithm, so users keep logging in after a switch
+// and are rehashed with the new settings on their next login.
+func NewPasswordHasher(cfg *config.Config) (PasswordHasher, error) {
+	bcryptCost, err := parseInt(cfg.BcryptCost, bcrypt.DefaultCost)
+	if err != nil || bcryptCost < bcrypt.MinCost || bcryptCost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("invalid BCRYPT_COST %q: must be between %d and %d", cfg.BcryptCost, bcrypt.MinCost, bcrypt.MaxCost)
+	}
+
+	memory, err := parseInt(cfg.Argon2MemoryKiB, defaultArgon2Memory)
+	if err != nil || memory < 8 {
+		return nil, fmt.Errorf("invalid ARGON2_MEMORY_KIB %q", cfg.Argon2MemoryKiB)
+	}
+
+	iterations, err := parseInt(cfg.Argon2Iterations, defaultArgon2Iterations)
+	if err != nil || iterations < 1 {
+		return nil, fmt.Errorf("invalid ARGON2_ITERATIONS %q", cfg.Argon2Iterations)
+	}
+
+	parallelism, err := parseInt(cfg.Argon2Parallelism, defaultArgon2Parallelism)
+	if err != nil || parallelism < 1 || parallelism > 255 {
+		return nil, fmt.Errorf("invalid ARGON2_PARALLELISM %q", cfg.Argon2Parallelism)
+	}
+
+	hasher := &multiHasher{
+		bcrypt: &BcryptHasher{Cost: bcryptCost},
+		argon2id: &Argon2idHasher{
+			Memory:      uint32(memory),
+			Iterations:  uint32(iterations),
+			Parallelism: uint8(parallelism),
+		},
+	}
+
+	switch cfg.PasswordHashAlgorithm {
+	case "", HashAlgorithmArgon2id:
+		hasher.active = hasher.argon2id
+	case HashAlgorithmBcrypt:
+		hasher.active = hasher.bcrypt
+	default:
+		return nil, fmt.Errorf("unknown PASSWORD_HASH_ALGORITHM %q", cfg.PasswordHashAlgorithm)
+	}
+
+	return hasher, nil
+}
+
+// multiHasher hashes with the active algorithm and verifies hashes of any supported algorithm
+type multiHasher struct {
+	active   PasswordHasher
+	bcrypt   *BcryptHasher
+	argon2id *Argon2idHasher
+}
+
+func (h *multiHasher) Hash(password string) (string, error) {
+	return h.active.Hash(password)
+}
+
+func (h *multiHasher) Verify(encoded, password string) (bool, error) {
+	switch {
+	case isArgon2idHash(encoded):
+		return h.argon2id.Verify(encoded, password)
+	case isBcryptHash(encoded):
+		return h.bcrypt.Verify(encoded, password)
+	}
+	return false, ErrUnknownHashFormat
+}
+
+func (h *multiHasher) NeedsRehash(encoded string) bool {
+	return h.active.NeedsRehash(encoded)
+}
+
+// BcryptHasher hashes passwords with bcrypt. The encoded form is bcrypt's own "$2a$<cost>$..." format.
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+func (h *BcryptHasher) Verify(encoded, password string) (bool, error) {
+	if !isBcryptHash(encoded) {
+		return false, ErrUnknownHashFormat
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (h *BcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != h.Cost
+}
+
+func isBcryptHash(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+// Argon2idHasher hashes passwords with Argon2id. The encoded form is the PHC string format:
+// $argon2id$v=19$m=<memory KiB>,t=<iterations>,p=<parallelism>$<salt>$<hash>
+type Argon2idHasher struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// argon2idParams are the parameters decoded from a stored hash
+type argon2idParams struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.Memory, h.Parallelism, argon2KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Memory, h.Iterations, h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h *Argon2idHasher) Verify(encoded, password string) (bool, error) {
+	params, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	key := argon2.IDKey([]byte(password), params.salt, params.iterations, params.memory, params.parallelism, uint32(len(params.key)))
+	return subtle.ConstantTimeCompare(key, params.key) == 1, nil
+}
+
+func (h *Argon2idHasher) NeedsRehash(encoded string) bool {
+	params, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+	return params.memory != h.Memory ||
+		params.iterations != h.Iterations ||
+		params.parallelism != h.Parallelism ||
+		len(params.key) != argon2KeyLength
+}
+
+func isArgon2idHash(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+func decodeArgon2id(encoded string) (*argon2idParams, error) {
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != HashAlgorithmArgon2id {
+		return nil, ErrUnknownHashFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, fmt.Errorf("unsupported argon2 version %q", parts[2])
+	}
+
+	var params argon2idParams
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism); err != nil {
+		return nil, fmt.Errorf("invalid argon2 parameters: %w", err)
+	}
+
+	var err error
+	if params.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, fmt.Errorf("invalid argon2 salt: %w", err)
+	}
+	if params.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(params.key) == 0 {
+		return nil, errors.New("invalid argon2 hash")
+	}
+
+	return &params, nil
+}
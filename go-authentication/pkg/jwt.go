@@ -1,42 +1,261 @@
 package pkg
 
 import (
-	"os"
-	"time"
 	"errors"
+	"fmt"
+	"go-authentication/config"
+	"strconv"
+	"time"
 
 	"github.com/dgrijalva/jwt-go"
 )
 
-var secretKey = []byte(os.Getenv("JWT_SECRET"))
+const (
+	defaultAccessTokenTTL  = 24 * time.Hour
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+	defaultClockSkew       = 30 * time.Second
+)
+
+// TokenService issues and validates the access tokens of this service.
+// Tokens carry the registered iss, aud, iat, nbf and exp claims so they are only accepted by the intended audience.
+type TokenService struct {
+	keys       *KeySet
+	issuer     string
+	audience   string
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	clockSkew  time.Duration
+}
+
+// NewTokenService creates a TokenService from the JWT settings in cfg, signing with the given key set
+func NewTokenService(cfg *config.Config, keys *KeySet) (*TokenService, error) {
+	accessTTL, err := ParseDuration(cfg.JWTExpiration, time.Hour, 1, defaultAccessTokenTTL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT_EXPIRATION_HOURS: %w", err)
+	}
 
-func GenerateJWT(userID int,email string)(string,error){
-	claims := jwt.MapClaims{
-		"user_id":userID,
-		"email":email,
-		"exp":time.Now().Add(time.Hour*24).Unix(),
+	refreshTTL, err := ParseDuration(cfg.RefreshTokenExpiration, time.Hour, 1, defaultRefreshTokenTTL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REFRESH_TOKEN_EXPIRATION_HOURS: %w", err)
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256,claims)
-	return token.SignedString(secretKey)
+	// A skew of 0 tolerates no clock drift at all, which is valid if strict
+	clockSkew, err := ParseDuration(cfg.JWTClockSkew, time.Second, 0, defaultClockSkew)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT_CLOCK_SKEW_SECONDS: %w", err)
+	}
+
+	if cfg.JWTIssuer == "" || cfg.JWTAudience == "" {
+		return nil, errors.New("JWT issuer and audience are required")
+	}
+
+	return &TokenService{
+		keys:       keys,
+		issuer:     cfg.JWTIssuer,
+		audience:   cfg.JWTAudience,
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
+		clockSkew:  clockSkew,
+	}, nil
 }
 
-func ValidateJWT(tokenString string) (jwt.MapClaims, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return secretKey, nil
-	})
+// ParseDuration reads an integer number of units that is at least minimum, falling back when value is empty
+func ParseDuration(value string, unit time.Duration, minimum int, fallback time.Duration) (time.Duration, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if n < minimum {
+		return 0, fmt.Errorf("must be at least %d", minimum)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// Keys returns the key set, e.g. to publish its public keys
+func (s *TokenService) Keys() *KeySet {
+	return s.keys
+}
+
+// AccessTTL is the lifetime of access tokens
+func (s *TokenService) AccessTTL() time.Duration {
+	return s.accessTTL
+}
+
+// RefreshTTL is the lifetime of refresh tokens
+func (s *TokenService) RefreshTTL() time.Duration {
+	return s.refreshTTL
+}
+
+// Token types distinguish access tokens from single-purpose tokens signed with the same keys
+const (
+	TokenTypeAccess            = "access"
+	TokenTypeEmailVerification = "email_verification"
+	TokenTypeEmailChange       = "email_change"
+	TokenTypeMFAChallenge      = "mfa_challenge"
+	// TokenTypeOAuthAccess tokens are issued to OAuth clients; they are not accepted by ValidateJWT
+	TokenTypeOAuthAccess = "oauth_access"
+)
 
-	if err != nil || !token.Valid {
-		return nil, errors.New("invalid or expired token")
+// GenerateJWT issues an access token. extra carries additional private claims;
+// it can never override the registered claims set here.
+func (s *TokenService) GenerateJWT(userID int, email string, extra jwt.MapClaims) (string, error) {
+	return s.generate(TokenTypeAccess, userID, email, s.accessTTL, extra)
+}
+
+// GeneratePurposeToken issues a short-lived token that is only accepted by ValidatePurposeToken
+// for the same purpose, e.g. an email verification link
+func (s *TokenService) GeneratePurposeToken(purpose string, userID int, email string, ttl time.Duration) (string, error) {
+	return s.generate(purpose, userID, email, ttl, nil)
+}
+
+// GenerateOAuthAccessToken issues an access token to an OAuth client. userID is 0 when the client acts on its own behalf.
+// Resource servers verify it with ValidatePurposeToken(TokenTypeOAuthAccess, ...) or the introspection endpoint.
+func (s *TokenService) GenerateOAuthAccessToken(userID int, email string, ttl time.Duration, extra jwt.MapClaims) (string, error) {
+	return s.generate(TokenTypeOAuthAccess, userID, email, ttl, extra)
+}
+
+func (s *TokenService) generate(tokenType string, userID int, email string, ttl time.Duration, extra jwt.MapClaims) (string, error) {
+	// jti uniquely identifies the token so it can be revoked before it expires
+	jti, err := GenerateOpaqueToken(16)
+	if err != nil {
+		return "", err
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return nil, errors.New("invalid token claims")
+	claims := jwt.MapClaims{}
+	for k, v := range extra {
+		claims[k] = v
+	}
+
+	now := time.Now()
+	claims["user_id"] = userID
+	claims["email"] = email
+	claims["typ"] = tokenType
+	claims["jti"] = jti
+	claims["iss"] = s.issuer
+	claims["aud"] = s.audience
+	claims["iat"] = now.Unix()
+	// iat only has second precision; iat_ms lets a revocation tell apart tokens issued in the same second
+	claims["iat_ms"] = now.UnixMilli()
+	claims["nbf"] = now.Unix()
+	claims["exp"] = now.Add(ttl).Unix()
+
+	return s.keys.Sign(claims)
+}
+
+// ValidateJWT verifies an access token. Tokens issued for another purpose are rejected.
+func (s *TokenService) ValidateJWT(tokenString string) (jwt.MapClaims, error) {
+	claims, err := s.validate(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tokens issued before the typ claim existed are access tokens
+	if typ, ok := claims["typ"].(string); ok && typ != TokenTypeAccess {
+		return nil, errors.New("not an access token")
 	}
 
 	return claims, nil
-}
\ No newline at end of file
+}
+
+// ValidatePurposeToken verifies a token issued by GeneratePurposeToken for the given purpose
+func (s *TokenService) ValidatePurposeToken(purpose, tokenString string) (jwt.MapClaims, error) {
+	claims, err := s.validate(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if typ, _ := claims["typ"].(string); typ != purpose {
+		return nil, errors.New("token was issued for another purpose")
+	}
+
+	return claims, nil
+}
+
+// validate verifies the signature and the registered claims of a token.
+// exp, nbf and iat are compared with the configured clock skew to tolerate drift between servers.
+func (s *TokenService) validate(tokenString string) (jwt.MapClaims, error) {
+	claims, err := s.keys.Parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	exp := ClaimTime(claims, "exp")
+	if exp.IsZero() || now.After(exp.Add(s.clockSkew)) {
+		return nil, errors.New("token has expired")
+	}
+
+	if nbf := ClaimTime(claims, "nbf"); !nbf.IsZero() && now.Before(nbf.Add(-s.clockSkew)) {
+		return nil, errors.New("token is not valid yet")
+	}
+
+	if iat := ClaimTime(claims, "iat"); !iat.IsZero() && iat.After(now.Add(s.clockSkew)) {
+		return nil, errors.New("token was issued in the future")
+	}
+
+	if iss, _ := claims["iss"].(string); iss != s.issuer {
+		return nil, errors.New("unexpected token issuer")
+	}
+
+	if !hasAudience(claims, s.audience) {
+		return nil, errors.New("token is not intended for this service")
+	}
+
+	return claims, nil
+}
+
+// hasAudience reports whether the aud claim, a string or an array of strings, contains audience
+func hasAudience(claims jwt.MapClaims, audience string) bool {
+	switch aud := claims["aud"].(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, v := range aud {
+			if s, ok := v.(string); ok && s == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// TokenID returns the jti claim of a token, or an empty string if it has none
+func TokenID(claims jwt.MapClaims) string {
+	jti, _ := claims["jti"].(string)
+	return jti
+}
+
+// SessionID returns the sid claim of a token, or an empty string if it has none
+func SessionID(claims jwt.MapClaims) string {
+	sid, _ := claims["sid"].(string)
+	return sid
+}
+
+// IssuedAt returns when a token was issued, to the millisecond if it carries iat_ms and to the second otherwise
+func IssuedAt(claims jwt.MapClaims) time.Time {
+	switch v := claims["iat_ms"].(type) {
+	case float64:
+		return time.UnixMilli(int64(v))
+	case int64:
+		return time.UnixMilli(v)
+	}
+	return ClaimTime(claims, "iat")
+}
+
+// ClaimTime returns a NumericDate claim such as exp or iat as a time.Time.
+// The zero time is returned when the claim is missing.
+func ClaimTime(claims jwt.MapClaims, name string) time.Time {
+	switch v := claims[name].(type) {
+	case float64:
+		return time.Unix(int64(v), 0)
+	case int64:
+		return time.Unix(v, 0)
+	case int:
+		return time.Unix(int64(v), 0)
+	}
+	return time.Time{}
+}
@@ -0,0 +1,341 @@
+package pkg
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// SigningKey is one key of a KeySet, identified by the kid header of the tokens it signs.
+// Keys loaded from a public key only can verify tokens but never sign them.
+type SigningKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// CanSign reports whether the private part of the key is available
+func (k *SigningKey) CanSign() bool {
+	return k.signKey != nil
+}
+
+// NewHMACKey creates an HS256 key from a shared secret
+func NewHMACKey(id string, secret []byte) *SigningKey {
+	return &SigningKey{ID: id, Method: jwt.SigningMethodHS256, signKey: secret, verifyKey: secret}
+}
+
+// NewRSAKey creates an RS256 key from an RSA private key
+func NewRSAKey(id string, privateKey *rsa.PrivateKey) *SigningKey {
+	return &SigningKey{ID: id, Method: jwt.SigningMethodRS256, signKey: privateKey, verifyKey: &privateKey.PublicKey}
+}
+
+// NewEd25519Key creates an EdDSA key from an Ed25519 private key
+func NewEd25519Key(id string, privateKey ed25519.PrivateKey) *SigningKey {
+	return &SigningKey{ID: id, Method: SigningMethodEd25519, signKey: privateKey, verifyKey: privateKey.Public()}
+}
+
+// NewVerificationKey creates a verify-only key from an RSA or Ed25519 public key
+func NewVerificationKey(id string, publicKey crypto.PublicKey) (*SigningKey, error) {
+	switch pub := publicKey.(type) {
+	case *rsa.PublicKey:
+		return &SigningKey{ID: id, Method: jwt.SigningMethodRS256, verifyKey: pub}, nil
+	case ed25519.PublicKey:
+		return &SigningKey{ID: id, Method: SigningMethodEd25519, verifyKey: pub}, nil
+	}
+	return nil, fmt.Errorf("unsupported public key type %T", publicKey)
+}
+
+// ParseKeyPEM builds a SigningKey from a PEM encoded RSA or Ed25519 key.
+// Private keys (PKCS#1 or PKCS#8) yield signing keys, public keys (PKIX) yield verify-only keys.
+func ParseKeyPEM(id string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return NewRSAKey(id, privateKey), nil
+	case "PRIVATE KEY":
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		switch privateKey := parsed.(type) {
+		case *rsa.PrivateKey:
+			return NewRSAKey(id, privateKey), nil
+		case ed25519.PrivateKey:
+			return NewEd25519Key(id, privateKey), nil
+		}
+		return nil, fmt.Errorf("unsupported private key type %T", parsed)
+	case "PUBLIC KEY":
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return NewVerificationKey(id, parsed)
+	}
+
+	return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+}
+
+// KeySet holds the key used to sign new tokens together with every key still accepted for verification.
+// During a rotation the previous keys stay in the set until the tokens they signed have expired.
+type KeySet struct {
+	active *SigningKey
+	keys   map[string]*SigningKey
+	// legacy verifies tokens issued before kid headers were introduced
+	legacy *SigningKey
+}
+
+// NewKeySet creates a KeySet that signs with active and also verifies with the additional keys
+func NewKeySet(active *SigningKey, additional ...*SigningKey) (*KeySet, error) {
+	if active == nil || !active.CanSign() {
+		return nil, errors.New("active key must include a private key")
+	}
+
+	ks := &KeySet{active: active, keys: make(map[string]*SigningKey)}
+	for _, key := range append([]*SigningKey{active}, additional...) {
+		if _, exists := ks.keys[key.ID]; exists {
+			return nil, fmt.Errorf("duplicate key id %q", key.ID)
+		}
+		ks.keys[key.ID] = key
+		if ks.legacy == nil && key.Method == jwt.SigningMethodHS256 {
+			ks.legacy = key
+		}
+	}
+
+	return ks, nil
+}
+
+// LoadKeySet builds a KeySet from a directory of PEM files named <kid>.pem (private)
+// or <kid>.pub.pem (public, verification only). activeKeyID selects the signing key;
+// it may be empty when the directory holds a single private key. Without a directory
+// the set falls back to a single HS256 key derived from secret.
+func LoadKeySet(dir, activeKeyID, secret string) (*KeySet, error) {
+	var hmacKey *SigningKey
+	if secret != "" {
+		hmacKey = NewHMACKey("hs256", []byte(secret))
+	}
+
+	if dir == "" {
+		if hmacKey == nil {
+			return nil, errors.New("either a signing key directory or a JWT secret is required")
+		}
+		return NewKeySet(hmacKey)
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var keys []*SigningKey
+	var signers []*SigningKey
+	for _, path := range paths {
+		name := filepath.Base(path)
+		id := strings.TrimSuffix(strings.TrimSuffix(name, ".pem"), ".pub")
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key, err := ParseKeyPEM(id, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load key %s: %w", name, err)
+		}
+
+		keys = append(keys, key)
+		if key.CanSign() {
+			signers = append(signers, key)
+		}
+	}
+
+	var active *SigningKey
+	var additional []*SigningKey
+	for _, key := range keys {
+		if active == nil && (key.ID == activeKeyID || (activeKeyID == "" && len(signers) == 1 && key.CanSign())) {
+			active = key
+			continue
+		}
+		additional = append(additional, key)
+	}
+
+	if active == nil {
+		return nil, fmt.Errorf("active signing key %q not found in %s", activeKeyID, dir)
+	}
+
+	// Keep accepting HS256 tokens while migrating away from the shared secret
+	if hmacKey != nil {
+		additional = append(additional, hmacKey)
+	}
+
+	return NewKeySet(active, additional...)
+}
+
+// Active returns the key that signs new tokens
+func (ks *KeySet) Active() *SigningKey {
+	return ks.active
+}
+
+// Sign signs the claims with the active key and records its id in the kid header
+func (ks *KeySet) Sign(claims jwt.MapClaims) (string, error) {
+	if ks.active == nil {
+		return "", errors.New("key set has no signing key")
+	}
+	token := jwt.NewWithClaims(ks.active.Method, claims)
+	token.Header["kid"] = ks.active.ID
+	return token.SignedString(ks.active.signKey)
+}
+
+// Parse verifies the signature of a token against the key named by its kid header and returns its claims.
+// Registered claims such as exp are not checked here; TokenService validates them with clock skew applied.
+func (ks *KeySet) Parse(tokenString string) (jwt.MapClaims, error) {
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+	token, err := parser.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		key := ks.legacy
+		if kid, ok := token.Header["kid"].(string); ok {
+			key = ks.keys[kid]
+		}
+		if key == nil {
+			return nil, errors.New("unknown signing key")
+		}
+
+		// The algorithm is bound to the key, never taken from the token alone
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, errors.New("unexpected signing method")
+		}
+		return key.verifyKey, nil
+	})
+
+	if err != nil || !token.Valid {
+		return nil, errors.New("invalid token signature")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("invalid token claims")
+	}
+
+	return claims, nil
+}
+
+// JWK is a single public key in JSON Web Key format (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS is the document served at /.well-known/jwks.json
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public part of every asymmetric key in the set.
+// HMAC keys are shared secrets and are never published.
+func (ks *KeySet) JWKS() JWKS {
+	ids := make([]string, 0, len(ks.keys))
+	for id := range ks.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	jwks := JWKS{Keys: []JWK{}}
+	for _, id := range ids {
+		key := ks.keys[id]
+		switch pub := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				Kty: "RSA",
+				Kid: key.ID,
+				Use: "sig",
+				Alg: key.Method.Alg(),
+				N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				Kty: "OKP",
+				Kid: key.ID,
+				Use: "sig",
+				Alg: key.Method.Alg(),
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+
+	return jwks
+}
+
+// PublicKey decodes an RSA or Ed25519 JWK into a public key
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil || len(n) == 0 {
+			return nil, fmt.Errorf("invalid RSA modulus in key %q", k.Kid)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("invalid RSA exponent in key %q", k.Kid)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if k.Crv != "Ed25519" || err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key %q", k.Kid)
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// NewVerificationKeySet builds a verify-only KeySet from the JSON Web Key Set of another issuer.
+// Keys that are not signing keys or have an unsupported type are skipped.
+func NewVerificationKeySet(jwks JWKS) (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]*SigningKey)}
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		publicKey, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		key, err := NewVerificationKey(jwk.Kid, publicKey)
+		if err != nil {
+			continue
+		}
+		ks.keys[key.ID] = key
+	}
+
+	if len(ks.keys) == 0 {
+		return nil, errors.New("key set contains no supported signing keys")
+	}
+	return ks, nil
+}
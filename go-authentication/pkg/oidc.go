@@ -0,0 +1,328 @@
+package pkg
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-authentication/config"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	oidcHTTPTimeout = 10 * time.Second
+	// oidcKeyRefreshInterval limits how often an unknown kid triggers a JWKS refetch
+	oidcKeyRefreshInterval = time.Minute
+)
+
+// ErrInvalidIDToken is returned when an ID token fails signature or claim validation
+var ErrInvalidIDToken = errors.New("invalid id token")
+
+// OIDCProvider is an OpenID Connect relying party for a single issuer.
+// Endpoints and signing keys are discovered from the issuer's /.well-known/openid-configuration.
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	HTTPClient   *http.Client
+
+	mu            sync.Mutex
+	discovery     *oidcDiscovery
+	keys          *KeySet
+	keysFetchedAt time.Time
+}
+
+// oidcDiscovery is the subset of the provider metadata this client uses
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCIdentity is the verified identity asserted by an ID token
+type OIDCIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// NewOIDCProvider creates an OIDCProvider from the OIDC_* settings in cfg
+func NewOIDCProvider(cfg *config.Config) (*OIDCProvider, error) {
+	if cfg.OIDCIssuer == "" || cfg.OIDCClientID == "" {
+		return nil, errors.New("OIDC_ISSUER and OIDC_CLIENT_ID are required")
+	}
+
+	redirectURL := cfg.OIDCRedirectURL
+	if redirectURL == "" {
+		redirectURL = strings.TrimRight(cfg.AppBaseURL, "/") + "/oidc/callback"
+	}
+
+	scopes := strings.Fields(cfg.OIDCScopes)
+	hasOpenID := false
+	for _, scope := range scopes {
+		hasOpenID = hasOpenID || scope == "openid"
+	}
+	if !hasOpenID {
+		scopes = append([]string{"openid"}, scopes...)
+	}
+
+	name := cfg.OIDCProviderName
+	if name == "" {
+		name = "oidc"
+	}
+
+	return &OIDCProvider{
+		Name:         name,
+		Issuer:       strings.TrimRight(cfg.OIDCIssuer, "/"),
+		ClientID:     cfg.OIDCClientID,
+		ClientSecret: cfg.OIDCClientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       scopes,
+		HTTPClient:   &http.Client{Timeout: oidcHTTPTimeout},
+	}, nil
+}
+
+// PKCEChallenge derives the S256 code challenge for a code verifier (RFC 7636)
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL returns the URL that sends the user to the provider to sign in
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	discovery, err := p.metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.ClientID},
+		"redirect_uri":          {p.RedirectURL},
+		"scope":                 {strings.Join(p.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Exchange redeems an authorization code and returns the raw ID token
+func (p *OIDCProvider) Exchange(ctx context.Context, code, codeVerifier string) (string, error) {
+	discovery, err := p.metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.RedirectURL},
+		"client_id":     {p.ClientID},
+		"code_verifier": {codeVerifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := p.doJSON(req, &body)
+	if err != nil {
+		return "", fmt.Errorf("token request failed: %w", err)
+	}
+	if status != http.StatusOK || body.Error != "" {
+		return "", fmt.Errorf("token request rejected with status %d: %s %s", status, body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return "", errors.New("token response has no id_token")
+	}
+
+	return body.IDToken, nil
+}
+
+// VerifyIDToken checks the signature, issuer, audience, lifetime and nonce of an ID token
+func (p *OIDCProvider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*OIDCIdentity, error) {
+	claims, err := p.parseIDToken(ctx, rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if exp := ClaimTime(claims, "exp"); exp.IsZero() || now.After(exp.Add(defaultClockSkew)) {
+		return nil, fmt.Errorf("%w: token has expired", ErrInvalidIDToken)
+	}
+	if iat := ClaimTime(claims, "iat"); iat.After(now.Add(defaultClockSkew)) {
+		return nil, fmt.Errorf("%w: token was issued in the future", ErrInvalidIDToken)
+	}
+	if iss, _ := claims["iss"].(string); iss != p.Issuer {
+		return nil, fmt.Errorf("%w: unexpected issuer %q", ErrInvalidIDToken, iss)
+	}
+	if !hasAudience(claims, p.ClientID) {
+		return nil, fmt.Errorf("%w: token is not intended for this client", ErrInvalidIDToken)
+	}
+	// With several audiences the authorized party must be this client
+	if azp, ok := claims["azp"].(string); ok && azp != p.ClientID {
+		return nil, fmt.Errorf("%w: unexpected authorized party %q", ErrInvalidIDToken, azp)
+	}
+	if tokenNonce, _ := claims["nonce"].(string); nonce == "" || tokenNonce != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+
+	identity := &OIDCIdentity{}
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Email, _ = claims["email"].(string)
+	identity.Name, _ = claims["name"].(string)
+	// Some providers send email_verified as a string
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		identity.EmailVerified = verified == "true"
+	}
+	if identity.Subject == "" {
+		return nil, fmt.Errorf("%w: missing sub claim", ErrInvalidIDToken)
+	}
+
+	return identity, nil
+}
+
+// parseIDToken verifies the signature, refetching the provider keys once if they may have rotated
+func (p *OIDCProvider) parseIDToken(ctx context.Context, rawIDToken string) (jwt.MapClaims, error) {
+	keys, err := p.signingKeys(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := keys.Parse(rawIDToken)
+	if err == nil {
+		return claims, nil
+	}
+
+	refreshed, refreshErr := p.signingKeys(ctx, true)
+	if refreshErr != nil || refreshed == keys {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	claims, err = refreshed.Parse(rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	return claims, nil
+}
+
+// metadata returns the cached provider metadata, fetching it on first use
+func (p *OIDCProvider) metadata(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.Issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var discovery oidcDiscovery
+	status, err := p.doJSON(req, &discovery)
+	if err != nil || status != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch OIDC discovery document (status %d): %v", status, err)
+	}
+	if strings.TrimRight(discovery.Issuer, "/") != p.Issuer {
+		return nil, fmt.Errorf("OIDC discovery issuer %q does not match %q", discovery.Issuer, p.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, errors.New("OIDC discovery document is missing endpoints")
+	}
+
+	p.discovery = &discovery
+	return p.discovery, nil
+}
+
+// signingKeys returns the cached provider keys. refresh refetches them unless they were fetched very recently.
+func (p *OIDCProvider) signingKeys(ctx context.Context, refresh bool) (*KeySet, error) {
+	discovery, err := p.metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.keys != nil && (!refresh || time.Since(p.keysFetchedAt) < oidcKeyRefreshInterval) {
+		return p.keys, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discovery.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var jwks JWKS
+	status, err := p.doJSON(req, &jwks)
+	if err != nil || status != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch OIDC signing keys (status %d): %v", status, err)
+	}
+
+	keys, err := NewVerificationKeySet(jwks)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OIDC signing keys: %w", err)
+	}
+
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+	return p.keys, nil
+}
+
+// doJSON sends req and decodes a JSON response body into v
+func (p *OIDCProvider) doJSON(req *http.Request, v interface{}) (int, error) {
+	client := p.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return resp.StatusCode, fmt.Errorf("invalid JSON response: %w", err)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,241 @@
+package pkg
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-authentication/config"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	defaultPasswordMinLength = 10
+	// bcryptMaxPasswordBytes is where bcrypt silently truncates its input
+	bcryptMaxPasswordBytes = 72
+	// minPersonalInfoLength keeps very short names from rejecting unrelated passwords
+	minPersonalInfoLength = 3
+)
+
+// PasswordPolicyError lists every rule a candidate password broke
+type PasswordPolicyError struct {
+	Violations []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return strings.Join(e.Violations, "; ")
+}
+
+// PasswordPolicy decides whether a new password is acceptable
+type PasswordPolicy struct {
+	MinLength     int
+	MaxLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// Breached is consulted for known breached passwords; nil disables the check
+	Breached BreachedPasswordSource
+}
+
+// NewPasswordPolicy creates a PasswordPolicy from the PASSWORD_* settings in cfg
+func NewPasswordPolicy(cfg *config.Config) (*PasswordPolicy, error) {
+	minLength, err := parseInt(cfg.PasswordMinLength, defaultPasswordMinLength)
+	if err != nil || minLength < 1 {
+		return nil, fmt.Errorf("invalid PASSWORD_MIN_LENGTH %q", cfg.PasswordMinLength)
+	}
+
+	maxLength, err := parseInt(cfg.PasswordMaxLength, bcryptMaxPasswordBytes)
+	if err != nil || maxLength < minLength || maxLength > bcryptMaxPasswordBytes {
+		return nil, fmt.Errorf("invalid PASSWORD_MAX_LENGTH %q: must be between PASSWORD_MIN_LENGTH and %d", cfg.PasswordMaxLength, bcryptMaxPasswordBytes)
+	}
+
+	policy := &PasswordPolicy{MinLength: minLength, MaxLength: maxLength}
+	flags := []struct {
+		name  string
+		value string
+		dest  *bool
+	}{
+		{"PASSWORD_REQUIRE_UPPER", cfg.PasswordRequireUpper, &policy.RequireUpper},
+		{"PASSWORD_REQUIRE_LOWER", cfg.PasswordRequireLower, &policy.RequireLower},
+		{"PASSWORD_REQUIRE_DIGIT", cfg.PasswordRequireDigit, &policy.RequireDigit},
+		{"PASSWORD_REQUIRE_SYMBOL", cfg.PasswordRequireSymbol, &policy.RequireSymbol},
+	}
+	for _, flag := range flags {
+		if flag.value == "" {
+			continue
+		}
+		if *flag.dest, err = strconv.ParseBool(flag.value); err != nil {
+			return nil, fmt.Errorf("invalid %s %q", flag.name, flag.value)
+		}
+	}
+
+	if cfg.PasswordBreachedListFile != "" {
+		source, err := LoadBreachedPasswordFile(cfg.PasswordBreachedListFile)
+		if err != nil {
+			return nil, err
+		}
+		policy.Breached = source
+	}
+
+	return policy, nil
+}
+
+func parseInt(value string, fallback int) (int, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// Validate checks password against the policy. personal holds the user's name, email and similar
+// values that must not appear in the password.
+func (p *PasswordPolicy) Validate(password string, personal ...string) error {
+	var violations []string
+
+	if len([]rune(password)) < p.MinLength {
+		violations = append(violations, fmt.Sprintf("password must be at least %d characters long", p.MinLength))
+	}
+	// The limit is in bytes because that is what bcrypt truncates
+	if len(password) > p.MaxLength {
+		violations = append(violations, fmt.Sprintf("password must be at most %d bytes long", p.MaxLength))
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case !unicode.IsLetter(r):
+			hasSymbol = true
+		}
+	}
+	if p.RequireUpper && !hasUpper {
+		violations = append(violations, "password must contain an uppercase letter")
+	}
+	if p.RequireLower && !hasLower {
+		violations = append(violations, "password must contain a lowercase letter")
+	}
+	if p.RequireDigit && !hasDigit {
+		violations = append(violations, "password must contain a digit")
+	}
+	if p.RequireSymbol && !hasSymbol {
+		violations = append(violations, "password must contain a symbol")
+	}
+
+	if containsPersonalInfo(password, personal) {
+		violations = append(violations, "password must not contain your name or email address")
+	}
+
+	if p.Breached != nil {
+		breached, err := IsBreachedPassword(p.Breached, password)
+		if err != nil {
+			return err
+		}
+		if breached {
+			violations = append(violations, "password appears in a list of breached passwords")
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// containsPersonalInfo reports whether password contains the email, its local part or any word of a name
+func containsPersonalInfo(password string, personal []string) bool {
+	lowered := strings.ToLower(password)
+
+	for _, value := range personal {
+		value = strings.ToLower(strings.TrimSpace(value))
+		parts := strings.Fields(value)
+		if local, _, found := strings.Cut(value, "@"); found {
+			parts = append(parts, value, local)
+		}
+
+		for _, part := range parts {
+			if len([]rune(part)) >= minPersonalInfoLength && strings.Contains(lowered, part) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// BreachedPasswordSource answers k-anonymity range queries: given the first five hex characters
+// of a password's SHA-1 hash it returns the remaining 35 characters of every breached hash with that prefix.
+// Only the prefix ever leaves the caller, so a remote source never learns the password.
+type BreachedPasswordSource interface {
+	Range(prefix string) ([]string, error)
+}
+
+// IsBreachedPassword checks password against source without revealing more than its hash prefix
+func IsBreachedPassword(source BreachedPasswordSource, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	suffixes, err := source.Range(hash[:5])
+	if err != nil {
+		return false, fmt.Errorf("failed to check breached passwords: %w", err)
+	}
+
+	for _, suffix := range suffixes {
+		if suffix == hash[5:] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// FileBreachedPasswordSource serves range queries from a local list of SHA-1 hashes
+type FileBreachedPasswordSource struct {
+	ranges map[string][]string
+}
+
+// LoadBreachedPasswordFile reads a breached password list with one uppercase or lowercase SHA-1 hash per line,
+// optionally followed by ":<count>" as in the Have I Been Pwned downloads. Blank lines and lines starting with # are ignored.
+func LoadBreachedPasswordFile(path string) (*FileBreachedPasswordSource, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached password list: %w", err)
+	}
+	defer f.Close()
+
+	source := &FileBreachedPasswordSource{ranges: make(map[string][]string)}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		hash, _, _ := strings.Cut(entry, ":")
+		hash = strings.ToUpper(hash)
+		if _, err := hex.DecodeString(hash); err != nil || len(hash) != 2*sha1.Size {
+			return nil, fmt.Errorf("breached password list %s line %d: not a SHA-1 hash", path, line)
+		}
+
+		source.ranges[hash[:5]] = append(source.ranges[hash[:5]], hash[5:])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read breached password list: %w", err)
+	}
+
+	return source, nil
+}
+
+func (s *FileBreachedPasswordSource) Range(prefix string) ([]string, error) {
+	if len(prefix) != 5 {
+		return nil, errors.New("range prefix must be 5 hex characters")
+	}
+	return s.ranges[strings.ToUpper(prefix)], nil
+}
@@ -0,0 +1,359 @@
+package pkg
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-authentication/config"
+	"math/big"
+	"net/url"
+	"strings"
+)
+
+// COSE algorithm identifiers of the supported credential keys (IANA COSE Algorithms registry)
+const (
+	COSEAlgES256 = -7
+	COSEAlgEdDSA = -8
+	COSEAlgRS256 = -257
+)
+
+// WebAuthnAlgorithms lists the supported algorithms in order of preference
+var WebAuthnAlgorithms = []int64{COSEAlgES256, COSEAlgEdDSA, COSEAlgRS256}
+
+// Ceremony types carried in the type member of the client data
+const (
+	WebAuthnCeremonyCreate = "webauthn.create"
+	WebAuthnCeremonyGet    = "webauthn.get"
+)
+
+// Authenticator data flags (WebAuthn Level 2, section 6.1)
+const (
+	AuthenticatorFlagUserPresent            = 0x01
+	AuthenticatorFlagUserVerified           = 0x04
+	AuthenticatorFlagAttestedCredentialData = 0x40
+	AuthenticatorFlagExtensionData          = 0x80
+)
+
+// minRSAKeyBits rejects RS256 credential keys too short to be safe
+const minRSAKeyBits = 2048
+
+// ErrInvalidWebAuthnResponse is returned when a registration or login response from the browser fails verification
+var ErrInvalidWebAuthnResponse = errors.New("invalid webauthn response")
+
+// WebAuthnRelyingParty verifies passkey ceremonies for one relying party ID, e.g. "example.com".
+// Credentials are bound to the ID, so changing it invalidates every registered passkey.
+type WebAuthnRelyingParty struct {
+	ID   string
+	Name string
+	// Origins are the exact origins, e.g. "https://app.example.com", the browser may report
+	Origins []string
+}
+
+// CollectedClientData is the clientDataJSON signed by the authenticator
+type CollectedClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// AuthenticatorData is the parsed authenticator data of a registration or login.
+// The attested credential fields are only set during registration.
+type AuthenticatorData struct {
+	RPIDHash     []byte
+	Flags        byte
+	SignCount    uint32
+	AAGUID       []byte
+	CredentialID []byte
+	// CredentialPublicKey is the raw COSE_Key, stored as is for later logins
+	CredentialPublicKey []byte
+}
+
+// HasFlag reports whether the authenticator set the given flag
+func (d *AuthenticatorData) HasFlag(flag byte) bool {
+	return d.Flags&flag != 0
+}
+
+// COSEKey is a credential public key decoded from its COSE_Key form (RFC 9053)
+type COSEKey struct {
+	Algorithm int64
+	PublicKey crypto.PublicKey
+}
+
+// NewWebAuthnRelyingParty creates a WebAuthnRelyingParty from the WEBAUTHN_* settings in cfg.
+// The ID and origin default to the host and origin of APP_BASE_URL.
+func NewWebAuthnRelyingParty(cfg *config.Config) (*WebAuthnRelyingParty, error) {
+	baseURL, err := url.Parse(cfg.AppBaseURL)
+	if err != nil || baseURL.Host == "" {
+		return nil, errors.New("APP_BASE_URL must be an absolute URL")
+	}
+
+	rp := &WebAuthnRelyingParty{
+		ID:   strings.ToLower(cfg.WebAuthnRPID),
+		Name: cfg.WebAuthnRPName,
+	}
+	if rp.ID == "" {
+		rp.ID = strings.ToLower(baseURL.Hostname())
+	}
+	if rp.Name == "" {
+		rp.Name = rp.ID
+	}
+
+	for _, origin := range strings.Split(cfg.WebAuthnOrigins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			rp.Origins = append(rp.Origins, strings.TrimRight(origin, "/"))
+		}
+	}
+	if len(rp.Origins) == 0 {
+		rp.Origins = []string{baseURL.Scheme + "://" + baseURL.Host}
+	}
+
+	for _, origin := range rp.Origins {
+		u, err := url.Parse(origin)
+		if err != nil || u.Host == "" || u.Path != "" {
+			return nil, fmt.Errorf("invalid WEBAUTHN_ORIGINS entry %q", origin)
+		}
+		// Browsers only offer WebAuthn in secure contexts
+		if u.Scheme != "https" && !(u.Scheme == "http" && u.Hostname() == "localhost") {
+			return nil, fmt.Errorf("WebAuthn origin %q must use https", origin)
+		}
+		host := strings.ToLower(u.Hostname())
+		if host != rp.ID && !strings.HasSuffix(host, "."+rp.ID) {
+			return nil, fmt.Errorf("WebAuthn origin %q is not within the relying party ID %q", origin, rp.ID)
+		}
+	}
+
+	return rp, nil
+}
+
+// ParseClientData decodes clientDataJSON and checks that it was produced for the given ceremony on one of our origins.
+// The caller still has to check that the challenge is one it issued.
+func (rp *WebAuthnRelyingParty) ParseClientData(raw []byte, ceremony string) (*CollectedClientData, error) {
+	var clientData CollectedClientData
+	if err := json.Unmarshal(raw, &clientData); err != nil {
+		return nil, fmt.Errorf("%w: malformed client data", ErrInvalidWebAuthnResponse)
+	}
+
+	if clientData.Type != ceremony {
+		return nil, fmt.Errorf("%w: unexpected ceremony type %q", ErrInvalidWebAuthnResponse, clientData.Type)
+	}
+	if clientData.Challenge == "" {
+		return nil, fmt.Errorf("%w: missing challenge", ErrInvalidWebAuthnResponse)
+	}
+	if clientData.CrossOrigin {
+		return nil, fmt.Errorf("%w: cross-origin requests are not allowed", ErrInvalidWebAuthnResponse)
+	}
+
+	for _, origin := range rp.Origins {
+		if clientData.Origin == origin {
+			return &clientData, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: unexpected origin %q", ErrInvalidWebAuthnResponse, clientData.Origin)
+}
+
+// VerifyAttestation parses the attestation object of a registration and verifies its authenticator data
+// and credential key. Attestation statements are not verified, as with the "none" conveyance preference:
+// the make of the authenticator is not trusted, only the key it created.
+func (rp *WebAuthnRelyingParty) VerifyAttestation(attestationObject []byte) (*AuthenticatorData, *COSEKey, error) {
+	decoded, rest, err := DecodeCBOR(attestationObject)
+	if err != nil || len(rest) != 0 {
+		return nil, nil, fmt.Errorf("%w: malformed attestation object", ErrInvalidWebAuthnResponse)
+	}
+	object, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: malformed attestation object", ErrInvalidWebAuthnResponse)
+	}
+	rawAuthData, ok := object["authData"].([]byte)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: missing authenticator data", ErrInvalidWebAuthnResponse)
+	}
+
+	authData, err := ParseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := rp.checkAuthenticatorData(authData); err != nil {
+		return nil, nil, err
+	}
+	if !authData.HasFlag(AuthenticatorFlagAttestedCredentialData) {
+		return nil, nil, fmt.Errorf("%w: no credential was attested", ErrInvalidWebAuthnResponse)
+	}
+
+	key, err := ParseCOSEKey(authData.CredentialPublicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return authData, key, nil
+}
+
+// VerifyAssertion verifies the authenticator data and signature of a login with the credential's public key
+func (rp *WebAuthnRelyingParty) VerifyAssertion(key *COSEKey, rawAuthData, clientDataJSON, signature []byte) (*AuthenticatorData, error) {
+	authData, err := ParseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if err := rp.checkAuthenticatorData(authData); err != nil {
+		return nil, err
+	}
+
+	// The signature covers the authenticator data followed by the hash of the client data
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	message := append(append([]byte{}, rawAuthData...), clientDataHash[:]...)
+	if err := key.Verify(message, signature); err != nil {
+		return nil, err
+	}
+
+	return authData, nil
+}
+
+// checkAuthenticatorData requires the credential to be scoped to our relying party ID, and the user
+// to be present and verified, e.g. with a PIN or biometric, which makes a passkey a second factor on its own
+func (rp *WebAuthnRelyingParty) checkAuthenticatorData(authData *AuthenticatorData) error {
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if subtle.ConstantTimeCompare(authData.RPIDHash, rpIDHash[:]) != 1 {
+		return fmt.Errorf("%w: credential belongs to another relying party", ErrInvalidWebAuthnResponse)
+	}
+	if !authData.HasFlag(AuthenticatorFlagUserPresent) {
+		return fmt.Errorf("%w: user was not present", ErrInvalidWebAuthnResponse)
+	}
+	if !authData.HasFlag(AuthenticatorFlagUserVerified) {
+		return fmt.Errorf("%w: user was not verified", ErrInvalidWebAuthnResponse)
+	}
+	return nil
+}
+
+// ParseAuthenticatorData decodes the binary authenticator data structure
+func ParseAuthenticatorData(data []byte) (*AuthenticatorData, error) {
+	if len(data) < 37 {
+		return nil, fmt.Errorf("%w: authenticator data too short", ErrInvalidWebAuthnResponse)
+	}
+
+	authData := &AuthenticatorData{
+		RPIDHash:  data[:32],
+		Flags:     data[32],
+		SignCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	rest := data[37:]
+
+	if authData.HasFlag(AuthenticatorFlagAttestedCredentialData) {
+		if len(rest) < 18 {
+			return nil, fmt.Errorf("%w: attested credential data too short", ErrInvalidWebAuthnResponse)
+		}
+		authData.AAGUID = rest[:16]
+		idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLength == 0 || idLength > 1023 || len(rest) < idLength {
+			return nil, fmt.Errorf("%w: invalid credential ID", ErrInvalidWebAuthnResponse)
+		}
+		authData.CredentialID = rest[:idLength]
+		rest = rest[idLength:]
+
+		// The key is the one CBOR item that follows; its length is only known by decoding it
+		_, afterKey, err := DecodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid credential public key", ErrInvalidWebAuthnResponse)
+		}
+		authData.CredentialPublicKey = rest[:len(rest)-len(afterKey)]
+		rest = afterKey
+	}
+
+	if authData.HasFlag(AuthenticatorFlagExtensionData) {
+		_, afterExtensions, err := DecodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid extension data", ErrInvalidWebAuthnResponse)
+		}
+		rest = afterExtensions
+	}
+
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("%w: trailing bytes in authenticator data", ErrInvalidWebAuthnResponse)
+	}
+
+	return authData, nil
+}
+
+// ParseCOSEKey decodes a credential public key. Only ES256, EdDSA (Ed25519) and RS256 keys are accepted.
+func ParseCOSEKey(data []byte) (*COSEKey, error) {
+	decoded, rest, err := DecodeCBOR(data)
+	if err != nil || len(rest) != 0 {
+		return nil, fmt.Errorf("%w: malformed credential public key", ErrInvalidWebAuthnResponse)
+	}
+	params, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: malformed credential public key", ErrInvalidWebAuthnResponse)
+	}
+
+	// COSE_Key labels: 1 kty, 3 alg, and the key type specific labels -1, -2 and -3
+	kty, _ := params[int64(1)].(int64)
+	alg, _ := params[int64(3)].(int64)
+	crv, _ := params[int64(-1)].(int64)
+
+	switch {
+	case alg == COSEAlgES256 && kty == 2 && crv == 1:
+		x, _ := params[int64(-2)].([]byte)
+		y, _ := params[int64(-3)].([]byte)
+		if len(x) != 32 || len(y) != 32 {
+			break
+		}
+		// Reject points that are not on the curve before using them
+		point := append(append([]byte{4}, x...), y...)
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			break
+		}
+		return &COSEKey{Algorithm: alg, PublicKey: &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}}, nil
+
+	case alg == COSEAlgEdDSA && kty == 1 && crv == 6:
+		x, _ := params[int64(-2)].([]byte)
+		if len(x) != ed25519.PublicKeySize {
+			break
+		}
+		return &COSEKey{Algorithm: alg, PublicKey: ed25519.PublicKey(x)}, nil
+
+	case alg == COSEAlgRS256 && kty == 3:
+		n, _ := params[int64(-1)].([]byte)
+		e, _ := params[int64(-2)].([]byte)
+		modulus := new(big.Int).SetBytes(n)
+		exponent := new(big.Int).SetBytes(e)
+		if modulus.BitLen() < minRSAKeyBits || !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			break
+		}
+		return &COSEKey{Algorithm: alg, PublicKey: &rsa.PublicKey{N: modulus, E: int(exponent.Int64())}}, nil
+	}
+
+	return nil, fmt.Errorf("%w: unsupported credential public key", ErrInvalidWebAuthnResponse)
+}
+
+// Verify checks a signature over message made with the credential's private key
+func (k *COSEKey) Verify(message, signature []byte) error {
+	digest := sha256.Sum256(message)
+
+	valid := false
+	switch key := k.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		valid = ecdsa.VerifyASN1(key, digest[:], signature)
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(key, message, signature)
+	case *rsa.PublicKey:
+		valid = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil
+	}
+
+	if !valid {
+		return fmt.Errorf("%w: invalid signature", ErrInvalidWebAuthnResponse)
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+// Package webauthntest provides a software WebAuthn authenticator for end-to-end tests of passkey registration and login.
+package webauthntest
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"go-authentication/pkg"
+)
+
+// Credential is a key pair created by the authenticator for one account
+type Credential struct {
+	ID         []byte
+	UserHandle []byte
+	// SignCount is the counter reported by the next assertion minus one; tests may lower it to simulate a cloned key
+	SignCount uint32
+
+	algorithm  int64
+	ecdsaKey   *ecdsa.PrivateKey
+	ed25519Key ed25519.PrivateKey
+}
+
+// AttestationResponse is the response of navigator.credentials.create(), base64url encoded like a browser would send it
+type AttestationResponse struct {
+	CredentialID      string
+	ClientDataJSON    string
+	AttestationObject string
+}
+
+// AssertionResponse is the response of navigator.credentials.get(), base64url encoded like a browser would send it
+type AssertionResponse struct {
+	CredentialID      string
+	ClientDataJSON    string
+	AuthenticatorData string
+	Signature         string
+	UserHandle        string
+}
+
+// Authenticator is a platform authenticator and the browser in front of it. It always verifies the user
+// unless UserVerified is cleared, and uses "none" attestation.
+type Authenticator struct {
+	RPID string
+	// Origin is reported in the client data, as the browser would for the page calling WebAuthn
+	Origin       string
+	UserVerified bool
+	// Algorithm of new credentials: pkg.COSEAlgES256 (the default) or pkg.COSEAlgEdDSA
+	Algorithm int64
+}
+
+// NewAuthenticator creates an authenticator for the relying party rpID, used from origin
+func NewAuthenticator(rpID, origin string) *Authenticator {
+	return &Authenticator{
+		RPID:         rpID,
+		Origin:       origin,
+		UserVerified: true,
+		Algorithm:    pkg.COSEAlgES256,
+	}
+}
+
+// Register creates a credential for userHandle in answer to a registration challenge
+func (a *Authenticator) Register(challenge string, userHandle []byte) (*Credential, *AttestationResponse, error) {
+	credential := &Credential{
+		ID:         make([]byte, 32),
+		UserHandle: userHandle,
+		algorithm:  a.Algorithm,
+	}
+	if _, err := rand.Read(credential.ID); err != nil {
+		return nil, nil, err
+	}
+
+	var coseKey []byte
+	switch a.Algorithm {
+	case pkg.COSEAlgES256:
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		credential.ecdsaKey = key
+		coseKey = encodeMap(
+			encodeInt(1), encodeInt(2),
+			encodeInt(3), encodeInt(pkg.COSEAlgES256),
+			encodeInt(-1), encodeInt(1),
+			encodeInt(-2), encodeBytes(key.X.FillBytes(make([]byte, 32))),
+			encodeInt(-3), encodeBytes(key.Y.FillBytes(make([]byte, 32))),
+		)
+	case pkg.COSEAlgEdDSA:
+		publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		credential.ed25519Key = privateKey
+		coseKey = encodeMap(
+			encodeInt(1), encodeInt(1),
+			encodeInt(3), encodeInt(pkg.COSEAlgEdDSA),
+			encodeInt(-1), encodeInt(6),
+			encodeInt(-2), encodeBytes(publicKey),
+		)
+	default:
+		return nil, nil, errors.New("unsupported algorithm")
+	}
+
+	// Attested credential data: AAGUID (all zero for "none" attestation), credential ID length, ID and key
+	attested := make([]byte, 16, 18+len(credential.ID)+len(coseKey))
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(credential.ID)))
+	attested = append(attested, credential.ID...)
+	attested = append(attested, coseKey...)
+
+	authData := a.authenticatorData(pkg.AuthenticatorFlagAttestedCredentialData, credential.SignCount, attested)
+	attestationObject := encodeMap(
+		encodeText("fmt"), encodeText("none"),
+		encodeText("attStmt"), encodeMap(),
+		encodeText("authData"), encodeBytes(authData),
+	)
+
+	clientDataJSON, err := a.clientData(pkg.WebAuthnCeremonyCreate, challenge)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return credential, &AttestationResponse{
+		CredentialID:      encode(credential.ID),
+		ClientDataJSON:    encode(clientDataJSON),
+		AttestationObject: encode(attestationObject),
+	}, nil
+}
+
+// Assert signs a login challenge with credential, increasing its signature counter
+func (a *Authenticator) Assert(challenge string, credential *Credential) (*AssertionResponse, error) {
+	credential.SignCount++
+	authData := a.authenticatorData(0, credential.SignCount, nil)
+
+	clientDataJSON, err := a.clientData(pkg.WebAuthnCeremonyGet, challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	message := append(append([]byte{}, authData...), clientDataHash[:]...)
+
+	var signature []byte
+	switch credential.algorithm {
+	case pkg.COSEAlgES256:
+		digest := sha256.Sum256(message)
+		signature, err = ecdsa.SignASN1(rand.Reader, credential.ecdsaKey, digest[:])
+		if err != nil {
+			return nil, err
+		}
+	case pkg.COSEAlgEdDSA:
+		signature = ed25519.Sign(credential.ed25519Key, message)
+	}
+
+	return &AssertionResponse{
+		CredentialID:      encode(credential.ID),
+		ClientDataJSON:    encode(clientDataJSON),
+		AuthenticatorData: encode(authData),
+		Signature:         encode(signature),
+		UserHandle:        encode(credential.UserHandle),
+	}, nil
+}
+
+func (a *Authenticator) authenticatorData(flags byte, signCount uint32, attested []byte) []byte {
+	flags |= pkg.AuthenticatorFlagUserPresent
+	if a.UserVerified {
+		flags |= pkg.AuthenticatorFlagUserVerified
+	}
+
+	rpIDHash := sha256.Sum256([]byte(a.RPID))
+	data := append(rpIDHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, signCount)
+	return append(data, attested...)
+}
+
+func (a *Authenticator) clientData(ceremony, challenge string) ([]byte, error) {
+	return json.Marshal(pkg.CollectedClientData{
+		Type:      ceremony,
+		Challenge: challenge,
+		Origin:    a.Origin,
+	})
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// The helpers below encode the few CBOR items needed for attestation objects and COSE keys
+
+func encodeHead(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n <= 0xff:
+		return []byte{major<<5 | 24, byte(n)}
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+	case n <= 0xffffffff:
+		return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(n))
+	}
+	return binary.BigEndian.AppendUint64([]byte{major<<5 | 27}, n)
+}
+
+func encodeInt(n int64) []byte {
+	if n < 0 {
+		return encodeHead(1, uint64(-1-n))
+	}
+	return encodeHead(0, uint64(n))
+}
+
+func encodeBytes(b []byte) []byte {
+	return append(encodeHead(2, uint64(len(b))), b...)
+}
+
+func encodeText(s string) []byte {
+	return append(encodeHead(3, uint64(len(s))), s...)
+}
+
+// encodeMap encodes alternating keys and values, in the given order
+func encodeMap(items ...[]byte) []byte {
+	data := encodeHead(5, uint64(len(items)/2))
+	for _, item := range items {
+		data = append(data, item...)
+	}
+	return data
+}
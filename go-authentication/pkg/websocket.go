@@ -9,6 +9,8 @@ import (
 type WebSocketMessage struct {
 	Type string          `json:"type"`
 	Data json.RawMessage `json:"data"`
+	// Delivered is called once the message was written to the connection, if set
+	Delivered func() `json:"-"`
 }
 
 // Client represents a connected WebSocket client
@@ -19,6 +21,12 @@ type Client struct {
 	Send chan WebSocketMessage
 	// UserID from authentication
 	ID int
+	// SessionID of the access token that opened the connection, empty for API keys
+	SessionID string
+	// ConnectionID tells the connections of a user apart in their presence
+	ConnectionID string
+	// Done is closed once the connection is gone; nothing reads Send afterwards
+	Done chan struct{}
 }
 
 // NewClient creates a new WebSocket client
@@ -27,6 +35,7 @@ func NewClient(conn *websocket.Conn, userID int) *Client {
 		Conn: conn,
 		Send: make(chan WebSocketMessage, 256),
 		ID:   userID,
+		Done: make(chan struct{}),
 	}
 }
 
@@ -0,0 +1,207 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"go-authentication/internal/delivery"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/repository"
+	"go-authentication/internal/usecase"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Mock API key repository for testing
+type mockAPIKeyRepo struct {
+	keys   map[int]*domain.APIKey
+	nextID int
+}
+
+func newMockAPIKeyRepo() *mockAPIKeyRepo {
+	return &mockAPIKeyRepo{keys: make(map[int]*domain.APIKey), nextID: 1}
+}
+
+func (m *mockAPIKeyRepo) Create(ctx context.Context, key *domain.APIKey) error {
+	key.ID = m.nextID
+	key.CreatedAt = time.Now()
+	m.nextID++
+	stored := *key
+	m.keys[key.ID] = &stored
+	return nil
+}
+
+func (m *mockAPIKeyRepo) GetByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error) {
+	for _, key := range m.keys {
+		if key.Prefix == prefix {
+			found := *key
+			return &found, nil
+		}
+	}
+	return nil, repository.ErrAPIKeyNotFound
+}
+
+func (m *mockAPIKeyRepo) ListByUser(ctx context.Context, userID int) ([]domain.APIKey, error) {
+	keys := []domain.APIKey{}
+	for _, key := range m.keys {
+		if key.UserID == userID {
+			keys = append(keys, *key)
+		}
+	}
+	return keys, nil
+}
+
+func (m *mockAPIKeyRepo) Revoke(ctx context.Context, id, userID int) error {
+	key, exists := m.keys[id]
+	if !exists || key.UserID != userID || key.RevokedAt != nil {
+		return repository.ErrAPIKeyNotFound
+	}
+	now := time.Now()
+	key.RevokedAt = &now
+	return nil
+}
+
+func (m *mockAPIKeyRepo) TouchLastUsed(ctx context.Context, id int, usedAt time.Time) error {
+	if key, exists := m.keys[id]; exists {
+		key.LastUsedAt = &usedAt
+	}
+	return nil
+}
+
+func TestAPIKeys(t *testing.T) {
+	repo := &mockAuthUserRepo{users: make(map[int]*domain.User)}
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), &recordingMailer{})
+	apiKeys := authUsecase.APIKeys
+	keyRepo := apiKeys.APIKeyRepo.(*mockAPIKeyRepo)
+	ctx := context.Background()
+
+	admin := signupVerified(t, authUsecase, repo, "Admin", "admin@example.com", "correct horse battery")
+	member := signupVerified(t, authUsecase, repo, "Member", "member@example.com", "correct horse battery")
+
+	// A key cannot carry permissions its owner does not hold
+	var scopeErr *usecase.InvalidScopeError
+	_, err := apiKeys.Create(ctx, member.ID, &domain.CreateAPIKeyRequest{Name: "bot", Scopes: []string{domain.PermissionUsersUnlock}})
+	if !errors.As(err, &scopeErr) {
+		t.Errorf("Create() with ungranted scope error = %v, want *InvalidScopeError", err)
+	}
+	if _, err := apiKeys.Create(ctx, member.ID, &domain.CreateAPIKeyRequest{Name: "bot", ExpiresInDays: 1000}); !errors.Is(err, usecase.ErrInvalidAPIKeyExpiry) {
+		t.Errorf("Create() with long expiry error = %v, want %v", err, usecase.ErrInvalidAPIKeyExpiry)
+	}
+
+	created, err := apiKeys.Create(ctx, admin.ID, &domain.CreateAPIKeyRequest{Name: "moderation bot", Scopes: []string{domain.PermissionMessagesModerate}})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if !strings.HasPrefix(created.Key, created.Prefix+".") {
+		t.Errorf("Create() key %q does not start with its prefix %q", created.Key, created.Prefix)
+	}
+	if stored := keyRepo.keys[created.ID]; strings.Contains(created.Key, stored.KeyHash) || stored.KeyHash == "" {
+		t.Error("Create() did not store a hash of the key")
+	}
+	if days := time.Until(created.ExpiresAt).Hours() / 24; days < 89 || days > 90 {
+		t.Errorf("Create() default expiry = %.1f days, want 90", days)
+	}
+
+	principal, err := apiKeys.Authenticate(ctx, created.Key)
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if principal.UserID != admin.ID || !principal.EmailVerified {
+		t.Errorf("Authenticate() principal = %+v, want verified user %d", principal, admin.ID)
+	}
+	if len(principal.Permissions) != 1 || principal.Permissions[0] != domain.PermissionMessagesModerate {
+		t.Errorf("Authenticate() permissions = %v, want only the key's scopes", principal.Permissions)
+	}
+	if keyRepo.keys[created.ID].LastUsedAt == nil {
+		t.Error("Authenticate() did not record the last use")
+	}
+
+	// Tampering with the secret is rejected even though the prefix matches
+	if _, err := apiKeys.Authenticate(ctx, created.Key+"x"); !errors.Is(err, usecase.ErrInvalidAPIKey) {
+		t.Errorf("Authenticate() with wrong secret error = %v, want %v", err, usecase.ErrInvalidAPIKey)
+	}
+
+	// Losing a role narrows the permissions of keys created with it
+	if _, err := authUsecase.Roles.RemoveRole(ctx, admin.ID, domain.RoleAdmin); err != nil {
+		t.Fatalf("RemoveRole() error = %v", err)
+	}
+	if principal, err = apiKeys.Authenticate(ctx, created.Key); err != nil || len(principal.Permissions) != 0 {
+		t.Errorf("Authenticate() after role removal = %v, %v, want no permissions", principal, err)
+	}
+
+	if err := apiKeys.Revoke(ctx, member.ID, created.ID); !errors.Is(err, usecase.ErrAPIKeyNotFound) {
+		t.Errorf("Revoke() of another user's key error = %v, want %v", err, usecase.ErrAPIKeyNotFound)
+	}
+	if err := apiKeys.Revoke(ctx, admin.ID, created.ID); err != nil {
+		t.Fatalf("Revoke() error = %v", err)
+	}
+	if _, err := apiKeys.Authenticate(ctx, created.Key); !errors.Is(err, usecase.ErrInvalidAPIKey) {
+		t.Errorf("Authenticate() after revoke error = %v, want %v", err, usecase.ErrInvalidAPIKey)
+	}
+
+	expiring, err := apiKeys.Create(ctx, member.ID, &domain.CreateAPIKeyRequest{Name: "ci", ExpiresInDays: 1})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	keyRepo.keys[expiring.ID].ExpiresAt = time.Now().Add(-time.Second)
+	if _, err := apiKeys.Authenticate(ctx, expiring.Key); !errors.Is(err, usecase.ErrInvalidAPIKey) {
+		t.Errorf("Authenticate() with expired key error = %v, want %v", err, usecase.ErrInvalidAPIKey)
+	}
+}
+
+func TestAPIKeyAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := &mockAuthUserRepo{users: make(map[int]*domain.User)}
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), &recordingMailer{})
+	admin := signupVerified(t, authUsecase, repo, "Admin", "admin@example.com", "correct horse battery")
+
+	scoped, err := authUsecase.APIKeys.Create(context.Background(), admin.ID, &domain.CreateAPIKeyRequest{Name: "moderation bot", Scopes: []string{domain.PermissionMessagesModerate}})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	unscoped, err := authUsecase.APIKeys.Create(context.Background(), admin.ID, &domain.CreateAPIKeyRequest{Name: "chat bot"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	router := gin.New()
+	router.Use(delivery.AuthMiddleware(authUsecase))
+	router.GET("/moderation", delivery.RequirePermission(domain.PermissionMessagesModerate), func(c *gin.Context) {
+		if userID, _ := c.Get("user_id"); userID != admin.ID {
+			t.Errorf("user_id in context = %v, want %d", userID, admin.ID)
+		}
+		c.Status(http.StatusNoContent)
+	})
+	router.GET("/api-keys", delivery.RequireTokenAuth(), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name   string
+		path   string
+		header string
+		want   int
+	}{
+		{"Scoped key holds the permission", "/moderation", "ApiKey " + scoped.Key, http.StatusNoContent},
+		{"Unscoped key is forbidden", "/moderation", "ApiKey " + unscoped.Key, http.StatusForbidden},
+		{"Unknown key is rejected", "/moderation", "ApiKey gak_unknown.secret", http.StatusUnauthorized},
+		{"Key cannot manage keys", "/api-keys", "ApiKey " + scoped.Key, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("Authorization", tt.header)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("GET %s status = %d, want %d", tt.path, w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/usecase"
+	"slices"
+	"testing"
+	"time"
+)
+
+// Mock audit log repository for testing; it keeps events in insertion order
+type mockAuthEventRepo struct {
+	events []domain.AuthEvent
+}
+
+func newMockAuthEventRepo() *mockAuthEventRepo {
+	return &mockAuthEventRepo{}
+}
+
+func (m *mockAuthEventRepo) Create(ctx context.Context, event *domain.AuthEvent) error {
+	event.ID = int64(len(m.events) + 1)
+	event.CreatedAt = time.Now()
+	m.events = append(m.events, *event)
+	return nil
+}
+
+func (m *mockAuthEventRepo) List(ctx context.Context, filter *domain.AuthEventFilter) ([]domain.AuthEvent, error) {
+	events := []domain.AuthEvent{}
+	for i := len(m.events) - 1; i >= 0 && len(events) < filter.Limit; i-- {
+		event := m.events[i]
+		if filter.UserID != nil && (event.UserID == nil || *event.UserID != *filter.UserID) {
+			continue
+		}
+		if len(filter.Types) > 0 && !slices.Contains(filter.Types, event.Type) {
+			continue
+		}
+		if filter.IP != "" && event.IP != filter.IP {
+			continue
+		}
+		if !filter.Since.IsZero() && event.CreatedAt.Before(filter.Since) {
+			continue
+		}
+		if !filter.Until.IsZero() && !event.CreatedAt.Before(filter.Until) {
+			continue
+		}
+		if filter.Before > 0 && event.ID >= filter.Before {
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// auditEvents returns the events recorded by authUsecase of the given type, oldest first
+func auditEvents(authUsecase *usecase.AuthUsecase, eventType string) []domain.AuthEvent {
+	var events []domain.AuthEvent
+	for _, event := range authUsecase.Audit.AuditRepo.(*mockAuthEventRepo).events {
+		if event.Type == eventType {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func TestAuditLoginEvents(t *testing.T) {
+	repo := &mockAuthUserRepo{users: make(map[int]*domain.User)}
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), &recordingMailer{})
+	ctx := context.Background()
+	client := domain.ClientInfo{IP: "203.0.113.7", UserAgent: "audit-test"}
+
+	user := signupVerified(t, authUsecase, repo, "Audited", "audited@example.com", "correct horse battery")
+	if signups := auditEvents(authUsecase, domain.AuthEventSignup); len(signups) != 1 || *signups[0].UserID != user.ID {
+		t.Fatalf("Expected one signup event for user %d, got %+v", user.ID, signups)
+	}
+
+	if _, err := authUsecase.Login(ctx, "nobody@example.com", "correct horse battery", client); err == nil {
+		t.Fatal("Expected login with an unknown email to fail")
+	}
+	if _, err := authUsecase.Login(ctx, user.Email, "wrong password", client); err == nil {
+		t.Fatal("Expected login with a wrong password to fail")
+	}
+
+	failures := auditEvents(authUsecase, domain.AuthEventLoginFailed)
+	if len(failures) != 2 {
+		t.Fatalf("Expected 2 login_failed events, got %d", len(failures))
+	}
+	if failures[0].UserID != nil || failures[0].Metadata["reason"] != "unknown_email" || failures[0].Metadata["email"] != "nobody@example.com" {
+		t.Errorf("Unexpected unknown email event: %+v", failures[0])
+	}
+	if failures[1].UserID == nil || *failures[1].UserID != user.ID || failures[1].Metadata["reason"] != "invalid_password" {
+		t.Errorf("Unexpected wrong password event: %+v", failures[1])
+	}
+	if failures[1].IP != client.IP || failures[1].UserAgent != client.UserAgent {
+		t.Errorf("Expected the client to be recorded, got ip=%q user_agent=%q", failures[1].IP, failures[1].UserAgent)
+	}
+
+	tokens, err := authUsecase.Login(ctx, user.Email, "correct horse battery", client)
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	successes := auditEvents(authUsecase, domain.AuthEventLoginSucceeded)
+	if len(successes) != 1 || successes[0].Metadata["method"] != domain.LoginMethodPassword || successes[0].IP != client.IP {
+		t.Fatalf("Unexpected login_succeeded events: %+v", successes)
+	}
+
+	claims, err := authUsecase.ValidateAccessToken(ctx, tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("ValidateAccessToken() error = %v", err)
+	}
+	if err := authUsecase.Logout(ctx, user.ID, claims, tokens.RefreshToken); err != nil {
+		t.Fatalf("Logout() error = %v", err)
+	}
+	if logouts := auditEvents(authUsecase, domain.AuthEventLogout); len(logouts) != 1 || logouts[0].Metadata["session_id"] != successes[0].Metadata["session_id"] {
+		t.Errorf("Expected a logout event for the session, got %+v", logouts)
+	}
+}
+
+func TestAuditActorAndClientFromContext(t *testing.T) {
+	repo := &mockAuthUserRepo{users: make(map[int]*domain.User)}
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), &recordingMailer{})
+
+	admin := signupVerified(t, authUsecase, repo, "Admin", "admin@example.com", "correct horse battery")
+	member := signupVerified(t, authUsecase, repo, "Member", "member@example.com", "correct horse battery")
+
+	ctx := domain.ContextWithClientInfo(context.Background(), domain.ClientInfo{IP: "198.51.100.1", UserAgent: "admin-console"})
+	ctx = domain.ContextWithActor(ctx, admin.ID)
+	if _, err := authUsecase.Roles.AssignRole(ctx, member.ID, domain.RoleAdmin); err != nil {
+		t.Fatalf("AssignRole() error = %v", err)
+	}
+
+	assigned := auditEvents(authUsecase, domain.AuthEventRoleAssigned)
+	if len(assigned) != 1 {
+		t.Fatalf("Expected one role_assigned event, got %d", len(assigned))
+	}
+	event := assigned[0]
+	if *event.UserID != member.ID || event.ActorID == nil || *event.ActorID != admin.ID {
+		t.Errorf("Expected user %d changed by actor %d, got %+v", member.ID, admin.ID, event)
+	}
+	if event.IP != "198.51.100.1" || event.UserAgent != "admin-console" || event.Metadata["role"] != domain.RoleAdmin {
+		t.Errorf("Unexpected role_assigned event: %+v", event)
+	}
+
+	// A user acting on their own account is not recorded as a separate actor
+	ctx = domain.ContextWithActor(context.Background(), member.ID)
+	if err := authUsecase.LogoutEverywhere(ctx, member.ID); err != nil {
+		t.Fatalf("LogoutEverywhere() error = %v", err)
+	}
+	if logouts := auditEvents(authUsecase, domain.AuthEventLogoutAll); len(logouts) != 1 || logouts[0].ActorID != nil {
+		t.Errorf("Expected a logout_all event without an actor, got %+v", logouts)
+	}
+}
+
+func TestAuditListForUser(t *testing.T) {
+	repo := &mockAuthUserRepo{users: make(map[int]*domain.User)}
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), &recordingMailer{})
+	ctx := context.Background()
+
+	user := signupVerified(t, authUsecase, repo, "Paged", "paged@example.com", "correct horse battery")
+	signupVerified(t, authUsecase, repo, "Other", "other@example.com", "correct horse battery")
+	for i := 0; i < 4; i++ {
+		if _, err := authUsecase.Login(ctx, user.Email, "wrong password", domain.ClientInfo{}); err == nil {
+			t.Fatal("Expected login with a wrong password to fail")
+		}
+	}
+
+	// signup plus 4 failures, newest first
+	first, err := authUsecase.Audit.ListForUser(ctx, user.ID, 0, 3)
+	if err != nil {
+		t.Fatalf("ListForUser() error = %v", err)
+	}
+	if len(first.Events) != 3 || first.NextBefore == 0 {
+		t.Fatalf("Expected a full first page with a cursor, got %+v", first)
+	}
+	if first.Events[0].ID <= first.Events[1].ID {
+		t.Errorf("Expected events newest first")
+	}
+
+	second, err := authUsecase.Audit.ListForUser(ctx, user.ID, first.NextBefore, 3)
+	if err != nil {
+		t.Fatalf("ListForUser() error = %v", err)
+	}
+	if len(second.Events) != 2 || second.NextBefore != 0 {
+		t.Fatalf("Expected a last page of 2 events, got %+v", second)
+	}
+	if second.Events[1].Type != domain.AuthEventSignup {
+		t.Errorf("Expected the oldest event to be the signup, got %s", second.Events[1].Type)
+	}
+	for _, event := range append(first.Events, second.Events...) {
+		if *event.UserID != user.ID {
+			t.Errorf("Listed an event of user %d", *event.UserID)
+		}
+	}
+
+	if _, err := authUsecase.Audit.ListForUser(ctx, user.ID, 0, 500); !errors.Is(err, usecase.ErrInvalidAuthEventQuery) {
+		t.Errorf("Expected ErrInvalidAuthEventQuery for an oversized page, got %v", err)
+	}
+}
+
+func TestAuditQuery(t *testing.T) {
+	repo := &mockAuthUserRepo{users: make(map[int]*domain.User)}
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), &recordingMailer{})
+	ctx := context.Background()
+
+	user := signupVerified(t, authUsecase, repo, "Queried", "queried@example.com", "correct horse battery")
+	if _, err := authUsecase.Login(ctx, user.Email, "wrong password", domain.ClientInfo{IP: "192.0.2.10"}); err == nil {
+		t.Fatal("Expected login with a wrong password to fail")
+	}
+	if _, err := authUsecase.Login(ctx, user.Email, "correct horse battery", domain.ClientInfo{IP: "192.0.2.20"}); err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+
+	page, err := authUsecase.Audit.Query(ctx, &domain.AuthEventQuery{Type: "login_failed, login_succeeded"})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(page.Events) != 2 || page.Events[0].Type != domain.AuthEventLoginSucceeded {
+		t.Fatalf("Expected both login events newest first, got %+v", page.Events)
+	}
+
+	page, err = authUsecase.Audit.Query(ctx, &domain.AuthEventQuery{UserID: &user.ID, IP: "192.0.2.10"})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(page.Events) != 1 || page.Events[0].Type != domain.AuthEventLoginFailed {
+		t.Errorf("Expected only the failed login from 192.0.2.10, got %+v", page.Events)
+	}
+
+	page, err = authUsecase.Audit.Query(ctx, &domain.AuthEventQuery{Since: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(page.Events) != 0 {
+		t.Errorf("Expected no events in the future, got %d", len(page.Events))
+	}
+
+	now := time.Now()
+	if _, err := authUsecase.Audit.Query(ctx, &domain.AuthEventQuery{Since: now, Until: now.Add(-time.Minute)}); !errors.Is(err, usecase.ErrInvalidAuthEventQuery) {
+		t.Errorf("Expected ErrInvalidAuthEventQuery for an empty time range, got %v", err)
+	}
+}
@@ -3,8 +3,15 @@ package tests
 import (
 	"context"
 	"errors"
+	"go-authentication/config"
 	"go-authentication/internal/domain"
+	"go-authentication/internal/repository"
+	"go-authentication/internal/services"
 	"go-authentication/internal/usecase"
+	"go-authentication/pkg"
+	"net/url"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
 
@@ -26,7 +33,7 @@ func (m *mockAuthUserRepo) Create(ctx context.Context, user *domain.User) error
 
 func (m *mockAuthUserRepo) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
 	for _, user := range m.users {
-		if user.Email == email {
+		if strings.EqualFold(user.Email, email) {
 			return user, nil
 		}
 	}
@@ -41,12 +48,184 @@ func (m *mockAuthUserRepo) GetByID(ctx context.Context, id int) (*domain.User, e
 	return user, nil
 }
 
+func (m *mockAuthUserRepo) UpdatePassword(ctx context.Context, id int, passwordHash string) error {
+	user, exists := m.users[id]
+	if !exists {
+		return errors.New("user not found")
+	}
+	user.Password = passwordHash
+	return nil
+}
+
+func (m *mockAuthUserRepo) MarkEmailVerified(ctx context.Context, id int, verifiedAt time.Time) error {
+	user, exists := m.users[id]
+	if !exists {
+		return errors.New("user not found")
+	}
+	user.EmailVerifiedAt = &verifiedAt
+	return nil
+}
+
+func (m *mockAuthUserRepo) UpdateProfile(ctx context.Context, user *domain.User) error {
+	stored, exists := m.users[user.ID]
+	if !exists {
+		return errors.New("user not found")
+	}
+	stored.Name, stored.DisplayName, stored.Bio, stored.StatusText = user.Name, user.DisplayName, user.Bio, user.StatusText
+	stored.UpdatedAt = time.Now()
+	user.UpdatedAt = stored.UpdatedAt
+	return nil
+}
+
+func (m *mockAuthUserRepo) UpdateEmail(ctx context.Context, id int, email string, verifiedAt time.Time) error {
+	for _, user := range m.users {
+		if user.Email == email && user.ID != id {
+			return repository.ErrEmailTaken
+		}
+	}
+	user, exists := m.users[id]
+	if !exists {
+		return errors.New("user not found")
+	}
+	user.Email = email
+	user.EmailVerifiedAt = &verifiedAt
+	return nil
+}
+
+// recordingMailer captures outgoing emails instead of delivering them
+type recordingMailer struct {
+	sent []services.Email
+}
+
+func (m *recordingMailer) Send(ctx context.Context, email services.Email) error {
+	m.sent = append(m.sent, email)
+	return nil
+}
+
+// lastLinkToken extracts the token query parameter from the link in the last email sent
+func (m *recordingMailer) lastLinkToken(t *testing.T) string {
+	t.Helper()
+	if len(m.sent) == 0 {
+		t.Fatal("no email was sent")
+	}
+	match := regexp.MustCompile(`token=([^\s]+)`).FindStringSubmatch(m.sent[len(m.sent)-1].Body)
+	if match == nil {
+		t.Fatal("email does not contain a token link")
+	}
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		t.Fatalf("Failed to unescape token: %v", err)
+	}
+	return token
+}
+
+// newTestAuthUsecase wires an AuthUsecase with in-memory dependencies
+func newTestAuthUsecase(t *testing.T, repo repository.UserRepository, refreshRepo repository.RefreshTokenRepository, mailer services.Mailer) *usecase.AuthUsecase {
+	t.Helper()
+	tokens := newTestTokenService(t)
+	hasher := newTestPasswordHasher(t)
+	audit := usecase.NewAuditUsecase(newMockAuthEventRepo())
+	verification := usecase.NewVerificationUsecase(repo, tokens, mailer, "http://localhost:8081", audit)
+	lockout := usecase.NewLockoutUsecase(newMockLoginThrottleRepo(), repo, testLockoutPolicy, audit)
+	mfa := usecase.NewMFAUsecase(newMockMFARepo(), repo, lockout, hasher, "go-authentication-test", audit)
+	revocations := repository.NewMemoryRevocationStore()
+	roles := usecase.NewRoleUsecase(newMockRoleRepo(), repo, revocations, "admin@example.com", audit)
+	apiKeys := usecase.NewAPIKeyUsecase(newMockAPIKeyRepo(), repo, roles, audit)
+	sessions := usecase.NewSessionUsecase(newMockSessionRepo(), refreshRepo, revocations, services.NewLocalSessionNotifier(), tokens, audit)
+	passkeys := usecase.NewPasskeyUsecase(newMockPasskeyRepo(), repo, newTestRelyingParty(t), audit)
+	return usecase.NewAuthorizaationcase(repo, refreshRepo, revocations, tokens, verification, mfa, lockout, newTestPasswordPolicy(t), hasher, roles, apiKeys, sessions, passkeys, audit)
+}
+
+// Mock refresh token repository for testing
+type mockRefreshTokenRepo struct {
+	tokens map[int]*domain.RefreshToken
+}
+
+func newMockRefreshTokenRepo() *mockRefreshTokenRepo {
+	return &mockRefreshTokenRepo{tokens: make(map[int]*domain.RefreshToken)}
+}
+
+func (m *mockRefreshTokenRepo) Create(ctx context.Context, token *domain.RefreshToken) error {
+	token.ID = len(m.tokens) + 1
+	token.CreatedAt = time.Now()
+	m.tokens[token.ID] = token
+	return nil
+}
+
+func (m *mockRefreshTokenRepo) GetByHash(ctx context.Context, tokenHash string) (*domain.RefreshToken, error) {
+	for _, token := range m.tokens {
+		if token.TokenHash == tokenHash {
+			copied := *token
+			return &copied, nil
+		}
+	}
+	return nil, errors.New("refresh token not found")
+}
+
+func (m *mockRefreshTokenRepo) Rotate(ctx context.Context, oldTokenID int, next *domain.RefreshToken) error {
+	old, exists := m.tokens[oldTokenID]
+	if !exists || old.RotatedAt != nil || old.RevokedAt != nil {
+		return repository.ErrRefreshTokenConsumed
+	}
+	if err := m.Create(ctx, next); err != nil {
+		return err
+	}
+	now := time.Now()
+	old.RotatedAt = &now
+	old.ReplacedBy = &next.ID
+	return nil
+}
+
+func (m *mockRefreshTokenRepo) RevokeAllForUser(ctx context.Context, userID int) error {
+	now := time.Now()
+	for _, token := range m.tokens {
+		if token.UserID == userID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (m *mockRefreshTokenRepo) RevokeFamily(ctx context.Context, familyID string) error {
+	now := time.Now()
+	for _, token := range m.tokens {
+		if token.FamilyID == familyID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+// newTestTokenService returns a TokenService backed by a throwaway HS256 key
+func newTestTokenService(t *testing.T) *pkg.TokenService {
+	t.Helper()
+	keys, err := pkg.NewKeySet(pkg.NewHMACKey("test", []byte("test-secret")))
+	if err != nil {
+		t.Fatalf("Failed to create key set: %v", err)
+	}
+	return newTokenServiceWithKeys(t, keys)
+}
+
+// newTokenServiceWithKeys returns a TokenService using the default test configuration
+func newTokenServiceWithKeys(t *testing.T, keys *pkg.KeySet) *pkg.TokenService {
+	t.Helper()
+	tokens, err := pkg.NewTokenService(&config.Config{
+		JWTIssuer:     "go-authentication-test",
+		JWTAudience:   "go-authentication-test",
+		JWTExpiration: "1",
+	}, keys)
+	if err != nil {
+		t.Fatalf("Failed to create token service: %v", err)
+	}
+	return tokens
+}
+
 func TestSignup(t *testing.T) {
 	// Initialize mock repository
 	repo := &mockAuthUserRepo{
 		users: make(map[int]*domain.User),
 	}
-	authUsecase := usecase.NewAuthorizaationcase(repo)
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), &recordingMailer{})
 
 	tests := []struct {
 		name    string
@@ -120,6 +299,7 @@ func TestLogin(t *testing.T) {
 	}
 
 	// Initialize mock repository with a test user
+	verifiedAt := time.Now()
 	repo := &mockAuthUserRepo{
 		users: map[int]*domain.User{
 			1: {
@@ -128,10 +308,12 @@ func TestLogin(t *testing.T) {
 				Email:     "test@example.com",
 				Password:  string(hashedPassword),
 				CreatedAt: time.Now(),
+				// Login is only allowed once the email address is verified
+				EmailVerifiedAt: &verifiedAt,
 			},
 		},
 	}
-	authUsecase := usecase.NewAuthorizaationcase(repo)
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), &recordingMailer{})
 
 	tests := []struct {
 		name     string
@@ -167,13 +349,166 @@ func TestLogin(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			token, err := authUsecase.Login(context.Background(), tt.email, tt.password)
+			tokens, err := authUsecase.Login(context.Background(), tt.email, tt.password, domain.ClientInfo{})
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Login() error = %v, wantErr %v", err, tt.wantErr)
 			}
-			if !tt.wantErr && token == "" {
-				t.Error("Login() returned empty token when no error expected")
+			if !tt.wantErr && (tokens == nil || tokens.AccessToken == "" || tokens.RefreshToken == "") {
+				t.Error("Login() returned empty tokens when no error expected")
 			}
 		})
 	}
 }
+
+func TestRefreshTokenRotation(t *testing.T) {
+	testPassword := "password123456"
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.DefaultCost)
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+
+	verifiedAt := time.Now()
+	repo := &mockAuthUserRepo{
+		users: map[int]*domain.User{
+			1: {ID: 1, Name: "Test User", Email: "test@example.com", Password: string(hashedPassword), EmailVerifiedAt: &verifiedAt},
+		},
+	}
+	refreshRepo := newMockRefreshTokenRepo()
+	authUsecase := newTestAuthUsecase(t, repo, refreshRepo, &recordingMailer{})
+
+	login, err := authUsecase.Login(context.Background(), "test@example.com", testPassword, domain.ClientInfo{})
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+
+	rotated, err := authUsecase.RefreshToken(context.Background(), login.RefreshToken)
+	if err != nil {
+		t.Fatalf("RefreshToken() error = %v", err)
+	}
+	if rotated.RefreshToken == login.RefreshToken {
+		t.Fatal("RefreshToken() did not rotate the refresh token")
+	}
+
+	// Replaying the original token must be detected and revoke the whole family
+	if _, err := authUsecase.RefreshToken(context.Background(), login.RefreshToken); !errors.Is(err, usecase.ErrRefreshTokenReused) {
+		t.Fatalf("RefreshToken() replay error = %v, want %v", err, usecase.ErrRefreshTokenReused)
+	}
+	if _, err := authUsecase.RefreshToken(context.Background(), rotated.RefreshToken); err == nil {
+		t.Fatal("RefreshToken() accepted a token from a revoked family")
+	}
+
+	if _, err := authUsecase.RefreshToken(context.Background(), "not-a-real-token"); !errors.Is(err, usecase.ErrInvalidRefreshToken) {
+		t.Errorf("RefreshToken() unknown token error = %v, want %v", err, usecase.ErrInvalidRefreshToken)
+	}
+}
+
+func TestLogoutRevokesTokens(t *testing.T) {
+	testPassword := "password123456"
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.DefaultCost)
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+
+	verifiedAt := time.Now()
+	repo := &mockAuthUserRepo{
+		users: map[int]*domain.User{
+			1: {ID: 1, Name: "Test User", Email: "test@example.com", Password: string(hashedPassword), EmailVerifiedAt: &verifiedAt},
+		},
+	}
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), &recordingMailer{})
+	ctx := context.Background()
+
+	first, err := authUsecase.Login(ctx, "test@example.com", testPassword, domain.ClientInfo{})
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	second, err := authUsecase.Login(ctx, "test@example.com", testPassword, domain.ClientInfo{})
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+
+	claims, err := authUsecase.ValidateAccessToken(ctx, first.AccessToken)
+	if err != nil {
+		t.Fatalf("ValidateAccessToken() error = %v", err)
+	}
+
+	// Logging out one session must not affect the other
+	if err := authUsecase.Logout(ctx, 1, claims, first.RefreshToken); err != nil {
+		t.Fatalf("Logout() error = %v", err)
+	}
+	if _, err := authUsecase.ValidateAccessToken(ctx, first.AccessToken); !errors.Is(err, usecase.ErrTokenRevoked) {
+		t.Errorf("ValidateAccessToken() after logout error = %v, want %v", err, usecase.ErrTokenRevoked)
+	}
+	if _, err := authUsecase.RefreshToken(ctx, first.RefreshToken); err == nil {
+		t.Error("RefreshToken() accepted a refresh token after logout")
+	}
+	if _, err := authUsecase.ValidateAccessToken(ctx, second.AccessToken); err != nil {
+		t.Errorf("ValidateAccessToken() for other session error = %v", err)
+	}
+
+	if err := authUsecase.LogoutEverywhere(ctx, 1); err != nil {
+		t.Fatalf("LogoutEverywhere() error = %v", err)
+	}
+	if _, err := authUsecase.ValidateAccessToken(ctx, second.AccessToken); !errors.Is(err, usecase.ErrTokenRevoked) {
+		t.Errorf("ValidateAccessToken() after logout everywhere error = %v, want %v", err, usecase.ErrTokenRevoked)
+	}
+	if _, err := authUsecase.RefreshToken(ctx, second.RefreshToken); err == nil {
+		t.Error("RefreshToken() accepted a refresh token after logout everywhere")
+	}
+}
+
+func TestEmailVerification(t *testing.T) {
+	repo := &mockAuthUserRepo{users: make(map[int]*domain.User)}
+	mailer := &recordingMailer{}
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), mailer)
+	ctx := context.Background()
+
+	user := &domain.User{Name: "Test User", Email: "test@example.com", Password: "password123456"}
+	if err := authUsecase.Signup(ctx, user); err != nil {
+		t.Fatalf("Signup() error = %v", err)
+	}
+	if len(mailer.sent) != 1 || mailer.sent[0].To != "test@example.com" {
+		t.Fatalf("Signup() sent %d emails, want one verification email to test@example.com", len(mailer.sent))
+	}
+
+	if _, err := authUsecase.Login(ctx, "test@example.com", "password123456", domain.ClientInfo{}); !errors.Is(err, usecase.ErrEmailNotVerified) {
+		t.Fatalf("Login() before verification error = %v, want %v", err, usecase.ErrEmailNotVerified)
+	}
+
+	// Resending is silent for unknown addresses
+	if err := authUsecase.Verification.ResendVerification(ctx, "nobody@example.com"); err != nil {
+		t.Errorf("ResendVerification() unknown address error = %v", err)
+	}
+	if err := authUsecase.Verification.ResendVerification(ctx, "test@example.com"); err != nil {
+		t.Fatalf("ResendVerification() error = %v", err)
+	}
+	if len(mailer.sent) != 2 {
+		t.Fatalf("ResendVerification() sent %d emails in total, want 2", len(mailer.sent))
+	}
+
+	if err := authUsecase.Verification.VerifyEmail(ctx, "garbage"); !errors.Is(err, usecase.ErrInvalidVerificationToken) {
+		t.Errorf("VerifyEmail() garbage token error = %v, want %v", err, usecase.ErrInvalidVerificationToken)
+	}
+
+	// A verification token must not be usable as an access token
+	token := mailer.lastLinkToken(t)
+	if _, err := authUsecase.ValidateAccessToken(ctx, token); err == nil {
+		t.Error("ValidateAccessToken() accepted an email verification token")
+	}
+
+	if err := authUsecase.Verification.VerifyEmail(ctx, token); err != nil {
+		t.Fatalf("VerifyEmail() error = %v", err)
+	}
+
+	tokens, err := authUsecase.Login(ctx, "test@example.com", "password123456", domain.ClientInfo{})
+	if err != nil {
+		t.Fatalf("Login() after verification error = %v", err)
+	}
+	claims, err := authUsecase.ValidateAccessToken(ctx, tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("ValidateAccessToken() error = %v", err)
+	}
+	if claims["email_verified"] != true {
+		t.Errorf("access token email_verified = %v, want true", claims["email_verified"])
+	}
+}
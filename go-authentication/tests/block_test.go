@@ -0,0 +1,214 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/repository"
+	"go-authentication/internal/usecase"
+	"testing"
+	"time"
+)
+
+// Mock block list repository for testing; pairs are [blocker, blocked]
+type mockBlockRepo struct {
+	blocks map[[2]int]time.Time
+}
+
+func newMockBlockRepo() *mockBlockRepo {
+	return &mockBlockRepo{blocks: make(map[[2]int]time.Time)}
+}
+
+func (m *mockBlockRepo) Block(ctx context.Context, blockerID, blockedID int) error {
+	if _, exists := m.blocks[[2]int{blockerID, blockedID}]; !exists {
+		m.blocks[[2]int{blockerID, blockedID}] = time.Now()
+	}
+	return nil
+}
+
+func (m *mockBlockRepo) Unblock(ctx context.Context, blockerID, blockedID int) error {
+	if _, exists := m.blocks[[2]int{blockerID, blockedID}]; !exists {
+		return repository.ErrBlockNotFound
+	}
+	delete(m.blocks, [2]int{blockerID, blockedID})
+	return nil
+}
+
+func (m *mockBlockRepo) ListBlocked(ctx context.Context, blockerID int) ([]domain.BlockedUser, error) {
+	blocked := []domain.BlockedUser{}
+	for pair, blockedAt := range m.blocks {
+		if pair[0] == blockerID {
+			blocked = append(blocked, domain.BlockedUser{PublicProfile: domain.PublicProfile{ID: pair[1]}, BlockedAt: blockedAt})
+		}
+	}
+	return blocked, nil
+}
+
+func (m *mockBlockRepo) IsBlocked(ctx context.Context, blockerID, blockedID int) (bool, error) {
+	_, exists := m.blocks[[2]int{blockerID, blockedID}]
+	return exists, nil
+}
+
+// Mock conversation mute repository for testing; pairs are [user, conversation]
+type mockMuteRepo struct {
+	mutes map[[2]int]time.Time
+}
+
+func newMockMuteRepo() *mockMuteRepo {
+	return &mockMuteRepo{mutes: make(map[[2]int]time.Time)}
+}
+
+func (m *mockMuteRepo) Mute(ctx context.Context, userID, conversationID int) error {
+	if _, exists := m.mutes[[2]int{userID, conversationID}]; !exists {
+		m.mutes[[2]int{userID, conversationID}] = time.Now()
+	}
+	return nil
+}
+
+func (m *mockMuteRepo) Unmute(ctx context.Context, userID, conversationID int) error {
+	if _, exists := m.mutes[[2]int{userID, conversationID}]; !exists {
+		return repository.ErrMuteNotFound
+	}
+	delete(m.mutes, [2]int{userID, conversationID})
+	return nil
+}
+
+func (m *mockMuteRepo) ListByUser(ctx context.Context, userID int) ([]domain.ConversationMute, error) {
+	mutes := []domain.ConversationMute{}
+	for pair, mutedAt := range m.mutes {
+		if pair[0] == userID {
+			mutes = append(mutes, domain.ConversationMute{ConversationID: pair[1], MutedAt: mutedAt})
+		}
+	}
+	return mutes, nil
+}
+
+func (m *mockMuteRepo) IsMuted(ctx context.Context, userID, conversationID int) (bool, error) {
+	_, exists := m.mutes[[2]int{userID, conversationID}]
+	return exists, nil
+}
+
+func newTestChatUsecase(t *testing.T) *usecase.ChatUsecase {
+	t.Helper()
+	userRepo := &mockChatUserRepo{
+		users: map[int]*domain.User{
+			1: {ID: 1, Name: "User1"},
+			2: {ID: 2, Name: "User2"},
+			3: {ID: 3, Name: "User3"},
+			4: {ID: 4, Name: "User4"},
+		},
+	}
+	chatRepo := &mockChatRepo{}
+	natsService := NewMockNatsService()
+	t.Cleanup(natsService.Close)
+	return usecase.NewChatUsecase(chatRepo, userRepo, newMockMuteRepo(), newMockReceiptRepo(chatRepo), usecase.NewBlockUsecase(newMockBlockRepo(), userRepo), natsService)
+}
+
+func TestBlockUser(t *testing.T) {
+	chatUsecase := newTestChatUsecase(t)
+	blocks := chatUsecase.Blocks
+	ctx := context.Background()
+
+	if err := blocks.BlockUser(ctx, 1, 1); !errors.Is(err, usecase.ErrCannotBlockSelf) {
+		t.Errorf("BlockUser() self error = %v, want %v", err, usecase.ErrCannotBlockSelf)
+	}
+	if err := blocks.BlockUser(ctx, 1, 999); !errors.Is(err, usecase.ErrUserNotFound) {
+		t.Errorf("BlockUser() unknown user error = %v, want %v", err, usecase.ErrUserNotFound)
+	}
+
+	if err := blocks.BlockUser(ctx, 1, 2); err != nil {
+		t.Fatalf("BlockUser() error = %v", err)
+	}
+	// Blocking again is not an error
+	if err := blocks.BlockUser(ctx, 1, 2); err != nil {
+		t.Fatalf("BlockUser() repeated error = %v", err)
+	}
+	blocked, err := blocks.ListBlocked(ctx, 1)
+	if err != nil {
+		t.Fatalf("ListBlocked() error = %v", err)
+	}
+	if len(blocked) != 1 || blocked[0].ID != 2 {
+		t.Fatalf("Expected user 2 to be the only blocked user, got %+v", blocked)
+	}
+
+	// Messages are refused in both directions, but not to anyone else
+	if _, err := chatUsecase.SendMessage(ctx, 2, 1, "Hello?"); !errors.Is(err, usecase.ErrMessagingBlocked) {
+		t.Errorf("SendMessage() from blocked user error = %v, want %v", err, usecase.ErrMessagingBlocked)
+	}
+	if _, err := chatUsecase.SendMessage(ctx, 1, 2, "Hello?"); !errors.Is(err, usecase.ErrMessagingBlocked) {
+		t.Errorf("SendMessage() to blocked user error = %v, want %v", err, usecase.ErrMessagingBlocked)
+	}
+	if _, err := chatUsecase.SendMessage(ctx, 2, 3, "Hello!"); err != nil {
+		t.Errorf("SendMessage() to an unrelated user error = %v", err)
+	}
+	if stored := chatUsecase.ChatRepo.(*mockChatRepo).messages; len(stored) != 1 {
+		t.Errorf("Expected only the unblocked message to be stored, got %d", len(stored))
+	}
+
+	if err := blocks.UnblockUser(ctx, 1, 2); err != nil {
+		t.Fatalf("UnblockUser() error = %v", err)
+	}
+	if err := blocks.UnblockUser(ctx, 1, 2); !errors.Is(err, usecase.ErrBlockNotFound) {
+		t.Errorf("UnblockUser() repeated error = %v, want %v", err, usecase.ErrBlockNotFound)
+	}
+	if _, err := chatUsecase.SendMessage(ctx, 2, 1, "Hello again"); err != nil {
+		t.Errorf("SendMessage() after unblocking error = %v", err)
+	}
+}
+
+func TestMuteConversation(t *testing.T) {
+	chatUsecase := newTestChatUsecase(t)
+	ctx := context.Background()
+
+	message, err := chatUsecase.SendMessage(ctx, 1, 2, "First")
+	if err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+	if message.ConversationID == 0 {
+		t.Fatal("Expected the sent message to carry its conversation")
+	}
+
+	// Only participants can mute a conversation
+	if err := chatUsecase.MuteConversation(ctx, 3, message.ConversationID); !errors.Is(err, usecase.ErrConversationNotFound) {
+		t.Errorf("MuteConversation() by an outsider error = %v, want %v", err, usecase.ErrConversationNotFound)
+	}
+	if err := chatUsecase.MuteConversation(ctx, 2, 999); !errors.Is(err, usecase.ErrConversationNotFound) {
+		t.Errorf("MuteConversation() unknown conversation error = %v, want %v", err, usecase.ErrConversationNotFound)
+	}
+
+	if err := chatUsecase.MuteConversation(ctx, 2, message.ConversationID); err != nil {
+		t.Fatalf("MuteConversation() error = %v", err)
+	}
+	mutes, err := chatUsecase.ListMutedConversations(ctx, 2)
+	if err != nil {
+		t.Fatalf("ListMutedConversations() error = %v", err)
+	}
+	if len(mutes) != 1 || mutes[0].ConversationID != message.ConversationID {
+		t.Fatalf("Expected the conversation to be muted, got %+v", mutes)
+	}
+
+	// Messages are still stored, but only the sender is notified
+	muted, err := chatUsecase.SendMessage(ctx, 1, 2, "Second")
+	if err != nil {
+		t.Fatalf("SendMessage() to a muted conversation error = %v", err)
+	}
+	if stored := chatUsecase.ChatRepo.(*mockChatRepo).messages; len(stored) != 2 {
+		t.Errorf("Expected both messages to be stored, got %d", len(stored))
+	}
+	if chatUsecase.ShouldNotify(ctx, 2, muted) {
+		t.Error("ShouldNotify() pushed a message of a muted conversation")
+	}
+	if !chatUsecase.ShouldNotify(ctx, 1, muted) {
+		t.Error("ShouldNotify() suppressed the sender's own message")
+	}
+
+	if err := chatUsecase.UnmuteConversation(ctx, 2, message.ConversationID); err != nil {
+		t.Fatalf("UnmuteConversation() error = %v", err)
+	}
+	if err := chatUsecase.UnmuteConversation(ctx, 2, message.ConversationID); !errors.Is(err, usecase.ErrMuteNotFound) {
+		t.Errorf("UnmuteConversation() repeated error = %v, want %v", err, usecase.ErrMuteNotFound)
+	}
+	if !chatUsecase.ShouldNotify(ctx, 2, muted) {
+		t.Error("ShouldNotify() suppressed a message after unmuting")
+	}
+}
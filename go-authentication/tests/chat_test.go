@@ -4,9 +4,12 @@ import (
 	"context"
 	"errors"
 	"go-authentication/internal/domain"
+	"go-authentication/internal/repository"
 	"go-authentication/internal/services"
 	"go-authentication/internal/usecase"
 	"log"
+	"slices"
+	"sort"
 	"testing"
 	"time"
 
@@ -17,6 +20,7 @@ import (
 type mockChatRepo struct {
 	messages      []*domain.Message
 	conversations []*domain.Conversation
+	members       map[int][]domain.ConversationMember // by conversation, in the order they joined
 }
 
 func (m *mockChatRepo) StoresMsg(ctx context.Context, message *domain.Message) error {
@@ -43,42 +47,267 @@ func (m *mockChatRepo) GetMsgbyConvo(ctx context.Context, user1ID, user2ID, limi
 	return result, nil
 }
 
-func (m *mockChatRepo) GetOrCreateConversation(ctx context.Context, user1ID, user2ID int) (*domain.Conversation, error) {
-	for _, conv := range m.conversations {
-		if (conv.User1ID == user1ID && conv.User2ID == user2ID) ||
-			(conv.User1ID == user2ID && conv.User2ID == user1ID) {
-			return conv, nil
+func (m *mockChatRepo) GetMessagesByConversation(ctx context.Context, conversationID, limit, offset int) ([]*domain.Message, error) {
+	result := []*domain.Message{}
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].ConversationID == conversationID {
+			copied := *m.messages[i]
+			result = append(result, &copied)
 		}
 	}
+	if offset >= len(result) {
+		return []*domain.Message{}, nil
+	}
+	return result[offset:min(offset+limit, len(result))], nil
+}
+
+// timestampColumn stores t the way pgx writes a TIMESTAMP column and reads it back:
+// to the microsecond, with the wall clock kept and the time zone dropped
+func timestampColumn(t time.Time) time.Time {
+	t = t.Truncate(time.Microsecond)
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+func (m *mockChatRepo) createConversation(conversationType, name string) *domain.Conversation {
+	if m.members == nil {
+		m.members = make(map[int][]domain.ConversationMember)
+	}
+	now := timestampColumn(time.Now())
 	conv := &domain.Conversation{
 		ID:        len(m.conversations) + 1,
-		User1ID:   user1ID,
-		User2ID:   user2ID,
-		UpdatedAt: time.Now(),
+		Type:      conversationType,
+		Name:      name,
+		CreatedAt: now,
+		UpdatedAt: now,
 	}
 	m.conversations = append(m.conversations, conv)
+	return conv
+}
+
+func (m *mockChatRepo) GetOrCreateConversation(ctx context.Context, user1ID, user2ID int) (*domain.Conversation, error) {
+	for _, conv := range m.conversations {
+		if conv.IsGroup() {
+			continue
+		}
+		if _, err := m.GetMember(ctx, conv.ID, user1ID); err != nil {
+			continue
+		}
+		if _, err := m.GetMember(ctx, conv.ID, user2ID); err == nil {
+			return conv, nil
+		}
+	}
+	conv := m.createConversation(domain.ConversationTypeDirect, "")
+	m.AddMember(ctx, conv.ID, user1ID, domain.ConversationRoleMember)
+	m.AddMember(ctx, conv.ID, user2ID, domain.ConversationRoleMember)
 	return conv, nil
 }
 
+func (m *mockChatRepo) CreateGroup(ctx context.Context, conversation *domain.Conversation, ownerID int, memberIDs []int) error {
+	created := m.createConversation(domain.ConversationTypeGroup, conversation.Name)
+	m.AddMember(ctx, created.ID, ownerID, domain.ConversationRoleOwner)
+	for _, userID := range memberIDs {
+		m.AddMember(ctx, created.ID, userID, domain.ConversationRoleMember)
+	}
+	*conversation = *created
+	return nil
+}
+
 func (m *mockChatRepo) UpdateConvo(ctx context.Context, conversationID int, lastMessage string) error {
 	for _, conv := range m.conversations {
 		if conv.ID == conversationID {
 			conv.LastMessage = lastMessage
-			conv.UpdatedAt = time.Now()
+			conv.UpdatedAt = timestampColumn(time.Now())
 			return nil
 		}
 	}
 	return errors.New("conversation not found")
 }
 
-func (m *mockChatRepo) GetConvoByUserId(ctx context.Context, userID int) ([]*domain.Conversation, error) {
-	var result []*domain.Conversation
+func (m *mockChatRepo) GetConversationByID(ctx context.Context, conversationID int) (*domain.Conversation, error) {
 	for _, conv := range m.conversations {
-		if conv.User1ID == userID || conv.User2ID == userID {
-			result = append(result, conv)
+		if conv.ID == conversationID {
+			copied := *conv
+			return &copied, nil
 		}
 	}
-	return result, nil
+	return nil, repository.ErrConversationNotFound
+}
+
+func (m *mockChatRepo) RenameConversation(ctx context.Context, conversationID int, name string) error {
+	for _, conv := range m.conversations {
+		if conv.ID == conversationID {
+			conv.Name = name
+			return nil
+		}
+	}
+	return repository.ErrConversationNotFound
+}
+
+func (m *mockChatRepo) ListMembers(ctx context.Context, conversationID int) ([]domain.ConversationMember, error) {
+	return append([]domain.ConversationMember{}, m.members[conversationID]...), nil
+}
+
+func (m *mockChatRepo) GetMember(ctx context.Context, conversationID, userID int) (*domain.ConversationMember, error) {
+	for _, member := range m.members[conversationID] {
+		if member.UserID == userID {
+			return &member, nil
+		}
+	}
+	return nil, repository.ErrMemberNotFound
+}
+
+func (m *mockChatRepo) AddMember(ctx context.Context, conversationID, userID int, role string) error {
+	if _, err := m.GetMember(ctx, conversationID, userID); err == nil {
+		return nil
+	}
+	latest, _ := m.LatestMessageID(ctx, conversationID)
+	m.members[conversationID] = append(m.members[conversationID], domain.ConversationMember{UserID: userID, Role: role, JoinedAt: time.Now(), LastReadMessageID: latest})
+	return nil
+}
+
+func (m *mockChatRepo) RemoveMember(ctx context.Context, conversationID, userID int) error {
+	members := m.members[conversationID]
+	for i, member := range members {
+		if member.UserID == userID {
+			m.members[conversationID] = append(members[:i:i], members[i+1:]...)
+			return nil
+		}
+	}
+	return repository.ErrMemberNotFound
+}
+
+func (m *mockChatRepo) LeaveGroup(ctx context.Context, conversationID, userID int) error {
+	leaving, err := m.GetMember(ctx, conversationID, userID)
+	if err != nil {
+		return err
+	}
+	m.RemoveMember(ctx, conversationID, userID)
+
+	members := m.members[conversationID]
+	if len(members) == 0 {
+		for i, conv := range m.conversations {
+			if conv.ID == conversationID {
+				m.conversations = append(m.conversations[:i], m.conversations[i+1:]...)
+				break
+			}
+		}
+		delete(m.members, conversationID)
+		return nil
+	}
+	if leaving.Role != domain.ConversationRoleOwner {
+		return nil
+	}
+
+	// Members are kept in the order they joined
+	successor := members[0]
+	for _, member := range members {
+		if member.Role == domain.ConversationRoleAdmin {
+			successor = member
+			break
+		}
+	}
+	return m.UpdateMemberRole(ctx, conversationID, successor.UserID, domain.ConversationRoleOwner)
+}
+
+func (m *mockChatRepo) UpdateMemberRole(ctx context.Context, conversationID, userID int, role string) error {
+	for i, member := range m.members[conversationID] {
+		if member.UserID == userID {
+			m.members[conversationID][i].Role = role
+			return nil
+		}
+	}
+	return repository.ErrMemberNotFound
+}
+
+func (m *mockChatRepo) MarkRead(ctx context.Context, conversationID, userID, messageID int) error {
+	for i, member := range m.members[conversationID] {
+		if member.UserID == userID {
+			m.members[conversationID][i].LastReadMessageID = max(member.LastReadMessageID, messageID)
+			return nil
+		}
+	}
+	return repository.ErrMemberNotFound
+}
+
+func (m *mockChatRepo) LatestMessageID(ctx context.Context, conversationID int) (int, error) {
+	latest := 0
+	for _, message := range m.messages {
+		if message.ConversationID == conversationID {
+			latest = max(latest, message.ID)
+		}
+	}
+	return latest, nil
+}
+
+func (m *mockChatRepo) DeleteMessage(ctx context.Context, messageID int) (*domain.Message, error) {
+	for i, message := range m.messages {
+		if message.ID == messageID {
+			m.messages = append(m.messages[:i], m.messages[i+1:]...)
+			return message, nil
+		}
+	}
+	return nil, repository.ErrMessageNotFound
+}
+
+// ListContacts of the mock does not know about blocks
+func (m *mockChatRepo) ListContacts(ctx context.Context, userID int) ([]int, error) {
+	contactIDs := []int{}
+	for conversationID := range m.members {
+		if _, err := m.GetMember(ctx, conversationID, userID); err != nil {
+			continue
+		}
+		for _, member := range m.members[conversationID] {
+			if member.UserID != userID && !slices.Contains(contactIDs, member.UserID) {
+				contactIDs = append(contactIDs, member.UserID)
+			}
+		}
+	}
+	slices.Sort(contactIDs)
+	return contactIDs, nil
+}
+
+func (m *mockChatRepo) ListInbox(ctx context.Context, filter *domain.InboxFilter) ([]domain.InboxEntry, error) {
+	entries := []domain.InboxEntry{}
+	// The cursor is compared against the column as pgx sends it, without its time zone
+	before := timestampColumn(filter.BeforeUpdatedAt)
+	for _, conv := range m.conversations {
+		self, err := m.GetMember(ctx, conv.ID, filter.UserID)
+		if err != nil {
+			continue
+		}
+		if filter.BeforeID != 0 && !conv.UpdatedAt.Before(before) &&
+			!(conv.UpdatedAt.Equal(before) && conv.ID < filter.BeforeID) {
+			continue
+		}
+		entry := domain.InboxEntry{ID: conv.ID, Type: conv.Type, Name: conv.Name, UpdatedAt: conv.UpdatedAt}
+		if !conv.IsGroup() {
+			for _, member := range m.members[conv.ID] {
+				if member.UserID != filter.UserID {
+					entry.OtherUser = &domain.PublicProfile{ID: member.UserID}
+				}
+			}
+		}
+		for _, msg := range m.messages {
+			if msg.ConversationID != conv.ID {
+				continue
+			}
+			entry.LastMessage = &domain.MessagePreview{ID: msg.ID, SenderID: msg.SenderID, Content: msg.Content, CreatedAt: msg.CreatedAt}
+			if msg.ID > self.LastReadMessageID && msg.SenderID != filter.UserID {
+				entry.UnreadCount++
+			}
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].UpdatedAt.Equal(entries[j].UpdatedAt) {
+			return entries[i].UpdatedAt.After(entries[j].UpdatedAt)
+		}
+		return entries[i].ID > entries[j].ID
+	})
+	if len(entries) > filter.Limit {
+		entries = entries[:filter.Limit]
+	}
+	return entries, nil
 }
 
 type mockChatUserRepo struct {
@@ -93,6 +322,45 @@ func (m *mockChatUserRepo) GetByID(ctx context.Context, id int) (*domain.User, e
 	return user, nil
 }
 
+func (m *mockChatUserRepo) UpdatePassword(ctx context.Context, id int, passwordHash string) error {
+	user, exists := m.users[id]
+	if !exists {
+		return errors.New("user not found")
+	}
+	user.Password = passwordHash
+	return nil
+}
+
+func (m *mockChatUserRepo) MarkEmailVerified(ctx context.Context, id int, verifiedAt time.Time) error {
+	user, exists := m.users[id]
+	if !exists {
+		return errors.New("user not found")
+	}
+	user.EmailVerifiedAt = &verifiedAt
+	return nil
+}
+
+func (m *mockChatUserRepo) UpdateProfile(ctx context.Context, user *domain.User) error {
+	stored, exists := m.users[user.ID]
+	if !exists {
+		return errors.New("user not found")
+	}
+	stored.Name, stored.DisplayName, stored.Bio, stored.StatusText = user.Name, user.DisplayName, user.Bio, user.StatusText
+	stored.UpdatedAt = time.Now()
+	user.UpdatedAt = stored.UpdatedAt
+	return nil
+}
+
+func (m *mockChatUserRepo) UpdateEmail(ctx context.Context, id int, email string, verifiedAt time.Time) error {
+	user, exists := m.users[id]
+	if !exists {
+		return errors.New("user not found")
+	}
+	user.Email = email
+	user.EmailVerifiedAt = &verifiedAt
+	return nil
+}
+
 func (m *mockChatUserRepo) Create(ctx context.Context, user *domain.User) error {
 	if user.ID == 0 {
 		user.ID = len(m.users) + 1
@@ -112,8 +380,8 @@ func (m *mockChatUserRepo) GetByEmail(ctx context.Context, email string) (*domai
 
 // NatsService interface for mocking
 type NatsService interface {
-	SubscribeToPrivateMessages(userID int, messageHandler func(msg *domain.Message)) error
-	SendPrivateMessage(message *domain.Message) error
+	SubscribeToUserMessages(userID int, messageHandler func(msg *domain.Message)) error
+	PublishMessage(message *domain.Message, recipientIDs []int) error
 	SubscribeToSubject(subject string, callback func(*domain.Message)) error
 	Close()
 }
@@ -133,11 +401,11 @@ func NewMockNatsService() *services.NatsService {
 	return ns
 }
 
-func (m *mockNatsService) SubscribeToPrivateMessages(userID int, messageHandler func(msg *domain.Message)) error {
+func (m *mockNatsService) SubscribeToUserMessages(userID int, messageHandler func(msg *domain.Message)) error {
 	return nil
 }
 
-func (m *mockNatsService) SendPrivateMessage(message *domain.Message) error {
+func (m *mockNatsService) PublishMessage(message *domain.Message, recipientIDs []int) error {
 	return nil
 }
 
@@ -178,7 +446,7 @@ func TestSendMessage(t *testing.T) {
 
 	// Create chat usecase with mock dependencies
 	log.Println("3. Creating chat usecase...")
-	chatUsecase := usecase.NewChatUsecase(chatRepo, userRepo, natsService)
+	chatUsecase := usecase.NewChatUsecase(chatRepo, userRepo, newMockMuteRepo(), newMockReceiptRepo(chatRepo), usecase.NewBlockUsecase(newMockBlockRepo(), userRepo), natsService)
 	log.Println("✓ Chat usecase created")
 
 	tests := []struct {
@@ -280,7 +548,7 @@ func TestGetMessages(t *testing.T) {
 	defer natsService.Close()
 	log.Println("✓ NATS service created")
 
-	chatUsecase := usecase.NewChatUsecase(chatRepo, userRepo, natsService)
+	chatUsecase := usecase.NewChatUsecase(chatRepo, userRepo, newMockMuteRepo(), newMockReceiptRepo(chatRepo), usecase.NewBlockUsecase(newMockBlockRepo(), userRepo), natsService)
 
 	tests := []struct {
 		name      string
@@ -347,3 +615,32 @@ func TestGetMessages(t *testing.T) {
 	}
 	log.Println("✓ GetMessages Test completed")
 }
+
+func TestRemoveMessage(t *testing.T) {
+	chatUsecase := newTestChatUsecase(t)
+	ctx := context.Background()
+
+	kept, err := chatUsecase.SendMessage(ctx, 1, 2, "Hello")
+	if err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+	removed, err := chatUsecase.SendMessage(ctx, 2, 1, "Something abusive")
+	if err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+
+	if err := chatUsecase.RemoveMessage(ctx, 3, removed.ID); err != nil {
+		t.Fatalf("RemoveMessage() error = %v", err)
+	}
+	history, err := chatUsecase.ChatRepo.GetMessagesByConversation(ctx, removed.ConversationID, 10, 0)
+	if err != nil {
+		t.Fatalf("GetMessagesByConversation() error = %v", err)
+	}
+	if len(history) != 1 || history[0].ID != kept.ID {
+		t.Errorf("Expected only message %d to remain, got %+v", kept.ID, history)
+	}
+
+	if err := chatUsecase.RemoveMessage(ctx, 3, removed.ID); !errors.Is(err, usecase.ErrMessageNotFound) {
+		t.Errorf("Expected ErrMessageNotFound for a removed message, got %v", err)
+	}
+}
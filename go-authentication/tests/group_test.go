@@ -0,0 +1,196 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/usecase"
+	"testing"
+)
+
+func memberRoles(conversation *domain.Conversation) map[int]string {
+	roles := make(map[int]string)
+	for _, member := range conversation.Members {
+		roles[member.UserID] = member.Role
+	}
+	return roles
+}
+
+func TestGroupConversation(t *testing.T) {
+	chatUsecase := newTestChatUsecase(t)
+	groups := usecase.NewGroupUsecase(chatUsecase.ChatRepo, chatUsecase.UserRepo, chatUsecase.Blocks)
+	ctx := context.Background()
+
+	if _, err := groups.CreateGroup(ctx, 1, &domain.CreateGroupRequest{Name: "   "}); !errors.Is(err, usecase.ErrInvalidGroupName) {
+		t.Errorf("CreateGroup() blank name error = %v, want %v", err, usecase.ErrInvalidGroupName)
+	}
+	if _, err := groups.CreateGroup(ctx, 1, &domain.CreateGroupRequest{Name: "Team", MemberIDs: []int{999}}); !errors.Is(err, usecase.ErrUserNotFound) {
+		t.Errorf("CreateGroup() unknown member error = %v, want %v", err, usecase.ErrUserNotFound)
+	}
+
+	group, err := groups.CreateGroup(ctx, 1, &domain.CreateGroupRequest{Name: " Team ", MemberIDs: []int{2, 2, 1}})
+	if err != nil {
+		t.Fatalf("CreateGroup() error = %v", err)
+	}
+	if !group.IsGroup() || group.Name != "Team" {
+		t.Fatalf("Expected a group named Team, got %+v", group)
+	}
+	if roles := memberRoles(group); len(roles) != 2 || roles[1] != domain.ConversationRoleOwner || roles[2] != domain.ConversationRoleMember {
+		t.Fatalf("Expected user 1 to own the group with user 2 as member, got %v", roles)
+	}
+
+	// Messages are addressed to the conversation and only members may post or read
+	message, err := chatUsecase.Send(ctx, 2, &domain.MessageRequest{ConversationID: group.ID, Content: "Hi all"})
+	if err != nil {
+		t.Fatalf("Send() to group error = %v", err)
+	}
+	if message.ConversationID != group.ID || message.ReceiverID != 0 {
+		t.Errorf("Expected a group message without receiver, got %+v", message)
+	}
+	if _, err := chatUsecase.SendToConversation(ctx, 3, group.ID, "Let me in"); !errors.Is(err, usecase.ErrConversationNotFound) {
+		t.Errorf("SendToConversation() by an outsider error = %v, want %v", err, usecase.ErrConversationNotFound)
+	}
+	if _, err := chatUsecase.GetConversationHistory(ctx, 3, group.ID, 20, 0); !errors.Is(err, usecase.ErrConversationNotFound) {
+		t.Errorf("GetConversationHistory() by an outsider error = %v, want %v", err, usecase.ErrConversationNotFound)
+	}
+	if _, err := chatUsecase.Send(ctx, 1, &domain.MessageRequest{ReceiverID: 2, ConversationID: group.ID, Content: "Both"}); !errors.Is(err, usecase.ErrInvalidMessageTarget) {
+		t.Errorf("Send() with two targets error = %v, want %v", err, usecase.ErrInvalidMessageTarget)
+	}
+
+	// Plain members cannot manage the group
+	if _, err := groups.InviteMembers(ctx, 2, group.ID, []int{3}); !errors.Is(err, usecase.ErrConversationForbidden) {
+		t.Errorf("InviteMembers() by a member error = %v, want %v", err, usecase.ErrConversationForbidden)
+	}
+	if _, err := groups.RenameGroup(ctx, 2, group.ID, "Mine"); !errors.Is(err, usecase.ErrConversationForbidden) {
+		t.Errorf("RenameGroup() by a member error = %v, want %v", err, usecase.ErrConversationForbidden)
+	}
+
+	group, err = groups.InviteMembers(ctx, 1, group.ID, []int{3, 4, 2})
+	if err != nil {
+		t.Fatalf("InviteMembers() error = %v", err)
+	}
+	if len(group.Members) != 4 {
+		t.Fatalf("Expected 4 members after inviting, got %d", len(group.Members))
+	}
+	history, err := chatUsecase.GetConversationHistory(ctx, 3, group.ID, 20, 0)
+	if err != nil || len(history) != 1 {
+		t.Fatalf("Expected an invited member to read the history, got %d messages, error %v", len(history), err)
+	}
+
+	// Only the owner changes roles, and admins only remove plain members
+	if _, err := groups.UpdateMemberRole(ctx, 1, group.ID, 2, domain.ConversationRoleOwner); !errors.Is(err, usecase.ErrInvalidMemberRole) {
+		t.Errorf("UpdateMemberRole() to owner error = %v, want %v", err, usecase.ErrInvalidMemberRole)
+	}
+	if _, err := groups.UpdateMemberRole(ctx, 1, group.ID, 2, domain.ConversationRoleAdmin); err != nil {
+		t.Fatalf("UpdateMemberRole() error = %v", err)
+	}
+	if _, err := groups.UpdateMemberRole(ctx, 2, group.ID, 3, domain.ConversationRoleAdmin); !errors.Is(err, usecase.ErrConversationForbidden) {
+		t.Errorf("UpdateMemberRole() by an admin error = %v, want %v", err, usecase.ErrConversationForbidden)
+	}
+	if err := groups.RemoveMember(ctx, 2, group.ID, 1); !errors.Is(err, usecase.ErrConversationForbidden) {
+		t.Errorf("RemoveMember() of the owner error = %v, want %v", err, usecase.ErrConversationForbidden)
+	}
+	if err := groups.RemoveMember(ctx, 2, group.ID, 4); err != nil {
+		t.Fatalf("RemoveMember() by an admin error = %v", err)
+	}
+	if err := groups.RemoveMember(ctx, 2, group.ID, 4); !errors.Is(err, usecase.ErrMemberNotFound) {
+		t.Errorf("RemoveMember() of a former member error = %v, want %v", err, usecase.ErrMemberNotFound)
+	}
+
+	renamed, err := groups.RenameGroup(ctx, 2, group.ID, "Core team")
+	if err != nil || renamed.Name != "Core team" {
+		t.Fatalf("RenameGroup() by an admin = %+v, error %v", renamed, err)
+	}
+
+	// The owner leaving hands the group to the admin
+	if err := groups.LeaveGroup(ctx, 1, group.ID); err != nil {
+		t.Fatalf("LeaveGroup() error = %v", err)
+	}
+	group, err = groups.GetConversation(ctx, 2, group.ID)
+	if err != nil {
+		t.Fatalf("GetConversation() error = %v", err)
+	}
+	if roles := memberRoles(group); len(roles) != 2 || roles[2] != domain.ConversationRoleOwner {
+		t.Fatalf("Expected user 2 to own the group after the owner left, got %v", roles)
+	}
+
+	// Removing yourself is leaving; the group is gone once its last member left
+	if err := groups.RemoveMember(ctx, 3, group.ID, 3); err != nil {
+		t.Fatalf("RemoveMember() of self error = %v", err)
+	}
+	if err := groups.LeaveGroup(ctx, 2, group.ID); err != nil {
+		t.Fatalf("LeaveGroup() by the last member error = %v", err)
+	}
+	if _, err := chatUsecase.ChatRepo.GetConversationByID(ctx, group.ID); err == nil {
+		t.Error("Expected the empty group to be deleted")
+	}
+}
+
+func TestDirectConversationIsNotAGroup(t *testing.T) {
+	chatUsecase := newTestChatUsecase(t)
+	groups := usecase.NewGroupUsecase(chatUsecase.ChatRepo, chatUsecase.UserRepo, chatUsecase.Blocks)
+	ctx := context.Background()
+
+	message, err := chatUsecase.SendMessage(ctx, 1, 2, "Hello")
+	if err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+
+	// Replying by conversation ID keeps the receiver of a direct message
+	reply, err := chatUsecase.SendToConversation(ctx, 2, message.ConversationID, "Hi")
+	if err != nil {
+		t.Fatalf("SendToConversation() error = %v", err)
+	}
+	if reply.ReceiverID != 1 {
+		t.Errorf("Expected the reply to be addressed to user 1, got %d", reply.ReceiverID)
+	}
+
+	if _, err := groups.InviteMembers(ctx, 1, message.ConversationID, []int{3}); !errors.Is(err, usecase.ErrNotGroupConversation) {
+		t.Errorf("InviteMembers() to a direct conversation error = %v, want %v", err, usecase.ErrNotGroupConversation)
+	}
+	if err := groups.LeaveGroup(ctx, 1, message.ConversationID); !errors.Is(err, usecase.ErrNotGroupConversation) {
+		t.Errorf("LeaveGroup() of a direct conversation error = %v, want %v", err, usecase.ErrNotGroupConversation)
+	}
+}
+
+func TestInvitedMemberStartsCaughtUp(t *testing.T) {
+	chatUsecase := newTestChatUsecase(t)
+	groups := usecase.NewGroupUsecase(chatUsecase.ChatRepo, chatUsecase.UserRepo, chatUsecase.Blocks)
+	ctx := context.Background()
+
+	group, err := groups.CreateGroup(ctx, 1, &domain.CreateGroupRequest{Name: "Team", MemberIDs: []int{2}})
+	if err != nil {
+		t.Fatalf("CreateGroup() error = %v", err)
+	}
+	for _, content := range []string{"Before you joined", "Also before"} {
+		if _, err := chatUsecase.SendToConversation(ctx, 2, group.ID, content); err != nil {
+			t.Fatalf("SendToConversation() error = %v", err)
+		}
+	}
+
+	if _, err := groups.InviteMembers(ctx, 1, group.ID, []int{3}); err != nil {
+		t.Fatalf("InviteMembers() error = %v", err)
+	}
+	unread := func() int {
+		t.Helper()
+		page, err := chatUsecase.Inbox(ctx, 3, &domain.InboxQuery{})
+		if err != nil {
+			t.Fatalf("Inbox() error = %v", err)
+		}
+		if len(page.Conversations) != 1 {
+			t.Fatalf("Expected the group in the inbox of the invitee, got %+v", page.Conversations)
+		}
+		return page.Conversations[0].UnreadCount
+	}
+
+	// The history sent before joining is readable but not unread
+	if count := unread(); count != 0 {
+		t.Errorf("Expected no unread messages right after joining, got %d", count)
+	}
+	if _, err := chatUsecase.SendToConversation(ctx, 2, group.ID, "Welcome"); err != nil {
+		t.Fatalf("SendToConversation() error = %v", err)
+	}
+	if count := unread(); count != 1 {
+		t.Errorf("Expected the message sent after joining to be unread, got %d", count)
+	}
+}
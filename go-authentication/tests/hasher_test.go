@@ -0,0 +1,119 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"go-authentication/config"
+	"go-authentication/internal/domain"
+	"go-authentication/pkg"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newTestPasswordHasher returns the default hasher, Argon2id with the OWASP parameters
+func newTestPasswordHasher(t *testing.T) pkg.PasswordHasher {
+	t.Helper()
+	hasher, err := pkg.NewPasswordHasher(&config.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create password hasher: %v", err)
+	}
+	return hasher
+}
+
+func TestPasswordHasher(t *testing.T) {
+	argon2id := newTestPasswordHasher(t)
+	bcryptHasher, err := pkg.NewPasswordHasher(&config.Config{PasswordHashAlgorithm: "bcrypt", BcryptCost: "4"})
+	if err != nil {
+		t.Fatalf("NewPasswordHasher() error = %v", err)
+	}
+
+	for name, hasher := range map[string]pkg.PasswordHasher{"argon2id": argon2id, "bcrypt": bcryptHasher} {
+		t.Run(name, func(t *testing.T) {
+			encoded, err := hasher.Hash("password123456")
+			if err != nil {
+				t.Fatalf("Hash() error = %v", err)
+			}
+			if ok, err := hasher.Verify(encoded, "password123456"); err != nil || !ok {
+				t.Errorf("Verify() = %v, %v, want true", ok, err)
+			}
+			if ok, _ := hasher.Verify(encoded, "wrong-password"); ok {
+				t.Error("Verify() accepted a wrong password")
+			}
+			if hasher.NeedsRehash(encoded) {
+				t.Error("NeedsRehash() = true for a fresh hash")
+			}
+		})
+	}
+
+	encoded, _ := argon2id.Hash("password123456")
+	if !strings.HasPrefix(encoded, "$argon2id$v=19$m=19456,t=2,p=1$") {
+		t.Errorf("Hash() = %q, want PHC encoded argon2id", encoded)
+	}
+
+	// Hashes of other algorithms still verify but are flagged for an upgrade
+	if ok, err := bcryptHasher.Verify(encoded, "password123456"); err != nil || !ok {
+		t.Errorf("bcrypt hasher Verify(argon2id hash) = %v, %v, want true", ok, err)
+	}
+	if !bcryptHasher.NeedsRehash(encoded) {
+		t.Error("NeedsRehash() = false after switching algorithm")
+	}
+
+	stronger, _ := pkg.NewPasswordHasher(&config.Config{Argon2Iterations: "3"})
+	if !stronger.NeedsRehash(encoded) {
+		t.Error("NeedsRehash() = false after raising argon2 iterations")
+	}
+	costlier, _ := pkg.NewPasswordHasher(&config.Config{PasswordHashAlgorithm: "bcrypt", BcryptCost: "5"})
+	bcryptEncoded, _ := bcryptHasher.Hash("password123456")
+	if !costlier.NeedsRehash(bcryptEncoded) {
+		t.Error("NeedsRehash() = false after raising bcrypt cost")
+	}
+
+	if _, err := argon2id.Verify("plaintext", "plaintext"); !errors.Is(err, pkg.ErrUnknownHashFormat) {
+		t.Errorf("Verify() unknown format error = %v, want %v", err, pkg.ErrUnknownHashFormat)
+	}
+
+	invalid := []*config.Config{
+		{PasswordHashAlgorithm: "md5"},
+		{BcryptCost: "3"},
+		{Argon2Parallelism: "0"},
+	}
+	for _, cfg := range invalid {
+		if _, err := pkg.NewPasswordHasher(cfg); err == nil {
+			t.Errorf("NewPasswordHasher(%+v) expected error", cfg)
+		}
+	}
+}
+
+func TestLoginRehashesStalePasswords(t *testing.T) {
+	password := "password123456"
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+
+	verifiedAt := time.Now()
+	repo := &mockAuthUserRepo{
+		users: map[int]*domain.User{
+			1: {ID: 1, Name: "Test User", Email: "test@example.com", Password: string(hashedPassword), EmailVerifiedAt: &verifiedAt},
+		},
+	}
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), &recordingMailer{})
+	ctx := context.Background()
+
+	if _, err := authUsecase.Login(ctx, "test@example.com", password, domain.ClientInfo{}); err != nil {
+		t.Fatalf("Login() with bcrypt hash error = %v", err)
+	}
+	if stored := repo.users[1].Password; !strings.HasPrefix(stored, "$argon2id$") {
+		t.Fatalf("stored hash after login = %q, want argon2id", stored)
+	}
+
+	if _, err := authUsecase.Login(ctx, "test@example.com", password, domain.ClientInfo{}); err != nil {
+		t.Errorf("Login() with rehashed password error = %v", err)
+	}
+	if _, err := authUsecase.Login(ctx, "test@example.com", "wrong-password", domain.ClientInfo{}); err == nil {
+		t.Error("Login() accepted a wrong password after rehash")
+	}
+}
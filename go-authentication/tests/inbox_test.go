@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/usecase"
+	"testing"
+	"time"
+)
+
+func TestInbox(t *testing.T) {
+	chatUsecase := newTestChatUsecase(t)
+	groups := usecase.NewGroupUsecase(chatUsecase.ChatRepo, chatUsecase.UserRepo, chatUsecase.Blocks)
+	ctx := context.Background()
+
+	// Space the activity out so every conversation has a distinct updated_at
+	step := func() { time.Sleep(2 * time.Millisecond) }
+
+	direct, err := chatUsecase.SendMessage(ctx, 1, 2, "Hello User2")
+	if err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+	step()
+	incoming, err := chatUsecase.SendMessage(ctx, 3, 1, "Hello User1")
+	if err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+	step()
+	group, err := groups.CreateGroup(ctx, 1, &domain.CreateGroupRequest{Name: "Team", MemberIDs: []int{2}})
+	if err != nil {
+		t.Fatalf("CreateGroup() error = %v", err)
+	}
+	for _, content := range []string{"First", "Second"} {
+		step()
+		if _, err := chatUsecase.SendToConversation(ctx, 2, group.ID, content); err != nil {
+			t.Fatalf("SendToConversation() error = %v", err)
+		}
+	}
+
+	page, err := chatUsecase.Inbox(ctx, 1, &domain.InboxQuery{})
+	if err != nil {
+		t.Fatalf("Inbox() error = %v", err)
+	}
+	if len(page.Conversations) != 3 || page.NextCursor != "" {
+		t.Fatalf("Expected 3 conversations on a single page, got %+v", page)
+	}
+	wantOrder := []int{group.ID, incoming.ConversationID, direct.ConversationID}
+	wantUnread := []int{2, 1, 0}
+	for i, entry := range page.Conversations {
+		if entry.ID != wantOrder[i] {
+			t.Errorf("Conversation %d = %d, want %d", i, entry.ID, wantOrder[i])
+		}
+		if entry.UnreadCount != wantUnread[i] {
+			t.Errorf("Unread count of conversation %d = %d, want %d", entry.ID, entry.UnreadCount, wantUnread[i])
+		}
+	}
+	if groupEntry := page.Conversations[0]; groupEntry.Name != "Team" || groupEntry.OtherUser != nil ||
+		groupEntry.LastMessage == nil || groupEntry.LastMessage.Content != "Second" {
+		t.Errorf("Unexpected group entry: %+v", groupEntry)
+	}
+	if directEntry := page.Conversations[1]; directEntry.OtherUser == nil || directEntry.OtherUser.ID != 3 {
+		t.Errorf("Expected the direct conversation to show user 3, got %+v", directEntry.OtherUser)
+	}
+
+	// Opening the latest messages reads the conversation
+	if _, err := chatUsecase.GetConversationHistory(ctx, 1, group.ID, 20, 0); err != nil {
+		t.Fatalf("GetConversationHistory() error = %v", err)
+	}
+
+	first, err := chatUsecase.Inbox(ctx, 1, &domain.InboxQuery{Limit: 2})
+	if err != nil {
+		t.Fatalf("Inbox() error = %v", err)
+	}
+	if len(first.Conversations) != 2 || first.NextCursor == "" {
+		t.Fatalf("Expected a full first page with a cursor, got %+v", first)
+	}
+	if first.Conversations[0].UnreadCount != 0 {
+		t.Errorf("Expected the group to be read, got %d unread", first.Conversations[0].UnreadCount)
+	}
+	second, err := chatUsecase.Inbox(ctx, 1, &domain.InboxQuery{Limit: 2, Cursor: first.NextCursor})
+	if err != nil {
+		t.Fatalf("Inbox() error = %v", err)
+	}
+	if len(second.Conversations) != 1 || second.Conversations[0].ID != direct.ConversationID || second.NextCursor != "" {
+		t.Fatalf("Expected the oldest conversation alone on the last page, got %+v", second)
+	}
+
+	if _, err := chatUsecase.Inbox(ctx, 1, &domain.InboxQuery{Limit: 500}); !errors.Is(err, usecase.ErrInvalidInboxQuery) {
+		t.Errorf("Inbox() oversized page error = %v, want %v", err, usecase.ErrInvalidInboxQuery)
+	}
+	if _, err := chatUsecase.Inbox(ctx, 1, &domain.InboxQuery{Cursor: "not-a-cursor"}); !errors.Is(err, usecase.ErrInvalidInboxQuery) {
+		t.Errorf("Inbox() malformed cursor error = %v, want %v", err, usecase.ErrInvalidInboxQuery)
+	}
+}
+
+func TestInboxCursorOutsideUTC(t *testing.T) {
+	// Servers away from UTC must page the same way
+	local := time.Local
+	time.Local = time.FixedZone("IST", 5*60*60+30*60)
+	t.Cleanup(func() { time.Local = local })
+
+	chatUsecase := newTestChatUsecase(t)
+	ctx := context.Background()
+
+	for _, receiverID := range []int{2, 3, 4} {
+		if _, err := chatUsecase.SendMessage(ctx, 1, receiverID, "Hello"); err != nil {
+			t.Fatalf("SendMessage() error = %v", err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	seen := make(map[int]bool)
+	query := &domain.InboxQuery{Limit: 1}
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("Inbox() did not stop paginating")
+		}
+		page, err := chatUsecase.Inbox(ctx, 1, query)
+		if err != nil {
+			t.Fatalf("Inbox() error = %v", err)
+		}
+		for _, entry := range page.Conversations {
+			if seen[entry.ID] {
+				t.Fatalf("Conversation %d appeared on two pages", entry.ID)
+			}
+			seen[entry.ID] = true
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+	if len(seen) != 3 {
+		t.Errorf("Expected to page through 3 conversations, got %d", len(seen))
+	}
+}
@@ -0,0 +1,260 @@
+package tests
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"go-authentication/config"
+	"go-authentication/pkg"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func newRSAKey(t *testing.T, id string) *pkg.SigningKey {
+	t.Helper()
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	return pkg.NewRSAKey(id, privateKey)
+}
+
+func newEd25519Key(t *testing.T, id string) *pkg.SigningKey {
+	t.Helper()
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v", err)
+	}
+	return pkg.NewEd25519Key(id, privateKey)
+}
+
+func TestTokenServiceAsymmetricSigning(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     *pkg.SigningKey
+		wantAlg string
+	}{
+		{name: "RS256", key: newRSAKey(t, "rsa-1"), wantAlg: "RS256"},
+		{name: "EdDSA", key: newEd25519Key(t, "ed-1"), wantAlg: "EdDSA"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := pkg.NewKeySet(tt.key)
+			if err != nil {
+				t.Fatalf("NewKeySet() error = %v", err)
+			}
+			tokens := newTokenServiceWithKeys(t, keys)
+
+			token, err := tokens.GenerateJWT(1, "test@example.com", nil)
+			if err != nil {
+				t.Fatalf("GenerateJWT() error = %v", err)
+			}
+
+			claims, err := tokens.ValidateJWT(token)
+			if err != nil {
+				t.Fatalf("ValidateJWT() error = %v", err)
+			}
+			if claims["email"] != "test@example.com" {
+				t.Errorf("ValidateJWT() email = %v, want test@example.com", claims["email"])
+			}
+
+			jwks := keys.JWKS()
+			if len(jwks.Keys) != 1 || jwks.Keys[0].Kid != tt.key.ID || jwks.Keys[0].Alg != tt.wantAlg {
+				t.Errorf("JWKS() = %+v, want one %s key with kid %s", jwks.Keys, tt.wantAlg, tt.key.ID)
+			}
+		})
+	}
+}
+
+func TestTokenServiceKeyRotation(t *testing.T) {
+	oldKey := newRSAKey(t, "2024-01")
+	newKey := newEd25519Key(t, "2024-02")
+
+	oldKeys, err := pkg.NewKeySet(oldKey)
+	if err != nil {
+		t.Fatalf("NewKeySet() error = %v", err)
+	}
+	oldToken, err := newTokenServiceWithKeys(t, oldKeys).GenerateJWT(1, "test@example.com", nil)
+	if err != nil {
+		t.Fatalf("GenerateJWT() error = %v", err)
+	}
+
+	// During the rotation window the new key signs while the old one still verifies
+	rotated, err := pkg.NewKeySet(newKey, oldKey)
+	if err != nil {
+		t.Fatalf("NewKeySet() error = %v", err)
+	}
+	tokens := newTokenServiceWithKeys(t, rotated)
+	if _, err := tokens.ValidateJWT(oldToken); err != nil {
+		t.Errorf("ValidateJWT() rejected a token signed by the previous key: %v", err)
+	}
+	if got := len(rotated.JWKS().Keys); got != 2 {
+		t.Errorf("JWKS() published %d keys during rotation, want 2", got)
+	}
+
+	// Once the old key is retired its tokens are no longer accepted
+	retired, err := pkg.NewKeySet(newKey)
+	if err != nil {
+		t.Fatalf("NewKeySet() error = %v", err)
+	}
+	if _, err := newTokenServiceWithKeys(t, retired).ValidateJWT(oldToken); err == nil {
+		t.Error("ValidateJWT() accepted a token signed by a retired key")
+	}
+
+	// HMAC secrets must never be published
+	withSecret, err := pkg.NewKeySet(newKey, pkg.NewHMACKey("hs256", []byte("secret")))
+	if err != nil {
+		t.Fatalf("NewKeySet() error = %v", err)
+	}
+	for _, jwk := range withSecret.JWKS().Keys {
+		if jwk.Kid == "hs256" {
+			t.Error("JWKS() published an HMAC key")
+		}
+	}
+}
+
+func TestTokenServiceRegisteredClaims(t *testing.T) {
+	keys, err := pkg.NewKeySet(newEd25519Key(t, "ed-1"))
+	if err != nil {
+		t.Fatalf("NewKeySet() error = %v", err)
+	}
+	tokens, err := pkg.NewTokenService(&config.Config{
+		JWTIssuer:     "auth.example.com",
+		JWTAudience:   "chat",
+		JWTExpiration: "2",
+		JWTClockSkew:  "30",
+	}, keys)
+	if err != nil {
+		t.Fatalf("NewTokenService() error = %v", err)
+	}
+
+	token, err := tokens.GenerateJWT(1, "test@example.com", nil)
+	if err != nil {
+		t.Fatalf("GenerateJWT() error = %v", err)
+	}
+	claims, err := tokens.ValidateJWT(token)
+	if err != nil {
+		t.Fatalf("ValidateJWT() error = %v", err)
+	}
+	if claims["iss"] != "auth.example.com" || claims["aud"] != "chat" {
+		t.Errorf("GenerateJWT() iss/aud = %v/%v, want auth.example.com/chat", claims["iss"], claims["aud"])
+	}
+	if lifetime := pkg.ClaimTime(claims, "exp").Sub(pkg.ClaimTime(claims, "iat")); lifetime != 2*time.Hour {
+		t.Errorf("GenerateJWT() lifetime = %v, want JWT_EXPIRATION_HOURS=2", lifetime)
+	}
+
+	now := time.Now()
+	base := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"user_id": 1,
+			"iss":     "auth.example.com",
+			"aud":     []interface{}{"other", "chat"},
+			"iat":     now.Unix(),
+			"nbf":     now.Unix(),
+			"exp":     now.Add(time.Hour).Unix(),
+		}
+	}
+
+	tests := []struct {
+		name    string
+		mutate  func(jwt.MapClaims)
+		wantErr bool
+	}{
+		{name: "Audience list containing this service", mutate: func(c jwt.MapClaims) {}, wantErr: false},
+		{name: "Expired within clock skew", mutate: func(c jwt.MapClaims) { c["exp"] = now.Add(-10 * time.Second).Unix() }, wantErr: false},
+		{name: "Expired beyond clock skew", mutate: func(c jwt.MapClaims) { c["exp"] = now.Add(-time.Minute).Unix() }, wantErr: true},
+		{name: "Not yet valid within clock skew", mutate: func(c jwt.MapClaims) { c["nbf"] = now.Add(10 * time.Second).Unix() }, wantErr: false},
+		{name: "Not yet valid beyond clock skew", mutate: func(c jwt.MapClaims) { c["nbf"] = now.Add(time.Minute).Unix() }, wantErr: true},
+		{name: "Issued in the future", mutate: func(c jwt.MapClaims) { c["iat"] = now.Add(time.Minute).Unix() }, wantErr: true},
+		{name: "Missing expiry", mutate: func(c jwt.MapClaims) { delete(c, "exp") }, wantErr: true},
+		{name: "Wrong issuer", mutate: func(c jwt.MapClaims) { c["iss"] = "evil.example.com" }, wantErr: true},
+		{name: "Wrong audience", mutate: func(c jwt.MapClaims) { c["aud"] = "billing" }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := base()
+			tt.mutate(claims)
+			token, err := keys.Sign(claims)
+			if err != nil {
+				t.Fatalf("Sign() error = %v", err)
+			}
+			if _, err := tokens.ValidateJWT(token); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateJWT() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := pkg.NewTokenService(&config.Config{JWTIssuer: "a", JWTAudience: "b", JWTExpiration: "soon"}, keys); err == nil {
+		t.Error("NewTokenService() accepted a non-numeric JWT_EXPIRATION_HOURS")
+	}
+	// Tokens that expire as they are issued are useless, but a strict clock skew is not
+	if _, err := pkg.NewTokenService(&config.Config{JWTIssuer: "a", JWTAudience: "b", JWTExpiration: "0"}, keys); err == nil {
+		t.Error("NewTokenService() accepted a JWT_EXPIRATION_HOURS of 0")
+	}
+	if _, err := pkg.NewTokenService(&config.Config{JWTIssuer: "a", JWTAudience: "b", RefreshTokenExpiration: "0"}, keys); err == nil {
+		t.Error("NewTokenService() accepted a REFRESH_TOKEN_EXPIRATION_HOURS of 0")
+	}
+	if _, err := pkg.NewTokenService(&config.Config{JWTIssuer: "a", JWTAudience: "b", JWTClockSkew: "0"}, keys); err != nil {
+		t.Errorf("NewTokenService() with a JWT_CLOCK_SKEW_SECONDS of 0 error = %v", err)
+	}
+}
+
+func TestLoadKeySet(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	writePEM(t, filepath.Join(dir, "old.pub.pem"), "PUBLIC KEY", mustMarshalPKIX(t, &rsaKey.PublicKey))
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v", err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(edKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	writePEM(t, filepath.Join(dir, "current.pem"), "PRIVATE KEY", pkcs8)
+
+	keys, err := pkg.LoadKeySet(dir, "current", "")
+	if err != nil {
+		t.Fatalf("LoadKeySet() error = %v", err)
+	}
+	if keys.Active().ID != "current" || keys.Active().Method.Alg() != "EdDSA" {
+		t.Errorf("LoadKeySet() active key = %s/%s, want current/EdDSA", keys.Active().ID, keys.Active().Method.Alg())
+	}
+	if got := len(keys.JWKS().Keys); got != 2 {
+		t.Errorf("LoadKeySet() published %d keys, want 2", got)
+	}
+
+	if _, err := pkg.LoadKeySet(dir, "old", ""); err == nil {
+		t.Error("LoadKeySet() accepted a public key as the active signing key")
+	}
+}
+
+func mustMarshalPKIX(t *testing.T, publicKey interface{}) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal public key: %v", err)
+	}
+	return der
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
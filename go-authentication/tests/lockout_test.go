@@ -0,0 +1,193 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"go-authentication/config"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/usecase"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var testLockoutPolicy = usecase.LockoutPolicy{
+	MaxAccountFailures: 3,
+	MaxIPFailures:      5,
+	BaseLockout:        time.Minute,
+	MaxLockout:         4 * time.Minute,
+	FailureWindow:      15 * time.Minute,
+}
+
+// Mock login throttle repository for testing
+type mockLoginThrottleRepo struct {
+	throttles map[string]*domain.LoginThrottle
+	events    []domain.LockoutEvent
+}
+
+func newMockLoginThrottleRepo() *mockLoginThrottleRepo {
+	return &mockLoginThrottleRepo{throttles: make(map[string]*domain.LoginThrottle)}
+}
+
+func (m *mockLoginThrottleRepo) Get(ctx context.Context, key string) (*domain.LoginThrottle, error) {
+	throttle, exists := m.throttles[key]
+	if !exists {
+		return &domain.LoginThrottle{Key: key}, nil
+	}
+	copied := *throttle
+	return &copied, nil
+}
+
+func (m *mockLoginThrottleRepo) RecordFailure(ctx context.Context, key string, at, windowStart time.Time) (*domain.LoginThrottle, error) {
+	throttle, exists := m.throttles[key]
+	if !exists {
+		throttle = &domain.LoginThrottle{Key: key}
+		m.throttles[key] = throttle
+	}
+
+	lastActivity := time.Time{}
+	if throttle.LastFailureAt != nil {
+		lastActivity = *throttle.LastFailureAt
+	}
+	if throttle.LockedUntil != nil && throttle.LockedUntil.After(lastActivity) {
+		lastActivity = *throttle.LockedUntil
+	}
+	if lastActivity.Before(windowStart) {
+		throttle.Failures = 0
+	}
+
+	throttle.Failures++
+	throttle.LastFailureAt = &at
+	copied := *throttle
+	return &copied, nil
+}
+
+func (m *mockLoginThrottleRepo) Lock(ctx context.Context, key string, until time.Time) error {
+	m.throttles[key].LockedUntil = &until
+	return nil
+}
+
+func (m *mockLoginThrottleRepo) Reset(ctx context.Context, key string) error {
+	delete(m.throttles, key)
+	return nil
+}
+
+func (m *mockLoginThrottleRepo) RecordEvent(ctx context.Context, event *domain.LockoutEvent) error {
+	event.ID = len(m.events) + 1
+	event.CreatedAt = time.Now()
+	m.events = append(m.events, *event)
+	return nil
+}
+
+// expireLock pretends the lockout of key ended a moment ago
+func (m *mockLoginThrottleRepo) expireLock(key string) {
+	past := time.Now().Add(-time.Second)
+	m.throttles[key].LockedUntil = &past
+}
+
+func TestLoginLockout(t *testing.T) {
+	password := "password123456"
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+
+	verifiedAt := time.Now()
+	repo := &mockAuthUserRepo{
+		users: map[int]*domain.User{
+			1: {ID: 1, Name: "Test User", Email: "test@example.com", Password: string(hashedPassword), EmailVerifiedAt: &verifiedAt},
+			2: {ID: 2, Name: "Other User", Email: "other@example.com", Password: string(hashedPassword), EmailVerifiedAt: &verifiedAt},
+		},
+	}
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), &recordingMailer{})
+	throttles := authUsecase.Lockout.ThrottleRepo.(*mockLoginThrottleRepo)
+	ctx := context.Background()
+	attacker := domain.ClientInfo{IP: "203.0.113.7", UserAgent: "test"}
+
+	for i := 0; i < testLockoutPolicy.MaxAccountFailures; i++ {
+		if _, err := authUsecase.Login(ctx, "test@example.com", "wrong-password", attacker); err == nil {
+			t.Fatal("Login() accepted a wrong password")
+		}
+	}
+
+	// Locked accounts refuse even the right password, from any address
+	_, err = authUsecase.Login(ctx, "TEST@example.com", password, domain.ClientInfo{IP: "198.51.100.1"})
+	var lockedErr *usecase.LoginLockedError
+	if !errors.As(err, &lockedErr) {
+		t.Fatalf("Login() on locked account error = %v, want LoginLockedError", err)
+	}
+	if lockedErr.RetryAfter <= 0 || lockedErr.RetryAfter > testLockoutPolicy.BaseLockout {
+		t.Errorf("Login() RetryAfter = %v, want at most %v", lockedErr.RetryAfter, testLockoutPolicy.BaseLockout)
+	}
+
+	if len(throttles.events) != 1 {
+		t.Fatalf("recorded %d lockout events, want 1", len(throttles.events))
+	}
+	event := throttles.events[0]
+	if event.Action != domain.LockoutActionLocked || event.UserID == nil || *event.UserID != 1 || event.IP != attacker.IP {
+		t.Errorf("lockout event = %+v", event)
+	}
+
+	// Every failure after the lockout expires doubles it
+	throttles.expireLock("email:test@example.com")
+	authUsecase.Login(ctx, "test@example.com", "wrong-password", domain.ClientInfo{IP: "198.51.100.1"})
+	throttle, _ := throttles.Get(ctx, "email:test@example.com")
+	if got := time.Until(*throttle.LockedUntil); got <= time.Minute || got > 2*time.Minute {
+		t.Errorf("second lockout lasts %v, want 2m", got)
+	}
+
+	// The attacking IP is locked out for every account once it reaches its own threshold
+	authUsecase.Login(ctx, "nobody@example.com", "wrong-password", attacker)
+	authUsecase.Login(ctx, "nobody@example.com", "wrong-password", attacker)
+	if _, err := authUsecase.Login(ctx, "other@example.com", password, attacker); !errors.As(err, &lockedErr) {
+		t.Errorf("Login() from locked IP error = %v, want LoginLockedError", err)
+	}
+	if _, err := authUsecase.Login(ctx, "other@example.com", password, domain.ClientInfo{IP: "198.51.100.2"}); err != nil {
+		t.Errorf("Login() from another IP error = %v", err)
+	}
+
+	// An admin can lift the account lockout
+	if err := authUsecase.Lockout.Unlock(ctx, 1, 2); err != nil {
+		t.Fatalf("Unlock() error = %v", err)
+	}
+	if _, err := authUsecase.Login(ctx, "test@example.com", password, domain.ClientInfo{IP: "198.51.100.3"}); err != nil {
+		t.Errorf("Login() after unlock error = %v", err)
+	}
+	if last := throttles.events[len(throttles.events)-1]; last.Action != domain.LockoutActionUnlocked {
+		t.Errorf("last lockout event action = %s, want %s", last.Action, domain.LockoutActionUnlocked)
+	}
+	if err := authUsecase.Lockout.Unlock(ctx, 42, 2); !errors.Is(err, usecase.ErrUserNotFound) {
+		t.Errorf("Unlock() unknown user error = %v, want %v", err, usecase.ErrUserNotFound)
+	}
+}
+
+func TestNewLockoutPolicy(t *testing.T) {
+	policy, err := usecase.NewLockoutPolicy(&config.Config{})
+	if err != nil {
+		t.Fatalf("NewLockoutPolicy() error = %v", err)
+	}
+	if policy.MaxAccountFailures != 5 || policy.MaxIPFailures != 20 || policy.BaseLockout != time.Minute {
+		t.Errorf("NewLockoutPolicy() defaults = %+v", policy)
+	}
+
+	policy, err = usecase.NewLockoutPolicy(&config.Config{LoginMaxAccountFailures: "10", LoginLockoutSeconds: "30", LoginMaxLockoutSeconds: "600"})
+	if err != nil {
+		t.Fatalf("NewLockoutPolicy() error = %v", err)
+	}
+	if policy.MaxAccountFailures != 10 || policy.BaseLockout != 30*time.Second || policy.MaxLockout != 10*time.Minute {
+		t.Errorf("NewLockoutPolicy() = %+v", policy)
+	}
+
+	invalid := []*config.Config{
+		{LoginMaxAccountFailures: "0"},
+		{LoginMaxIPFailures: "many"},
+		{LoginLockoutSeconds: "120", LoginMaxLockoutSeconds: "60"},
+		{LoginFailureWindowSeconds: "0"},
+	}
+	for _, cfg := range invalid {
+		if _, err := usecase.NewLockoutPolicy(cfg); err == nil {
+			t.Errorf("NewLockoutPolicy(%+v) expected error", cfg)
+		}
+	}
+}
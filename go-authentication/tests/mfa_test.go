@@ -0,0 +1,282 @@
+package tests
+
+import (
+	"context"
+	"encoding/base32"
+	"errors"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/repository"
+	"go-authentication/internal/usecase"
+	"go-authentication/pkg"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Mock MFA repository for testing
+type mockMFARepo struct {
+	enrollments   map[int]*domain.UserMFA
+	recoveryCodes map[int]map[string]bool
+}
+
+func newMockMFARepo() *mockMFARepo {
+	return &mockMFARepo{
+		enrollments:   make(map[int]*domain.UserMFA),
+		recoveryCodes: make(map[int]map[string]bool),
+	}
+}
+
+func (m *mockMFARepo) GetByUserID(ctx context.Context, userID int) (*domain.UserMFA, error) {
+	mfa, exists := m.enrollments[userID]
+	if !exists {
+		return nil, repository.ErrMFANotFound
+	}
+	copied := *mfa
+	return &copied, nil
+}
+
+func (m *mockMFARepo) SavePending(ctx context.Context, mfa *domain.UserMFA) error {
+	if existing, exists := m.enrollments[mfa.UserID]; exists && existing.IsEnabled() {
+		return repository.ErrMFAAlreadyEnabled
+	}
+	mfa.CreatedAt = time.Now()
+	copied := *mfa
+	m.enrollments[mfa.UserID] = &copied
+	return nil
+}
+
+func (m *mockMFARepo) Enable(ctx context.Context, userID int, step int64, recoveryCodeHashes []string) error {
+	mfa, exists := m.enrollments[userID]
+	if !exists || mfa.IsEnabled() {
+		return repository.ErrMFAAlreadyEnabled
+	}
+	now := time.Now()
+	mfa.EnabledAt = &now
+	mfa.LastUsedStep = step
+	return m.ReplaceRecoveryCodes(ctx, userID, recoveryCodeHashes)
+}
+
+func (m *mockMFARepo) Delete(ctx context.Context, userID int) error {
+	delete(m.enrollments, userID)
+	delete(m.recoveryCodes, userID)
+	return nil
+}
+
+func (m *mockMFARepo) UseStep(ctx context.Context, userID int, step int64) error {
+	mfa, exists := m.enrollments[userID]
+	if !exists || mfa.LastUsedStep >= step {
+		return repository.ErrTOTPStepUsed
+	}
+	mfa.LastUsedStep = step
+	return nil
+}
+
+func (m *mockMFARepo) ReplaceRecoveryCodes(ctx context.Context, userID int, recoveryCodeHashes []string) error {
+	codes := make(map[string]bool)
+	for _, hash := range recoveryCodeHashes {
+		codes[hash] = false
+	}
+	m.recoveryCodes[userID] = codes
+	return nil
+}
+
+func (m *mockMFARepo) UseRecoveryCode(ctx context.Context, userID int, codeHash string) error {
+	used, exists := m.recoveryCodes[userID][codeHash]
+	if !exists || used {
+		return repository.ErrRecoveryCodeInvalid
+	}
+	m.recoveryCodes[userID][codeHash] = true
+	return nil
+}
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B test vectors for SHA1, truncated to six digits
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		got, err := pkg.TOTPCode(secret, pkg.TOTPStep(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("TOTPCode() error = %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("TOTPCode(%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+
+	now := time.Unix(1111111111, 0)
+	if _, ok := pkg.ValidateTOTP(secret, "081804", now, 1); !ok {
+		t.Error("ValidateTOTP() rejected a code from the previous step")
+	}
+	if _, ok := pkg.ValidateTOTP(secret, "287082", now, 1); ok {
+		t.Error("ValidateTOTP() accepted a code far outside the skew window")
+	}
+}
+
+func TestMFALogin(t *testing.T) {
+	password := "password123456"
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+
+	verifiedAt := time.Now()
+	repo := &mockAuthUserRepo{
+		users: map[int]*domain.User{
+			1: {ID: 1, Name: "Test User", Email: "test@example.com", Password: string(hashedPassword), EmailVerifiedAt: &verifiedAt},
+		},
+	}
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), &recordingMailer{})
+	mfa := authUsecase.MFA
+	ctx := context.Background()
+
+	enrollment, err := mfa.Enroll(ctx, 1)
+	if err != nil {
+		t.Fatalf("Enroll() error = %v", err)
+	}
+	uri, err := url.Parse(enrollment.ProvisioningURI)
+	if err != nil || uri.Scheme != "otpauth" || uri.Query().Get("secret") != enrollment.Secret {
+		t.Errorf("Enroll() provisioning URI = %q", enrollment.ProvisioningURI)
+	}
+
+	// A pending enrollment does not change login
+	if _, err := authUsecase.Login(ctx, "test@example.com", password, domain.ClientInfo{}); err != nil {
+		t.Fatalf("Login() before enabling mfa error = %v", err)
+	}
+
+	if _, err := mfa.Enable(ctx, 1, "000000"); !errors.Is(err, usecase.ErrInvalidMFACode) {
+		t.Errorf("Enable() with wrong code error = %v, want %v", err, usecase.ErrInvalidMFACode)
+	}
+
+	// Use the previous step so the login below can use a fresh one
+	previous := pkg.TOTPStep(time.Now()) - 1
+	code, _ := pkg.TOTPCode(enrollment.Secret, previous)
+	recoveryCodes, err := mfa.Enable(ctx, 1, code)
+	if err != nil {
+		t.Fatalf("Enable() error = %v", err)
+	}
+	if len(recoveryCodes) != 10 {
+		t.Errorf("Enable() returned %d recovery codes, want 10", len(recoveryCodes))
+	}
+
+	_, err = authUsecase.Login(ctx, "test@example.com", password, domain.ClientInfo{})
+	var mfaErr *usecase.MFARequiredError
+	if !errors.As(err, &mfaErr) {
+		t.Fatalf("Login() error = %v, want MFARequiredError", err)
+	}
+	challenge := mfaErr.Challenge.MFAToken
+
+	// The challenge is not an access token
+	if _, err := authUsecase.ValidateAccessToken(ctx, challenge); err == nil {
+		t.Error("ValidateAccessToken() accepted an mfa challenge")
+	}
+
+	if _, err := authUsecase.LoginMFA(ctx, challenge, code, domain.ClientInfo{}); !errors.Is(err, usecase.ErrInvalidMFACode) {
+		t.Errorf("LoginMFA() with replayed code error = %v, want %v", err, usecase.ErrInvalidMFACode)
+	}
+
+	current, _ := pkg.TOTPCode(enrollment.Secret, previous+1)
+	tokens, err := authUsecase.LoginMFA(ctx, challenge, current, domain.ClientInfo{})
+	if err != nil {
+		t.Fatalf("LoginMFA() error = %v", err)
+	}
+	if _, err := authUsecase.ValidateAccessToken(ctx, tokens.AccessToken); err != nil {
+		t.Errorf("ValidateAccessToken() after mfa login error = %v", err)
+	}
+
+	// Challenges are single-use
+	if _, err := authUsecase.LoginMFA(ctx, challenge, recoveryCodes[0], domain.ClientInfo{}); !errors.Is(err, usecase.ErrInvalidMFAChallenge) {
+		t.Errorf("LoginMFA() with reused challenge error = %v, want %v", err, usecase.ErrInvalidMFAChallenge)
+	}
+
+	// Recovery codes work once, in any case and without the dash
+	_, err = authUsecase.Login(ctx, "test@example.com", password, domain.ClientInfo{})
+	if !errors.As(err, &mfaErr) {
+		t.Fatalf("Login() error = %v, want MFARequiredError", err)
+	}
+	typed := strings.ToUpper(strings.ReplaceAll(recoveryCodes[0], "-", ""))
+	if _, err := authUsecase.LoginMFA(ctx, mfaErr.Challenge.MFAToken, typed, domain.ClientInfo{}); err != nil {
+		t.Fatalf("LoginMFA() with recovery code error = %v", err)
+	}
+
+	_, err = authUsecase.Login(ctx, "test@example.com", password, domain.ClientInfo{})
+	if !errors.As(err, &mfaErr) {
+		t.Fatalf("Login() error = %v, want MFARequiredError", err)
+	}
+	if _, err := authUsecase.LoginMFA(ctx, mfaErr.Challenge.MFAToken, recoveryCodes[0], domain.ClientInfo{}); !errors.Is(err, usecase.ErrInvalidMFACode) {
+		t.Errorf("LoginMFA() with used recovery code error = %v, want %v", err, usecase.ErrInvalidMFACode)
+	}
+
+	// Disabling needs the password as well as a code
+	if err := mfa.Disable(ctx, 1, &domain.MFADisableRequest{Code: recoveryCodes[1], Password: "wrong password"}); !errors.Is(err, usecase.ErrIncorrectPassword) {
+		t.Errorf("Disable() with wrong password error = %v, want %v", err, usecase.ErrIncorrectPassword)
+	}
+	if err := mfa.Disable(ctx, 1, &domain.MFADisableRequest{Code: recoveryCodes[1], Password: password}); err != nil {
+		t.Fatalf("Disable() error = %v", err)
+	}
+	if _, err := authUsecase.Login(ctx, "test@example.com", password, domain.ClientInfo{}); err != nil {
+		t.Errorf("Login() after disabling mfa error = %v", err)
+	}
+}
+
+func TestMFACodeAttemptsLockOut(t *testing.T) {
+	password := "password123456"
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+
+	verifiedAt := time.Now()
+	repo := &mockAuthUserRepo{
+		users: map[int]*domain.User{
+			1: {ID: 1, Name: "Test User", Email: "test@example.com", Password: string(hashedPassword), EmailVerifiedAt: &verifiedAt},
+		},
+	}
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), &recordingMailer{})
+	mfa := authUsecase.MFA
+	ctx := domain.ContextWithClientInfo(context.Background(), domain.ClientInfo{IP: "203.0.113.7"})
+
+	enrollment, err := mfa.Enroll(ctx, 1)
+	if err != nil {
+		t.Fatalf("Enroll() error = %v", err)
+	}
+	code, _ := pkg.TOTPCode(enrollment.Secret, pkg.TOTPStep(time.Now())-1)
+	recoveryCodes, err := mfa.Enable(ctx, 1, code)
+	if err != nil {
+		t.Fatalf("Enable() error = %v", err)
+	}
+
+	// A signed in session must not be a way to guess codes without limit, even knowing the password
+	for i := 0; i < testLockoutPolicy.MaxAccountFailures-1; i++ {
+		err := mfa.Disable(ctx, 1, &domain.MFADisableRequest{Code: "000000", Password: password})
+		if !errors.Is(err, usecase.ErrInvalidMFACode) {
+			t.Fatalf("Disable() attempt %d error = %v, want %v", i+1, err, usecase.ErrInvalidMFACode)
+		}
+	}
+	if _, err := mfa.RegenerateRecoveryCodes(ctx, 1, "000000"); !errors.Is(err, usecase.ErrInvalidMFACode) {
+		t.Fatalf("RegenerateRecoveryCodes() error = %v, want %v", err, usecase.ErrInvalidMFACode)
+	}
+
+	var lockedErr *usecase.LoginLockedError
+	if _, err := mfa.RegenerateRecoveryCodes(ctx, 1, recoveryCodes[0]); !errors.As(err, &lockedErr) {
+		t.Errorf("RegenerateRecoveryCodes() on locked account error = %v, want LoginLockedError", err)
+	}
+	if err := mfa.Disable(ctx, 1, &domain.MFADisableRequest{Code: recoveryCodes[0], Password: password}); !errors.As(err, &lockedErr) {
+		t.Errorf("Disable() on locked account error = %v, want LoginLockedError", err)
+	}
+	if enabled, _ := mfa.IsEnabled(ctx, 1); !enabled {
+		t.Error("Disable() turned off mfa on a locked account")
+	}
+}
@@ -0,0 +1,553 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-authentication/internal/delivery"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/repository"
+	"go-authentication/internal/usecase"
+	"go-authentication/pkg"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Mock OAuth repository for testing
+type mockOAuthRepo struct {
+	clients       map[string]*domain.OAuthClient
+	codes         map[string]*domain.OAuthAuthorizationCode
+	consents      map[string]*domain.OAuthConsent
+	refreshTokens map[int]*domain.OAuthRefreshToken
+}
+
+func newMockOAuthRepo() *mockOAuthRepo {
+	return &mockOAuthRepo{
+		clients:       make(map[string]*domain.OAuthClient),
+		codes:         make(map[string]*domain.OAuthAuthorizationCode),
+		consents:      make(map[string]*domain.OAuthConsent),
+		refreshTokens: make(map[int]*domain.OAuthRefreshToken),
+	}
+}
+
+func consentKey(userID int, clientID string) string {
+	return fmt.Sprintf("%d/%s", userID, clientID)
+}
+
+func (m *mockOAuthRepo) CreateClient(ctx context.Context, client *domain.OAuthClient) error {
+	client.ID = len(m.clients) + 1
+	client.CreatedAt = time.Now()
+	m.clients[client.ClientID] = client
+	return nil
+}
+
+func (m *mockOAuthRepo) GetClient(ctx context.Context, clientID string) (*domain.OAuthClient, error) {
+	client, exists := m.clients[clientID]
+	if !exists {
+		return nil, repository.ErrOAuthClientNotFound
+	}
+	return client, nil
+}
+
+func (m *mockOAuthRepo) ListClients(ctx context.Context) ([]domain.OAuthClient, error) {
+	clients := []domain.OAuthClient{}
+	for _, client := range m.clients {
+		clients = append(clients, *client)
+	}
+	return clients, nil
+}
+
+func (m *mockOAuthRepo) RevokeClient(ctx context.Context, clientID string) error {
+	client, exists := m.clients[clientID]
+	if !exists || client.RevokedAt != nil {
+		return repository.ErrOAuthClientNotFound
+	}
+	now := time.Now()
+	client.RevokedAt = &now
+	for _, token := range m.refreshTokens {
+		if token.ClientID == clientID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (m *mockOAuthRepo) SaveAuthorizationCode(ctx context.Context, code *domain.OAuthAuthorizationCode) error {
+	code.CreatedAt = time.Now()
+	m.codes[code.CodeHash] = code
+	return nil
+}
+
+func (m *mockOAuthRepo) ConsumeAuthorizationCode(ctx context.Context, codeHash string) (*domain.OAuthAuthorizationCode, error) {
+	code, exists := m.codes[codeHash]
+	if !exists {
+		return nil, repository.ErrAuthorizationCodeNotFound
+	}
+	delete(m.codes, codeHash)
+	return code, nil
+}
+
+func (m *mockOAuthRepo) GetConsent(ctx context.Context, userID int, clientID string) (*domain.OAuthConsent, error) {
+	consent, exists := m.consents[consentKey(userID, clientID)]
+	if !exists {
+		return nil, repository.ErrConsentNotFound
+	}
+	copied := *consent
+	return &copied, nil
+}
+
+func (m *mockOAuthRepo) SaveConsent(ctx context.Context, consent *domain.OAuthConsent) error {
+	now := time.Now()
+	consent.GrantedAt, consent.UpdatedAt = now, now
+	if existing, exists := m.consents[consentKey(consent.UserID, consent.ClientID)]; exists {
+		consent.GrantedAt = existing.GrantedAt
+	}
+	if client, exists := m.clients[consent.ClientID]; exists {
+		consent.ClientName = client.Name
+	}
+	stored := *consent
+	m.consents[consentKey(consent.UserID, consent.ClientID)] = &stored
+	return nil
+}
+
+func (m *mockOAuthRepo) ListConsents(ctx context.Context, userID int) ([]domain.OAuthConsent, error) {
+	consents := []domain.OAuthConsent{}
+	for _, consent := range m.consents {
+		if consent.UserID == userID {
+			consents = append(consents, *consent)
+		}
+	}
+	return consents, nil
+}
+
+func (m *mockOAuthRepo) DeleteConsent(ctx context.Context, userID int, clientID string) error {
+	if _, exists := m.consents[consentKey(userID, clientID)]; !exists {
+		return repository.ErrConsentNotFound
+	}
+	delete(m.consents, consentKey(userID, clientID))
+	return nil
+}
+
+func (m *mockOAuthRepo) CreateRefreshToken(ctx context.Context, token *domain.OAuthRefreshToken) error {
+	token.ID = len(m.refreshTokens) + 1
+	token.CreatedAt = time.Now()
+	m.refreshTokens[token.ID] = token
+	return nil
+}
+
+func (m *mockOAuthRepo) GetRefreshToken(ctx context.Context, tokenHash string) (*domain.OAuthRefreshToken, error) {
+	for _, token := range m.refreshTokens {
+		if token.TokenHash == tokenHash {
+			copied := *token
+			return &copied, nil
+		}
+	}
+	return nil, repository.ErrOAuthRefreshTokenNotFound
+}
+
+func (m *mockOAuthRepo) RotateRefreshToken(ctx context.Context, oldTokenID int, next *domain.OAuthRefreshToken) error {
+	old, exists := m.refreshTokens[oldTokenID]
+	if !exists || old.RotatedAt != nil || old.RevokedAt != nil {
+		return repository.ErrOAuthRefreshTokenConsumed
+	}
+	now := time.Now()
+	old.RotatedAt = &now
+	return m.CreateRefreshToken(ctx, next)
+}
+
+func (m *mockOAuthRepo) RevokeRefreshToken(ctx context.Context, id int) error {
+	if token, exists := m.refreshTokens[id]; exists && token.RevokedAt == nil {
+		now := time.Now()
+		token.RevokedAt = &now
+	}
+	return nil
+}
+
+func (m *mockOAuthRepo) RevokeGrant(ctx context.Context, userID int, clientID string) error {
+	now := time.Now()
+	for _, token := range m.refreshTokens {
+		if token.UserID == userID && token.ClientID == clientID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+// newTestOAuthUsecase wires an OAuthUsecase sharing the token service and revocations of authUsecase
+func newTestOAuthUsecase(authUsecase *usecase.AuthUsecase, repo repository.UserRepository) *usecase.OAuthUsecase {
+	return usecase.NewOAuthUsecase(newMockOAuthRepo(), repo, authUsecase.Revocations, authUsecase.Tokens, authUsecase.Audit)
+}
+
+// expectOAuthError fails unless err is an *usecase.OAuthError with the given code
+func expectOAuthError(t *testing.T, err error, code string) {
+	t.Helper()
+	var oauthErr *usecase.OAuthError
+	if !errors.As(err, &oauthErr) || oauthErr.Code != code {
+		t.Errorf("error = %v, want OAuth error %q", err, code)
+	}
+}
+
+// redirectParams parses the query of a redirect back to the client
+func redirectParams(t *testing.T, response *domain.AuthorizationResponse) url.Values {
+	t.Helper()
+	if response == nil || response.RedirectTo == "" {
+		t.Fatalf("response = %+v, want a redirect", response)
+	}
+	redirect, err := url.Parse(response.RedirectTo)
+	if err != nil {
+		t.Fatalf("invalid redirect %q: %v", response.RedirectTo, err)
+	}
+	return redirect.Query()
+}
+
+func TestOAuthClientRegistration(t *testing.T) {
+	repo := &mockAuthUserRepo{users: make(map[int]*domain.User)}
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), &recordingMailer{})
+	oauth := newTestOAuthUsecase(authUsecase, repo)
+	ctx := context.Background()
+
+	invalid := []domain.CreateOAuthClientRequest{
+		{Name: " "},
+		{Name: "No redirect"},
+		{Name: "Plain http", RedirectURIs: []string{"http://app.example.com/callback"}},
+		{Name: "Fragment", RedirectURIs: []string{"https://app.example.com/callback#x"}},
+		{Name: "Relative", RedirectURIs: []string{"/callback"}},
+		{Name: "Public machine", GrantTypes: []string{domain.GrantTypeClientCredentials}},
+		{Name: "Refresh only", GrantTypes: []string{domain.GrantTypeRefreshToken}, Confidential: true},
+		{Name: "Implicit", GrantTypes: []string{"implicit"}, RedirectURIs: []string{"https://app.example.com/cb"}},
+		{Name: "Bad scope", RedirectURIs: []string{"https://app.example.com/cb"}, Scopes: []string{"Has Spaces"}},
+	}
+	for _, req := range invalid {
+		if _, err := oauth.RegisterClient(ctx, 1, &req); !errors.Is(err, usecase.ErrInvalidOAuthClient) {
+			t.Errorf("RegisterClient(%+v) error = %v, want %v", req, err, usecase.ErrInvalidOAuthClient)
+		}
+	}
+
+	public, err := oauth.RegisterClient(ctx, 1, &domain.CreateOAuthClientRequest{
+		Name:         "Mobile app",
+		RedirectURIs: []string{"com.example.app:/callback", "http://127.0.0.1:8765/callback"},
+	})
+	if err != nil {
+		t.Fatalf("RegisterClient() for a public client error = %v", err)
+	}
+	if public.ClientSecret != "" || public.Confidential {
+		t.Error("public client received a secret")
+	}
+	if len(public.GrantTypes) != 1 || public.GrantTypes[0] != domain.GrantTypeAuthorizationCode {
+		t.Errorf("grant types = %v, want the authorization code grant by default", public.GrantTypes)
+	}
+	if _, err := oauth.AuthenticateClient(ctx, public.ClientID, ""); err != nil {
+		t.Errorf("AuthenticateClient() for a public client error = %v", err)
+	}
+	_, err = oauth.AuthenticateClient(ctx, public.ClientID, "guess")
+	expectOAuthError(t, err, usecase.OAuthErrInvalidClient)
+
+	confidential, err := oauth.RegisterClient(ctx, 1, &domain.CreateOAuthClientRequest{
+		Name:         "Reports",
+		GrantTypes:   []string{domain.GrantTypeClientCredentials},
+		Scopes:       []string{"reports:read"},
+		Confidential: true,
+	})
+	if err != nil {
+		t.Fatalf("RegisterClient() for a confidential client error = %v", err)
+	}
+	if !strings.HasPrefix(confidential.ClientSecret, "gcs_") || confidential.SecretHash == confidential.ClientSecret {
+		t.Errorf("client secret = %q, want a gcs_ secret stored as a hash", confidential.ClientSecret)
+	}
+	if _, err := oauth.AuthenticateClient(ctx, confidential.ClientID, confidential.ClientSecret); err != nil {
+		t.Errorf("AuthenticateClient() error = %v", err)
+	}
+	_, err = oauth.AuthenticateClient(ctx, confidential.ClientID, "")
+	expectOAuthError(t, err, usecase.OAuthErrInvalidClient)
+
+	if err := oauth.RevokeClient(ctx, confidential.ClientID); err != nil {
+		t.Fatalf("RevokeClient() error = %v", err)
+	}
+	_, err = oauth.AuthenticateClient(ctx, confidential.ClientID, confidential.ClientSecret)
+	expectOAuthError(t, err, usecase.OAuthErrInvalidClient)
+}
+
+func TestOAuthAuthorizationCodeFlow(t *testing.T) {
+	repo := &mockAuthUserRepo{users: make(map[int]*domain.User)}
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), &recordingMailer{})
+	oauth := newTestOAuthUsecase(authUsecase, repo)
+	ctx := context.Background()
+
+	user := signupVerified(t, authUsecase, repo, "Test User", "test@example.com", "correct horse battery")
+	registered, err := oauth.RegisterClient(ctx, user.ID, &domain.CreateOAuthClientRequest{
+		Name:         "Wiki",
+		RedirectURIs: []string{"https://wiki.example.com/callback"},
+		GrantTypes:   []string{domain.GrantTypeAuthorizationCode, domain.GrantTypeRefreshToken},
+		Scopes:       []string{"profile", domain.ScopeOfflineAccess},
+		Confidential: true,
+	})
+	if err != nil {
+		t.Fatalf("RegisterClient() error = %v", err)
+	}
+	client := registered.OAuthClient
+
+	verifier := "a-very-long-code-verifier-with-enough-entropy-1234567890"
+	req := &domain.AuthorizationRequest{
+		ResponseType:        "code",
+		ClientID:            client.ClientID,
+		RedirectURI:         "https://wiki.example.com/callback",
+		Scope:               "profile offline_access",
+		State:               "xyz",
+		CodeChallenge:       pkg.PKCEChallenge(verifier),
+		CodeChallengeMethod: "S256",
+	}
+
+	// The first authorization asks for consent
+	response, err := oauth.Authorize(ctx, user.ID, req)
+	if err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+	if !response.ConsentRequired || response.ClientName != "Wiki" || len(response.Scopes) != 2 {
+		t.Fatalf("Authorize() = %+v, want a consent prompt for both scopes", response)
+	}
+
+	response, err = oauth.Decide(ctx, user.ID, &domain.AuthorizationDecision{AuthorizationRequest: *req, Approve: true})
+	if err != nil {
+		t.Fatalf("Decide() error = %v", err)
+	}
+	params := redirectParams(t, response)
+	if params.Get("state") != "xyz" || params.Get("code") == "" {
+		t.Fatalf("redirect = %q, want a code and the state", response.RedirectTo)
+	}
+
+	// A wrong verifier fails and burns the code
+	tokenReq := &domain.TokenRequest{
+		GrantType:    domain.GrantTypeAuthorizationCode,
+		Code:         params.Get("code"),
+		RedirectURI:  req.RedirectURI,
+		CodeVerifier: "wrong-verifier",
+	}
+	_, err = oauth.Token(ctx, client, tokenReq)
+	expectOAuthError(t, err, usecase.OAuthErrInvalidGrant)
+	tokenReq.CodeVerifier = verifier
+	_, err = oauth.Token(ctx, client, tokenReq)
+	expectOAuthError(t, err, usecase.OAuthErrInvalidGrant)
+
+	// Consent is remembered, so the next authorization redirects straight back
+	response, err = oauth.Authorize(ctx, user.ID, req)
+	if err != nil {
+		t.Fatalf("Authorize() after consent error = %v", err)
+	}
+	tokenReq.Code = redirectParams(t, response).Get("code")
+	tokens, err := oauth.Token(ctx, client, tokenReq)
+	if err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+	if tokens.RefreshToken == "" || tokens.Scope != "profile offline_access" || tokens.TokenType != "Bearer" {
+		t.Errorf("Token() = %+v, want an access and refresh token for both scopes", tokens)
+	}
+
+	// OAuth access tokens are for other applications, not for this API
+	if _, err := authUsecase.ValidateAccessToken(ctx, tokens.AccessToken); err == nil {
+		t.Error("ValidateAccessToken() accepted an OAuth access token")
+	}
+	claims, err := authUsecase.Tokens.ValidatePurposeToken(pkg.TokenTypeOAuthAccess, tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("ValidatePurposeToken() error = %v", err)
+	}
+	if claims["client_id"] != client.ClientID || claims["sub"] != "1" || claims["scope"] != "profile offline_access" {
+		t.Errorf("access token claims = %v", claims)
+	}
+
+	introspection, err := oauth.Introspect(ctx, client, tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("Introspect() error = %v", err)
+	}
+	if !introspection.Active || introspection.Username != user.Email || introspection.ClientID != client.ClientID {
+		t.Errorf("Introspect() = %+v, want an active token of the user", introspection)
+	}
+
+	// Refresh tokens rotate; presenting an old one revokes the whole grant
+	refreshed, err := oauth.Token(ctx, client, &domain.TokenRequest{GrantType: domain.GrantTypeRefreshToken, RefreshToken: tokens.RefreshToken, Scope: "profile"})
+	if err != nil {
+		t.Fatalf("Token() refresh error = %v", err)
+	}
+	if refreshed.Scope != "profile" || refreshed.RefreshToken == tokens.RefreshToken {
+		t.Errorf("refresh = %+v, want a narrowed scope and a new refresh token", refreshed)
+	}
+	_, err = oauth.Token(ctx, client, &domain.TokenRequest{GrantType: domain.GrantTypeRefreshToken, RefreshToken: tokens.RefreshToken})
+	expectOAuthError(t, err, usecase.OAuthErrInvalidGrant)
+	_, err = oauth.Token(ctx, client, &domain.TokenRequest{GrantType: domain.GrantTypeRefreshToken, RefreshToken: refreshed.RefreshToken})
+	expectOAuthError(t, err, usecase.OAuthErrInvalidGrant)
+
+	// A revoked access token introspects as inactive
+	if err := oauth.Revoke(ctx, client, refreshed.AccessToken); err != nil {
+		t.Fatalf("Revoke() error = %v", err)
+	}
+	if introspection, _ := oauth.Introspect(ctx, client, refreshed.AccessToken); introspection.Active {
+		t.Error("Introspect() reports a revoked token as active")
+	}
+
+	// Withdrawing consent brings the prompt back
+	if err := oauth.RevokeConsent(ctx, user.ID, client.ClientID); err != nil {
+		t.Fatalf("RevokeConsent() error = %v", err)
+	}
+	if response, _ := oauth.Authorize(ctx, user.ID, req); !response.ConsentRequired {
+		t.Error("Authorize() after revoking consent did not ask for consent")
+	}
+}
+
+func TestOAuthAuthorizationErrors(t *testing.T) {
+	repo := &mockAuthUserRepo{users: make(map[int]*domain.User)}
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), &recordingMailer{})
+	oauth := newTestOAuthUsecase(authUsecase, repo)
+	ctx := context.Background()
+
+	registered, err := oauth.RegisterClient(ctx, 1, &domain.CreateOAuthClientRequest{
+		Name:         "Mobile app",
+		RedirectURIs: []string{"com.example.app:/callback"},
+		Scopes:       []string{"profile"},
+	})
+	if err != nil {
+		t.Fatalf("RegisterClient() error = %v", err)
+	}
+
+	valid := func() *domain.AuthorizationRequest {
+		return &domain.AuthorizationRequest{
+			ResponseType:        "code",
+			ClientID:            registered.ClientID,
+			Scope:               "profile",
+			State:               "s1",
+			CodeChallenge:       pkg.PKCEChallenge("verifier"),
+			CodeChallengeMethod: "S256",
+		}
+	}
+
+	// Never redirect to an unknown client or an unregistered URI
+	req := valid()
+	req.ClientID = "unknown"
+	_, err = oauth.Authorize(ctx, 1, req)
+	expectOAuthError(t, err, usecase.OAuthErrInvalidClient)
+	req = valid()
+	req.RedirectURI = "https://evil.example.com/callback"
+	_, err = oauth.Authorize(ctx, 1, req)
+	expectOAuthError(t, err, usecase.OAuthErrInvalidRequest)
+
+	// Other errors are sent back to the client with the state
+	tests := []struct {
+		name   string
+		modify func(req *domain.AuthorizationRequest)
+		want   string
+	}{
+		{"token response type", func(req *domain.AuthorizationRequest) { req.ResponseType = "token" }, usecase.OAuthErrUnsupportedResponseType},
+		{"no PKCE", func(req *domain.AuthorizationRequest) { req.CodeChallenge = "" }, usecase.OAuthErrInvalidRequest},
+		{"plain PKCE", func(req *domain.AuthorizationRequest) { req.CodeChallengeMethod = "plain" }, usecase.OAuthErrInvalidRequest},
+		{"unregistered scope", func(req *domain.AuthorizationRequest) { req.Scope = "profile admin" }, usecase.OAuthErrInvalidScope},
+	}
+	for _, tt := range tests {
+		req := valid()
+		tt.modify(req)
+		response, err := oauth.Authorize(ctx, 1, req)
+		if err != nil {
+			t.Errorf("%s: Authorize() error = %v, want a redirect", tt.name, err)
+			continue
+		}
+		params := redirectParams(t, response)
+		if params.Get("error") != tt.want || params.Get("state") != "s1" || !strings.HasPrefix(response.RedirectTo, "com.example.app:/callback") {
+			t.Errorf("%s: redirect = %q, want error %q", tt.name, response.RedirectTo, tt.want)
+		}
+	}
+
+	response, err := oauth.Decide(ctx, 1, &domain.AuthorizationDecision{AuthorizationRequest: *valid(), Approve: false})
+	if err != nil {
+		t.Fatalf("Decide() error = %v", err)
+	}
+	if params := redirectParams(t, response); params.Get("error") != usecase.OAuthErrAccessDenied || params.Get("code") != "" {
+		t.Errorf("denied redirect = %q, want access_denied", response.RedirectTo)
+	}
+}
+
+func TestOAuthClientCredentials(t *testing.T) {
+	repo := &mockAuthUserRepo{users: make(map[int]*domain.User)}
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), &recordingMailer{})
+	oauth := newTestOAuthUsecase(authUsecase, repo)
+	ctx := context.Background()
+
+	registered, err := oauth.RegisterClient(ctx, 1, &domain.CreateOAuthClientRequest{
+		Name:         "Billing",
+		GrantTypes:   []string{domain.GrantTypeClientCredentials},
+		Scopes:       []string{"invoices:read", "invoices:write"},
+		Confidential: true,
+	})
+	if err != nil {
+		t.Fatalf("RegisterClient() error = %v", err)
+	}
+	client := registered.OAuthClient
+
+	tokens, err := oauth.Token(ctx, client, &domain.TokenRequest{GrantType: domain.GrantTypeClientCredentials})
+	if err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+	if tokens.Scope != "invoices:read invoices:write" || tokens.RefreshToken != "" {
+		t.Errorf("Token() = %+v, want every registered scope and no refresh token", tokens)
+	}
+	introspection, err := oauth.Introspect(ctx, client, tokens.AccessToken)
+	if err != nil || !introspection.Active || introspection.Sub != client.ClientID || introspection.Username != "" {
+		t.Errorf("Introspect() = %+v, %v, want an active token with the client as subject", introspection, err)
+	}
+
+	_, err = oauth.Token(ctx, client, &domain.TokenRequest{GrantType: domain.GrantTypeClientCredentials, Scope: "invoices:delete"})
+	expectOAuthError(t, err, usecase.OAuthErrInvalidScope)
+	_, err = oauth.Token(ctx, client, &domain.TokenRequest{GrantType: domain.GrantTypeAuthorizationCode, Code: "x", CodeVerifier: "y"})
+	expectOAuthError(t, err, usecase.OAuthErrUnauthorizedClient)
+	_, err = oauth.Token(ctx, client, &domain.TokenRequest{GrantType: "password"})
+	expectOAuthError(t, err, usecase.OAuthErrUnsupportedGrantType)
+
+	// Tokens of a revoked client stop introspecting as active
+	if err := oauth.RevokeClient(ctx, client.ClientID); err != nil {
+		t.Fatalf("RevokeClient() error = %v", err)
+	}
+	if introspection, _ := oauth.Introspect(ctx, client, tokens.AccessToken); introspection.Active {
+		t.Error("Introspect() reports a token of a revoked client as active")
+	}
+}
+
+func TestOAuthTokenEndpoint(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := &mockAuthUserRepo{users: make(map[int]*domain.User)}
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), &recordingMailer{})
+	oauth := newTestOAuthUsecase(authUsecase, repo)
+	handler := delivery.NewOAuthHandler(oauth)
+
+	registered, err := oauth.RegisterClient(context.Background(), 1, &domain.CreateOAuthClientRequest{
+		Name:         "Billing",
+		GrantTypes:   []string{domain.GrantTypeClientCredentials},
+		Scopes:       []string{"invoices:read"},
+		Confidential: true,
+	})
+	if err != nil {
+		t.Fatalf("RegisterClient() error = %v", err)
+	}
+
+	router := gin.New()
+	router.POST("/oauth/token", handler.TokenHandler)
+
+	request := func(secret string) *httptest.ResponseRecorder {
+		form := url.Values{"grant_type": {domain.GrantTypeClientCredentials}}
+		req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth(registered.ClientID, secret)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := request(registered.ClientSecret); w.Code != http.StatusOK || w.Header().Get("Cache-Control") != "no-store" ||
+		!strings.Contains(w.Body.String(), `"access_token"`) {
+		t.Errorf("token request = %d %s, want 200 with an access token and no-store", w.Code, w.Body.String())
+	}
+	if w := request("wrong"); w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), `"invalid_client"`) {
+		t.Errorf("token request with a wrong secret = %d %s, want 401 invalid_client", w.Code, w.Body.String())
+	}
+}
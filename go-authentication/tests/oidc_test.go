@@ -0,0 +1,263 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"go-authentication/config"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/repository"
+	"go-authentication/internal/usecase"
+	"go-authentication/pkg"
+	"go-authentication/pkg/oidctest"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Mock user identity repository for testing
+type mockUserIdentityRepo struct {
+	identities []*domain.UserIdentity
+	states     map[string]*domain.OIDCLoginState
+}
+
+func newMockUserIdentityRepo() *mockUserIdentityRepo {
+	return &mockUserIdentityRepo{states: make(map[string]*domain.OIDCLoginState)}
+}
+
+func (m *mockUserIdentityRepo) GetByProviderSubject(ctx context.Context, provider, subject string) (*domain.UserIdentity, error) {
+	for _, identity := range m.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return identity, nil
+		}
+	}
+	return nil, repository.ErrIdentityNotFound
+}
+
+func (m *mockUserIdentityRepo) Create(ctx context.Context, identity *domain.UserIdentity) error {
+	identity.ID = len(m.identities) + 1
+	identity.CreatedAt = time.Now()
+	m.identities = append(m.identities, identity)
+	return nil
+}
+
+func (m *mockUserIdentityRepo) TouchLastLogin(ctx context.Context, id int, at time.Time) error {
+	for _, identity := range m.identities {
+		if identity.ID == id {
+			identity.LastLoginAt = &at
+			return nil
+		}
+	}
+	return repository.ErrIdentityNotFound
+}
+
+func (m *mockUserIdentityRepo) SaveLoginState(ctx context.Context, state *domain.OIDCLoginState) error {
+	m.states[state.StateHash] = state
+	return nil
+}
+
+func (m *mockUserIdentityRepo) ConsumeLoginState(ctx context.Context, stateHash string) (*domain.OIDCLoginState, error) {
+	state, exists := m.states[stateHash]
+	if !exists {
+		return nil, repository.ErrOIDCStateNotFound
+	}
+	delete(m.states, stateHash)
+	return state, nil
+}
+
+// newTestOIDCUsecase starts a fake provider and wires an OIDCUsecase against it
+func newTestOIDCUsecase(t *testing.T, authUsecase *usecase.AuthUsecase, repo repository.UserRepository) (*usecase.OIDCUsecase, *oidctest.Provider) {
+	t.Helper()
+	idp, err := oidctest.NewProvider("test-client", "test-secret")
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+	t.Cleanup(idp.Close)
+
+	provider, err := pkg.NewOIDCProvider(&config.Config{
+		OIDCProviderName: "test-idp",
+		OIDCIssuer:       idp.Issuer(),
+		OIDCClientID:     "test-client",
+		OIDCClientSecret: "test-secret",
+		AppBaseURL:       "http://localhost:8081",
+		OIDCScopes:       "email profile",
+	})
+	if err != nil {
+		t.Fatalf("NewOIDCProvider() error = %v", err)
+	}
+
+	return usecase.NewOIDCUsecase(provider, newMockUserIdentityRepo(), repo, authUsecase), idp
+}
+
+// authorizeAtProvider follows the login redirect to the provider and returns the state and code it sends back
+func authorizeAtProvider(t *testing.T, oidcUsecase *usecase.OIDCUsecase) (string, string) {
+	t.Helper()
+	authorization, err := oidcUsecase.StartLogin(context.Background())
+	if err != nil {
+		t.Fatalf("StartLogin() error = %v", err)
+	}
+
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authorization.URL)
+	if err != nil {
+		t.Fatalf("authorization request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if resp.StatusCode != http.StatusFound || err != nil {
+		t.Fatalf("authorization returned status %d, location %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	if location.Path != "/oidc/callback" {
+		t.Fatalf("provider redirected to %q, want the callback", location.Path)
+	}
+	if errCode := location.Query().Get("error"); errCode != "" {
+		t.Fatalf("provider returned error %q", errCode)
+	}
+	if location.Query().Get("state") != authorization.State {
+		t.Fatal("provider did not return the state")
+	}
+	return authorization.State, location.Query().Get("code")
+}
+
+func TestOIDCLoginCreatesAndLinksAccounts(t *testing.T) {
+	repo := &mockAuthUserRepo{users: make(map[int]*domain.User)}
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), &recordingMailer{})
+	oidcUsecase, idp := newTestOIDCUsecase(t, authUsecase, repo)
+	ctx := context.Background()
+
+	// A new identity with a verified email signs up a verified account
+	idp.SetUser(oidctest.User{Subject: "sub-1", Email: "New@Example.com", EmailVerified: true, Name: "New User"})
+	state, code := authorizeAtProvider(t, oidcUsecase)
+	tokens, err := oidcUsecase.Callback(ctx, state, code, domain.ClientInfo{})
+	if err != nil {
+		t.Fatalf("Callback() error = %v", err)
+	}
+	claims, err := authUsecase.ValidateAccessToken(ctx, tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("ValidateAccessToken() error = %v", err)
+	}
+	created, err := repo.GetByEmail(ctx, "new@example.com")
+	if err != nil {
+		t.Fatalf("account was not created: %v", err)
+	}
+	if !created.IsEmailVerified() || created.Name != "New User" {
+		t.Errorf("created account = %+v, want a verified account named New User", created)
+	}
+	if int(claims["user_id"].(float64)) != created.ID {
+		t.Errorf("token user_id = %v, want %d", claims["user_id"], created.ID)
+	}
+
+	// A code is single use, and so is the state
+	if _, err := oidcUsecase.Callback(ctx, state, code, domain.ClientInfo{}); !errors.Is(err, usecase.ErrInvalidOIDCState) {
+		t.Errorf("Callback() with a used state error = %v, want %v", err, usecase.ErrInvalidOIDCState)
+	}
+
+	// The next login with the same identity reuses the account even if the email changed at the provider
+	idp.SetUser(oidctest.User{Subject: "sub-1", Email: "renamed@example.com", EmailVerified: true})
+	state, code = authorizeAtProvider(t, oidcUsecase)
+	if _, err := oidcUsecase.Callback(ctx, state, code, domain.ClientInfo{}); err != nil {
+		t.Fatalf("Callback() for a linked identity error = %v", err)
+	}
+	if len(repo.users) != 1 {
+		t.Errorf("linked login created another account, have %d users", len(repo.users))
+	}
+
+	// An identity with the email of a verified local account is linked to it, whatever the case of either
+	local := signupVerified(t, authUsecase, repo, "Local User", "Local@Example.com", "correct horse battery")
+	idp.SetUser(oidctest.User{Subject: "sub-2", Email: "local@example.com", EmailVerified: true})
+	state, code = authorizeAtProvider(t, oidcUsecase)
+	tokens, err = oidcUsecase.Callback(ctx, state, code, domain.ClientInfo{})
+	if err != nil {
+		t.Fatalf("Callback() for an existing account error = %v", err)
+	}
+	claims, _ = authUsecase.ValidateAccessToken(ctx, tokens.AccessToken)
+	if int(claims["user_id"].(float64)) != local.ID {
+		t.Errorf("token user_id = %v, want the linked account %d", claims["user_id"], local.ID)
+	}
+	if len(repo.users) != 2 {
+		t.Errorf("linking a mixed-case account created another account, have %d users", len(repo.users))
+	}
+}
+
+func TestOIDCLoginRejectsUnverifiedEmails(t *testing.T) {
+	repo := &mockAuthUserRepo{users: make(map[int]*domain.User)}
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), &recordingMailer{})
+	oidcUsecase, idp := newTestOIDCUsecase(t, authUsecase, repo)
+	ctx := context.Background()
+
+	idp.SetUser(oidctest.User{Subject: "sub-1", Email: "someone@example.com", EmailVerified: false})
+	state, code := authorizeAtProvider(t, oidcUsecase)
+	if _, err := oidcUsecase.Callback(ctx, state, code, domain.ClientInfo{}); !errors.Is(err, usecase.ErrOIDCEmailNotVerified) {
+		t.Errorf("Callback() with an unverified email error = %v, want %v", err, usecase.ErrOIDCEmailNotVerified)
+	}
+
+	// A local account whose owner never verified the email must not be taken over
+	if err := authUsecase.Signup(ctx, &domain.User{Name: "Squatter", Email: "victim@example.com", Password: "correct horse battery"}); err != nil {
+		t.Fatalf("Signup() error = %v", err)
+	}
+	idp.SetUser(oidctest.User{Subject: "sub-2", Email: "victim@example.com", EmailVerified: true})
+	state, code = authorizeAtProvider(t, oidcUsecase)
+	if _, err := oidcUsecase.Callback(ctx, state, code, domain.ClientInfo{}); !errors.Is(err, usecase.ErrOIDCAccountNotVerified) {
+		t.Errorf("Callback() for an unverified account error = %v, want %v", err, usecase.ErrOIDCAccountNotVerified)
+	}
+}
+
+func TestOIDCLoginValidatesIDToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(claims jwt.MapClaims)
+	}{
+		{"wrong nonce", func(claims jwt.MapClaims) { claims["nonce"] = "replayed" }},
+		{"wrong audience", func(claims jwt.MapClaims) { claims["aud"] = "other-client" }},
+		{"wrong issuer", func(claims jwt.MapClaims) { claims["iss"] = "https://evil.example.com" }},
+		{"expired", func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-time.Hour).Unix() }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &mockAuthUserRepo{users: make(map[int]*domain.User)}
+			authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), &recordingMailer{})
+			oidcUsecase, idp := newTestOIDCUsecase(t, authUsecase, repo)
+			idp.SetUser(oidctest.User{Subject: "sub-1", Email: "user@example.com", EmailVerified: true})
+			idp.ModifyIDToken = tt.modify
+
+			state, code := authorizeAtProvider(t, oidcUsecase)
+			if _, err := oidcUsecase.Callback(context.Background(), state, code, domain.ClientInfo{}); !errors.Is(err, usecase.ErrOIDCLoginFailed) {
+				t.Errorf("Callback() error = %v, want %v", err, usecase.ErrOIDCLoginFailed)
+			}
+			if len(repo.users) != 0 {
+				t.Error("a rejected login created an account")
+			}
+		})
+	}
+}
+
+func TestOIDCLoginRequiresMFA(t *testing.T) {
+	repo := &mockAuthUserRepo{users: make(map[int]*domain.User)}
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), &recordingMailer{})
+	oidcUsecase, idp := newTestOIDCUsecase(t, authUsecase, repo)
+	ctx := context.Background()
+
+	user := signupVerified(t, authUsecase, repo, "Test User", "test@example.com", "correct horse battery")
+	enrollment, err := authUsecase.MFA.Enroll(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("Enroll() error = %v", err)
+	}
+	code, _ := pkg.TOTPCode(enrollment.Secret, pkg.TOTPStep(time.Now()))
+	if _, err := authUsecase.MFA.Enable(ctx, user.ID, code); err != nil {
+		t.Fatalf("Enable() error = %v", err)
+	}
+
+	idp.SetUser(oidctest.User{Subject: "sub-1", Email: "test@example.com", EmailVerified: true})
+	state, authCode := authorizeAtProvider(t, oidcUsecase)
+	_, err = oidcUsecase.Callback(ctx, state, authCode, domain.ClientInfo{})
+	var mfaErr *usecase.MFARequiredError
+	if !errors.As(err, &mfaErr) {
+		t.Errorf("Callback() error = %v, want an MFA challenge", err)
+	}
+}
@@ -0,0 +1,416 @@
+package tests
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"go-authentication/config"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/repository"
+	"go-authentication/internal/usecase"
+	"go-authentication/pkg"
+	"go-authentication/pkg/webauthntest"
+	"testing"
+	"time"
+)
+
+const (
+	testRPID   = "localhost"
+	testOrigin = "http://localhost:8081"
+)
+
+// Mock passkey repository for testing
+type mockPasskeyRepo struct {
+	passkeys   map[int]*domain.Passkey
+	challenges map[string]*domain.WebAuthnChallenge
+}
+
+func newMockPasskeyRepo() *mockPasskeyRepo {
+	return &mockPasskeyRepo{
+		passkeys:   make(map[int]*domain.Passkey),
+		challenges: make(map[string]*domain.WebAuthnChallenge),
+	}
+}
+
+func (m *mockPasskeyRepo) Create(ctx context.Context, passkey *domain.Passkey) error {
+	for _, existing := range m.passkeys {
+		if existing.CredentialID == passkey.CredentialID {
+			return repository.ErrPasskeyExists
+		}
+	}
+	passkey.ID = len(m.passkeys) + 1
+	passkey.CreatedAt = time.Now()
+	stored := *passkey
+	m.passkeys[passkey.ID] = &stored
+	return nil
+}
+
+func (m *mockPasskeyRepo) GetByCredentialID(ctx context.Context, credentialID string) (*domain.Passkey, error) {
+	for _, passkey := range m.passkeys {
+		if passkey.CredentialID == credentialID {
+			copied := *passkey
+			return &copied, nil
+		}
+	}
+	return nil, repository.ErrPasskeyNotFound
+}
+
+func (m *mockPasskeyRepo) ListByUser(ctx context.Context, userID int) ([]domain.Passkey, error) {
+	passkeys := []domain.Passkey{}
+	for _, passkey := range m.passkeys {
+		if passkey.UserID == userID {
+			passkeys = append(passkeys, *passkey)
+		}
+	}
+	return passkeys, nil
+}
+
+func (m *mockPasskeyRepo) Delete(ctx context.Context, id, userID int) error {
+	passkey, exists := m.passkeys[id]
+	if !exists || passkey.UserID != userID {
+		return repository.ErrPasskeyNotFound
+	}
+	delete(m.passkeys, id)
+	return nil
+}
+
+func (m *mockPasskeyRepo) UpdateSignCount(ctx context.Context, id int, signCount int64, usedAt time.Time) error {
+	passkey, exists := m.passkeys[id]
+	if !exists || (signCount != 0 && passkey.SignCount >= signCount) {
+		return repository.ErrPasskeySignCountStale
+	}
+	passkey.SignCount = signCount
+	passkey.LastUsedAt = &usedAt
+	return nil
+}
+
+func (m *mockPasskeyRepo) SaveChallenge(ctx context.Context, challenge *domain.WebAuthnChallenge) error {
+	challenge.CreatedAt = time.Now()
+	m.challenges[challenge.ChallengeHash] = challenge
+	return nil
+}
+
+func (m *mockPasskeyRepo) ConsumeChallenge(ctx context.Context, challengeHash string) (*domain.WebAuthnChallenge, error) {
+	challenge, exists := m.challenges[challengeHash]
+	if !exists {
+		return nil, repository.ErrWebAuthnChallengeNotFound
+	}
+	delete(m.challenges, challengeHash)
+	return challenge, nil
+}
+
+func newTestRelyingParty(t *testing.T) *pkg.WebAuthnRelyingParty {
+	t.Helper()
+	rp, err := pkg.NewWebAuthnRelyingParty(&config.Config{AppBaseURL: testOrigin, WebAuthnRPName: "go-authentication-test"})
+	if err != nil {
+		t.Fatalf("NewWebAuthnRelyingParty() error = %v", err)
+	}
+	return rp
+}
+
+// registerPasskey runs a registration ceremony for userID with authenticator
+func registerPasskey(t *testing.T, passkeys *usecase.PasskeyUsecase, authenticator *webauthntest.Authenticator, userID int, name string) (*webauthntest.Credential, *domain.Passkey) {
+	t.Helper()
+	ctx := context.Background()
+
+	options, err := passkeys.BeginRegistration(ctx, userID)
+	if err != nil {
+		t.Fatalf("BeginRegistration() error = %v", err)
+	}
+	userHandle, err := base64.RawURLEncoding.DecodeString(options.User.ID)
+	if err != nil {
+		t.Fatalf("invalid user handle %q: %v", options.User.ID, err)
+	}
+
+	credential, response, err := authenticator.Register(options.Challenge, userHandle)
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	passkey, err := passkeys.FinishRegistration(ctx, userID, registrationRequest(name, response))
+	if err != nil {
+		t.Fatalf("FinishRegistration() error = %v", err)
+	}
+	return credential, passkey
+}
+
+func registrationRequest(name string, response *webauthntest.AttestationResponse) *domain.PasskeyRegistrationRequest {
+	return &domain.PasskeyRegistrationRequest{
+		Name: name,
+		ID:   response.CredentialID,
+		Response: domain.PasskeyAttestationResponse{
+			ClientDataJSON:    response.ClientDataJSON,
+			AttestationObject: response.AttestationObject,
+			Transports:        []string{"internal"},
+		},
+	}
+}
+
+// assertPasskey starts a login and answers it with credential
+func assertPasskey(t *testing.T, passkeys *usecase.PasskeyUsecase, authenticator *webauthntest.Authenticator, credential *webauthntest.Credential) *domain.PasskeyLoginRequest {
+	t.Helper()
+
+	options, err := passkeys.BeginLogin(context.Background())
+	if err != nil {
+		t.Fatalf("BeginLogin() error = %v", err)
+	}
+
+	response, err := authenticator.Assert(options.Challenge, credential)
+	if err != nil {
+		t.Fatalf("Assert() error = %v", err)
+	}
+
+	return &domain.PasskeyLoginRequest{
+		ID: response.CredentialID,
+		Response: domain.PasskeyAssertionResponse{
+			ClientDataJSON:    response.ClientDataJSON,
+			AuthenticatorData: response.AuthenticatorData,
+			Signature:         response.Signature,
+			UserHandle:        response.UserHandle,
+		},
+	}
+}
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	repo := &mockAuthUserRepo{users: make(map[int]*domain.User)}
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), &recordingMailer{})
+	passkeys := authUsecase.Passkeys
+	ctx := context.Background()
+
+	user := signupVerified(t, authUsecase, repo, "Test User", "test@example.com", "correct horse battery")
+
+	laptop := webauthntest.NewAuthenticator(testRPID, testOrigin)
+	laptopCredential, laptopPasskey := registerPasskey(t, passkeys, laptop, user.ID, "Laptop")
+	if laptopPasskey.Name != "Laptop" || laptopPasskey.Algorithm != pkg.COSEAlgES256 {
+		t.Errorf("passkey = %+v, want an ES256 passkey named Laptop", laptopPasskey)
+	}
+
+	// Several passkeys per account; registering another excludes the existing ones
+	phone := webauthntest.NewAuthenticator(testRPID, testOrigin)
+	phone.Algorithm = pkg.COSEAlgEdDSA
+	options, err := passkeys.BeginRegistration(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("BeginRegistration() error = %v", err)
+	}
+	if len(options.ExcludeCredentials) != 1 || options.ExcludeCredentials[0].ID != laptopPasskey.CredentialID {
+		t.Errorf("excludeCredentials = %+v, want the laptop passkey", options.ExcludeCredentials)
+	}
+	phoneCredential, _ := registerPasskey(t, passkeys, phone, user.ID, "")
+	list, err := passkeys.ListPasskeys(ctx, user.ID)
+	if err != nil || len(list) != 2 {
+		t.Fatalf("ListPasskeys() = %d passkeys, %v, want 2", len(list), err)
+	}
+
+	// A passkey is a complete login on its own, even with TOTP enabled
+	enrollment, err := authUsecase.MFA.Enroll(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("Enroll() error = %v", err)
+	}
+	code, _ := pkg.TOTPCode(enrollment.Secret, pkg.TOTPStep(time.Now()))
+	if _, err := authUsecase.MFA.Enable(ctx, user.ID, code); err != nil {
+		t.Fatalf("Enable() error = %v", err)
+	}
+
+	for _, tc := range []struct {
+		name          string
+		authenticator *webauthntest.Authenticator
+		credential    *webauthntest.Credential
+	}{
+		{"ES256", laptop, laptopCredential},
+		{"EdDSA", phone, phoneCredential},
+	} {
+		req := assertPasskey(t, passkeys, tc.authenticator, tc.credential)
+		tokens, err := authUsecase.LoginPasskey(ctx, req, domain.ClientInfo{IP: "203.0.113.7"})
+		if err != nil {
+			t.Fatalf("%s: LoginPasskey() error = %v", tc.name, err)
+		}
+		claims, err := authUsecase.ValidateAccessToken(ctx, tokens.AccessToken)
+		if err != nil || claims["user_id"] != float64(user.ID) {
+			t.Errorf("%s: access token claims = %v, %v", tc.name, claims, err)
+		}
+
+		// The same response cannot be replayed
+		if _, err := authUsecase.LoginPasskey(ctx, req, domain.ClientInfo{}); !errors.Is(err, usecase.ErrInvalidPasskeyLogin) {
+			t.Errorf("%s: replayed LoginPasskey() error = %v, want %v", tc.name, err, usecase.ErrInvalidPasskeyLogin)
+		}
+	}
+
+	// The signature counter is recorded
+	stored, _ := passkeys.PasskeyRepo.GetByCredentialID(ctx, laptopPasskey.CredentialID)
+	if stored.SignCount != int64(laptopCredential.SignCount) || stored.LastUsedAt == nil {
+		t.Errorf("stored passkey = %+v, want counter %d and a last use", stored, laptopCredential.SignCount)
+	}
+
+	// A deleted passkey no longer logs in
+	if err := passkeys.DeletePasskey(ctx, user.ID+1, laptopPasskey.ID); !errors.Is(err, usecase.ErrPasskeyNotFound) {
+		t.Errorf("DeletePasskey() of another user error = %v, want %v", err, usecase.ErrPasskeyNotFound)
+	}
+	if err := passkeys.DeletePasskey(ctx, user.ID, laptopPasskey.ID); err != nil {
+		t.Fatalf("DeletePasskey() error = %v", err)
+	}
+	req := assertPasskey(t, passkeys, laptop, laptopCredential)
+	if _, err := authUsecase.LoginPasskey(ctx, req, domain.ClientInfo{}); !errors.Is(err, usecase.ErrInvalidPasskeyLogin) {
+		t.Errorf("LoginPasskey() with a deleted passkey error = %v, want %v", err, usecase.ErrInvalidPasskeyLogin)
+	}
+}
+
+func TestPasskeyRegistrationVerification(t *testing.T) {
+	repo := &mockAuthUserRepo{users: make(map[int]*domain.User)}
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), &recordingMailer{})
+	passkeys := authUsecase.Passkeys
+	ctx := context.Background()
+
+	alice := signupVerified(t, authUsecase, repo, "Alice", "alice@example.com", "correct horse battery")
+	bob := signupVerified(t, authUsecase, repo, "Bob", "bob@example.com", "correct horse battery")
+
+	tests := []struct {
+		name   string
+		modify func(a *webauthntest.Authenticator)
+		userID int
+	}{
+		{"phishing origin", func(a *webauthntest.Authenticator) { a.Origin = "https://login.examp1e.com" }, alice.ID},
+		{"other relying party", func(a *webauthntest.Authenticator) { a.RPID = "examp1e.com" }, alice.ID},
+		{"user not verified", func(a *webauthntest.Authenticator) { a.UserVerified = false }, alice.ID},
+		{"challenge of another user", func(a *webauthntest.Authenticator) {}, bob.ID},
+	}
+	for _, tt := range tests {
+		options, err := passkeys.BeginRegistration(ctx, alice.ID)
+		if err != nil {
+			t.Fatalf("BeginRegistration() error = %v", err)
+		}
+		authenticator := webauthntest.NewAuthenticator(testRPID, testOrigin)
+		tt.modify(authenticator)
+		_, response, err := authenticator.Register(options.Challenge, []byte("1"))
+		if err != nil {
+			t.Fatalf("Register() error = %v", err)
+		}
+		if _, err := passkeys.FinishRegistration(ctx, tt.userID, registrationRequest("", response)); !errors.Is(err, usecase.ErrInvalidPasskeyCreate) {
+			t.Errorf("%s: FinishRegistration() error = %v, want %v", tt.name, err, usecase.ErrInvalidPasskeyCreate)
+		}
+	}
+
+	// A login challenge cannot be used to register
+	loginOptions, _ := passkeys.BeginLogin(ctx)
+	_, response, _ := webauthntest.NewAuthenticator(testRPID, testOrigin).Register(loginOptions.Challenge, []byte("1"))
+	if _, err := passkeys.FinishRegistration(ctx, alice.ID, registrationRequest("", response)); !errors.Is(err, usecase.ErrInvalidPasskeyCreate) {
+		t.Errorf("FinishRegistration() with a login challenge error = %v, want %v", err, usecase.ErrInvalidPasskeyCreate)
+	}
+
+	if list, _ := passkeys.ListPasskeys(ctx, alice.ID); len(list) != 0 {
+		t.Errorf("rejected registrations stored %d passkeys", len(list))
+	}
+}
+
+func TestPasskeyLoginVerification(t *testing.T) {
+	repo := &mockAuthUserRepo{users: make(map[int]*domain.User)}
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), &recordingMailer{})
+	passkeys := authUsecase.Passkeys
+	ctx := context.Background()
+
+	user := signupVerified(t, authUsecase, repo, "Test User", "test@example.com", "correct horse battery")
+	authenticator := webauthntest.NewAuthenticator(testRPID, testOrigin)
+	credential, _ := registerPasskey(t, passkeys, authenticator, user.ID, "Laptop")
+
+	tests := []struct {
+		name   string
+		modify func(req *domain.PasskeyLoginRequest)
+	}{
+		{"tampered signature", func(req *domain.PasskeyLoginRequest) {
+			req.Response.Signature = base64.RawURLEncoding.EncodeToString([]byte("not a signature"))
+		}},
+		{"other user handle", func(req *domain.PasskeyLoginRequest) {
+			req.Response.UserHandle = base64.RawURLEncoding.EncodeToString([]byte("2"))
+		}},
+		{"unknown credential", func(req *domain.PasskeyLoginRequest) {
+			req.ID = base64.RawURLEncoding.EncodeToString([]byte("unknown"))
+		}},
+		{"registration ceremony", func(req *domain.PasskeyLoginRequest) {
+			options, _ := passkeys.BeginRegistration(ctx, user.ID)
+			_, response, _ := authenticator.Register(options.Challenge, []byte("1"))
+			req.Response.ClientDataJSON = response.ClientDataJSON
+		}},
+	}
+	for _, tt := range tests {
+		req := assertPasskey(t, passkeys, authenticator, credential)
+		tt.modify(req)
+		if _, err := authUsecase.LoginPasskey(ctx, req, domain.ClientInfo{}); !errors.Is(err, usecase.ErrInvalidPasskeyLogin) {
+			t.Errorf("%s: LoginPasskey() error = %v, want %v", tt.name, err, usecase.ErrInvalidPasskeyLogin)
+		}
+	}
+
+	authenticator.UserVerified = false
+	req := assertPasskey(t, passkeys, authenticator, credential)
+	if _, err := authUsecase.LoginPasskey(ctx, req, domain.ClientInfo{}); !errors.Is(err, usecase.ErrInvalidPasskeyLogin) {
+		t.Errorf("LoginPasskey() without user verification error = %v, want %v", err, usecase.ErrInvalidPasskeyLogin)
+	}
+	authenticator.UserVerified = true
+
+	req = assertPasskey(t, passkeys, authenticator, credential)
+	if _, err := authUsecase.LoginPasskey(ctx, req, domain.ClientInfo{}); err != nil {
+		t.Fatalf("LoginPasskey() error = %v", err)
+	}
+
+	// A counter that goes backwards means the key was copied
+	credential.SignCount -= 2
+	req = assertPasskey(t, passkeys, authenticator, credential)
+	if _, err := authUsecase.LoginPasskey(ctx, req, domain.ClientInfo{}); !errors.Is(err, usecase.ErrInvalidPasskeyLogin) {
+		t.Errorf("LoginPasskey() with a stale counter error = %v, want %v", err, usecase.ErrInvalidPasskeyLogin)
+	}
+}
+
+func TestWebAuthnRelyingPartyConfig(t *testing.T) {
+	rp := newTestRelyingParty(t)
+	if rp.ID != "localhost" || len(rp.Origins) != 1 || rp.Origins[0] != testOrigin {
+		t.Errorf("relying party = %+v, want the host and origin of APP_BASE_URL", rp)
+	}
+
+	rp, err := pkg.NewWebAuthnRelyingParty(&config.Config{
+		AppBaseURL:      "https://app.example.com",
+		WebAuthnRPID:    "example.com",
+		WebAuthnOrigins: "https://app.example.com, https://example.com",
+	})
+	if err != nil || len(rp.Origins) != 2 {
+		t.Errorf("NewWebAuthnRelyingParty() = %+v, %v, want two origins", rp, err)
+	}
+
+	invalid := []*config.Config{
+		{AppBaseURL: "http://app.example.com"},
+		{AppBaseURL: "https://app.example.com", WebAuthnRPID: "other.com"},
+		{AppBaseURL: "https://app.example.com", WebAuthnRPID: "example.com", WebAuthnOrigins: "https://notexample.com"},
+	}
+	for _, cfg := range invalid {
+		if _, err := pkg.NewWebAuthnRelyingParty(cfg); err == nil {
+			t.Errorf("NewWebAuthnRelyingParty(%+v) accepted an invalid configuration", cfg)
+		}
+	}
+}
+
+func TestDecodeCBOR(t *testing.T) {
+	value, rest, err := pkg.DecodeCBOR([]byte{0xa2, 0x01, 0x02, 0x20, 0x43, 'a', 'b', 'c', 0xff})
+	if err != nil {
+		t.Fatalf("DecodeCBOR() error = %v", err)
+	}
+	entries, ok := value.(map[interface{}]interface{})
+	if !ok || entries[int64(1)] != int64(2) || string(entries[int64(-1)].([]byte)) != "abc" || len(rest) != 1 {
+		t.Errorf("DecodeCBOR() = %v, rest %v", value, rest)
+	}
+
+	deep := make([]byte, 100)
+	for i := range deep {
+		deep[i] = 0x81
+	}
+	invalid := map[string][]byte{
+		"truncated":          {0x43, 'a'},
+		"indefinite length":  {0x5f},
+		"huge length":        {0x5b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		"duplicate key":      {0xa2, 0x01, 0x01, 0x01, 0x02},
+		"byte string as key": {0xa1, 0x41, 'k', 0x01},
+		"float":              {0xf9, 0x3c, 0x00},
+		"deep nesting":       deep,
+	}
+	for name, data := range invalid {
+		if _, _, err := pkg.DecodeCBOR(data); !errors.Is(err, pkg.ErrInvalidCBOR) {
+			t.Errorf("%s: DecodeCBOR() error = %v, want %v", name, err, pkg.ErrInvalidCBOR)
+		}
+	}
+}
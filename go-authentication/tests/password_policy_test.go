@@ -0,0 +1,121 @@
+package tests
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"go-authentication/config"
+	"go-authentication/internal/domain"
+	"go-authentication/pkg"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestPasswordPolicy returns the default policy: at least 10 characters and no personal information
+func newTestPasswordPolicy(t *testing.T) *pkg.PasswordPolicy {
+	t.Helper()
+	policy, err := pkg.NewPasswordPolicy(&config.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create password policy: %v", err)
+	}
+	return policy
+}
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	listFile := filepath.Join(t.TempDir(), "breached.txt")
+	list := "# breached passwords\n" +
+		strings.ToUpper(sha1Hex("Correct-Horse-1")) + ":42\n" +
+		sha1Hex("letmein12345") + "\n"
+	if err := os.WriteFile(listFile, []byte(list), 0600); err != nil {
+		t.Fatalf("Failed to write breached password list: %v", err)
+	}
+
+	policy, err := pkg.NewPasswordPolicy(&config.Config{
+		PasswordMinLength:        "12",
+		PasswordRequireUpper:     "true",
+		PasswordRequireLower:     "true",
+		PasswordRequireDigit:     "true",
+		PasswordRequireSymbol:    "true",
+		PasswordBreachedListFile: listFile,
+	})
+	if err != nil {
+		t.Fatalf("NewPasswordPolicy() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		want     string
+	}{
+		{"Valid password", "Tr0ub4dor&3-xyz", ""},
+		{"Too short", "Ab1!", "at least 12 characters"},
+		{"Too long for bcrypt", "Aa1!" + strings.Repeat("x", 69), "at most 72 bytes"},
+		{"Missing uppercase", "tr0ub4dor&3-xyz", "uppercase"},
+		{"Missing lowercase", "TR0UB4DOR&3-XYZ", "lowercase"},
+		{"Missing digit", "Troubador&three", "digit"},
+		{"Missing symbol", "Tr0ub4dor3xyzw", "symbol"},
+		{"Contains name", "Jane-Is-Gr8-2024", "name or email"},
+		{"Contains email local part", "Xx-jdoe-2024-Yy", "name or email"},
+		{"Breached", "Correct-Horse-1", "breached"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.password, "Jane Doe", "jdoe@example.com")
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+
+			var policyErr *pkg.PasswordPolicyError
+			if !errors.As(err, &policyErr) {
+				t.Fatalf("Validate() error = %v, want PasswordPolicyError", err)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate() error = %q, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+
+	// Lowercase hashes in the list are matched too
+	if breached, err := pkg.IsBreachedPassword(policy.Breached, "letmein12345"); err != nil || !breached {
+		t.Errorf("IsBreachedPassword() = %v, %v, want true", breached, err)
+	}
+
+	invalid := []*config.Config{
+		{PasswordMaxLength: "100"},
+		{PasswordMinLength: "20", PasswordMaxLength: "15"},
+		{PasswordRequireDigit: "sometimes"},
+		{PasswordBreachedListFile: filepath.Join(t.TempDir(), "missing.txt")},
+	}
+	for _, cfg := range invalid {
+		if _, err := pkg.NewPasswordPolicy(cfg); err == nil {
+			t.Errorf("NewPasswordPolicy(%+v) expected error", cfg)
+		}
+	}
+}
+
+func TestSignupRejectsPersonalInfoInPassword(t *testing.T) {
+	repo := &mockAuthUserRepo{users: make(map[int]*domain.User)}
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), &recordingMailer{})
+
+	err := authUsecase.Signup(context.Background(), &domain.User{
+		Name:     "Marguerite",
+		Email:    "marguerite@example.com",
+		Password: "marguerite-2024",
+	})
+	var policyErr *pkg.PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		t.Errorf("Signup() error = %v, want PasswordPolicyError", err)
+	}
+}
@@ -0,0 +1,212 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"go-authentication/internal/delivery"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/repository"
+	"go-authentication/internal/usecase"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Mock password reset repository for testing
+type mockPasswordResetRepo struct {
+	tokens map[int]*domain.PasswordResetToken
+}
+
+func newMockPasswordResetRepo() *mockPasswordResetRepo {
+	return &mockPasswordResetRepo{tokens: make(map[int]*domain.PasswordResetToken)}
+}
+
+func (m *mockPasswordResetRepo) Create(ctx context.Context, token *domain.PasswordResetToken) error {
+	token.ID = len(m.tokens) + 1
+	token.CreatedAt = time.Now()
+	m.tokens[token.ID] = token
+	return nil
+}
+
+func (m *mockPasswordResetRepo) GetByHash(ctx context.Context, tokenHash string) (*domain.PasswordResetToken, error) {
+	for _, token := range m.tokens {
+		if token.TokenHash == tokenHash {
+			copied := *token
+			return &copied, nil
+		}
+	}
+	return nil, errors.New("password reset token not found")
+}
+
+func (m *mockPasswordResetRepo) MarkUsed(ctx context.Context, id int) error {
+	token, exists := m.tokens[id]
+	if !exists || token.UsedAt != nil {
+		return repository.ErrResetTokenUsed
+	}
+	now := time.Now()
+	token.UsedAt = &now
+	return nil
+}
+
+func (m *mockPasswordResetRepo) InvalidateForUser(ctx context.Context, userID int) error {
+	now := time.Now()
+	for _, token := range m.tokens {
+		if token.UserID == userID && token.UsedAt == nil {
+			token.UsedAt = &now
+		}
+	}
+	return nil
+}
+
+func TestPasswordReset(t *testing.T) {
+	oldPassword := "password123456"
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(oldPassword), bcrypt.DefaultCost)
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+
+	verifiedAt := time.Now()
+	repo := &mockAuthUserRepo{
+		users: map[int]*domain.User{
+			1: {ID: 1, Name: "Test User", Email: "test@example.com", Password: string(hashedPassword), EmailVerifiedAt: &verifiedAt},
+		},
+	}
+	refreshRepo := newMockRefreshTokenRepo()
+	mailer := &recordingMailer{}
+	authUsecase := newTestAuthUsecase(t, repo, refreshRepo, mailer)
+	passwordUsecase := usecase.NewPasswordUsecase(repo, newMockPasswordResetRepo(), authUsecase.Sessions, mailer, newTestPasswordPolicy(t), newTestPasswordHasher(t), authUsecase.Lockout, "http://localhost:8081", authUsecase.Audit)
+	ctx := context.Background()
+
+	session, err := authUsecase.Login(ctx, "test@example.com", oldPassword, domain.ClientInfo{})
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+
+	// Unknown addresses get the same answer and no email
+	if err := passwordUsecase.ForgotPassword(ctx, "nobody@example.com"); err != nil {
+		t.Errorf("ForgotPassword() unknown address error = %v", err)
+	}
+	if len(mailer.sent) != 0 {
+		t.Fatalf("ForgotPassword() sent an email for an unknown address")
+	}
+
+	if err := passwordUsecase.ForgotPassword(ctx, "test@example.com"); err != nil {
+		t.Fatalf("ForgotPassword() error = %v", err)
+	}
+	token := mailer.lastLinkToken(t)
+
+	if err := passwordUsecase.ResetPassword(ctx, token, "short"); !errors.Is(err, usecase.ErrInvalidPassword) {
+		t.Errorf("ResetPassword() weak password error = %v, want %v", err, usecase.ErrInvalidPassword)
+	}
+
+	newPassword := "a-much-better-password"
+	if err := passwordUsecase.ResetPassword(ctx, token, newPassword); err != nil {
+		t.Fatalf("ResetPassword() error = %v", err)
+	}
+
+	// Reset tokens are single-use
+	if err := passwordUsecase.ResetPassword(ctx, token, "yet-another-password"); !errors.Is(err, usecase.ErrInvalidResetToken) {
+		t.Errorf("ResetPassword() reused token error = %v, want %v", err, usecase.ErrInvalidResetToken)
+	}
+
+	// Existing sessions are revoked
+	if _, err := authUsecase.ValidateAccessToken(ctx, session.AccessToken); !errors.Is(err, usecase.ErrTokenRevoked) {
+		t.Errorf("ValidateAccessToken() after reset error = %v, want %v", err, usecase.ErrTokenRevoked)
+	}
+	if _, err := authUsecase.RefreshToken(ctx, session.RefreshToken); err == nil {
+		t.Error("RefreshToken() accepted a refresh token issued before the reset")
+	}
+
+	if _, err := authUsecase.Login(ctx, "test@example.com", oldPassword, domain.ClientInfo{}); err == nil {
+		t.Error("Login() accepted the old password")
+	}
+
+	// Issue times have millisecond precision, so wait for a fresh millisecond before logging in again
+	time.Sleep(time.Until(time.Now().Truncate(time.Millisecond).Add(time.Millisecond)))
+	fresh, err := authUsecase.Login(ctx, "test@example.com", newPassword, domain.ClientInfo{})
+	if err != nil {
+		t.Fatalf("Login() with new password error = %v", err)
+	}
+	if _, err := authUsecase.ValidateAccessToken(ctx, fresh.AccessToken); err != nil {
+		t.Errorf("ValidateAccessToken() for a login after the reset error = %v", err)
+	}
+}
+
+func TestPasswordResetExpiredToken(t *testing.T) {
+	repo := &mockAuthUserRepo{
+		users: map[int]*domain.User{
+			1: {ID: 1, Name: "Test User", Email: "test@example.com", Password: "hash"},
+		},
+	}
+	resetRepo := newMockPasswordResetRepo()
+	mailer := &recordingMailer{}
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), mailer)
+	passwordUsecase := usecase.NewPasswordUsecase(repo, resetRepo, authUsecase.Sessions, mailer, newTestPasswordPolicy(t), newTestPasswordHasher(t), authUsecase.Lockout, "http://localhost:8081", authUsecase.Audit)
+	ctx := context.Background()
+
+	if err := passwordUsecase.ForgotPassword(ctx, "test@example.com"); err != nil {
+		t.Fatalf("ForgotPassword() error = %v", err)
+	}
+	for _, token := range resetRepo.tokens {
+		token.ExpiresAt = time.Now().Add(-time.Minute)
+	}
+
+	if err := passwordUsecase.ResetPassword(ctx, mailer.lastLinkToken(t), "a-much-better-password"); !errors.Is(err, usecase.ErrInvalidResetToken) {
+		t.Errorf("ResetPassword() expired token error = %v, want %v", err, usecase.ErrInvalidResetToken)
+	}
+}
+
+func TestPasswordResetLinkOpensForm(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := &mockAuthUserRepo{
+		users: map[int]*domain.User{
+			1: {ID: 1, Name: "Test User", Email: "test@example.com", Password: "hash"},
+		},
+	}
+	mailer := &recordingMailer{}
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), mailer)
+	passwordUsecase := usecase.NewPasswordUsecase(repo, newMockPasswordResetRepo(), authUsecase.Sessions, mailer, newTestPasswordPolicy(t), newTestPasswordHasher(t), authUsecase.Lockout, "http://localhost:8081", authUsecase.Audit)
+	passwordHandler := delivery.NewPasswordHandler(passwordUsecase)
+
+	router := gin.New()
+	router.GET("/password/reset", passwordHandler.ResetPasswordFormHandler)
+	router.POST("/password/reset", passwordHandler.ResetPasswordHandler)
+
+	if err := passwordUsecase.ForgotPassword(context.Background(), "test@example.com"); err != nil {
+		t.Fatalf("ForgotPassword() error = %v", err)
+	}
+	rawLink := regexp.MustCompile(`https?://\S+`).FindString(mailer.sent[len(mailer.sent)-1].Body)
+	link, err := url.Parse(rawLink)
+	if err != nil {
+		t.Fatalf("Failed to parse reset link %q: %v", rawLink, err)
+	}
+
+	// The emailed link must open a page, not the JSON-only reset endpoint
+	req := httptest.NewRequest(http.MethodGet, link.RequestURI(), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s status = %d, want %d", link.Path, w.Code, http.StatusOK)
+	}
+	if contentType := w.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/html") {
+		t.Errorf("Content-Type = %q, want an HTML page", contentType)
+	}
+	if token := mailer.lastLinkToken(t); !strings.Contains(w.Body.String(), html.EscapeString(token)) {
+		t.Error("Reset form does not carry the token from the link")
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/password/reset", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("GET /password/reset without a token status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,165 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/repository"
+	"go-authentication/internal/usecase"
+	"testing"
+	"time"
+)
+
+func newTestPresenceUsecase(t *testing.T) *usecase.PresenceUsecase {
+	t.Helper()
+	chatUsecase := newTestChatUsecase(t)
+	return usecase.NewPresenceUsecase(repository.NewMemoryPresenceStore(), chatUsecase.ChatRepo, chatUsecase.UserRepo, chatUsecase.Blocks, chatUsecase.NatsService)
+}
+
+func TestPresenceAcrossConnections(t *testing.T) {
+	presence := newTestPresenceUsecase(t)
+	store := presence.Store
+	ctx := context.Background()
+
+	current, err := presence.GetPresence(ctx, 2, 1)
+	if err != nil {
+		t.Fatalf("GetPresence() error = %v", err)
+	}
+	if current.Status != domain.PresenceOffline || current.LastSeen != nil {
+		t.Fatalf("Expected a user who never connected to be offline without last seen, got %+v", current)
+	}
+
+	// The status changes once per transition, whatever the number of connections
+	steps := []struct {
+		name        string
+		apply       func() (*domain.Presence, bool, error)
+		wantStatus  string
+		wantChanged bool
+	}{
+		{"phone connects", func() (*domain.Presence, bool, error) {
+			return store.Heartbeat(ctx, 1, "phone", domain.PresenceOnline, time.Minute)
+		}, domain.PresenceOnline, true},
+		{"laptop connects idle", func() (*domain.Presence, bool, error) {
+			return store.Heartbeat(ctx, 1, "laptop", domain.PresenceAway, time.Minute)
+		}, domain.PresenceOnline, false},
+		{"phone goes idle", func() (*domain.Presence, bool, error) {
+			return store.Heartbeat(ctx, 1, "phone", domain.PresenceAway, time.Minute)
+		}, domain.PresenceAway, true},
+		{"phone disconnects", func() (*domain.Presence, bool, error) {
+			return store.Disconnect(ctx, 1, "phone")
+		}, domain.PresenceAway, false},
+		{"laptop disconnects", func() (*domain.Presence, bool, error) {
+			return store.Disconnect(ctx, 1, "laptop")
+		}, domain.PresenceOffline, true},
+	}
+	for _, step := range steps {
+		got, changed, err := step.apply()
+		if err != nil {
+			t.Fatalf("%s: error = %v", step.name, err)
+		}
+		if got.Status != step.wantStatus || changed != step.wantChanged {
+			t.Errorf("%s: got status %s changed=%v, want %s changed=%v", step.name, got.Status, changed, step.wantStatus, step.wantChanged)
+		}
+	}
+
+	current, err = presence.GetPresence(ctx, 2, 1)
+	if err != nil {
+		t.Fatalf("GetPresence() error = %v", err)
+	}
+	if current.Status != domain.PresenceOffline || current.LastSeen == nil || time.Since(*current.LastSeen) > time.Minute {
+		t.Errorf("Expected user 1 offline with a recent last seen, got %+v", current)
+	}
+
+	if err := presence.Heartbeat(ctx, 1, "phone", "busy"); !errors.Is(err, domain.ErrInvalidPresenceStatus) {
+		t.Errorf("Expected ErrInvalidPresenceStatus, got %v", err)
+	}
+	if _, err := presence.GetPresence(ctx, 2, 99); !errors.Is(err, usecase.ErrUserNotFound) {
+		t.Errorf("Expected ErrUserNotFound for an unknown user, got %v", err)
+	}
+}
+
+func TestPresenceKeepsLastSeenOffline(t *testing.T) {
+	store := repository.NewMemoryPresenceStore()
+	ctx := context.Background()
+
+	if _, _, err := store.Heartbeat(ctx, 1, "phone", domain.PresenceOnline, time.Minute); err != nil {
+		t.Fatalf("Heartbeat() error = %v", err)
+	}
+	offline, changed, err := store.Disconnect(ctx, 1, "phone")
+	if err != nil || !changed || offline.LastSeen == nil {
+		t.Fatalf("Disconnect() = %+v, changed=%v, error %v; want offline with last seen", offline, changed, err)
+	}
+
+	// The record is gone with the last connection, and the sweep has nothing left to read
+	if changes, _ := store.Sweep(ctx); len(changes) != 0 {
+		t.Errorf("Expected nothing to sweep for an offline user, got %+v", changes)
+	}
+
+	// Coming back idle does not move the last seen time, which outlived the record
+	away, changed, err := store.Heartbeat(ctx, 1, "laptop", domain.PresenceAway, time.Minute)
+	if err != nil {
+		t.Fatalf("Heartbeat() error = %v", err)
+	}
+	if !changed || away.Status != domain.PresenceAway || away.LastSeen == nil || !away.LastSeen.Equal(*offline.LastSeen) {
+		t.Errorf("Expected user 1 away and last seen at %v, got %+v changed=%v", offline.LastSeen, away, changed)
+	}
+}
+
+func TestPresenceLapses(t *testing.T) {
+	presence := newTestPresenceUsecase(t)
+	presence.TTL = 30 * time.Millisecond
+	ctx := context.Background()
+
+	if err := presence.Heartbeat(ctx, 1, "phone", domain.PresenceOnline); err != nil {
+		t.Fatalf("Heartbeat() error = %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	// A lapsed connection reads as offline right away, and the sweep reports the change once
+	current, err := presence.GetPresence(ctx, 1, 1)
+	if err != nil {
+		t.Fatalf("GetPresence() error = %v", err)
+	}
+	if current.Status != domain.PresenceOffline {
+		t.Errorf("Expected a lapsed connection to read as offline, got %s", current.Status)
+	}
+
+	changes, err := presence.Store.Sweep(ctx)
+	if err != nil {
+		t.Fatalf("Sweep() error = %v", err)
+	}
+	if len(changes) != 1 || changes[0].UserID != 1 || changes[0].Status != domain.PresenceOffline {
+		t.Fatalf("Expected the sweep to report user 1 offline, got %+v", changes)
+	}
+	if changes, _ := presence.Store.Sweep(ctx); len(changes) != 0 {
+		t.Errorf("Expected a second sweep to report nothing, got %+v", changes)
+	}
+}
+
+func TestPresenceHiddenByBlocks(t *testing.T) {
+	presence := newTestPresenceUsecase(t)
+	ctx := context.Background()
+
+	if err := presence.Heartbeat(ctx, 1, "phone", domain.PresenceOnline); err != nil {
+		t.Fatalf("Heartbeat() error = %v", err)
+	}
+	if err := presence.Blocks.BlockUser(ctx, 1, 2); err != nil {
+		t.Fatalf("BlockUser() error = %v", err)
+	}
+
+	seenByBlocked, err := presence.GetPresence(ctx, 2, 1)
+	if err != nil {
+		t.Fatalf("GetPresence() error = %v", err)
+	}
+	if seenByBlocked.Status != domain.PresenceOffline || seenByBlocked.LastSeen != nil {
+		t.Errorf("Expected a blocked user to see user 1 offline, got %+v", seenByBlocked)
+	}
+
+	seenByOther, err := presence.GetPresence(ctx, 3, 1)
+	if err != nil {
+		t.Fatalf("GetPresence() error = %v", err)
+	}
+	if seenByOther.Status != domain.PresenceOnline {
+		t.Errorf("Expected other users to see user 1 online, got %+v", seenByOther)
+	}
+}
@@ -0,0 +1,432 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/repository"
+	"go-authentication/internal/usecase"
+	"go-authentication/pkg"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Mock user search repository for testing. Prefix matches on name or email score 1000,
+// and a substring match stands in for trigram similarity with 500. Like the query on user_blocks,
+// it leaves out users who blocked the caller, reading the blocks a BlockUsecase stores.
+type mockUserSearchRepo struct {
+	users  *mockAuthUserRepo
+	blocks repository.BlockRepository
+}
+
+func newMockUserSearchRepo(users *mockAuthUserRepo, blocks repository.BlockRepository) *mockUserSearchRepo {
+	return &mockUserSearchRepo{users: users, blocks: blocks}
+}
+
+func (m *mockUserSearchRepo) Search(ctx context.Context, filter *domain.UserSearchFilter) ([]domain.UserSearchResult, error) {
+	term := strings.ToLower(filter.Query)
+	var matches []domain.UserSearchResult
+	for _, user := range m.users.users {
+		if user.ID == filter.CallerID {
+			continue
+		}
+		blocked, err := m.blocks.IsBlocked(ctx, user.ID, filter.CallerID)
+		if err != nil {
+			return nil, err
+		}
+		if blocked {
+			continue
+		}
+		name, email := strings.ToLower(user.Name), strings.ToLower(user.Email)
+		score := 0
+		if strings.HasPrefix(name, term) || strings.HasPrefix(email, term) {
+			score = 1000
+		} else if strings.Contains(name, term) || strings.Contains(email, term) {
+			score = 500
+		} else {
+			continue
+		}
+		if filter.AfterID != 0 && (score > filter.AfterScore || (score == filter.AfterScore && user.ID <= filter.AfterID)) {
+			continue
+		}
+		matches = append(matches, domain.UserSearchResult{PublicProfile: *user.PublicProfile(), Score: score})
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].ID < matches[j].ID
+	})
+	if len(matches) > filter.Limit {
+		matches = matches[:filter.Limit]
+	}
+	return matches, nil
+}
+
+// newTestProfileUsecase wires a ProfileUsecase sharing the dependencies of authUsecase
+func newTestProfileUsecase(t *testing.T, authUsecase *usecase.AuthUsecase, mailer *recordingMailer) *usecase.ProfileUsecase {
+	t.Helper()
+	search := newMockUserSearchRepo(authUsecase.UserRepo.(*mockAuthUserRepo), newMockBlockRepo())
+	return usecase.NewProfileUsecase(authUsecase.UserRepo, search, authUsecase.Tokens, authUsecase.Revocations, mailer, authUsecase.Hasher, authUsecase.Lockout, "http://localhost:8081", authUsecase.Audit)
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestProfileUpdate(t *testing.T) {
+	repo := &mockAuthUserRepo{users: make(map[int]*domain.User)}
+	mailer := &recordingMailer{}
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), mailer)
+	profiles := newTestProfileUsecase(t, authUsecase, mailer)
+	ctx := context.Background()
+
+	user := signupVerified(t, authUsecase, repo, "Profile User", "profile@example.com", "correct horse battery")
+
+	profile, err := profiles.GetProfile(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("GetProfile() error = %v", err)
+	}
+	if profile.Name != "Profile User" || profile.Email != "profile@example.com" || !profile.EmailVerified {
+		t.Errorf("Unexpected profile: %+v", profile)
+	}
+
+	profile, err = profiles.UpdateProfile(ctx, user.ID, &domain.UpdateProfileRequest{
+		DisplayName: stringPtr("  Pro  "),
+		Bio:         stringPtr("Writes Go"),
+		StatusText:  stringPtr("In a meeting"),
+	})
+	if err != nil {
+		t.Fatalf("UpdateProfile() error = %v", err)
+	}
+	if profile.Name != "Profile User" || profile.DisplayName != "Pro" || profile.Bio != "Writes Go" || profile.StatusText != "In a meeting" {
+		t.Errorf("UpdateProfile() changed the wrong fields: %+v", profile)
+	}
+
+	// Fields can be cleared, but the name is required
+	profile, err = profiles.UpdateProfile(ctx, user.ID, &domain.UpdateProfileRequest{StatusText: stringPtr("")})
+	if err != nil {
+		t.Fatalf("UpdateProfile() clearing status error = %v", err)
+	}
+	if profile.StatusText != "" || profile.Bio != "Writes Go" {
+		t.Errorf("UpdateProfile() did not clear only the status: %+v", profile)
+	}
+
+	invalid := []*domain.UpdateProfileRequest{
+		{Name: stringPtr("   ")},
+		{Name: stringPtr(strings.Repeat("n", 101))},
+		{Bio: stringPtr(strings.Repeat("b", 501))},
+		{StatusText: stringPtr(strings.Repeat("s", 141))},
+	}
+	for _, req := range invalid {
+		if _, err := profiles.UpdateProfile(ctx, user.ID, req); !errors.Is(err, usecase.ErrInvalidProfile) {
+			t.Errorf("UpdateProfile(%+v) error = %v, want %v", req, err, usecase.ErrInvalidProfile)
+		}
+	}
+
+	if _, err := profiles.GetProfile(ctx, 999); !errors.Is(err, usecase.ErrUserNotFound) {
+		t.Errorf("GetProfile() unknown user error = %v, want %v", err, usecase.ErrUserNotFound)
+	}
+}
+
+func TestPublicProfileHidesPrivateFields(t *testing.T) {
+	repo := &mockAuthUserRepo{users: make(map[int]*domain.User)}
+	mailer := &recordingMailer{}
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), mailer)
+	profiles := newTestProfileUsecase(t, authUsecase, mailer)
+
+	user := signupVerified(t, authUsecase, repo, "Public User", "public@example.com", "correct horse battery")
+
+	public, err := profiles.GetPublicProfile(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("GetPublicProfile() error = %v", err)
+	}
+	body, err := json.Marshal(public)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	for _, private := range []string{"password", "email", user.Password} {
+		if strings.Contains(string(body), private) {
+			t.Errorf("Public profile %s contains %q", body, private)
+		}
+	}
+	if public.Name != "Public User" {
+		t.Errorf("Public profile name = %q", public.Name)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	repo := &mockAuthUserRepo{users: make(map[int]*domain.User)}
+	mailer := &recordingMailer{}
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), mailer)
+	passwordUsecase := usecase.NewPasswordUsecase(repo, newMockPasswordResetRepo(), authUsecase.Sessions, mailer, newTestPasswordPolicy(t), newTestPasswordHasher(t), authUsecase.Lockout, "http://localhost:8081", authUsecase.Audit)
+	ctx := context.Background()
+
+	user := signupVerified(t, authUsecase, repo, "Changer", "changer@example.com", "correct horse battery")
+	session, err := authUsecase.Login(ctx, user.Email, "correct horse battery", domain.ClientInfo{})
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+
+	err = passwordUsecase.ChangePassword(ctx, user.ID, &domain.ChangePasswordRequest{CurrentPassword: "wrong password", NewPassword: "a-much-better-password"})
+	if !errors.Is(err, usecase.ErrIncorrectPassword) {
+		t.Errorf("ChangePassword() wrong current password error = %v, want %v", err, usecase.ErrIncorrectPassword)
+	}
+	err = passwordUsecase.ChangePassword(ctx, user.ID, &domain.ChangePasswordRequest{CurrentPassword: "correct horse battery", NewPassword: "short"})
+	if !errors.Is(err, usecase.ErrInvalidPassword) {
+		t.Errorf("ChangePassword() weak password error = %v, want %v", err, usecase.ErrInvalidPassword)
+	}
+
+	err = passwordUsecase.ChangePassword(ctx, user.ID, &domain.ChangePasswordRequest{CurrentPassword: "correct horse battery", NewPassword: "a-much-better-password"})
+	if err != nil {
+		t.Fatalf("ChangePassword() error = %v", err)
+	}
+
+	if _, err := authUsecase.ValidateAccessToken(ctx, session.AccessToken); !errors.Is(err, usecase.ErrTokenRevoked) {
+		t.Errorf("ValidateAccessToken() after change error = %v, want %v", err, usecase.ErrTokenRevoked)
+	}
+	if _, err := authUsecase.Login(ctx, user.Email, "correct horse battery", domain.ClientInfo{}); err == nil {
+		t.Error("Login() accepted the old password")
+	}
+	if _, err := authUsecase.Login(ctx, user.Email, "a-much-better-password", domain.ClientInfo{}); err != nil {
+		t.Errorf("Login() with the new password error = %v", err)
+	}
+
+	changes := auditEvents(authUsecase, domain.AuthEventPasswordChanged)
+	if len(changes) != 1 || changes[0].Metadata["via"] != "change" {
+		t.Errorf("Expected one password_changed event, got %+v", changes)
+	}
+}
+
+func TestChangePasswordLockout(t *testing.T) {
+	repo := &mockAuthUserRepo{users: make(map[int]*domain.User)}
+	mailer := &recordingMailer{}
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), mailer)
+	passwordUsecase := usecase.NewPasswordUsecase(repo, newMockPasswordResetRepo(), authUsecase.Sessions, mailer, newTestPasswordPolicy(t), newTestPasswordHasher(t), authUsecase.Lockout, "http://localhost:8081", authUsecase.Audit)
+	profileUsecase := newTestProfileUsecase(t, authUsecase, mailer)
+	ctx := domain.ContextWithClientInfo(context.Background(), domain.ClientInfo{IP: "203.0.113.9"})
+
+	user := signupVerified(t, authUsecase, repo, "Guesser", "guesser@example.com", "correct horse battery")
+
+	// A hijacked session must not be a way around the login lockout
+	for i := 0; i < testLockoutPolicy.MaxAccountFailures; i++ {
+		err := passwordUsecase.ChangePassword(ctx, user.ID, &domain.ChangePasswordRequest{CurrentPassword: "wrong password", NewPassword: "a-much-better-password"})
+		if !errors.Is(err, usecase.ErrIncorrectPassword) {
+			t.Fatalf("ChangePassword() attempt %d error = %v, want %v", i+1, err, usecase.ErrIncorrectPassword)
+		}
+	}
+
+	var lockedErr *usecase.LoginLockedError
+	err := passwordUsecase.ChangePassword(ctx, user.ID, &domain.ChangePasswordRequest{CurrentPassword: "correct horse battery", NewPassword: "a-much-better-password"})
+	if !errors.As(err, &lockedErr) {
+		t.Errorf("ChangePassword() on locked account error = %v, want LoginLockedError", err)
+	}
+	err = profileUsecase.RequestEmailChange(ctx, user.ID, &domain.ChangeEmailRequest{Email: "elsewhere@example.com", Password: "correct horse battery"})
+	if !errors.As(err, &lockedErr) {
+		t.Errorf("RequestEmailChange() on locked account error = %v, want LoginLockedError", err)
+	}
+	if _, err := authUsecase.Login(ctx, user.Email, "correct horse battery", domain.ClientInfo{}); !errors.As(err, &lockedErr) {
+		t.Errorf("Login() on locked account error = %v, want LoginLockedError", err)
+	}
+}
+
+func TestEmailChange(t *testing.T) {
+	repo := &mockAuthUserRepo{users: make(map[int]*domain.User)}
+	mailer := &recordingMailer{}
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), mailer)
+	profiles := newTestProfileUsecase(t, authUsecase, mailer)
+	ctx := context.Background()
+
+	user := signupVerified(t, authUsecase, repo, "Mover", "old@example.com", "correct horse battery")
+	signupVerified(t, authUsecase, repo, "Other", "taken@example.com", "correct horse battery")
+
+	requests := []struct {
+		req  domain.ChangeEmailRequest
+		want error
+	}{
+		{domain.ChangeEmailRequest{Email: "not-an-email", Password: "correct horse battery"}, usecase.ErrInvalidEmail},
+		{domain.ChangeEmailRequest{Email: "new@example.com", Password: "wrong password"}, usecase.ErrIncorrectPassword},
+		{domain.ChangeEmailRequest{Email: "OLD@example.com", Password: "correct horse battery"}, usecase.ErrEmailUnchanged},
+		{domain.ChangeEmailRequest{Email: "taken@example.com", Password: "correct horse battery"}, usecase.ErrEmailTaken},
+	}
+	for _, tt := range requests {
+		if err := profiles.RequestEmailChange(ctx, user.ID, &tt.req); !errors.Is(err, tt.want) {
+			t.Errorf("RequestEmailChange(%s) error = %v, want %v", tt.req.Email, err, tt.want)
+		}
+	}
+
+	sentBefore := len(mailer.sent)
+	if err := profiles.RequestEmailChange(ctx, user.ID, &domain.ChangeEmailRequest{Email: "new@example.com", Password: "correct horse battery"}); err != nil {
+		t.Fatalf("RequestEmailChange() error = %v", err)
+	}
+	sent := mailer.sent[sentBefore:]
+	if len(sent) != 2 || sent[0].To != "old@example.com" || sent[1].To != "new@example.com" {
+		t.Fatalf("Expected a notice to the old address and a link to the new one, got %+v", sent)
+	}
+	token := mailer.lastLinkToken(t)
+
+	// Nothing changes until the new address is confirmed
+	if repo.users[user.ID].Email != "old@example.com" {
+		t.Fatalf("Email changed before confirmation")
+	}
+
+	if err := profiles.ConfirmEmailChange(ctx, "not-a-token"); !errors.Is(err, usecase.ErrInvalidVerificationToken) {
+		t.Errorf("ConfirmEmailChange() invalid token error = %v, want %v", err, usecase.ErrInvalidVerificationToken)
+	}
+	// An email verification token is not an email change token
+	verification, err := authUsecase.Tokens.GeneratePurposeToken(pkg.TokenTypeEmailVerification, user.ID, "new@example.com", time.Hour)
+	if err != nil {
+		t.Fatalf("GeneratePurposeToken() error = %v", err)
+	}
+	if err := profiles.ConfirmEmailChange(ctx, verification); !errors.Is(err, usecase.ErrInvalidVerificationToken) {
+		t.Errorf("ConfirmEmailChange() verification token error = %v, want %v", err, usecase.ErrInvalidVerificationToken)
+	}
+
+	if err := profiles.ConfirmEmailChange(ctx, token); err != nil {
+		t.Fatalf("ConfirmEmailChange() error = %v", err)
+	}
+	changed := repo.users[user.ID]
+	if changed.Email != "new@example.com" || !changed.IsEmailVerified() {
+		t.Errorf("Expected a verified new address, got %q verified=%v", changed.Email, changed.IsEmailVerified())
+	}
+	if _, err := authUsecase.Login(ctx, "new@example.com", "correct horse battery", domain.ClientInfo{}); err != nil {
+		t.Errorf("Login() with the new address error = %v", err)
+	}
+	if _, err := authUsecase.Login(ctx, "old@example.com", "correct horse battery", domain.ClientInfo{}); err == nil {
+		t.Error("Login() accepted the old address")
+	}
+
+	// Following the link again is harmless
+	if err := profiles.ConfirmEmailChange(ctx, token); err != nil {
+		t.Errorf("ConfirmEmailChange() repeated error = %v", err)
+	}
+	if events := auditEvents(authUsecase, domain.AuthEventEmailChanged); len(events) != 1 || events[0].Metadata["old_email"] != "old@example.com" {
+		t.Errorf("Expected one email_changed event, got %+v", events)
+	}
+
+	// Once the address moved on, the used link cannot bring it back
+	if err := profiles.RequestEmailChange(ctx, user.ID, &domain.ChangeEmailRequest{Email: "newer@example.com", Password: "correct horse battery"}); err != nil {
+		t.Fatalf("RequestEmailChange() error = %v", err)
+	}
+	if err := profiles.ConfirmEmailChange(ctx, mailer.lastLinkToken(t)); err != nil {
+		t.Fatalf("ConfirmEmailChange() error = %v", err)
+	}
+	if err := profiles.ConfirmEmailChange(ctx, token); !errors.Is(err, usecase.ErrInvalidVerificationToken) {
+		t.Errorf("ConfirmEmailChange() replayed token error = %v, want %v", err, usecase.ErrInvalidVerificationToken)
+	}
+	if email := repo.users[user.ID].Email; email != "newer@example.com" {
+		t.Errorf("Replayed link moved the account to %q", email)
+	}
+}
+
+func TestEmailChangeCancelledByPasswordChange(t *testing.T) {
+	repo := &mockAuthUserRepo{users: make(map[int]*domain.User)}
+	mailer := &recordingMailer{}
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), mailer)
+	profiles := newTestProfileUsecase(t, authUsecase, mailer)
+	passwordUsecase := usecase.NewPasswordUsecase(repo, newMockPasswordResetRepo(), authUsecase.Sessions, mailer, newTestPasswordPolicy(t), newTestPasswordHasher(t), authUsecase.Lockout, "http://localhost:8081", authUsecase.Audit)
+	ctx := context.Background()
+
+	user := signupVerified(t, authUsecase, repo, "Owner", "owner@example.com", "correct horse battery")
+
+	// Someone holding the password asks to move the account to their address
+	if err := profiles.RequestEmailChange(ctx, user.ID, &domain.ChangeEmailRequest{Email: "attacker@example.com", Password: "correct horse battery"}); err != nil {
+		t.Fatalf("RequestEmailChange() error = %v", err)
+	}
+	token := mailer.lastLinkToken(t)
+
+	// The owner follows the warning and changes their password
+	err := passwordUsecase.ChangePassword(ctx, user.ID, &domain.ChangePasswordRequest{CurrentPassword: "correct horse battery", NewPassword: "a-much-better-password"})
+	if err != nil {
+		t.Fatalf("ChangePassword() error = %v", err)
+	}
+
+	if err := profiles.ConfirmEmailChange(ctx, token); !errors.Is(err, usecase.ErrInvalidVerificationToken) {
+		t.Errorf("ConfirmEmailChange() after a password change error = %v, want %v", err, usecase.ErrInvalidVerificationToken)
+	}
+	if repo.users[user.ID].Email != "owner@example.com" {
+		t.Errorf("Expected the address to stay owner@example.com, got %q", repo.users[user.ID].Email)
+	}
+}
+
+func TestSearchUsers(t *testing.T) {
+	repo := &mockAuthUserRepo{users: make(map[int]*domain.User)}
+	mailer := &recordingMailer{}
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), mailer)
+	profiles := newTestProfileUsecase(t, authUsecase, mailer)
+	ctx := context.Background()
+
+	caller := signupVerified(t, authUsecase, repo, "Alex Caller", "caller@example.com", "correct horse battery")
+	signupVerified(t, authUsecase, repo, "Alice Anders", "alice@example.com", "correct horse battery")
+	signupVerified(t, authUsecase, repo, "Ali Baba", "ali@example.com", "correct horse battery")
+	signupVerified(t, authUsecase, repo, "Sam Malice", "sam@example.com", "correct horse battery")
+	blocker := signupVerified(t, authUsecase, repo, "Alina Blocker", "alina@example.com", "correct horse battery")
+	blocks := usecase.NewBlockUsecase(profiles.SearchRepo.(*mockUserSearchRepo).blocks, repo)
+	if err := blocks.BlockUser(ctx, blocker.ID, caller.ID); err != nil {
+		t.Fatalf("BlockUser() error = %v", err)
+	}
+
+	// Page through the results two at a time
+	var names []string
+	query := &domain.UserSearchQuery{Q: "  ali ", Limit: 2}
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("SearchUsers() did not stop paginating")
+		}
+		page, err := profiles.SearchUsers(ctx, caller.ID, query)
+		if err != nil {
+			t.Fatalf("SearchUsers() error = %v", err)
+		}
+		for _, user := range page.Users {
+			names = append(names, user.Name)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+
+	// Prefix matches come first, the blocker and the caller never appear
+	want := []string{"Alice Anders", "Ali Baba", "Sam Malice"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("SearchUsers() = %v, want %v", names, want)
+	}
+
+	// The blocker still finds the caller
+	page, err := profiles.SearchUsers(ctx, blocker.ID, &domain.UserSearchQuery{Q: "caller"})
+	if err != nil {
+		t.Fatalf("SearchUsers() error = %v", err)
+	}
+	if len(page.Users) != 1 || page.Users[0].ID != caller.ID {
+		t.Errorf("SearchUsers() by the blocker = %+v", page.Users)
+	}
+
+	// Lifting the block makes the blocker findable again
+	if err := blocks.UnblockUser(ctx, blocker.ID, caller.ID); err != nil {
+		t.Fatalf("UnblockUser() error = %v", err)
+	}
+	page, err = profiles.SearchUsers(ctx, caller.ID, &domain.UserSearchQuery{Q: "alina"})
+	if err != nil {
+		t.Fatalf("SearchUsers() error = %v", err)
+	}
+	if len(page.Users) != 1 || page.Users[0].ID != blocker.ID {
+		t.Errorf("SearchUsers() after unblocking = %+v, want the former blocker", page.Users)
+	}
+
+	invalid := []*domain.UserSearchQuery{
+		{Q: "   "},
+		{Q: strings.Repeat("q", 101)},
+		{Q: "ali", Limit: 51},
+		{Q: "ali", Limit: -1},
+		{Q: "ali", Cursor: "not a cursor"},
+	}
+	for _, query := range invalid {
+		if _, err := profiles.SearchUsers(ctx, caller.ID, query); !errors.Is(err, usecase.ErrInvalidSearchQuery) {
+			t.Errorf("SearchUsers(%+v) error = %v, want %v", query, err, usecase.ErrInvalidSearchQuery)
+		}
+	}
+}
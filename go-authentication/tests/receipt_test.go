@@ -0,0 +1,197 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/usecase"
+	"testing"
+	"time"
+)
+
+// Mock receipt repository for testing; it reads messages and read positions from the chat mock
+type mockReceiptRepo struct {
+	chatRepo *mockChatRepo
+	receipts map[[2]int]*domain.MessageReceipt // by [message, user]
+}
+
+func newMockReceiptRepo(chatRepo *mockChatRepo) *mockReceiptRepo {
+	return &mockReceiptRepo{chatRepo: chatRepo, receipts: make(map[[2]int]*domain.MessageReceipt)}
+}
+
+func (m *mockReceiptRepo) MarkDelivered(ctx context.Context, messageID, userID int, at time.Time) (bool, error) {
+	if _, exists := m.receipts[[2]int{messageID, userID}]; exists {
+		return false, nil
+	}
+	m.receipts[[2]int{messageID, userID}] = &domain.MessageReceipt{MessageID: messageID, UserID: userID, DeliveredAt: at}
+	return true, nil
+}
+
+func (m *mockReceiptRepo) MarkRead(ctx context.Context, conversationID, userID, upToMessageID int, at time.Time) (map[int]int, error) {
+	readPosition := 0
+	if member, err := m.chatRepo.GetMember(ctx, conversationID, userID); err == nil {
+		readPosition = member.LastReadMessageID
+	}
+
+	latestBySender := make(map[int]int)
+	for _, message := range m.chatRepo.messages {
+		if message.ConversationID != conversationID || message.SenderID == userID ||
+			message.ID > upToMessageID || message.ID <= readPosition {
+			continue
+		}
+		receipt, exists := m.receipts[[2]int{message.ID, userID}]
+		if !exists {
+			receipt = &domain.MessageReceipt{MessageID: message.ID, UserID: userID, DeliveredAt: at}
+			m.receipts[[2]int{message.ID, userID}] = receipt
+		}
+		if receipt.ReadAt != nil {
+			continue
+		}
+		readAt := at
+		receipt.ReadAt = &readAt
+		latestBySender[message.SenderID] = max(latestBySender[message.SenderID], message.ID)
+	}
+	return latestBySender, nil
+}
+
+func (m *mockReceiptRepo) ListByMessages(ctx context.Context, messageIDs []int) ([]domain.MessageReceipt, error) {
+	receipts := []domain.MessageReceipt{}
+	for _, messageID := range messageIDs {
+		for key, receipt := range m.receipts {
+			if key[0] == messageID {
+				receipts = append(receipts, *receipt)
+			}
+		}
+	}
+	return receipts, nil
+}
+
+func TestMessageReceipts(t *testing.T) {
+	chatUsecase := newTestChatUsecase(t)
+	groups := usecase.NewGroupUsecase(chatUsecase.ChatRepo, chatUsecase.UserRepo, chatUsecase.Blocks)
+	receipts := chatUsecase.ReceiptRepo.(*mockReceiptRepo)
+	ctx := context.Background()
+
+	group, err := groups.CreateGroup(ctx, 1, &domain.CreateGroupRequest{Name: "Receipts", MemberIDs: []int{2, 3}})
+	if err != nil {
+		t.Fatalf("CreateGroup() error = %v", err)
+	}
+	first, err := chatUsecase.SendToConversation(ctx, 1, group.ID, "First")
+	if err != nil {
+		t.Fatalf("SendToConversation() error = %v", err)
+	}
+	second, err := chatUsecase.SendToConversation(ctx, 1, group.ID, "Second")
+	if err != nil {
+		t.Fatalf("SendToConversation() error = %v", err)
+	}
+
+	// The sender's own devices never produce receipts, other devices only the first one
+	if err := chatUsecase.MarkDelivered(ctx, 1, first); err != nil {
+		t.Fatalf("MarkDelivered() error = %v", err)
+	}
+	if len(receipts.receipts) != 0 {
+		t.Fatalf("Expected no receipt for the sender, got %d", len(receipts.receipts))
+	}
+	for i := 0; i < 2; i++ {
+		if err := chatUsecase.MarkDelivered(ctx, 2, first); err != nil {
+			t.Fatalf("MarkDelivered() error = %v", err)
+		}
+	}
+	delivered := receipts.receipts[[2]int{first.ID, 2}]
+	if delivered == nil || delivered.ReadAt != nil {
+		t.Fatalf("Expected message %d delivered to user 2 and unread, got %+v", first.ID, delivered)
+	}
+	deliveredAt := delivered.DeliveredAt
+
+	// Reading up to the first message leaves the second one unread
+	if err := chatUsecase.MarkConversationRead(ctx, 2, group.ID, first.ID); err != nil {
+		t.Fatalf("MarkConversationRead() error = %v", err)
+	}
+	if read := receipts.receipts[[2]int{first.ID, 2}]; read.ReadAt == nil || !read.DeliveredAt.Equal(deliveredAt) {
+		t.Errorf("Expected message %d read with its delivery time kept, got %+v", first.ID, read)
+	}
+	if _, exists := receipts.receipts[[2]int{second.ID, 2}]; exists {
+		t.Errorf("Expected message %d to have no receipt yet", second.ID)
+	}
+
+	// Reading also counts as delivery
+	if err := chatUsecase.MarkConversationRead(ctx, 3, group.ID, second.ID); err != nil {
+		t.Fatalf("MarkConversationRead() error = %v", err)
+	}
+	if read := receipts.receipts[[2]int{second.ID, 3}]; read == nil || read.ReadAt == nil || read.DeliveredAt.IsZero() {
+		t.Errorf("Expected message %d delivered and read by user 3, got %+v", second.ID, read)
+	}
+
+	// The sender sees the receipts of the other members on their own messages only
+	history, err := chatUsecase.GetConversationHistory(ctx, 1, group.ID, 10, 0)
+	if err != nil {
+		t.Fatalf("GetConversationHistory() error = %v", err)
+	}
+	wantReceipts := map[int]int{first.ID: 2, second.ID: 1}
+	for _, message := range history {
+		if len(message.Receipts) != wantReceipts[message.ID] {
+			t.Errorf("Message %d has %d receipts, want %d", message.ID, len(message.Receipts), wantReceipts[message.ID])
+		}
+	}
+	history, err = chatUsecase.GetConversationHistory(ctx, 2, group.ID, 10, 1)
+	if err != nil {
+		t.Fatalf("GetConversationHistory() error = %v", err)
+	}
+	for _, message := range history {
+		if len(message.Receipts) != 0 {
+			t.Errorf("Expected no receipts on messages of others, got %+v", message.Receipts)
+		}
+	}
+
+	if err := chatUsecase.MarkConversationRead(ctx, 4, group.ID, second.ID); !errors.Is(err, usecase.ErrConversationNotFound) {
+		t.Errorf("Expected ErrConversationNotFound for a non-member, got %v", err)
+	}
+	if err := chatUsecase.MarkConversationRead(ctx, 2, group.ID, 0); !errors.Is(err, usecase.ErrInvalidReadPosition) {
+		t.Errorf("Expected ErrInvalidReadPosition, got %v", err)
+	}
+}
+
+func TestReadPositionPastLatestMessage(t *testing.T) {
+	chatUsecase := newTestChatUsecase(t)
+	ctx := context.Background()
+
+	sent, err := chatUsecase.SendMessage(ctx, 1, 2, "First")
+	if err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+
+	// Reading ahead of the conversation must not swallow the messages still to come
+	if err := chatUsecase.MarkConversationRead(ctx, 2, sent.ConversationID, sent.ID+100); !errors.Is(err, usecase.ErrInvalidReadPosition) {
+		t.Fatalf("Expected ErrInvalidReadPosition, got %v", err)
+	}
+
+	next, err := chatUsecase.SendMessage(ctx, 1, 2, "Second")
+	if err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+	member, err := chatUsecase.ChatRepo.GetMember(ctx, sent.ConversationID, 2)
+	if err != nil {
+		t.Fatalf("GetMember() error = %v", err)
+	}
+	if member.LastReadMessageID >= next.ID {
+		t.Errorf("Expected message %d to stay unread, read position is %d", next.ID, member.LastReadMessageID)
+	}
+}
+
+func TestReplyReadsEarlierMessages(t *testing.T) {
+	chatUsecase := newTestChatUsecase(t)
+	receipts := chatUsecase.ReceiptRepo.(*mockReceiptRepo)
+	ctx := context.Background()
+
+	incoming, err := chatUsecase.SendMessage(ctx, 2, 1, "Are you there?")
+	if err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+	if _, err := chatUsecase.SendMessage(ctx, 1, 2, "Yes"); err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+
+	if read := receipts.receipts[[2]int{incoming.ID, 1}]; read == nil || read.ReadAt == nil {
+		t.Errorf("Expected replying to read message %d, got %+v", incoming.ID, read)
+	}
+}
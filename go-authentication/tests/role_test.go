@@ -0,0 +1,191 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"go-authentication/internal/delivery"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/repository"
+	"go-authentication/internal/usecase"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Mock role repository for testing, seeded like the migrations
+type mockRoleRepo struct {
+	rolePermissions map[string][]string
+	userRoles       map[int]map[string]bool
+}
+
+func newMockRoleRepo() *mockRoleRepo {
+	return &mockRoleRepo{
+		rolePermissions: map[string][]string{
+			domain.RoleUser:      {},
+			domain.RoleModerator: {domain.PermissionMessagesModerate},
+			domain.RoleAdmin:     {domain.PermissionAuditRead, domain.PermissionMessagesModerate, domain.PermissionOAuthClientsManage, domain.PermissionRolesAssign, domain.PermissionUsersUnlock},
+		},
+		userRoles: make(map[int]map[string]bool),
+	}
+}
+
+func (m *mockRoleRepo) GetUserRoles(ctx context.Context, userID int) ([]string, error) {
+	roles := []string{}
+	for role := range m.userRoles[userID] {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	return roles, nil
+}
+
+func (m *mockRoleRepo) GetUserPermissions(ctx context.Context, userID int) ([]string, error) {
+	seen := make(map[string]bool)
+	permissions := []string{}
+	for role := range m.userRoles[userID] {
+		for _, permission := range m.rolePermissions[role] {
+			if !seen[permission] {
+				seen[permission] = true
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+	sort.Strings(permissions)
+	return permissions, nil
+}
+
+func (m *mockRoleRepo) AssignRole(ctx context.Context, userID int, role string) error {
+	if _, exists := m.rolePermissions[role]; !exists {
+		return repository.ErrRoleNotFound
+	}
+	if m.userRoles[userID] == nil {
+		m.userRoles[userID] = make(map[string]bool)
+	}
+	m.userRoles[userID][role] = true
+	return nil
+}
+
+func (m *mockRoleRepo) RemoveRole(ctx context.Context, userID int, role string) error {
+	if _, exists := m.rolePermissions[role]; !exists {
+		return repository.ErrRoleNotFound
+	}
+	delete(m.userRoles[userID], role)
+	return nil
+}
+
+// signupVerified signs up a user through the usecase and marks their email as verified
+func signupVerified(t *testing.T, authUsecase *usecase.AuthUsecase, repo *mockAuthUserRepo, name, email, password string) *domain.User {
+	t.Helper()
+	user := &domain.User{Name: name, Email: email, Password: password}
+	if err := authUsecase.Signup(context.Background(), user); err != nil {
+		t.Fatalf("Signup() error = %v", err)
+	}
+	if err := repo.MarkEmailVerified(context.Background(), user.ID, time.Now()); err != nil {
+		t.Fatalf("MarkEmailVerified() error = %v", err)
+	}
+	return user
+}
+
+func TestRolesInTokenClaims(t *testing.T) {
+	repo := &mockAuthUserRepo{users: make(map[int]*domain.User)}
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), &recordingMailer{})
+	ctx := context.Background()
+
+	admin := signupVerified(t, authUsecase, repo, "Admin", "admin@example.com", "correct horse battery")
+	member := signupVerified(t, authUsecase, repo, "Member", "member@example.com", "correct horse battery")
+
+	adminTokens, err := authUsecase.Login(ctx, admin.Email, "correct horse battery", domain.ClientInfo{})
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	claims, err := authUsecase.ValidateAccessToken(ctx, adminTokens.AccessToken)
+	if err != nil {
+		t.Fatalf("ValidateAccessToken() error = %v", err)
+	}
+	if roles := claims["roles"].([]interface{}); len(roles) != 2 || roles[0] != domain.RoleAdmin || roles[1] != domain.RoleUser {
+		t.Errorf("bootstrap admin roles claim = %v, want [admin user]", roles)
+	}
+
+	if _, err := authUsecase.Roles.AssignRole(ctx, member.ID, "superuser"); !errors.Is(err, usecase.ErrUnknownRole) {
+		t.Errorf("AssignRole() unknown role error = %v, want %v", err, usecase.ErrUnknownRole)
+	}
+	if _, err := authUsecase.Roles.RemoveRole(ctx, member.ID, "superuser"); !errors.Is(err, usecase.ErrUnknownRole) {
+		t.Errorf("RemoveRole() unknown role error = %v, want %v", err, usecase.ErrUnknownRole)
+	}
+	granted, err := authUsecase.Roles.AssignRole(ctx, member.ID, domain.RoleModerator)
+	if err != nil {
+		t.Fatalf("AssignRole() error = %v", err)
+	}
+	if len(granted.Permissions) != 1 || granted.Permissions[0] != domain.PermissionMessagesModerate {
+		t.Errorf("AssignRole() permissions = %v, want [%s]", granted.Permissions, domain.PermissionMessagesModerate)
+	}
+
+	memberTokens, err := authUsecase.Login(ctx, member.Email, "correct horse battery", domain.ClientInfo{})
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+
+	// Removing a role revokes access tokens that still carry it; a refresh picks up the new roles
+	if _, err := authUsecase.Roles.RemoveRole(ctx, member.ID, domain.RoleModerator); err != nil {
+		t.Fatalf("RemoveRole() error = %v", err)
+	}
+	if _, err := authUsecase.ValidateAccessToken(ctx, memberTokens.AccessToken); !errors.Is(err, usecase.ErrTokenRevoked) {
+		t.Errorf("ValidateAccessToken() after role removal error = %v, want %v", err, usecase.ErrTokenRevoked)
+	}
+
+	refreshed, err := authUsecase.RefreshToken(ctx, memberTokens.RefreshToken)
+	if err != nil {
+		t.Fatalf("RefreshToken() after role removal error = %v", err)
+	}
+	refreshedClaims, err := authUsecase.Tokens.ValidateJWT(refreshed.AccessToken)
+	if err != nil {
+		t.Fatalf("ValidateJWT() error = %v", err)
+	}
+	if permissions := refreshedClaims["permissions"].([]interface{}); len(permissions) != 0 {
+		t.Errorf("permissions claim after role removal = %v, want none", permissions)
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := &mockAuthUserRepo{users: make(map[int]*domain.User)}
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), &recordingMailer{})
+
+	admin := signupVerified(t, authUsecase, repo, "Admin", "admin@example.com", "correct horse battery")
+	member := signupVerified(t, authUsecase, repo, "Member", "member@example.com", "correct horse battery")
+
+	router := gin.New()
+	router.GET("/moderation", delivery.AuthMiddleware(authUsecase), delivery.RequirePermission(domain.PermissionMessagesModerate), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name string
+		user *domain.User
+		want int
+	}{
+		{"Admin holds the permission", admin, http.StatusNoContent},
+		{"Regular user is forbidden", member, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := authUsecase.Login(context.Background(), tt.user.Email, "correct horse battery", domain.ClientInfo{})
+			if err != nil {
+				t.Fatalf("Login() error = %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/moderation", nil)
+			req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("GET /moderation status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/repository"
+	"go-authentication/internal/usecase"
+	"go-authentication/pkg"
+	"testing"
+	"time"
+)
+
+// Mock session repository for testing
+type mockSessionRepo struct {
+	sessions map[string]*domain.Session
+}
+
+func newMockSessionRepo() *mockSessionRepo {
+	return &mockSessionRepo{sessions: make(map[string]*domain.Session)}
+}
+
+func (m *mockSessionRepo) Create(ctx context.Context, session *domain.Session) error {
+	session.CreatedAt = time.Now()
+	session.LastSeenAt = session.CreatedAt
+	stored := *session
+	m.sessions[session.ID] = &stored
+	return nil
+}
+
+func (m *mockSessionRepo) GetByID(ctx context.Context, id string) (*domain.Session, error) {
+	session, exists := m.sessions[id]
+	if !exists {
+		return nil, repository.ErrSessionNotFound
+	}
+	found := *session
+	return &found, nil
+}
+
+func (m *mockSessionRepo) ListActiveByUser(ctx context.Context, userID int, now time.Time) ([]domain.Session, error) {
+	sessions := []domain.Session{}
+	for _, session := range m.sessions {
+		if session.UserID == userID && session.IsActive(now) {
+			sessions = append(sessions, *session)
+		}
+	}
+	return sessions, nil
+}
+
+func (m *mockSessionRepo) Touch(ctx context.Context, id string, lastSeenAt, expiresAt time.Time) error {
+	if session, exists := m.sessions[id]; exists && session.RevokedAt == nil {
+		session.LastSeenAt = lastSeenAt
+		session.ExpiresAt = expiresAt
+	}
+	return nil
+}
+
+func (m *mockSessionRepo) Revoke(ctx context.Context, id string) error {
+	if session, exists := m.sessions[id]; exists && session.RevokedAt == nil {
+		now := time.Now()
+		session.RevokedAt = &now
+	}
+	return nil
+}
+
+func (m *mockSessionRepo) RevokeAllForUser(ctx context.Context, userID int) error {
+	for id, session := range m.sessions {
+		if session.UserID == userID {
+			m.Revoke(ctx, id)
+		}
+	}
+	return nil
+}
+
+// recordSessionEvents collects the session revocations published by authUsecase
+func recordSessionEvents(t *testing.T, authUsecase *usecase.AuthUsecase) *[]domain.SessionRevokedEvent {
+	t.Helper()
+	events := &[]domain.SessionRevokedEvent{}
+	err := authUsecase.Sessions.SubscribeToRevocations(func(event *domain.SessionRevokedEvent) {
+		*events = append(*events, *event)
+	})
+	if err != nil {
+		t.Fatalf("SubscribeToRevocations() error = %v", err)
+	}
+	return events
+}
+
+func TestSessions(t *testing.T) {
+	repo := &mockAuthUserRepo{users: make(map[int]*domain.User)}
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), &recordingMailer{})
+	events := recordSessionEvents(t, authUsecase)
+	ctx := context.Background()
+
+	user := signupVerified(t, authUsecase, repo, "Test User", "test@example.com", "correct horse battery")
+	other := signupVerified(t, authUsecase, repo, "Other User", "other@example.com", "correct horse battery")
+
+	laptop, err := authUsecase.Login(ctx, user.Email, "correct horse battery", domain.ClientInfo{IP: "192.0.2.1", UserAgent: "Firefox"})
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	phone, err := authUsecase.Login(ctx, user.Email, "correct horse battery", domain.ClientInfo{IP: "198.51.100.7", UserAgent: "Mobile Safari"})
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+
+	laptopClaims, err := authUsecase.ValidateAccessToken(ctx, laptop.AccessToken)
+	if err != nil {
+		t.Fatalf("ValidateAccessToken() error = %v", err)
+	}
+	phoneClaims, err := authUsecase.ValidateAccessToken(ctx, phone.AccessToken)
+	if err != nil {
+		t.Fatalf("ValidateAccessToken() error = %v", err)
+	}
+	laptopSession, phoneSession := pkg.SessionID(laptopClaims), pkg.SessionID(phoneClaims)
+	if laptopSession == "" || laptopSession == phoneSession {
+		t.Fatalf("sid claims = %q and %q, want two distinct sessions", laptopSession, phoneSession)
+	}
+
+	sessions, err := authUsecase.Sessions.List(ctx, user.ID, laptopSession)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("List() returned %d sessions, want 2", len(sessions))
+	}
+	for _, session := range sessions {
+		if session.ID == laptopSession && (!session.Current || session.IP != "192.0.2.1" || session.UserAgent != "Firefox") {
+			t.Errorf("laptop session = %+v, want current session from 192.0.2.1 using Firefox", session)
+		}
+		if session.ID == phoneSession && session.Current {
+			t.Error("phone session is marked as current")
+		}
+	}
+
+	// A refresh keeps the session and carries it into the new access token
+	refreshed, err := authUsecase.RefreshToken(ctx, laptop.RefreshToken)
+	if err != nil {
+		t.Fatalf("RefreshToken() error = %v", err)
+	}
+	refreshedClaims, err := authUsecase.Tokens.ValidateJWT(refreshed.AccessToken)
+	if err != nil {
+		t.Fatalf("ValidateJWT() error = %v", err)
+	}
+	if sid := pkg.SessionID(refreshedClaims); sid != laptopSession {
+		t.Errorf("sid after refresh = %q, want %q", sid, laptopSession)
+	}
+
+	if err := authUsecase.Sessions.Revoke(ctx, other.ID, phoneSession); !errors.Is(err, usecase.ErrSessionNotFound) {
+		t.Errorf("Revoke() of another user's session error = %v, want %v", err, usecase.ErrSessionNotFound)
+	}
+	if err := authUsecase.Sessions.Revoke(ctx, user.ID, phoneSession); err != nil {
+		t.Fatalf("Revoke() error = %v", err)
+	}
+	if _, err := authUsecase.ValidateAccessToken(ctx, phone.AccessToken); !errors.Is(err, usecase.ErrTokenRevoked) {
+		t.Errorf("ValidateAccessToken() of revoked session error = %v, want %v", err, usecase.ErrTokenRevoked)
+	}
+	if _, err := authUsecase.RefreshToken(ctx, phone.RefreshToken); err == nil {
+		t.Error("RefreshToken() accepted a refresh token of a revoked session")
+	}
+	if _, err := authUsecase.ValidateAccessToken(ctx, refreshed.AccessToken); err != nil {
+		t.Errorf("ValidateAccessToken() of other session error = %v", err)
+	}
+	if len(*events) != 1 || (*events)[0].SessionID != phoneSession || (*events)[0].UserID != user.ID {
+		t.Errorf("published events = %+v, want revocation of session %s", *events, phoneSession)
+	}
+
+	if sessions, _ := authUsecase.Sessions.List(ctx, user.ID, laptopSession); len(sessions) != 1 {
+		t.Errorf("List() after revoke returned %d sessions, want 1", len(sessions))
+	}
+}
+
+func TestLogoutEverywhereEndsSessions(t *testing.T) {
+	repo := &mockAuthUserRepo{users: make(map[int]*domain.User)}
+	authUsecase := newTestAuthUsecase(t, repo, newMockRefreshTokenRepo(), &recordingMailer{})
+	events := recordSessionEvents(t, authUsecase)
+	ctx := context.Background()
+
+	user := signupVerified(t, authUsecase, repo, "Test User", "test@example.com", "correct horse battery")
+	if _, err := authUsecase.Login(ctx, user.Email, "correct horse battery", domain.ClientInfo{}); err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+
+	if err := authUsecase.LogoutEverywhere(ctx, user.ID); err != nil {
+		t.Fatalf("LogoutEverywhere() error = %v", err)
+	}
+	if sessions, _ := authUsecase.Sessions.List(ctx, user.ID, ""); len(sessions) != 0 {
+		t.Errorf("List() after logout everywhere returned %d sessions, want none", len(sessions))
+	}
+	want := domain.SessionRevokedEvent{UserID: user.ID}
+	if len(*events) != 1 || (*events)[0] != want {
+		t.Errorf("published events = %+v, want %+v", *events, want)
+	}
+}
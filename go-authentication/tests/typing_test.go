@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"go-authentication/internal/domain"
+	"go-authentication/internal/usecase"
+	"testing"
+	"time"
+)
+
+func newTestTypingUsecase(t *testing.T) (*usecase.ChatUsecase, *usecase.TypingUsecase) {
+	t.Helper()
+	chatUsecase := newTestChatUsecase(t)
+	return chatUsecase, usecase.NewTypingUsecase(chatUsecase.ChatRepo, chatUsecase.Blocks, chatUsecase.NatsService)
+}
+
+func TestTypingThrottleAndStop(t *testing.T) {
+	chatUsecase, typing := newTestTypingUsecase(t)
+	typing.Throttle = 50 * time.Millisecond
+	typing.TTL = time.Second
+	ctx := context.Background()
+
+	message, err := chatUsecase.SendMessage(ctx, 1, 2, "Hello")
+	if err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+
+	relayed, err := typing.StartTyping(ctx, 2, message.ConversationID)
+	if err != nil || !relayed {
+		t.Fatalf("Expected the first typing_start to be relayed, got relayed=%v err=%v", relayed, err)
+	}
+	if relayed, _ := typing.StartTyping(ctx, 2, message.ConversationID); relayed {
+		t.Error("Expected a typing_start within the throttle interval to only renew the indicator")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if relayed, _ := typing.StartTyping(ctx, 2, message.ConversationID); !relayed {
+		t.Error("Expected a typing_start after the throttle interval to be relayed")
+	}
+
+	if !typing.StopTyping(2, message.ConversationID) {
+		t.Error("Expected StopTyping to clear the indicator")
+	}
+	if typing.StopTyping(2, message.ConversationID) {
+		t.Error("Expected a second StopTyping to have nothing to clear")
+	}
+	if relayed, _ := typing.StartTyping(ctx, 2, message.ConversationID); !relayed {
+		t.Error("Expected a typing_start after typing_stop to be relayed")
+	}
+}
+
+func TestTypingExpires(t *testing.T) {
+	chatUsecase, typing := newTestTypingUsecase(t)
+	typing.Throttle = time.Second
+	typing.TTL = 30 * time.Millisecond
+	ctx := context.Background()
+
+	message, err := chatUsecase.SendMessage(ctx, 1, 2, "Hello")
+	if err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+	if _, err := typing.StartTyping(ctx, 1, message.ConversationID); err != nil {
+		t.Fatalf("StartTyping() error = %v", err)
+	}
+
+	// Renewals push the expiry back
+	for i := 0; i < 3; i++ {
+		time.Sleep(15 * time.Millisecond)
+		if relayed, _ := typing.StartTyping(ctx, 1, message.ConversationID); relayed {
+			t.Fatal("Expected renewals within the throttle interval not to be relayed")
+		}
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if typing.StopTyping(1, message.ConversationID) {
+		t.Error("Expected the indicator to have expired")
+	}
+}
+
+func TestTypingRecipients(t *testing.T) {
+	chatUsecase, typing := newTestTypingUsecase(t)
+	groups := usecase.NewGroupUsecase(chatUsecase.ChatRepo, chatUsecase.UserRepo, chatUsecase.Blocks)
+	ctx := context.Background()
+
+	message, err := chatUsecase.SendMessage(ctx, 1, 2, "Hello")
+	if err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+	if _, err := typing.StartTyping(ctx, 3, message.ConversationID); !errors.Is(err, usecase.ErrConversationNotFound) {
+		t.Errorf("Expected ErrConversationNotFound for a non-member, got %v", err)
+	}
+	if _, err := typing.StartTyping(ctx, 1, 999); !errors.Is(err, usecase.ErrConversationNotFound) {
+		t.Errorf("Expected ErrConversationNotFound for an unknown conversation, got %v", err)
+	}
+
+	group, err := groups.CreateGroup(ctx, 1, &domain.CreateGroupRequest{Name: "Typing", MemberIDs: []int{2, 3}})
+	if err != nil {
+		t.Fatalf("CreateGroup() error = %v", err)
+	}
+	if err := chatUsecase.Blocks.BlockUser(ctx, 2, 1); err != nil {
+		t.Fatalf("BlockUser() error = %v", err)
+	}
+
+	// A block refuses the indicator in a direct conversation but only hides it from the blocker in a group
+	if _, err := typing.StartTyping(ctx, 1, message.ConversationID); !errors.Is(err, usecase.ErrMessagingBlocked) {
+		t.Errorf("Expected ErrMessagingBlocked in the direct conversation, got %v", err)
+	}
+	if relayed, err := typing.StartTyping(ctx, 1, group.ID); err != nil || !relayed {
+		t.Errorf("Expected typing in the group to be relayed, got relayed=%v err=%v", relayed, err)
+	}
+}